@@ -17,6 +17,7 @@ import (
 
 	"github.com/joho/godotenv"
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	jwtaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/jwt_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
@@ -441,6 +442,7 @@ func main() {
 
 	// Register built-in access providers before constructing services.
 	configaccess.Register()
+	jwtaccess.Register()
 
 	// Handle different command modes based on the provided flags.
 
@@ -6,10 +6,17 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtualkeys"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 )
 
+// Result metadata keys set for virtual keys.
+const (
+	MetadataVirtualKeyID    = "virtual-key-id"
+	MetadataVirtualKeyOwner = "virtual-key-owner"
+)
+
 var registerOnce sync.Once
 
 // Register ensures the config-access provider is available to the access manager.
@@ -20,11 +27,12 @@ func Register() {
 }
 
 type provider struct {
-	name string
-	keys map[string]struct{}
+	name     string
+	keys     map[string]struct{}
+	policies map[string]sdkaccess.Policy
 }
 
-func newProvider(cfg *sdkconfig.AccessProvider, _ *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+func newProvider(cfg *sdkconfig.AccessProvider, root *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
 	name := cfg.Name
 	if name == "" {
 		name = sdkconfig.DefaultAccessProviderName
@@ -36,7 +44,29 @@ func newProvider(cfg *sdkconfig.AccessProvider, _ *sdkconfig.SDKConfig) (sdkacce
 		}
 		keys[key] = struct{}{}
 	}
-	return &provider{name: name, keys: keys}, nil
+	return &provider{name: name, keys: keys, policies: clientKeyPolicies(root)}, nil
+}
+
+// clientKeyPolicies indexes the access policies of the structured client key entries.
+func clientKeyPolicies(root *sdkconfig.SDKConfig) map[string]sdkaccess.Policy {
+	if root == nil || len(root.APIKeyEntries) == 0 {
+		return nil
+	}
+	policies := make(map[string]sdkaccess.Policy, len(root.APIKeyEntries))
+	for _, entry := range root.APIKeyEntries {
+		key := strings.TrimSpace(entry.APIKey)
+		if key == "" {
+			continue
+		}
+		policies[key] = sdkaccess.Policy{
+			Name:          entry.Name,
+			Models:        entry.Models,
+			Providers:     entry.Providers,
+			Prefixes:      entry.Prefixes,
+			DefaultPrefix: entry.DefaultPrefix,
+		}
+	}
+	return policies
 }
 
 func (p *provider) Identifier() string {
@@ -50,7 +80,7 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 	if p == nil {
 		return nil, sdkaccess.ErrNotHandled
 	}
-	if len(p.keys) == 0 {
+	if len(p.keys) == 0 && virtualkeys.Default().Len() == 0 {
 		return nil, sdkaccess.ErrNotHandled
 	}
 	authHeader := r.Header.Get("Authorization")
@@ -84,19 +114,50 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 			continue
 		}
 		if _, ok := p.keys[candidate.value]; ok {
+			metadata := map[string]string{
+				"source": candidate.source,
+			}
+			if policy, hasPolicy := p.policies[candidate.value]; hasPolicy {
+				for key, value := range policy.Metadata() {
+					metadata[key] = value
+				}
+			}
 			return &sdkaccess.Result{
 				Provider:  p.Identifier(),
 				Principal: candidate.value,
-				Metadata: map[string]string{
-					"source": candidate.source,
-				},
+				Metadata:  metadata,
 			}, nil
 		}
 	}
 
+	for _, candidate := range candidates {
+		if candidate.value == "" {
+			continue
+		}
+		if key, ok := virtualkeys.Default().Authenticate(candidate.value); ok {
+			return VirtualKeyResult(p.Identifier(), key, candidate.source), nil
+		}
+	}
+
 	return nil, sdkaccess.ErrInvalidCredential
 }
 
+// VirtualKeyResult returns the authentication result of a virtual key presented through
+// source to the provider named provider.
+func VirtualKeyResult(provider string, key virtualkeys.Key, source string) *sdkaccess.Result {
+	metadata := key.Policy().Metadata()
+	metadata["source"] = source
+	metadata[MetadataVirtualKeyID] = key.ID
+	if key.Owner != "" {
+		metadata[MetadataVirtualKeyOwner] = key.Owner
+	}
+	return &sdkaccess.Result{
+		Provider:  provider,
+		Principal: key.Principal(),
+		Metadata:  metadata,
+	}
+}
+
 func extractBearerToken(header string) string {
 	if header == "" {
 		return ""
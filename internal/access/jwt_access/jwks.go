@@ -0,0 +1,376 @@
+package jwtaccess
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minForcedRefresh bounds how often an unknown key ID may trigger a JWKS refresh.
+	minForcedRefresh = 30 * time.Second
+	// minFailureBackoff and maxFailureBackoff bound the wait after a failed load; the wait
+	// doubles with every consecutive failure.
+	minFailureBackoff = time.Second
+	maxFailureBackoff = time.Minute
+)
+
+// jwk is one entry of a JSON Web Key Set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type publicKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// keySet caches the signing keys of a JWKS document loaded from a URL or a file. Loads run
+// outside the lock and are shared by concurrent lookups; while the source fails, the cached
+// keys keep being served and further loads are throttled.
+type keySet struct {
+	url     string
+	file    string
+	refresh time.Duration
+	client  *http.Client
+
+	mu          sync.Mutex
+	keys        []publicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	// failures counts consecutive failed loads; retryAt is the earliest time the next may run.
+	failures int
+	retryAt  time.Time
+	lastErr  error
+	// loading is closed when the load in flight completes; nil when none is running.
+	loading chan struct{}
+}
+
+func newKeySet(url, file string, refresh time.Duration) *keySet {
+	return &keySet{url: url, file: file, refresh: refresh, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// lookup returns the keys that may have signed a token with the given key ID and algorithm,
+// loading or refreshing the key set when needed.
+func (s *keySet) lookup(ctx context.Context, kid, alg string) ([]publicKey, error) {
+	if !supportedAlgorithm(alg) {
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	now := time.Now()
+	s.mu.Lock()
+	due := (s.fetchedAt.IsZero() || now.Sub(s.fetchedAt) >= s.refresh) && !now.Before(s.retryAt)
+	s.mu.Unlock()
+	if due {
+		_ = s.load(ctx)
+	}
+
+	s.mu.Lock()
+	if len(s.keys) == 0 && s.lastErr != nil {
+		err := s.lastErr
+		s.mu.Unlock()
+		return nil, err
+	}
+	matches := s.matchLocked(kid, alg)
+	forced := len(matches) == 0 && kid != "" && now.Sub(s.attemptedAt) >= minForcedRefresh && !now.Before(s.retryAt)
+	s.mu.Unlock()
+	if forced {
+		if err := s.load(ctx); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		matches = s.matchLocked(kid, alg)
+		s.mu.Unlock()
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no signing key for kid %q and alg %s", kid, alg)
+	}
+	return matches, nil
+}
+
+func (s *keySet) matchLocked(kid, alg string) []publicKey {
+	var out []publicKey
+	for _, key := range s.keys {
+		if kid != "" && key.kid != kid {
+			continue
+		}
+		if key.alg != "" && key.alg != alg {
+			continue
+		}
+		if !keyMatchesAlgorithm(key.key, alg) {
+			continue
+		}
+		out = append(out, key)
+	}
+	return out
+}
+
+// load reads the JWKS document and replaces the cached keys. Concurrent callers wait for the
+// load already in flight instead of starting another one.
+func (s *keySet) load(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.mu.Lock()
+	if wait := s.loading; wait != nil {
+		s.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.lastErr
+	}
+	done := make(chan struct{})
+	s.loading = done
+	s.attemptedAt = time.Now()
+	s.mu.Unlock()
+
+	// The load is shared, so it must not be cut short when this caller goes away.
+	keys, err := s.read(context.WithoutCancel(ctx))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if err != nil {
+		s.failures++
+		s.retryAt = now.Add(failureBackoff(s.failures))
+		s.lastErr = err
+	} else {
+		s.keys = keys
+		s.fetchedAt = now
+		s.failures = 0
+		s.retryAt = time.Time{}
+		s.lastErr = nil
+	}
+	s.loading = nil
+	close(done)
+	return err
+}
+
+func (s *keySet) read(ctx context.Context) ([]publicKey, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if s.file != "" {
+		data, err = os.ReadFile(s.file)
+	} else {
+		data, err = s.fetch(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load jwks: %w", err)
+	}
+	return parseJWKS(data)
+}
+
+// failureBackoff returns the wait after the given number of consecutive failed loads.
+func failureBackoff(failures int) time.Duration {
+	backoff := minFailureBackoff
+	for i := 1; i < failures && backoff < maxFailureBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxFailureBackoff)
+}
+
+func (s *keySet) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, s.url)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func parseJWKS(data []byte) ([]publicKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+	keys := make([]publicKey, 0, len(doc.Keys))
+	for _, entry := range doc.Keys {
+		if entry.Use != "" && entry.Use != "sig" {
+			continue
+		}
+		key, err := entry.publicKey()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, publicKey{kid: entry.Kid, alg: entry.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > int64(^uint32(0)>>1) {
+			return nil, errors.New("rsa exponent out of range")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil || len(raw) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
+
+func supportedAlgorithm(alg string) bool {
+	switch alg {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA":
+		return true
+	default:
+		return false
+	}
+}
+
+func keyMatchesAlgorithm(key crypto.PublicKey, alg string) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		switch alg {
+		case "ES256":
+			return k.Curve == elliptic.P256()
+		case "ES384":
+			return k.Curve == elliptic.P384()
+		case "ES512":
+			return k.Curve == elliptic.P521()
+		}
+		return false
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	default:
+		return false
+	}
+}
+
+func algorithmHash(alg string) crypto.Hash {
+	switch alg[len(alg)-3:] {
+	case "384":
+		return crypto.SHA384
+	case "512":
+		return crypto.SHA512
+	default:
+		return crypto.SHA256
+	}
+}
+
+// verifySignature checks a JWS signature over signingInput.
+func verifySignature(alg string, key publicKey, signingInput, signature []byte) error {
+	if alg == "EdDSA" {
+		pub, ok := key.key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(pub, signingInput, signature) {
+			return errors.New("invalid EdDSA signature")
+		}
+		return nil
+	}
+	hash := algorithmHash(alg)
+	hasher := hash.New()
+	hasher.Write(signingInput)
+	digest := hasher.Sum(nil)
+
+	switch pub := key.key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid ECDSA signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	default:
+		return errors.New("unsupported key")
+	}
+}
@@ -0,0 +1,282 @@
+// Package jwtaccess provides the built-in access provider validating JWT bearer tokens issued
+// by an identity provider, such as an OIDC single sign-on service.
+package jwtaccess
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultPrincipalClaim = "sub"
+	defaultGroupsClaim    = "groups"
+	defaultEmailClaim     = "email"
+	defaultLeeway         = 60 * time.Second
+	defaultRefresh        = 5 * time.Minute
+)
+
+// Result metadata keys describing the token's identity claims.
+const (
+	MetadataSubject = "sub"
+	MetadataEmail   = "email"
+	MetadataGroups  = "groups"
+)
+
+var registerOnce sync.Once
+
+// Register ensures the jwt provider is available to the access manager.
+func Register() {
+	registerOnce.Do(func() {
+		sdkaccess.RegisterProvider(sdkconfig.AccessProviderTypeJWT, newProvider)
+	})
+}
+
+type provider struct {
+	name     string
+	settings sdkconfig.JWTAuthConfig
+	leeway   time.Duration
+	keys     *keySet
+	now      func() time.Time
+}
+
+func newProvider(cfg *sdkconfig.AccessProvider, _ *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+	name := strings.TrimSpace(cfg.Name)
+	if name == "" {
+		name = sdkconfig.AccessProviderTypeJWT
+	}
+	var settings sdkconfig.JWTAuthConfig
+	if len(cfg.Config) > 0 {
+		data, err := json.Marshal(cfg.Config)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: encode config: %w", err)
+		}
+		if err = json.Unmarshal(data, &settings); err != nil {
+			return nil, fmt.Errorf("jwt: decode config: %w", err)
+		}
+	}
+	if !settings.Enabled() {
+		return nil, fmt.Errorf("jwt: jwks-url or jwks-file is required")
+	}
+	if settings.PrincipalClaim == "" {
+		settings.PrincipalClaim = defaultPrincipalClaim
+	}
+	if settings.GroupsClaim == "" {
+		settings.GroupsClaim = defaultGroupsClaim
+	}
+	if settings.EmailClaim == "" {
+		settings.EmailClaim = defaultEmailClaim
+	}
+	leeway := defaultLeeway
+	if settings.LeewaySeconds > 0 {
+		leeway = time.Duration(settings.LeewaySeconds) * time.Second
+	}
+	refresh := defaultRefresh
+	if settings.RefreshSeconds > 0 {
+		refresh = time.Duration(settings.RefreshSeconds) * time.Second
+	}
+	keys := newKeySet(strings.TrimSpace(settings.JWKSURL), strings.TrimSpace(settings.JWKSFile), refresh)
+	return &provider{name: name, settings: settings, leeway: leeway, keys: keys, now: time.Now}, nil
+}
+
+func (p *provider) Identifier() string {
+	if p == nil || p.name == "" {
+		return sdkconfig.AccessProviderTypeJWT
+	}
+	return p.name
+}
+
+// Authenticate validates a JWT presented as an Authorization bearer token. Bearer values that
+// are not JWTs are left to the other providers.
+func (p *provider) Authenticate(ctx context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if p == nil {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	if header == "" {
+		return nil, sdkaccess.ErrNoCredentials
+	}
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "bearer") {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	token = strings.TrimSpace(token)
+	if strings.Count(token, ".") != 2 {
+		return nil, sdkaccess.ErrNotHandled
+	}
+
+	claims, err := p.verify(ctx, token)
+	if err != nil {
+		log.Debugf("jwt access: rejected bearer token: %v", err)
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	principal := strings.TrimSpace(claims.Get(gjsonPath(p.settings.PrincipalClaim)).String())
+	if principal == "" {
+		log.Debugf("jwt access: token has no %q claim", p.settings.PrincipalClaim)
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+
+	metadata := map[string]string{"source": "jwt"}
+	if sub := claims.Get("sub").String(); sub != "" {
+		metadata[MetadataSubject] = sub
+	}
+	if email := claims.Get(gjsonPath(p.settings.EmailClaim)).String(); email != "" {
+		metadata[MetadataEmail] = email
+	}
+	if groups := claimStrings(claims.Get(gjsonPath(p.settings.GroupsClaim))); len(groups) > 0 {
+		metadata[MetadataGroups] = strings.Join(groups, ",")
+	}
+	if policy, ok := p.policyFor(claims); ok {
+		for key, value := range policy.Metadata() {
+			metadata[key] = value
+		}
+	}
+	return &sdkaccess.Result{
+		Provider:  p.Identifier(),
+		Principal: principal,
+		Metadata:  metadata,
+	}, nil
+}
+
+// verify checks the token signature and its registered claims and returns the claims.
+func (p *provider) verify(ctx context.Context, token string) (gjson.Result, error) {
+	parts := strings.Split(token, ".")
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return gjson.Result{}, fmt.Errorf("decode header: %w", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return gjson.Result{}, fmt.Errorf("decode payload: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return gjson.Result{}, fmt.Errorf("decode signature: %w", err)
+	}
+	if !gjson.ValidBytes(headerJSON) || !gjson.ValidBytes(payload) {
+		return gjson.Result{}, fmt.Errorf("malformed token")
+	}
+	header := gjson.ParseBytes(headerJSON)
+	alg := header.Get("alg").String()
+	kid := header.Get("kid").String()
+
+	candidates, err := p.keys.lookup(ctx, kid, alg)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range candidates {
+		if err = verifySignature(alg, key, signingInput, signature); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return gjson.Result{}, fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	claims := gjson.ParseBytes(payload)
+	if err = p.validateClaims(claims); err != nil {
+		return gjson.Result{}, err
+	}
+	return claims, nil
+}
+
+func (p *provider) validateClaims(claims gjson.Result) error {
+	now := p.now()
+	exp := claims.Get("exp")
+	if !exp.Exists() {
+		return fmt.Errorf("missing exp claim")
+	}
+	if now.After(time.Unix(exp.Int(), 0).Add(p.leeway)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf := claims.Get("nbf"); nbf.Exists() && now.Add(p.leeway).Before(time.Unix(nbf.Int(), 0)) {
+		return fmt.Errorf("token not yet valid")
+	}
+	if issuer := strings.TrimSpace(p.settings.Issuer); issuer != "" && claims.Get("iss").String() != issuer {
+		return fmt.Errorf("unexpected issuer %q", claims.Get("iss").String())
+	}
+	if len(p.settings.Audience) > 0 {
+		audiences := claimStrings(claims.Get("aud"))
+		matched := false
+		for _, want := range p.settings.Audience {
+			for _, got := range audiences {
+				if got == strings.TrimSpace(want) {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return fmt.Errorf("unexpected audience %v", audiences)
+		}
+	}
+	return nil
+}
+
+// policyFor returns the access policy of the first claim policy matching the token.
+func (p *provider) policyFor(claims gjson.Result) (sdkaccess.Policy, bool) {
+	for _, rule := range p.settings.Policies {
+		values := claimStrings(claims.Get(gjsonPath(rule.Claim)))
+		if !anyValueMatches(rule.Values, values) {
+			continue
+		}
+		return sdkaccess.Policy{
+			Name:          rule.Name,
+			Models:        rule.Models,
+			Providers:     rule.Providers,
+			Prefixes:      rule.Prefixes,
+			DefaultPrefix: rule.DefaultPrefix,
+		}, true
+	}
+	return sdkaccess.Policy{}, false
+}
+
+func anyValueMatches(patterns, values []string) bool {
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if sdkaccess.MatchPattern(strings.TrimSpace(pattern), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimStrings returns a string or string-array claim as a list.
+func claimStrings(value gjson.Result) []string {
+	if !value.Exists() {
+		return nil
+	}
+	if value.IsArray() {
+		var out []string
+		for _, item := range value.Array() {
+			if s := strings.TrimSpace(item.String()); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	if s := strings.TrimSpace(value.String()); s != "" {
+		return []string{s}
+	}
+	return nil
+}
+
+// gjsonPath escapes characters with a special meaning in gjson paths, keeping dots as
+// separators for nested claims.
+func gjsonPath(claim string) string {
+	replacer := strings.NewReplacer("*", `\*`, "?", `\?`, "#", `\#`, "|", `\|`, "@", `\@`)
+	return replacer.Replace(strings.TrimSpace(claim))
+}
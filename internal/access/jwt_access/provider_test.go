@@ -0,0 +1,204 @@
+package jwtaccess
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func b64(data []byte) string { return base64.RawURLEncoding.EncodeToString(data) }
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return input + "." + b64(sig)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + b64(sig)
+}
+
+func newTestProvider(t *testing.T, settings sdkconfig.JWTAuthConfig) (*rsa.PrivateKey, *ecdsa.PrivateKey, sdkaccess.Provider) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ec key: %v", err)
+	}
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+	}})
+	settings.JWKSFile = filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(settings.JWKSFile, jwks, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	cfg := (&sdkconfig.SDKConfig{JWTAuth: settings}).JWTAccessProvider()
+	provider, err := newProvider(cfg, nil)
+	if err != nil {
+		t.Fatalf("newProvider: %v", err)
+	}
+	return rsaKey, ecKey, provider
+}
+
+func bearerRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestProvider_AuthenticatesAndMapsClaims(t *testing.T) {
+	rsaKey, ecKey, provider := newTestProvider(t, sdkconfig.JWTAuthConfig{
+		Issuer:   "https://sso.example.com/",
+		Audience: []string{"cli-proxy"},
+		Policies: []sdkconfig.JWTClaimPolicy{{Claim: "groups", Values: []string{"team-*"}, Name: "teams", Prefixes: []string{"teamA"}}},
+	})
+	claims := map[string]any{
+		"sub":    "user-1",
+		"email":  "user@example.com",
+		"groups": []string{"staff", "team-a"},
+		"iss":    "https://sso.example.com/",
+		"aud":    []string{"other", "cli-proxy"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+
+	result, err := provider.Authenticate(context.Background(), bearerRequest(signRS256(t, rsaKey, "rsa-1", claims)))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if result.Principal != "user-1" || result.Metadata[MetadataEmail] != "user@example.com" || result.Metadata[MetadataGroups] != "staff,team-a" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	policy := sdkaccess.PolicyFromMetadata(result.Metadata)
+	if policy.Name != "teams" || len(policy.Prefixes) != 1 || policy.Prefixes[0] != "teamA" {
+		t.Fatalf("expected the claim policy to apply, got %+v", policy)
+	}
+
+	if _, err = provider.Authenticate(context.Background(), bearerRequest(signES256(t, ecKey, "ec-1", claims))); err != nil {
+		t.Fatalf("ES256 token rejected: %v", err)
+	}
+}
+
+func TestProvider_RejectsInvalidTokens(t *testing.T) {
+	rsaKey, _, provider := newTestProvider(t, sdkconfig.JWTAuthConfig{Issuer: "https://sso.example.com/", Audience: []string{"cli-proxy"}})
+	valid := map[string]any{"sub": "user-1", "iss": "https://sso.example.com/", "aud": "cli-proxy", "exp": time.Now().Add(time.Hour).Unix()}
+	with := func(key string, value any) map[string]any {
+		claims := make(map[string]any, len(valid))
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	cases := map[string]string{
+		"expired":        signRS256(t, rsaKey, "rsa-1", with("exp", time.Now().Add(-time.Hour).Unix())),
+		"wrong issuer":   signRS256(t, rsaKey, "rsa-1", with("iss", "https://evil.example.com/")),
+		"wrong audience": signRS256(t, rsaKey, "rsa-1", with("aud", "someone-else")),
+		"wrong key":      signRS256(t, otherKey, "rsa-1", valid),
+		"unsigned":       b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{"sub":"user-1"}`)) + ".",
+	}
+	for name, token := range cases {
+		if _, err := provider.Authenticate(context.Background(), bearerRequest(token)); !errors.Is(err, sdkaccess.ErrInvalidCredential) {
+			t.Errorf("%s: expected ErrInvalidCredential, got %v", name, err)
+		}
+	}
+
+	if _, err := provider.Authenticate(context.Background(), bearerRequest("sk-static-key")); !errors.Is(err, sdkaccess.ErrNotHandled) {
+		t.Fatalf("expected static keys to be left to other providers, got %v", err)
+	}
+}
+
+func TestKeySet_ThrottlesFailedRefreshes(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ec key: %v", err)
+	}
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+	}})
+	var hits, failing atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		if failing.Load() == 1 {
+			time.Sleep(50 * time.Millisecond)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write(jwks)
+	}))
+	defer server.Close()
+
+	keys := newKeySet(server.URL, "", time.Millisecond)
+	if _, err = keys.lookup(context.Background(), "ec-1", "ES256"); err != nil {
+		t.Fatalf("initial lookup: %v", err)
+	}
+	failing.Store(1)
+	time.Sleep(5 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errLookup := keys.lookup(context.Background(), "ec-1", "ES256")
+			errs <- errLookup
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for errLookup := range errs {
+		if errLookup != nil {
+			t.Fatalf("expected the cached key to be served while the JWKS endpoint fails: %v", errLookup)
+		}
+	}
+	if _, err = keys.lookup(context.Background(), "ec-1", "ES256"); err != nil {
+		t.Fatalf("lookup during backoff: %v", err)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("expected one shared refresh attempt during the outage, got %d requests", got-1)
+	}
+}
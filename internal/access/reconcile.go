@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtualkeys"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkConfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
@@ -81,11 +82,11 @@ func ReconcileProviders(oldCfg, newCfg *config.Config, existing []sdkaccess.Prov
 	}
 
 	if len(result) == 0 {
-		if inline := sdkConfig.MakeInlineAPIKeyProvider(newCfg.APIKeys); inline != nil {
+		if inline := inlineAccessProvider(newCfg); inline != nil {
 			key := providerIdentifier(inline)
 			if key != "" {
 				if oldCfgProvider, ok := oldCfgMap[key]; ok {
-					if providerConfigEqual(oldCfgProvider, inline) {
+					if providerConfigEqual(oldCfgProvider, inline) && clientKeyPoliciesEqual(oldCfg, newCfg) {
 						if existingProvider, okExisting := existingMap[key]; okExisting {
 							result = append(result, existingProvider)
 							finalIDs[key] = struct{}{}
@@ -176,13 +177,16 @@ func accessProviderMap(cfg *config.Config) map[string]*sdkConfig.AccessProvider
 		}
 		result[key] = providerCfg
 	}
-	if len(result) == 0 && len(cfg.APIKeys) > 0 {
-		if provider := sdkConfig.MakeInlineAPIKeyProvider(cfg.APIKeys); provider != nil {
+	if len(result) == 0 {
+		if provider := inlineAccessProvider(cfg); provider != nil {
 			if key := providerIdentifier(provider); key != "" {
 				result[key] = provider
 			}
 		}
 	}
+	if provider := cfg.JWTAccessProvider(); provider != nil {
+		result[providerIdentifier(provider)] = provider
+	}
 	return result
 }
 
@@ -197,14 +201,32 @@ func collectProviderEntries(cfg *config.Config) []*sdkConfig.AccessProvider {
 			entries = append(entries, providerCfg)
 		}
 	}
-	if len(entries) == 0 && len(cfg.APIKeys) > 0 {
-		if inline := sdkConfig.MakeInlineAPIKeyProvider(cfg.APIKeys); inline != nil {
+	if len(entries) == 0 {
+		if inline := inlineAccessProvider(cfg); inline != nil {
 			entries = append(entries, inline)
 		}
 	}
+	if jwt := cfg.JWTAccessProvider(); jwt != nil {
+		entries = append(entries, jwt)
+	}
 	return entries
 }
 
+// inlineAccessProvider returns the inline API key provider configuration when configured client
+// keys or virtual keys exist. Without either, requests are not authenticated.
+func inlineAccessProvider(cfg *config.Config) *sdkConfig.AccessProvider {
+	if provider := sdkConfig.MakeInlineAPIKeyProvider(cfg.ClientAPIKeys()); provider != nil {
+		return provider
+	}
+	if virtualkeys.Default().Len() == 0 {
+		return nil
+	}
+	return &sdkConfig.AccessProvider{
+		Name: sdkConfig.DefaultAccessProviderName,
+		Type: sdkConfig.AccessProviderTypeConfigAPIKey,
+	}
+}
+
 func providerIdentifier(provider *sdkConfig.AccessProvider) string {
 	if provider == nil {
 		return ""
@@ -244,6 +266,22 @@ func providerConfigEqual(a, b *sdkConfig.AccessProvider) bool {
 	return true
 }
 
+// clientKeyPoliciesEqual reports whether the client key policies are unchanged; the inline
+// provider embeds them, so it must be rebuilt when they differ.
+func clientKeyPoliciesEqual(oldCfg, newCfg *config.Config) bool {
+	var oldEntries, newEntries []sdkConfig.ClientAPIKey
+	if oldCfg != nil {
+		oldEntries = oldCfg.APIKeyEntries
+	}
+	if newCfg != nil {
+		newEntries = newCfg.APIKeyEntries
+	}
+	if len(oldEntries) == 0 && len(newEntries) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(oldEntries, newEntries)
+}
+
 func stringSetEqual(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
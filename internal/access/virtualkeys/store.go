@@ -0,0 +1,432 @@
+// Package virtualkeys manages client API keys created at runtime through the management API.
+// Only a SHA-256 hash of each secret is stored; the secret is returned once on creation or
+// rotation.
+package virtualkeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	log "github.com/sirupsen/logrus"
+)
+
+// SecretPrefix starts every generated secret so that virtual keys are recognizable.
+const SecretPrefix = "sk-cpa-"
+
+// PrincipalPrefix starts the principal reported for virtual keys; the principal identifies the
+// key in usage records, rate limits and budgets without exposing the secret.
+const PrincipalPrefix = "vk-"
+
+// lastUsedSaveDelay batches state writes caused by last-used timestamps.
+const lastUsedSaveDelay = time.Minute
+
+// ErrNotFound is returned for unknown key IDs.
+var ErrNotFound = errors.New("virtual key not found")
+
+var defaultStore = NewStore()
+
+// Default returns the process-wide store consulted by the config-access provider.
+func Default() *Store { return defaultStore }
+
+// Scopes restrict what a virtual key may use; empty lists are unrestricted.
+type Scopes struct {
+	Models        []string `json:"models,omitempty"`
+	Providers     []string `json:"providers,omitempty"`
+	Prefixes      []string `json:"prefixes,omitempty"`
+	DefaultPrefix string   `json:"default-prefix,omitempty"`
+}
+
+// Key is a stored virtual key. RateLimit and Budgets override client-rate-limit and
+// client-budgets for the key, like the fields of an api-key-entries item.
+type Key struct {
+	ID         string                  `json:"id"`
+	Label      string                  `json:"label"`
+	Owner      string                  `json:"owner,omitempty"`
+	Hash       string                  `json:"hash"`
+	Hint       string                  `json:"hint"`
+	Scopes     Scopes                  `json:"scopes"`
+	RateLimit  *config.ClientRateLimit `json:"rate-limit,omitempty"`
+	Budgets    []config.ClientBudget   `json:"budgets,omitempty"`
+	CreatedAt  time.Time               `json:"created-at"`
+	ExpiresAt  *time.Time              `json:"expires-at,omitempty"`
+	RotatedAt  *time.Time              `json:"rotated-at,omitempty"`
+	RevokedAt  *time.Time              `json:"revoked-at,omitempty"`
+	LastUsedAt *time.Time              `json:"last-used-at,omitempty"`
+}
+
+// Principal returns the identity reported for requests made with the key.
+func (k Key) Principal() string { return PrincipalPrefix + k.ID }
+
+// Status returns "active", "expired" or "revoked".
+func (k Key) Status(now time.Time) string {
+	switch {
+	case k.RevokedAt != nil:
+		return "revoked"
+	case k.ExpiresAt != nil && !now.Before(*k.ExpiresAt):
+		return "expired"
+	default:
+		return "active"
+	}
+}
+
+// Policy returns the access policy derived from the key's label and scopes.
+func (k Key) Policy() sdkaccess.Policy {
+	return sdkaccess.Policy{
+		Name:          k.Label,
+		Models:        k.Scopes.Models,
+		Providers:     k.Scopes.Providers,
+		Prefixes:      k.Scopes.Prefixes,
+		DefaultPrefix: k.Scopes.DefaultPrefix,
+	}
+}
+
+// Store holds virtual keys and persists them to a JSON file.
+type Store struct {
+	mu        sync.RWMutex
+	keys      map[string]*Key
+	byHash    map[string]string
+	path      string
+	now       func() time.Time
+	onChange  func()
+	saveTimer *time.Timer
+}
+
+// NewStore constructs an empty in-memory store.
+func NewStore() *Store {
+	return &Store{keys: make(map[string]*Key), byHash: make(map[string]string), now: time.Now}
+}
+
+// SetOnChange registers a callback invoked after the stored keys change, for example to
+// rebuild the access providers when the first key is created.
+func (s *Store) SetOnChange(fn func()) {
+	s.mu.Lock()
+	s.onChange = fn
+	s.mu.Unlock()
+}
+
+// Len returns the number of stored keys, including revoked and expired ones.
+func (s *Store) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+// Load reads stored keys from path and persists subsequent changes there. A missing file
+// starts with an empty store.
+func (s *Store) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("virtual keys: read store: %w", err)
+	}
+	var keys []*Key
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &keys); err != nil {
+			return fmt.Errorf("virtual keys: decode store: %w", err)
+		}
+	}
+	s.mu.Lock()
+	s.path = path
+	for _, key := range keys {
+		if key == nil || key.ID == "" || key.Hash == "" {
+			continue
+		}
+		s.keys[key.ID] = key
+		s.byHash[key.Hash] = key.ID
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Authenticate returns the active key matching secret.
+func (s *Store) Authenticate(secret string) (Key, bool) {
+	if s == nil || secret == "" {
+		return Key{}, false
+	}
+	hash := hashSecret(secret)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.byHash[hash]
+	if !ok {
+		return Key{}, false
+	}
+	key := s.keys[id]
+	now := s.now()
+	if key == nil || key.Status(now) != "active" {
+		return Key{}, false
+	}
+	key.LastUsedAt = &now
+	s.scheduleSaveLocked()
+	return *key, true
+}
+
+// List returns all keys ordered by creation time.
+func (s *Store) List() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		out = append(out, *key)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out
+}
+
+// ByPrincipal returns the key reported under the given principal.
+func (s *Store) ByPrincipal(principal string) (Key, bool) {
+	id, ok := strings.CutPrefix(principal, PrincipalPrefix)
+	if s == nil || !ok {
+		return Key{}, false
+	}
+	return s.Get(id)
+}
+
+// RateLimitFor returns the rate limit of the client key with the given principal: the virtual
+// key's own limit when it defines one, otherwise cfg.RateLimitFor.
+func (s *Store) RateLimitFor(cfg *config.SDKConfig, principal string) config.ClientRateLimit {
+	if key, ok := s.ByPrincipal(principal); ok && key.RateLimit != nil {
+		return *key.RateLimit
+	}
+	return cfg.RateLimitFor(principal)
+}
+
+// BudgetsFor returns the budgets of the client key with the given principal: the virtual key's
+// own budgets when it defines any, otherwise cfg.BudgetsFor.
+func (s *Store) BudgetsFor(cfg *config.SDKConfig, principal string) []config.ClientBudget {
+	if key, ok := s.ByPrincipal(principal); ok && len(key.Budgets) > 0 {
+		return key.Budgets
+	}
+	return cfg.BudgetsFor(principal)
+}
+
+// Get returns the key with the given ID.
+func (s *Store) Get(id string) (Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return Key{}, false
+	}
+	return *key, true
+}
+
+// Create stores a new key and returns it together with its secret.
+func (s *Store) Create(label, owner string, scopes Scopes, expiresAt *time.Time) (Key, string, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return Key{}, "", err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return Key{}, "", err
+	}
+	s.mu.Lock()
+	key := &Key{
+		ID:        id,
+		Label:     strings.TrimSpace(label),
+		Owner:     strings.TrimSpace(owner),
+		Hash:      hashSecret(secret),
+		Hint:      secretHint(secret),
+		Scopes:    scopes,
+		CreatedAt: s.now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	if key.Label == "" {
+		key.Label = key.Principal()
+	}
+	s.keys[id] = key
+	s.byHash[key.Hash] = id
+	created := *key
+	err = s.commitLocked()
+	return created, secret, err
+}
+
+// Update applies fn to the key with the given ID and persists the result. The hash and
+// timestamps managed by the store cannot be changed through fn.
+func (s *Store) Update(id string, fn func(*Key)) (Key, error) {
+	s.mu.Lock()
+	key, ok := s.keys[id]
+	if !ok {
+		s.mu.Unlock()
+		return Key{}, ErrNotFound
+	}
+	updated := *key
+	fn(&updated)
+	updated.ID, updated.Hash, updated.Hint = key.ID, key.Hash, key.Hint
+	updated.CreatedAt, updated.RotatedAt, updated.RevokedAt = key.CreatedAt, key.RotatedAt, key.RevokedAt
+	updated.Label = strings.TrimSpace(updated.Label)
+	if updated.Label == "" {
+		updated.Label = updated.Principal()
+	}
+	*key = updated
+	err := s.commitLocked()
+	return updated, err
+}
+
+// Rotate replaces the secret of a key, invalidating the previous one immediately.
+func (s *Store) Rotate(id string) (Key, string, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return Key{}, "", err
+	}
+	s.mu.Lock()
+	key, ok := s.keys[id]
+	if !ok {
+		s.mu.Unlock()
+		return Key{}, "", ErrNotFound
+	}
+	delete(s.byHash, key.Hash)
+	now := s.now().UTC()
+	key.Hash = hashSecret(secret)
+	key.Hint = secretHint(secret)
+	key.RotatedAt = &now
+	s.byHash[key.Hash] = id
+	rotated := *key
+	err = s.commitLocked()
+	return rotated, secret, err
+}
+
+// Revoke disables a key permanently while keeping it listed.
+func (s *Store) Revoke(id string) (Key, error) {
+	s.mu.Lock()
+	key, ok := s.keys[id]
+	if !ok {
+		s.mu.Unlock()
+		return Key{}, ErrNotFound
+	}
+	if key.RevokedAt == nil {
+		now := s.now().UTC()
+		key.RevokedAt = &now
+	}
+	revoked := *key
+	err := s.commitLocked()
+	return revoked, err
+}
+
+// Delete removes a key.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	key, ok := s.keys[id]
+	if !ok {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	delete(s.byHash, key.Hash)
+	delete(s.keys, id)
+	return s.commitLocked()
+}
+
+// commitLocked persists the store, releases s.mu and notifies the change callback. The caller
+// must hold s.mu.
+func (s *Store) commitLocked() error {
+	if s.saveTimer != nil {
+		s.saveTimer.Stop()
+		s.saveTimer = nil
+	}
+	err := s.writeLocked()
+	onChange := s.onChange
+	s.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+	return err
+}
+
+// Save writes the store to the path given to Load.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saveTimer != nil {
+		s.saveTimer.Stop()
+		s.saveTimer = nil
+	}
+	return s.writeLocked()
+}
+
+func (s *Store) writeLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("virtual keys: encode store: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	err = os.MkdirAll(filepath.Dir(s.path), 0o755)
+	if err == nil {
+		err = os.WriteFile(tmp, data, 0o600)
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		return fmt.Errorf("virtual keys: write store: %w", err)
+	}
+	return nil
+}
+
+// scheduleSaveLocked arranges for last-used timestamps to be written. The caller must hold s.mu.
+func (s *Store) scheduleSaveLocked() {
+	if s.path == "" || s.saveTimer != nil {
+		return
+	}
+	s.saveTimer = time.AfterFunc(lastUsedSaveDelay, func() {
+		if err := s.Save(); err != nil {
+			log.Warnf("failed to persist virtual keys: %v", err)
+		}
+	})
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// secretHint keeps enough of a secret to recognise it in listings.
+func secretHint(secret string) string {
+	if len(secret) <= len(SecretPrefix)+8 {
+		return SecretPrefix + "..."
+	}
+	return secret[:len(SecretPrefix)+4] + "..." + secret[len(secret)-4:]
+}
+
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("virtual keys: generate secret: %w", err)
+	}
+	return SecretPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("virtual keys: generate id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,112 @@
+package virtualkeys
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestStore_LifecycleAndHashedStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "virtual-keys.json")
+	store := NewStore()
+	if err := store.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	key, secret, err := store.Create("ci-bot", "alice", Scopes{Models: []string{"gemini-*"}}, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(secret, SecretPrefix) {
+		t.Fatalf("unexpected secret format %q", secret)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read store: %v", err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Fatalf("store file contains the plaintext secret")
+	}
+
+	got, ok := store.Authenticate(secret)
+	if !ok || got.ID != key.ID || got.Policy().Name != "ci-bot" || got.Principal() != PrincipalPrefix+key.ID {
+		t.Fatalf("unexpected authentication result %+v %v", got, ok)
+	}
+
+	_, rotated, err := store.Rotate(key.ID)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if _, ok = store.Authenticate(secret); ok {
+		t.Fatalf("previous secret still valid after rotation")
+	}
+	if _, ok = store.Authenticate(rotated); !ok {
+		t.Fatalf("rotated secret rejected")
+	}
+
+	restored := NewStore()
+	if err = restored.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, ok = restored.Authenticate(rotated); !ok {
+		t.Fatalf("rotated secret rejected after reload")
+	}
+
+	if _, err = store.Revoke(key.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, ok = store.Authenticate(rotated); ok {
+		t.Fatalf("revoked key still valid")
+	}
+}
+
+func TestStore_Expiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewStore()
+	store.now = func() time.Time { return now }
+	expiresAt := now.Add(time.Hour)
+	_, secret, err := store.Create("", "", Scopes{}, &expiresAt)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, ok := store.Authenticate(secret); !ok {
+		t.Fatalf("key rejected before expiry")
+	}
+	now = now.Add(2 * time.Hour)
+	if _, ok := store.Authenticate(secret); ok {
+		t.Fatalf("expired key accepted")
+	}
+}
+
+func TestStore_LimitsOverrideClientDefaults(t *testing.T) {
+	store := NewStore()
+	cfg := &config.SDKConfig{
+		ClientRateLimit: config.ClientRateLimit{RequestsPerMinute: 10},
+		ClientBudgets:   []config.ClientBudget{{Period: config.BudgetPeriodDaily, HardTokens: 100}},
+	}
+	key, _, err := store.Create("limited", "", Scopes{}, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got := store.RateLimitFor(cfg, key.Principal()); got.RequestsPerMinute != 10 {
+		t.Fatalf("expected the client default rate limit, got %+v", got)
+	}
+	if _, err = store.Update(key.ID, func(k *Key) {
+		k.RateLimit = &config.ClientRateLimit{RequestsPerMinute: 2}
+		k.Budgets = []config.ClientBudget{{Period: config.BudgetPeriodMonthly, HardCost: 5}}
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := store.RateLimitFor(cfg, key.Principal()); got.RequestsPerMinute != 2 {
+		t.Fatalf("expected the virtual key rate limit, got %+v", got)
+	}
+	if got := store.BudgetsFor(cfg, key.Principal()); len(got) != 1 || got[0].HardCost != 5 {
+		t.Fatalf("expected the virtual key budgets, got %+v", got)
+	}
+	if got := store.BudgetsFor(cfg, "other-key"); len(got) != 1 || got[0].HardTokens != 100 {
+		t.Fatalf("expected the client default budgets for other keys, got %+v", got)
+	}
+}
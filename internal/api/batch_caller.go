@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtualkeys"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+)
+
+// resolveBatchCaller re-authenticates the creator of a batch before each of its requests runs.
+// Batches only persist the hash of their creator's client key, so inline keys are found again by
+// that hash and checked through the access manager, and virtual keys through their store.
+// Principals of other providers, such as JWT subjects, cannot be checked without their token:
+// they keep the identity verified at creation and their batches fail after a restart.
+func (s *Server) resolveBatchCaller(ctx context.Context, owner string, caller batch.Caller) (batch.Caller, error) {
+	if owner == "" {
+		// Created while no client key was required: valid as long as none is.
+		if _, err := s.authenticateBatchKey(ctx, ""); err != nil {
+			return batch.Caller{}, err
+		}
+		return caller, nil
+	}
+
+	s.cfgMu.Lock()
+	cfg := s.cfg
+	s.cfgMu.Unlock()
+
+	for _, key := range inlineClientKeys(cfg) {
+		if batch.OwnerOf(key) != owner {
+			continue
+		}
+		result, err := s.authenticateBatchKey(ctx, key)
+		if err != nil {
+			return batch.Caller{}, err
+		}
+		if result == nil {
+			return batch.Caller{}, nil
+		}
+		return batch.Caller{APIKey: result.Principal, Provider: result.Provider, Metadata: result.Metadata}, nil
+	}
+
+	for _, key := range virtualkeys.Default().List() {
+		if batch.OwnerOf(key.Principal()) != owner {
+			continue
+		}
+		if status := key.Status(time.Now()); status != "active" {
+			return batch.Caller{}, fmt.Errorf("virtual key %s is %s", key.ID, status)
+		}
+		provider := s.inlineProvider(cfg, caller.Provider)
+		if provider == "" {
+			return batch.Caller{}, errors.New("virtual keys are not accepted")
+		}
+		source := caller.Metadata["source"]
+		if source == "" {
+			source = "authorization"
+		}
+		result := configaccess.VirtualKeyResult(provider, key, source)
+		return batch.Caller{APIKey: result.Principal, Provider: result.Provider, Metadata: result.Metadata}, nil
+	}
+
+	if caller.APIKey != "" && batch.OwnerOf(caller.APIKey) == owner && !isInlineProvider(cfg, caller.Provider) {
+		return caller, nil
+	}
+	return batch.Caller{}, errors.New("the client key is not configured")
+}
+
+// authenticateBatchKey runs the access manager on a request presenting key, or no credentials
+// when key is empty. It returns a nil result when no client key is required.
+func (s *Server) authenticateBatchKey(ctx context.Context, key string) (*sdkaccess.Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	return s.accessManager.Authenticate(ctx, req)
+}
+
+// inlineProvider returns the name of the active inline API key provider, which also accepts
+// virtual keys, preferring preferred, or "" when there is none.
+func (s *Server) inlineProvider(cfg *config.Config, preferred string) string {
+	name := ""
+	for _, provider := range s.accessManager.Providers() {
+		id := provider.Identifier()
+		if !isInlineProvider(cfg, id) {
+			continue
+		}
+		if id == preferred {
+			return id
+		}
+		if name == "" {
+			name = id
+		}
+	}
+	return name
+}
+
+// inlineClientKeys lists the client keys of the configuration and its inline providers.
+func inlineClientKeys(cfg *config.Config) []string {
+	if cfg == nil {
+		return nil
+	}
+	keys := cfg.ClientAPIKeys()
+	for i := range cfg.Access.Providers {
+		if cfg.Access.Providers[i].Type == config.AccessProviderTypeConfigAPIKey {
+			keys = append(keys, cfg.Access.Providers[i].APIKeys...)
+		}
+	}
+	return keys
+}
+
+// isInlineProvider reports whether name identifies an inline API key provider of cfg.
+func isInlineProvider(cfg *config.Config, name string) bool {
+	if name == config.DefaultAccessProviderName {
+		return true
+	}
+	if cfg == nil {
+		return false
+	}
+	for i := range cfg.Access.Providers {
+		if cfg.Access.Providers[i].Type == config.AccessProviderTypeConfigAPIKey && cfg.Access.Providers[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
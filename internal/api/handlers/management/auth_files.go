@@ -411,6 +411,35 @@ func (h *Handler) buildAuthFileEntry(auth *coreauth.Auth) gin.H {
 	if !auth.LastRefreshedAt.IsZero() {
 		entry["last_refresh"] = auth.LastRefreshedAt
 	}
+	if h.authManager != nil {
+		if stats := h.authManager.ExecutionStats(auth.ID); len(stats) > 0 {
+			models := make([]gin.H, 0, len(stats))
+			for _, stat := range stats {
+				models = append(models, gin.H{
+					"model":           stat.Model,
+					"latency_ewma_ms": stat.LatencyEWMA.Milliseconds(),
+					"last_latency_ms": stat.LastLatency.Milliseconds(),
+					"in_flight":       stat.InFlight,
+					"samples":         stat.Samples,
+					"updated_at":      stat.UpdatedAt,
+				})
+			}
+			entry["execution_stats"] = models
+		}
+		if history, ok := h.authManager.ProbeHistory(auth.ID); ok {
+			probe := gin.H{
+				"last_probe_at":        history.LastProbeAt,
+				"consecutive_failures": history.ConsecutiveFailures,
+			}
+			if !history.LastVerifiedAt.IsZero() {
+				probe["last_verified_at"] = history.LastVerifiedAt
+			}
+			if n := len(history.Results); n > 0 {
+				probe["last_result"] = history.Results[n-1]
+			}
+			entry["health_probe"] = probe
+		}
+	}
 	if path != "" {
 		entry["path"] = path
 		entry["source"] = "file"
@@ -0,0 +1,139 @@
+package management
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtualkeys"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/budget"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+)
+
+// GetBudgets reports the budgets of every client key together with its usage in the current
+// daily, weekly and monthly periods. Usage recorded for keys that are no longer configured is
+// listed under its key hash.
+func (h *Handler) GetBudgets(c *gin.Context) {
+	tracker := budget.Default()
+	names := make(map[string]string)
+	keys := make(map[string]string)
+	for _, key := range h.cfg.ClientAPIKeys() {
+		keys[budget.KeyHash(key)] = key
+	}
+	for _, entry := range h.cfg.APIKeyEntries {
+		names[entry.APIKey] = entry.Name
+	}
+	for _, key := range virtualkeys.Default().List() {
+		keys[budget.KeyHash(key.Principal())] = key.Principal()
+		names[key.Principal()] = key.Label
+	}
+	for _, hash := range tracker.Keys() {
+		if _, known := keys[hash]; !known {
+			keys[hash] = ""
+		}
+	}
+	ordered := make([]string, 0, len(keys))
+	for hash := range keys {
+		ordered = append(ordered, hash)
+	}
+	// Configured keys come first, ordered by key; unknown hashes follow.
+	sort.Slice(ordered, func(i, j int) bool {
+		ki, kj := keys[ordered[i]], keys[ordered[j]]
+		if (ki == "") != (kj == "") {
+			return ki != ""
+		}
+		if ki != kj {
+			return ki < kj
+		}
+		return ordered[i] < ordered[j]
+	})
+
+	entries := make([]gin.H, 0, len(ordered))
+	for _, hash := range ordered {
+		key := keys[hash]
+		entry := gin.H{"api-key-hash": hash, "usage": tracker.UsageByHash(hash)}
+		if key != "" {
+			entry["api-key"] = key
+			entry["name"] = names[key]
+			entry["budgets"] = virtualkeys.Default().BudgetsFor(&h.cfg.SDKConfig, key)
+		}
+		entries = append(entries, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{"client-budgets": h.cfg.ClientBudgets, "budgets": entries, "currency": pricing.Default().Currency()})
+}
+
+// PutBudgets replaces the budgets of the api-key-entries item named by "api-key", or the
+// default client-budgets when "api-key" is omitted.
+func (h *Handler) PutBudgets(c *gin.Context) {
+	var body struct {
+		APIKey  string                `json:"api-key"`
+		Budgets []config.ClientBudget `json:"budgets"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	budgets := config.SanitizeClientBudgets(body.Budgets)
+	if len(budgets) != len(body.Budgets) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "budgets need a daily, weekly or monthly period, a soft or hard cap, and distinct periods"})
+		return
+	}
+	key := strings.TrimSpace(body.APIKey)
+	if key == "" {
+		h.cfg.ClientBudgets = budgets
+		h.persist(c)
+		return
+	}
+	for i := range h.cfg.APIKeyEntries {
+		if h.cfg.APIKeyEntries[i].APIKey == key {
+			h.cfg.APIKeyEntries[i].Budgets = budgets
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "api-key-entries item not found"})
+}
+
+// DeleteBudgets removes the budgets of the api-key-entries item named by the api-key query
+// parameter, falling back to the default client-budgets, or clears client-budgets when no key
+// is given.
+func (h *Handler) DeleteBudgets(c *gin.Context) {
+	key := strings.TrimSpace(c.Query("api-key"))
+	if key == "" {
+		h.cfg.ClientBudgets = nil
+		h.persist(c)
+		return
+	}
+	for i := range h.cfg.APIKeyEntries {
+		if h.cfg.APIKeyEntries[i].APIKey == key {
+			h.cfg.APIKeyEntries[i].Budgets = nil
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "api-key-entries item not found"})
+}
+
+// ResetBudget clears the recorded usage of a client key for one period, or for all periods
+// when "period" is omitted. "api-key" may also be an api-key-hash reported by GetBudgets.
+func (h *Handler) ResetBudget(c *gin.Context) {
+	var body struct {
+		APIKey string `json:"api-key"`
+		Period string `json:"period"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.APIKey) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "api-key is required"})
+		return
+	}
+	period := strings.ToLower(strings.TrimSpace(body.Period))
+	switch period {
+	case "", config.BudgetPeriodDaily, config.BudgetPeriodWeekly, config.BudgetPeriodMonthly:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be daily, weekly or monthly"})
+		return
+	}
+	budget.Default().Reset(strings.TrimSpace(body.APIKey), period)
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
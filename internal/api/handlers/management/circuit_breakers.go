@@ -0,0 +1,92 @@
+package management
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetCircuitBreakers lists circuit breaker state for every credential and model that has
+// recorded transient failures or is currently open or half-open.
+func (h *Handler) GetCircuitBreakers(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	entries := make([]gin.H, 0)
+	for _, auth := range h.authManager.List() {
+		if auth == nil {
+			continue
+		}
+		for model, state := range auth.ModelStates {
+			if state == nil {
+				continue
+			}
+			circuit := state.Circuit
+			if circuit.State == "" || (circuit.State == coreauth.CircuitClosed && circuit.ConsecutiveFailures == 0 && circuit.WindowFailures == 0) {
+				continue
+			}
+			entry := gin.H{
+				"auth_id":              auth.ID,
+				"auth_index":           auth.EnsureIndex(),
+				"provider":             auth.Provider,
+				"label":                auth.Label,
+				"model":                model,
+				"state":                circuit.State,
+				"consecutive_failures": circuit.ConsecutiveFailures,
+				"window_requests":      circuit.WindowRequests,
+				"window_failures":      circuit.WindowFailures,
+			}
+			if !circuit.OpenedAt.IsZero() {
+				entry["opened_at"] = circuit.OpenedAt
+			}
+			if !circuit.ProbeStartedAt.IsZero() {
+				entry["probe_started_at"] = circuit.ProbeStartedAt
+			}
+			if circuit.State != coreauth.CircuitClosed && !state.NextRetryAfter.IsZero() {
+				entry["next_probe_at"] = state.NextRetryAfter
+			}
+			if state.LastError != nil {
+				entry["last_error"] = state.LastError.Message
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i]["auth_id"] != entries[j]["auth_id"] {
+			return entries[i]["auth_id"].(string) < entries[j]["auth_id"].(string)
+		}
+		return entries[i]["model"].(string) < entries[j]["model"].(string)
+	})
+	c.JSON(http.StatusOK, gin.H{"circuit-breakers": entries})
+}
+
+// ResetCircuitBreaker force-closes the circuit breaker for a credential and model.
+func (h *Handler) ResetCircuitBreaker(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	var body struct {
+		AuthID string `json:"auth_id"`
+		Model  string `json:"model"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	authID := strings.TrimSpace(body.AuthID)
+	model := strings.TrimSpace(body.Model)
+	if authID == "" || model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "auth_id and model are required"})
+		return
+	}
+	if !h.authManager.ResetCircuitBreaker(c.Request.Context(), authID, model) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "circuit breaker not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
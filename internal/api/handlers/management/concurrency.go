@@ -0,0 +1,54 @@
+package management
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetConcurrency reports in-flight requests for credentials with a max-concurrency limit and
+// the depth of the queue of requests waiting for a free slot.
+func (h *Handler) GetConcurrency(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	snapshot := h.authManager.ConcurrencyStats()
+	type poolUsage struct{ inFlight, waiting int }
+	pools := make(map[string]poolUsage, len(snapshot.Pools))
+	for _, pool := range snapshot.Pools {
+		pools[pool.Key] = poolUsage{inFlight: pool.InFlight, waiting: pool.Waiting}
+	}
+	entries := make([]gin.H, 0)
+	for _, auth := range h.authManager.List() {
+		if auth == nil || auth.Attributes == nil {
+			continue
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(auth.Attributes["max_concurrency"]))
+		if err != nil || limit <= 0 {
+			continue
+		}
+		pool := auth.ID
+		if parent := strings.TrimSpace(auth.Attributes["gemini_virtual_parent"]); parent != "" {
+			pool = parent
+		}
+		usage := pools[pool]
+		entries = append(entries, gin.H{
+			"auth_id":         auth.ID,
+			"auth_index":      auth.EnsureIndex(),
+			"provider":        auth.Provider,
+			"label":           auth.Label,
+			"pool":            pool,
+			"max_concurrency": limit,
+			"in_flight":       usage.inFlight,
+			"waiting":         usage.waiting,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i]["auth_id"].(string) < entries[j]["auth_id"].(string)
+	})
+	c.JSON(http.StatusOK, gin.H{"queue_depth": snapshot.QueueDepth, "concurrency": entries})
+}
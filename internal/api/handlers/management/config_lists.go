@@ -119,6 +119,139 @@ func (h *Handler) DeleteAPIKeys(c *gin.Context) {
 	h.deleteFromStringList(c, &h.cfg.APIKeys, func() { h.cfg.Access.Providers = nil })
 }
 
+// api-key-entries: []ClientAPIKey
+func (h *Handler) GetAPIKeyEntries(c *gin.Context) {
+	c.JSON(200, gin.H{"api-key-entries": h.cfg.APIKeyEntries})
+}
+func (h *Handler) PutAPIKeyEntries(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []config.ClientAPIKey
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []config.ClientAPIKey `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil || len(obj.Items) == 0 {
+			c.JSON(400, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	h.cfg.APIKeyEntries = append([]config.ClientAPIKey(nil), arr...)
+	h.cfg.SanitizeClientAPIKeys()
+	h.cfg.Access.Providers = nil
+	h.persist(c)
+}
+func (h *Handler) PatchAPIKeyEntry(c *gin.Context) {
+	type clientKeyPatch struct {
+		APIKey        *string   `json:"api-key"`
+		Name          *string   `json:"name"`
+		Models        *[]string `json:"models"`
+		Providers     *[]string `json:"providers"`
+		Prefixes      *[]string `json:"prefixes"`
+		DefaultPrefix *string   `json:"default-prefix"`
+		// RateLimit replaces the key's rate-limit; an empty object clears the override.
+		RateLimit *config.ClientRateLimit `json:"rate-limit"`
+	}
+	var body struct {
+		Index *int            `json:"index"`
+		Match *string         `json:"match"`
+		Value *clientKeyPatch `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	targetIndex := -1
+	if body.Index != nil && *body.Index >= 0 && *body.Index < len(h.cfg.APIKeyEntries) {
+		targetIndex = *body.Index
+	}
+	if targetIndex == -1 && body.Match != nil {
+		match := strings.TrimSpace(*body.Match)
+		if match != "" {
+			for i := range h.cfg.APIKeyEntries {
+				if h.cfg.APIKeyEntries[i].APIKey == match {
+					targetIndex = i
+					break
+				}
+			}
+		}
+	}
+	if targetIndex == -1 {
+		c.JSON(404, gin.H{"error": "item not found"})
+		return
+	}
+
+	entry := h.cfg.APIKeyEntries[targetIndex]
+	if body.Value.APIKey != nil {
+		trimmed := strings.TrimSpace(*body.Value.APIKey)
+		if trimmed == "" {
+			h.cfg.APIKeyEntries = append(h.cfg.APIKeyEntries[:targetIndex], h.cfg.APIKeyEntries[targetIndex+1:]...)
+			h.cfg.Access.Providers = nil
+			h.persist(c)
+			return
+		}
+		entry.APIKey = trimmed
+	}
+	if body.Value.Name != nil {
+		entry.Name = *body.Value.Name
+	}
+	if body.Value.Models != nil {
+		entry.Models = *body.Value.Models
+	}
+	if body.Value.Providers != nil {
+		entry.Providers = *body.Value.Providers
+	}
+	if body.Value.Prefixes != nil {
+		entry.Prefixes = *body.Value.Prefixes
+	}
+	if body.Value.DefaultPrefix != nil {
+		entry.DefaultPrefix = *body.Value.DefaultPrefix
+	}
+	if body.Value.RateLimit != nil {
+		entry.RateLimit = nil
+		if body.Value.RateLimit.Enabled() {
+			limit := *body.Value.RateLimit
+			entry.RateLimit = &limit
+		}
+	}
+	h.cfg.APIKeyEntries[targetIndex] = entry
+	h.cfg.SanitizeClientAPIKeys()
+	h.cfg.Access.Providers = nil
+	h.persist(c)
+}
+func (h *Handler) DeleteAPIKeyEntry(c *gin.Context) {
+	if val := strings.TrimSpace(c.Query("api-key")); val != "" {
+		out := make([]config.ClientAPIKey, 0, len(h.cfg.APIKeyEntries))
+		for _, v := range h.cfg.APIKeyEntries {
+			if v.APIKey != val {
+				out = append(out, v)
+			}
+		}
+		if len(out) != len(h.cfg.APIKeyEntries) {
+			h.cfg.APIKeyEntries = out
+			h.cfg.Access.Providers = nil
+			h.persist(c)
+		} else {
+			c.JSON(404, gin.H{"error": "item not found"})
+		}
+		return
+	}
+	if idxStr := c.Query("index"); idxStr != "" {
+		var idx int
+		if _, err := fmt.Sscanf(idxStr, "%d", &idx); err == nil && idx >= 0 && idx < len(h.cfg.APIKeyEntries) {
+			h.cfg.APIKeyEntries = append(h.cfg.APIKeyEntries[:idx], h.cfg.APIKeyEntries[idx+1:]...)
+			h.cfg.Access.Providers = nil
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(400, gin.H{"error": "missing api-key or index"})
+}
+
 // gemini-api-key: []GeminiKey
 func (h *Handler) GetGeminiKeys(c *gin.Context) {
 	c.JSON(200, gin.H{"gemini-api-key": h.cfg.GeminiKey})
@@ -148,6 +281,9 @@ func (h *Handler) PatchGeminiKey(c *gin.Context) {
 	type geminiKeyPatch struct {
 		APIKey         *string            `json:"api-key"`
 		Prefix         *string            `json:"prefix"`
+		Priority       *int               `json:"priority"`
+		Weight         *int               `json:"weight"`
+		MaxConcurrency *int               `json:"max-concurrency"`
 		BaseURL        *string            `json:"base-url"`
 		ProxyURL       *string            `json:"proxy-url"`
 		Headers        *map[string]string `json:"headers"`
@@ -196,6 +332,15 @@ func (h *Handler) PatchGeminiKey(c *gin.Context) {
 	if body.Value.Prefix != nil {
 		entry.Prefix = strings.TrimSpace(*body.Value.Prefix)
 	}
+	if body.Value.Priority != nil {
+		entry.Priority = *body.Value.Priority
+	}
+	if body.Value.Weight != nil {
+		entry.Weight = *body.Value.Weight
+	}
+	if body.Value.MaxConcurrency != nil {
+		entry.MaxConcurrency = *body.Value.MaxConcurrency
+	}
 	if body.Value.BaseURL != nil {
 		entry.BaseURL = strings.TrimSpace(*body.Value.BaseURL)
 	}
@@ -274,6 +419,9 @@ func (h *Handler) PatchClaudeKey(c *gin.Context) {
 	type claudeKeyPatch struct {
 		APIKey         *string               `json:"api-key"`
 		Prefix         *string               `json:"prefix"`
+		Priority       *int                  `json:"priority"`
+		Weight         *int                  `json:"weight"`
+		MaxConcurrency *int                  `json:"max-concurrency"`
 		BaseURL        *string               `json:"base-url"`
 		ProxyURL       *string               `json:"proxy-url"`
 		Models         *[]config.ClaudeModel `json:"models"`
@@ -314,6 +462,15 @@ func (h *Handler) PatchClaudeKey(c *gin.Context) {
 	if body.Value.Prefix != nil {
 		entry.Prefix = strings.TrimSpace(*body.Value.Prefix)
 	}
+	if body.Value.Priority != nil {
+		entry.Priority = *body.Value.Priority
+	}
+	if body.Value.Weight != nil {
+		entry.Weight = *body.Value.Weight
+	}
+	if body.Value.MaxConcurrency != nil {
+		entry.MaxConcurrency = *body.Value.MaxConcurrency
+	}
 	if body.Value.BaseURL != nil {
 		entry.BaseURL = strings.TrimSpace(*body.Value.BaseURL)
 	}
@@ -615,6 +772,9 @@ func (h *Handler) PatchCodexKey(c *gin.Context) {
 	type codexKeyPatch struct {
 		APIKey         *string            `json:"api-key"`
 		Prefix         *string            `json:"prefix"`
+		Priority       *int               `json:"priority"`
+		Weight         *int               `json:"weight"`
+		MaxConcurrency *int               `json:"max-concurrency"`
 		BaseURL        *string            `json:"base-url"`
 		ProxyURL       *string            `json:"proxy-url"`
 		Headers        *map[string]string `json:"headers"`
@@ -654,6 +814,15 @@ func (h *Handler) PatchCodexKey(c *gin.Context) {
 	if body.Value.Prefix != nil {
 		entry.Prefix = strings.TrimSpace(*body.Value.Prefix)
 	}
+	if body.Value.Priority != nil {
+		entry.Priority = *body.Value.Priority
+	}
+	if body.Value.Weight != nil {
+		entry.Weight = *body.Value.Weight
+	}
+	if body.Value.MaxConcurrency != nil {
+		entry.MaxConcurrency = *body.Value.MaxConcurrency
+	}
 	if body.Value.BaseURL != nil {
 		trimmed := strings.TrimSpace(*body.Value.BaseURL)
 		if trimmed == "" {
@@ -0,0 +1,76 @@
+package management
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetHealthProbes lists the recorded health probe history for every credential.
+func (h *Handler) GetHealthProbes(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	entries := make([]gin.H, 0)
+	for _, auth := range h.authManager.List() {
+		if auth == nil {
+			continue
+		}
+		history, ok := h.authManager.ProbeHistory(auth.ID)
+		if !ok {
+			continue
+		}
+		entry := gin.H{
+			"auth_id":              auth.ID,
+			"auth_index":           auth.EnsureIndex(),
+			"provider":             auth.Provider,
+			"label":                auth.Label,
+			"status":               auth.Status,
+			"last_probe_at":        history.LastProbeAt,
+			"consecutive_failures": history.ConsecutiveFailures,
+			"results":              history.Results,
+		}
+		if !history.LastVerifiedAt.IsZero() {
+			entry["last_verified_at"] = history.LastVerifiedAt
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i]["auth_id"].(string) < entries[j]["auth_id"].(string)
+	})
+	c.JSON(http.StatusOK, gin.H{"health-probes": entries})
+}
+
+// RunHealthProbe probes a single credential immediately.
+func (h *Handler) RunHealthProbe(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	var body struct {
+		AuthID string `json:"auth_id"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	authID := strings.TrimSpace(body.AuthID)
+	if authID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "auth_id is required"})
+		return
+	}
+	result, err := h.authManager.ProbeAuth(c.Request.Context(), authID)
+	if err != nil {
+		status := http.StatusConflict
+		if authErr, ok := err.(*coreauth.Error); ok && authErr.Code == "auth_not_found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": result})
+}
@@ -0,0 +1,37 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+)
+
+// GetRateLimits reports the live sliding-window counters of rate-limited client keys together
+// with their configured limits.
+func (h *Handler) GetRateLimits(c *gin.Context) {
+	names := make(map[string]string)
+	if h.cfg != nil {
+		for _, entry := range h.cfg.APIKeyEntries {
+			if name := strings.TrimSpace(entry.Name); name != "" {
+				names[entry.APIKey] = name
+			}
+		}
+	}
+	snapshot := ratelimit.Default().Snapshot()
+	entries := make([]gin.H, 0, len(snapshot))
+	for _, usage := range snapshot {
+		entries = append(entries, gin.H{
+			"api-key":              usage.Key,
+			"name":                 names[usage.Key],
+			"limits":               usage.Limits,
+			"requests_last_minute": usage.Requests,
+			"tokens_last_minute":   usage.Tokens,
+			"pending_tokens":       usage.PendingTokens,
+			"in_flight":            usage.InFlight,
+			"rejected":             usage.Rejected,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"rate-limits": entries})
+}
@@ -0,0 +1,217 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtualkeys"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// virtualKeyRequest is the body accepted when creating or updating a virtual key. Expiry is
+// given either as an RFC 3339 timestamp or as a lifetime in seconds; an empty "expires-at"
+// removes the expiry. "rate-limit" and "budgets" override the client defaults for the key;
+// an empty object or list removes the override.
+type virtualKeyRequest struct {
+	Label      *string                 `json:"label"`
+	Owner      *string                 `json:"owner"`
+	Scopes     *virtualkeys.Scopes     `json:"scopes"`
+	ExpiresAt  *string                 `json:"expires-at"`
+	TTLSeconds *int64                  `json:"ttl-seconds"`
+	RateLimit  *config.ClientRateLimit `json:"rate-limit"`
+	Budgets    *[]config.ClientBudget  `json:"budgets"`
+}
+
+// limits validates the rate limit and budgets of the request.
+func (r virtualKeyRequest) limits() (*config.ClientRateLimit, []config.ClientBudget, error) {
+	var limit *config.ClientRateLimit
+	if r.RateLimit != nil {
+		if r.RateLimit.RequestsPerMinute < 0 || r.RateLimit.TokensPerMinute < 0 || r.RateLimit.MaxConcurrent < 0 {
+			return nil, nil, errors.New("rate-limit values must not be negative")
+		}
+		if r.RateLimit.Enabled() {
+			copied := *r.RateLimit
+			limit = &copied
+		}
+	}
+	var budgets []config.ClientBudget
+	if r.Budgets != nil {
+		budgets = config.SanitizeClientBudgets(*r.Budgets)
+		if len(budgets) != len(*r.Budgets) {
+			return nil, nil, errors.New("budgets need a daily, weekly or monthly period, a soft or hard cap, and distinct periods")
+		}
+	}
+	return limit, budgets, nil
+}
+
+func (r virtualKeyRequest) expiry(now time.Time) (*time.Time, bool, error) {
+	if r.TTLSeconds != nil {
+		if *r.TTLSeconds <= 0 {
+			return nil, true, errors.New("ttl-seconds must be positive")
+		}
+		at := now.Add(time.Duration(*r.TTLSeconds) * time.Second).UTC()
+		return &at, true, nil
+	}
+	if r.ExpiresAt == nil {
+		return nil, false, nil
+	}
+	raw := strings.TrimSpace(*r.ExpiresAt)
+	if raw == "" {
+		return nil, true, nil
+	}
+	at, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, true, errors.New("expires-at must be an RFC 3339 timestamp")
+	}
+	at = at.UTC()
+	return &at, true, nil
+}
+
+// virtualKeyView omits the secret hash from API responses.
+func virtualKeyView(key virtualkeys.Key) gin.H {
+	return gin.H{
+		"id":           key.ID,
+		"label":        key.Label,
+		"owner":        key.Owner,
+		"hint":         key.Hint,
+		"principal":    key.Principal(),
+		"scopes":       key.Scopes,
+		"rate-limit":   key.RateLimit,
+		"budgets":      key.Budgets,
+		"status":       key.Status(time.Now()),
+		"created-at":   key.CreatedAt,
+		"expires-at":   key.ExpiresAt,
+		"rotated-at":   key.RotatedAt,
+		"revoked-at":   key.RevokedAt,
+		"last-used-at": key.LastUsedAt,
+	}
+}
+
+// GetVirtualKeys lists the virtual keys without their secrets.
+func (h *Handler) GetVirtualKeys(c *gin.Context) {
+	keys := virtualkeys.Default().List()
+	out := make([]gin.H, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, virtualKeyView(key))
+	}
+	c.JSON(http.StatusOK, gin.H{"virtual-keys": out})
+}
+
+// CreateVirtualKey creates a virtual key. The secret is only returned in this response.
+func (h *Handler) CreateVirtualKey(c *gin.Context) {
+	var body virtualKeyRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	expiresAt, _, err := body.expiry(time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var label, owner string
+	if body.Label != nil {
+		label = *body.Label
+	}
+	if body.Owner != nil {
+		owner = *body.Owner
+	}
+	var scopes virtualkeys.Scopes
+	if body.Scopes != nil {
+		scopes = *body.Scopes
+	}
+	limit, budgets, err := body.limits()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, secret, err := virtualkeys.Default().Create(label, owner, scopes, expiresAt)
+	if err == nil && (limit != nil || budgets != nil) {
+		key, err = virtualkeys.Default().Update(key.ID, func(key *virtualkeys.Key) {
+			key.RateLimit, key.Budgets = limit, budgets
+		})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"virtual-key": virtualKeyView(key), "secret": secret})
+}
+
+// PatchVirtualKey updates the label, owner, scopes, expiry, rate limit or budgets of a virtual key.
+func (h *Handler) PatchVirtualKey(c *gin.Context) {
+	var body virtualKeyRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	expiresAt, setExpiry, err := body.expiry(time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, budgets, err := body.limits()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, err := virtualkeys.Default().Update(c.Param("id"), func(key *virtualkeys.Key) {
+		if body.Label != nil {
+			key.Label = *body.Label
+		}
+		if body.Owner != nil {
+			key.Owner = strings.TrimSpace(*body.Owner)
+		}
+		if body.Scopes != nil {
+			key.Scopes = *body.Scopes
+		}
+		if setExpiry {
+			key.ExpiresAt = expiresAt
+		}
+		if body.RateLimit != nil {
+			key.RateLimit = limit
+		}
+		if body.Budgets != nil {
+			key.Budgets = budgets
+		}
+	})
+	h.writeVirtualKeyResult(c, key, "", err)
+}
+
+// RotateVirtualKey issues a new secret for a virtual key; the previous secret stops working
+// immediately. The new secret is only returned in this response.
+func (h *Handler) RotateVirtualKey(c *gin.Context) {
+	key, secret, err := virtualkeys.Default().Rotate(c.Param("id"))
+	h.writeVirtualKeyResult(c, key, secret, err)
+}
+
+// RevokeVirtualKey disables a virtual key while keeping it listed.
+func (h *Handler) RevokeVirtualKey(c *gin.Context) {
+	key, err := virtualkeys.Default().Revoke(c.Param("id"))
+	h.writeVirtualKeyResult(c, key, "", err)
+}
+
+// DeleteVirtualKey removes a virtual key.
+func (h *Handler) DeleteVirtualKey(c *gin.Context) {
+	if err := virtualkeys.Default().Delete(c.Param("id")); err != nil {
+		h.writeVirtualKeyResult(c, virtualkeys.Key{}, "", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h *Handler) writeVirtualKeyResult(c *gin.Context, key virtualkeys.Key, secret string, err error) {
+	switch {
+	case errors.Is(err, virtualkeys.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "virtual key not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case secret != "":
+		c.JSON(http.StatusOK, gin.H{"virtual-key": virtualKeyView(key), "secret": secret})
+	default:
+		c.JSON(http.StatusOK, gin.H{"virtual-key": virtualKeyView(key)})
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,13 +20,20 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtualkeys"
 	managementHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/budget"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -121,8 +129,10 @@ type Server struct {
 	// handlers contains the API handlers for processing requests.
 	handlers *handlers.BaseAPIHandler
 
-	// cfg holds the current server configuration.
-	cfg *config.Config
+	// cfg holds the current server configuration. cfgMu guards its replacement against
+	// access providers being rebuilt from another goroutine.
+	cfg   *config.Config
+	cfgMu sync.Mutex
 
 	// oldConfigYaml stores a YAML snapshot of the previous configuration for change detection.
 	// This prevents issues when the config object is modified in place by Management API.
@@ -168,6 +178,12 @@ type Server struct {
 	keepAliveOnTimeout func()
 	keepAliveHeartbeat chan struct{}
 	keepAliveStop      chan struct{}
+
+	// metricsServer serves /metrics on metrics.listen when configured.
+	metricsServer *http.Server
+
+	// tracingShutdown flushes and stops the tracer provider.
+	tracingShutdown func(context.Context) error
 }
 
 // NewServer creates and initializes a new API server instance.
@@ -201,6 +217,13 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	// Add middleware
 	engine.Use(logging.GinLogrusLogger())
 	engine.Use(logging.GinLogrusRecovery())
+	tracingShutdown, errTracing := tracing.Setup(cfg.Tracing)
+	if errTracing != nil {
+		log.Errorf("failed to set up tracing: %v", errTracing)
+	}
+	if tracing.Enabled() {
+		engine.Use(tracing.Middleware())
+	}
 	for _, mw := range optionState.extraMiddleware {
 		engine.Use(mw)
 	}
@@ -243,14 +266,26 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		currentPath:         wd,
 		envManagementSecret: envManagementSecret,
 		wsRoutes:            make(map[string]struct{}),
+		tracingShutdown:     tracingShutdown,
 	}
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
 	// Save initial YAML snapshot
 	s.oldConfigYaml, _ = yaml.Marshal(cfg)
+	if err := virtualkeys.Default().Load(statePath(configFilePath, "virtual-keys.json")); err != nil {
+		log.Errorf("failed to load virtual keys: %v", err)
+	}
+	// Creating the first virtual key (or deleting the last one) changes whether clients must
+	// authenticate, so rebuild the access providers whenever the store changes.
+	virtualkeys.Default().SetOnChange(func() {
+		s.cfgMu.Lock()
+		defer s.cfgMu.Unlock()
+		s.applyAccessConfig(s.cfg, s.cfg)
+	})
 	s.applyAccessConfig(nil, cfg)
 	if authManager != nil {
 		authManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
 	}
+	metrics.Default().SetAuthManager(authManager)
 	managementasset.SetCurrentConfig(cfg)
 	auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 	// Initialize management handler
@@ -263,6 +298,32 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		logDir = filepath.Join(base, "logs")
 	}
 	s.mgmt.SetLogDirectory(logDir)
+	if err := budget.Default().Load(statePath(configFilePath, "budget-state.json")); err != nil {
+		log.Errorf("failed to load budget state: %v", err)
+	}
+	pricing.Default().SetConfig(cfg.Pricing)
+	if err := responsestore.Default().Configure(cfg.ResponsesStore, statePath(configFilePath, "responses")); err != nil {
+		log.Errorf("failed to configure responses store: %v", err)
+	}
+	batch.Default().SetLoad(handlers.InteractiveInFlight)
+	batch.Default().SetResolver(s.resolveBatchCaller)
+	if err := batch.Default().Configure(cfg.Batch, statePath(configFilePath, "batches")); err != nil {
+		log.Errorf("failed to configure batches: %v", err)
+	}
+	usageStats := usage.GetRequestStatistics()
+	usageStats.SetRetention(usage.RetentionFromConfig(cfg.UsageStorage))
+	if cfg.UsageStorage.Enabled {
+		usageDir := strings.TrimSpace(cfg.UsageStorage.Dir)
+		if usageDir == "" {
+			usageDir = statePath(configFilePath, "usage")
+		}
+		if store, errStore := usage.OpenSegmentStore(usageDir); errStore != nil {
+			log.Errorf("failed to open usage store: %v", errStore)
+		} else if errStore = usageStats.SetStore(store); errStore != nil {
+			log.Errorf("failed to restore usage statistics: %v", errStore)
+			_ = store.Close()
+		}
+	}
 	s.localPassword = optionState.localPassword
 
 	// Setup routes
@@ -315,6 +376,10 @@ func (s *Server) setupRoutes() {
 	geminiCLIHandlers := gemini.NewGeminiCLIAPIHandler(s.handlers)
 	claudeCodeHandlers := claude.NewClaudeCodeAPIHandler(s.handlers)
 	openaiResponsesHandlers := openai.NewOpenAIResponsesAPIHandler(s.handlers)
+	openaiBatchHandlers := openai.NewOpenAIBatchAPIHandler(s.handlers)
+	batch.Default().Register(batch.KindOpenAI, openaiBatchHandlers)
+	claudeBatchHandlers := claude.NewClaudeBatchAPIHandler(s.handlers)
+	batch.Default().Register(batch.KindClaude, claudeBatchHandlers)
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
@@ -325,8 +390,31 @@ func (s *Server) setupRoutes() {
 		v1.POST("/completions", openaiHandlers.Completions)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
+		v1.POST("/messages/batches", claudeBatchHandlers.CreateMessageBatch)
+		v1.GET("/messages/batches", claudeBatchHandlers.ListMessageBatches)
+		v1.GET("/messages/batches/:id", claudeBatchHandlers.GetMessageBatch)
+		v1.DELETE("/messages/batches/:id", claudeBatchHandlers.DeleteMessageBatch)
+		v1.POST("/messages/batches/:id/cancel", claudeBatchHandlers.CancelMessageBatch)
+		v1.GET("/messages/batches/:id/results", claudeBatchHandlers.MessageBatchResults)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
-	}
+		v1.GET("/responses/:id", openaiResponsesHandlers.GetResponse)
+		v1.DELETE("/responses/:id", openaiResponsesHandlers.DeleteResponse)
+		v1.GET("/responses/:id/input_items", openaiResponsesHandlers.ResponseInputItems)
+		v1.POST("/embeddings", openaiHandlers.Embeddings)
+		v1.POST("/images/generations", openaiHandlers.ImageGenerations)
+		v1.POST("/images/edits", openaiHandlers.ImageEdits)
+		v1.POST("/files", openaiBatchHandlers.UploadFile)
+		v1.GET("/files", openaiBatchHandlers.ListFiles)
+		v1.GET("/files/:id", openaiBatchHandlers.GetFile)
+		v1.DELETE("/files/:id", openaiBatchHandlers.DeleteFile)
+		v1.GET("/files/:id/content", openaiBatchHandlers.FileContent)
+		v1.POST("/batches", openaiBatchHandlers.CreateBatch)
+		v1.GET("/batches", openaiBatchHandlers.ListBatches)
+		v1.GET("/batches/:id", openaiBatchHandlers.GetBatch)
+		v1.POST("/batches/:id/cancel", openaiBatchHandlers.CancelBatch)
+	}
+	// Images returned as URLs are fetched without credentials; their ids are unguessable.
+	s.engine.GET("/v1/images/files/:id", openaiHandlers.ImageFile)
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
@@ -344,6 +432,11 @@ func (s *Server) setupRoutes() {
 			"endpoints": []string{
 				"POST /v1/chat/completions",
 				"POST /v1/completions",
+				"POST /v1/embeddings",
+				"POST /v1/images/generations",
+				"POST /v1/images/edits",
+				"POST /v1/files",
+				"POST /v1/batches",
 				"GET /v1/models",
 			},
 		})
@@ -477,6 +570,7 @@ func (s *Server) registerManagementRoutes() {
 	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
 	{
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
+		mgmt.GET("/metrics", s.serveMetrics)
 		mgmt.GET("/usage/export", s.mgmt.ExportUsageStatistics)
 		mgmt.POST("/usage/import", s.mgmt.ImportUsageStatistics)
 		mgmt.GET("/config", s.mgmt.GetConfig)
@@ -513,6 +607,10 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PUT("/api-keys", s.mgmt.PutAPIKeys)
 		mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
 		mgmt.DELETE("/api-keys", s.mgmt.DeleteAPIKeys)
+		mgmt.GET("/api-key-entries", s.mgmt.GetAPIKeyEntries)
+		mgmt.PUT("/api-key-entries", s.mgmt.PutAPIKeyEntries)
+		mgmt.PATCH("/api-key-entries", s.mgmt.PatchAPIKeyEntry)
+		mgmt.DELETE("/api-key-entries", s.mgmt.DeleteAPIKeyEntry)
 
 		mgmt.GET("/gemini-api-key", s.mgmt.GetGeminiKeys)
 		mgmt.PUT("/gemini-api-key", s.mgmt.PutGeminiKeys)
@@ -585,6 +683,24 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
 		mgmt.POST("/vertex/import", s.mgmt.ImportVertexCredential)
 
+		mgmt.GET("/circuit-breakers", s.mgmt.GetCircuitBreakers)
+		mgmt.POST("/circuit-breakers/reset", s.mgmt.ResetCircuitBreaker)
+		mgmt.GET("/concurrency", s.mgmt.GetConcurrency)
+		mgmt.GET("/rate-limits", s.mgmt.GetRateLimits)
+		mgmt.GET("/budgets", s.mgmt.GetBudgets)
+		mgmt.PUT("/budgets", s.mgmt.PutBudgets)
+		mgmt.DELETE("/budgets", s.mgmt.DeleteBudgets)
+		mgmt.POST("/budgets/reset", s.mgmt.ResetBudget)
+
+		mgmt.GET("/virtual-keys", s.mgmt.GetVirtualKeys)
+		mgmt.POST("/virtual-keys", s.mgmt.CreateVirtualKey)
+		mgmt.PATCH("/virtual-keys/:id", s.mgmt.PatchVirtualKey)
+		mgmt.DELETE("/virtual-keys/:id", s.mgmt.DeleteVirtualKey)
+		mgmt.POST("/virtual-keys/:id/rotate", s.mgmt.RotateVirtualKey)
+		mgmt.POST("/virtual-keys/:id/revoke", s.mgmt.RevokeVirtualKey)
+		mgmt.GET("/health-probes", s.mgmt.GetHealthProbes)
+		mgmt.POST("/health-probes/run", s.mgmt.RunHealthProbe)
+
 		mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
 		mgmt.GET("/codex-auth-url", s.mgmt.RequestCodexToken)
 		mgmt.GET("/gemini-cli-auth-url", s.mgmt.RequestGeminiCLIToken)
@@ -766,6 +882,21 @@ func (s *Server) Start() error {
 		return fmt.Errorf("failed to start HTTP server: server not initialized")
 	}
 
+	if s.cfg != nil && s.cfg.Metrics.Enabled {
+		if listen := strings.TrimSpace(s.cfg.Metrics.Listen); listen != "" {
+			s.metricsServer = &http.Server{Addr: listen, Handler: metricsMux()}
+			go func(srv *http.Server) {
+				log.Debugf("Starting metrics server on %s", srv.Addr)
+				if errServe := srv.ListenAndServe(); errServe != nil && !errors.Is(errServe, http.ErrServerClosed) {
+					log.Errorf("metrics server failed: %v", errServe)
+				}
+			}(s.metricsServer)
+		}
+	}
+
+	// Batches run in the background of a serving proxy, not of a server merely constructed.
+	batch.Default().Start()
+
 	useTLS := s.cfg != nil && s.cfg.TLS.Enable
 	if useTLS {
 		cert := strings.TrimSpace(s.cfg.TLS.Cert)
@@ -811,10 +942,60 @@ func (s *Server) Stop(ctx context.Context) error {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
 
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Shutdown(ctx); err != nil {
+			log.Errorf("failed to shutdown metrics server: %v", err)
+		}
+	}
+
+	if s.tracingShutdown != nil {
+		if err := s.tracingShutdown(ctx); err != nil {
+			log.Errorf("failed to flush traces: %v", err)
+		}
+	}
+
+	batch.Default().Stop()
+	if err := budget.Default().Save(); err != nil {
+		log.Errorf("failed to persist budget state: %v", err)
+	}
+	if err := virtualkeys.Default().Save(); err != nil {
+		log.Errorf("failed to persist virtual keys: %v", err)
+	}
+	if err := usage.GetRequestStatistics().Close(); err != nil {
+		log.Errorf("failed to persist usage statistics: %v", err)
+	}
+
 	log.Debug("API server stopped")
 	return nil
 }
 
+// serveMetrics exposes metrics behind management authentication when enabled without a
+// separate listener. Metrics carry credential and client key labels, so they are never served
+// to API clients.
+func (s *Server) serveMetrics(c *gin.Context) {
+	if s.cfg == nil || !s.cfg.Metrics.Enabled || strings.TrimSpace(s.cfg.Metrics.Listen) != "" {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	metrics.Default().ServeHTTP(c.Writer, c.Request)
+}
+
+// metricsMux routes the dedicated metrics listener.
+func metricsMux() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default())
+	return mux
+}
+
+// statePath returns where runtime state such as budget usage and virtual keys is persisted:
+// the writable path when set, otherwise next to the configuration file.
+func statePath(configFilePath, name string) string {
+	if base := util.WritablePath(); base != "" {
+		return filepath.Join(base, name)
+	}
+	return filepath.Join(filepath.Dir(configFilePath), name)
+}
+
 // corsMiddleware returns a Gin middleware handler that adds CORS headers
 // to every response, allowing cross-origin requests.
 //
@@ -892,6 +1073,26 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.Pricing, cfg.Pricing) {
+		pricing.Default().SetConfig(cfg.Pricing)
+	}
+
+	if oldCfg != nil && oldCfg.ResponsesStore != cfg.ResponsesStore {
+		if err := responsestore.Default().Configure(cfg.ResponsesStore, statePath(s.configFilePath, "responses")); err != nil {
+			log.Errorf("failed to configure responses store: %v", err)
+		}
+	}
+
+	if oldCfg != nil && oldCfg.Batch != cfg.Batch {
+		if err := batch.Default().Configure(cfg.Batch, statePath(s.configFilePath, "batches")); err != nil {
+			log.Errorf("failed to configure batches: %v", err)
+		}
+	}
+
+	if oldCfg != nil && oldCfg.UsageStorage != cfg.UsageStorage {
+		usage.GetRequestStatistics().SetRetention(usage.RetentionFromConfig(cfg.UsageStorage))
+	}
+
 	if oldCfg == nil || oldCfg.UsageStatisticsEnabled != cfg.UsageStatisticsEnabled {
 		usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 		if oldCfg != nil {
@@ -955,8 +1156,10 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	s.cfgMu.Lock()
 	s.applyAccessConfig(oldCfg, cfg)
 	s.cfg = cfg
+	s.cfgMu.Unlock()
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
 	if oldCfg != nil && s.wsAuthChanged != nil && oldCfg.WebsocketAuth != cfg.WebsocketAuth {
 		s.wsAuthChanged(oldCfg.WebsocketAuth, cfg.WebsocketAuth)
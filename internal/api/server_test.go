@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -9,6 +10,8 @@ import (
 	"testing"
 
 	gin "github.com/gin-gonic/gin"
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
 	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
@@ -109,3 +112,38 @@ func TestAmpProviderModelRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveBatchCaller(t *testing.T) {
+	configaccess.Register()
+	server := newTestServer(t)
+	applyKeys := func(keys ...string) {
+		server.cfg.APIKeys = keys
+		providers, err := sdkaccess.BuildProviders(&server.cfg.SDKConfig)
+		if err != nil {
+			t.Fatalf("BuildProviders: %v", err)
+		}
+		server.accessManager.SetProviders(providers)
+	}
+	applyKeys("test-key")
+	ctx := context.Background()
+
+	caller, err := server.resolveBatchCaller(ctx, batch.OwnerOf("test-key"), batch.Caller{})
+	if err != nil || caller.APIKey != "test-key" || caller.Provider != sdkconfig.DefaultAccessProviderName {
+		t.Fatalf("expected the configured key to be resolved from its hash, got %+v, %v", caller, err)
+	}
+	if _, err = server.resolveBatchCaller(ctx, "", batch.Caller{}); err == nil {
+		t.Fatalf("expected a batch created without a key to be refused once keys are required")
+	}
+	jwtCaller := batch.Caller{APIKey: "user-1", Provider: "jwt"}
+	if caller, err = server.resolveBatchCaller(ctx, batch.OwnerOf("user-1"), jwtCaller); err != nil || caller.APIKey != "user-1" {
+		t.Fatalf("expected the caller verified at creation to be kept, got %+v, %v", caller, err)
+	}
+	if _, err = server.resolveBatchCaller(ctx, batch.OwnerOf("user-1"), batch.Caller{}); err == nil {
+		t.Fatalf("expected an unverifiable caller to be refused after a restart")
+	}
+
+	applyKeys("other-key")
+	if _, err = server.resolveBatchCaller(ctx, batch.OwnerOf("test-key"), batch.Caller{APIKey: "test-key", Provider: sdkconfig.DefaultAccessProviderName}); err == nil {
+		t.Fatalf("expected a removed key to be refused")
+	}
+}
@@ -0,0 +1,728 @@
+// Package batch implements asynchronous batch processing: uploaded JSONL files whose lines are
+// executed in the background by a small worker pool, with results written to output files.
+// Files and batch state live on disk so unfinished batches resume after a restart.
+//
+// The package is API agnostic; a Processor registered per batch kind validates and executes
+// the lines of its client API.
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultConcurrency    = 2
+	defaultYieldThreshold = 1
+	defaultMaxFileSizeMB  = 200
+	// CompletionWindow is the only supported completion window; unfinished requests expire after it.
+	CompletionWindow = 24 * time.Hour
+	// MaxRequests bounds the number of lines of one batch.
+	MaxRequests = 50000
+	// maxValidationErrors bounds the line errors reported for a rejected input file.
+	maxValidationErrors = 100
+	// maxLineSize bounds a single input or output line.
+	maxLineSize = 64 << 20
+
+	filesDir   = "files"
+	batchesDir = "batches"
+	metaExt    = ".json"
+	dataExt    = ".jsonl"
+	outputExt  = ".output.jsonl"
+	errorsExt  = ".errors.jsonl"
+)
+
+// Batch kinds, one per client API.
+const (
+	// KindOpenAI identifies batches created through the OpenAI Batch API.
+	KindOpenAI = "openai"
+	// KindClaude identifies batches created through the Anthropic Message Batches API.
+	KindClaude = "claude"
+)
+
+// Status is the lifecycle state of a batch.
+type Status string
+
+// Batch statuses, named after the OpenAI Batch API.
+const (
+	StatusValidating Status = "validating"
+	StatusFailed     Status = "failed"
+	StatusInProgress Status = "in_progress"
+	StatusCompleted  Status = "completed"
+	StatusExpired    Status = "expired"
+	StatusCancelling Status = "cancelling"
+	StatusCancelled  Status = "cancelled"
+)
+
+// Terminal reports whether no more work happens for a batch in status s.
+func (s Status) Terminal() bool {
+	switch s {
+	case StatusFailed, StatusCompleted, StatusExpired, StatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+var (
+	// ErrNotFound is returned for unknown files and batches, and for those of another client key.
+	ErrNotFound = errors.New("batch: not found")
+	// ErrDisabled is returned when files or batches are created before storage is configured.
+	ErrDisabled = errors.New("batch: disabled")
+	// ErrInvalid wraps errors caused by the request rather than the server.
+	ErrInvalid = errors.New("batch: invalid request")
+	// ErrFileTooLarge is returned when an upload exceeds the configured size limit.
+	ErrFileTooLarge = errors.New("batch: file too large")
+	// ErrUnauthorized fails a batch whose creator's client key is no longer accepted.
+	ErrUnauthorized = errors.New("the client key that created the batch is no longer valid")
+)
+
+// validID restricts file and batch ids to characters safe to use as file names.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// File is an uploaded input file or a batch result file.
+type File struct {
+	ID        string `json:"id"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	// Owner is a hash of the client API key that created the file; other keys cannot access it.
+	Owner string `json:"owner,omitempty"`
+	// Internal files back batches of APIs without a files endpoint and are not listed.
+	Internal bool `json:"internal,omitempty"`
+}
+
+// Caller identifies the client that created a batch; its requests run with the same client
+// key, so budgets, rate limits, access policies and usage apply to it. The key itself is only
+// kept in memory: the batch stores its hash as Owner, and the Resolver recovers the caller
+// from it after a restart.
+type Caller struct {
+	APIKey   string            `json:"-"`
+	Provider string            `json:"provider,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Resolver re-authenticates the creator of a batch before each of its requests runs. It
+// receives the batch owner and the caller as last resolved, whose APIKey is empty after a
+// restart, and returns the caller as currently authenticated, or an error when its client key
+// is no longer accepted.
+type Resolver func(ctx context.Context, owner string, caller Caller) (Caller, error)
+
+// CallerFromGin returns the caller authenticated on c.
+func CallerFromGin(c *gin.Context) Caller {
+	var caller Caller
+	if c == nil {
+		return caller
+	}
+	if v, ok := c.Get("apiKey"); ok {
+		caller.APIKey, _ = v.(string)
+	}
+	if v, ok := c.Get("accessProvider"); ok {
+		caller.Provider, _ = v.(string)
+	}
+	if v, ok := c.Get("accessMetadata"); ok {
+		caller.Metadata, _ = v.(map[string]string)
+	}
+	return caller
+}
+
+// Values returns the Gin context values that authenticate a background request as the caller.
+func (c Caller) Values() map[string]any {
+	values := make(map[string]any, 3)
+	if c.APIKey != "" {
+		values["apiKey"] = c.APIKey
+	}
+	if c.Provider != "" {
+		values["accessProvider"] = c.Provider
+	}
+	if c.Metadata != nil {
+		values["accessMetadata"] = c.Metadata
+	}
+	return values
+}
+
+// OwnerOf returns the owner value stored for a client API key.
+func OwnerOf(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// Counts tracks the requests of a batch.
+type Counts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// LineError describes an input line rejected during validation.
+type LineError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// Error implements error.
+func (e *LineError) Error() string { return e.Message }
+
+// Batch is the state of one batch. Timestamps are Unix seconds, zero when not reached.
+type Batch struct {
+	ID string `json:"id"`
+	// Kind selects the Processor executing the batch.
+	Kind             string            `json:"kind"`
+	Owner            string            `json:"owner,omitempty"`
+	Caller           Caller            `json:"caller"`
+	Endpoint         string            `json:"endpoint"`
+	InputFileID      string            `json:"input_file_id"`
+	CompletionWindow string            `json:"completion_window"`
+	Status           Status            `json:"status"`
+	Errors           []LineError       `json:"errors,omitempty"`
+	OutputFileID     string            `json:"output_file_id,omitempty"`
+	ErrorFileID      string            `json:"error_file_id,omitempty"`
+	Counts           Counts            `json:"request_counts"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	CreatedAt        int64             `json:"created_at"`
+	InProgressAt     int64             `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64             `json:"expires_at"`
+	FinalizingAt     int64             `json:"finalizing_at,omitempty"`
+	CompletedAt      int64             `json:"completed_at,omitempty"`
+	FailedAt         int64             `json:"failed_at,omitempty"`
+	ExpiredAt        int64             `json:"expired_at,omitempty"`
+	CancellingAt     int64             `json:"cancelling_at,omitempty"`
+	CancelledAt      int64             `json:"cancelled_at,omitempty"`
+}
+
+func (b *Batch) clone() *Batch {
+	cp := *b
+	cp.Errors = append([]LineError(nil), b.Errors...)
+	return &cp
+}
+
+// Result is the outcome of one batch request.
+type Result struct {
+	// Line is written to the output file, or to the error file when Failed is set.
+	Line   []byte
+	Failed bool
+	// RetryAfter asks for the request to run again after the delay, for example when the
+	// caller's rate limit was hit. The result is recorded once the retries are exhausted.
+	RetryAfter time.Duration
+}
+
+// Processor validates and executes the lines of one kind of batch.
+type Processor interface {
+	// Validate checks an input line and returns its custom_id.
+	Validate(b *Batch, line []byte) (string, *LineError)
+	// Execute runs one input line. The batch passed is a snapshot.
+	Execute(ctx context.Context, b *Batch, customID string, line []byte) Result
+	// Skip returns the line recorded in the error file for a request that was not executed
+	// because the batch ended with status, or nil to record nothing.
+	Skip(b *Batch, customID string, line []byte, status Status) []byte
+}
+
+var defaultManager = New()
+
+// Default returns the process-wide batch manager.
+func Default() *Manager { return defaultManager }
+
+// Manager stores files and batches and runs the worker pool executing them.
+type Manager struct {
+	mu         sync.Mutex
+	cfg        config.BatchConfig
+	dir        string
+	files      map[string]*File
+	batches    map[string]*Batch
+	processors map[string]Processor
+	load       func() int64
+	resolve    Resolver
+	now        func() time.Time
+	active     int
+	wake       chan struct{}
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// New constructs a manager without storage; Configure must be called before use.
+func New() *Manager {
+	return &Manager{
+		files:      make(map[string]*File),
+		batches:    make(map[string]*Batch),
+		processors: make(map[string]Processor),
+		now:        time.Now,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Configure applies cfg. defaultDir is used when cfg.Dir is empty; files and batches already
+// stored there are loaded when the directory changes.
+func (m *Manager) Configure(cfg config.BatchConfig, defaultDir string) error {
+	dir := strings.TrimSpace(cfg.Dir)
+	if dir == "" {
+		dir = defaultDir
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+	defer m.signal()
+	if dir == m.dir {
+		return nil
+	}
+	files, batches, err := loadState(dir)
+	if err != nil {
+		return err
+	}
+	m.dir = dir
+	m.files = files
+	m.batches = batches
+	return nil
+}
+
+// Enabled reports whether the batch APIs are served.
+func (m *Manager) Enabled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.cfg.Disabled && m.dir != ""
+}
+
+// SetLoad installs the function reporting the number of interactive requests in flight.
+func (m *Manager) SetLoad(load func() int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.load = load
+}
+
+// SetResolver installs the function re-authenticating the creator of a batch before each of
+// its requests runs. Without one, requests run as the caller recorded at creation.
+func (m *Manager) SetResolver(resolve Resolver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resolve = resolve
+}
+
+// Register installs the processor executing batches of kind.
+func (m *Manager) Register(kind string, p Processor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processors[kind] = p
+	m.signal()
+}
+
+func loadState(dir string) (map[string]*File, map[string]*Batch, error) {
+	files := make(map[string]*File)
+	batches := make(map[string]*Batch)
+	if dir == "" {
+		return files, batches, nil
+	}
+	if err := readMetadata(filepath.Join(dir, filesDir), func(data []byte) {
+		var f File
+		if json.Unmarshal(data, &f) == nil && validID.MatchString(f.ID) {
+			files[f.ID] = &f
+		}
+	}); err != nil {
+		return nil, nil, err
+	}
+	if err := readMetadata(filepath.Join(dir, batchesDir), func(data []byte) {
+		var b Batch
+		if json.Unmarshal(data, &b) == nil && validID.MatchString(b.ID) {
+			batches[b.ID] = &b
+		}
+	}); err != nil {
+		return nil, nil, err
+	}
+	return files, batches, nil
+}
+
+func readMetadata(dir string, fn func([]byte)) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("batch: read %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), metaExt) {
+			continue
+		}
+		data, errRead := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if errRead != nil {
+			log.Warnf("batch: skipping %s: %v", entry.Name(), errRead)
+			continue
+		}
+		fn(data)
+	}
+	return nil
+}
+
+func (m *Manager) fileDataPath(id string) string {
+	return filepath.Join(m.dir, filesDir, id+dataExt)
+}
+
+func (m *Manager) batchPath(id, ext string) string {
+	return filepath.Join(m.dir, batchesDir, id+ext)
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (m *Manager) saveFileLocked(f *File) error {
+	if err := writeJSON(filepath.Join(m.dir, filesDir, f.ID+metaExt), f); err != nil {
+		return fmt.Errorf("batch: write file %s: %w", f.ID, err)
+	}
+	return nil
+}
+
+func (m *Manager) saveBatchLocked(b *Batch) {
+	if err := writeJSON(m.batchPath(b.ID, metaExt), b); err != nil {
+		log.Errorf("batch: failed to persist batch %s: %v", b.ID, err)
+	}
+}
+
+func newID(prefix string) string {
+	return prefix + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// CreateFile stores the content of data as a new file described by f, whose ID, Bytes and
+// CreatedAt are assigned.
+func (m *Manager) CreateFile(f File, data io.Reader) (*File, error) {
+	m.mu.Lock()
+	dir := m.dir
+	limit := int64(m.cfg.MaxFileSizeMB)
+	m.mu.Unlock()
+	if dir == "" {
+		return nil, ErrDisabled
+	}
+	if limit <= 0 {
+		limit = defaultMaxFileSizeMB
+	}
+	limit <<= 20
+
+	f.ID = newID("file-")
+	f.CreatedAt = m.now().Unix()
+	path := filepath.Join(dir, filesDir, f.ID+dataExt)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("batch: create directory: %w", err)
+	}
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("batch: create file: %w", err)
+	}
+	n, err := io.Copy(out, io.LimitReader(data, limit+1))
+	if errClose := out.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil && n > limit {
+		err = fmt.Errorf("%w: the limit is %d MB", ErrFileTooLarge, limit>>20)
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+	f.Bytes = n
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err = m.saveFileLocked(&f); err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+	m.files[f.ID] = &f
+	cp := f
+	return &cp, nil
+}
+
+// ListFiles returns the listed files of owner, newest first. An empty purpose matches all.
+func (m *Manager) ListFiles(owner, purpose string) []File {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []File
+	for _, f := range m.files {
+		if f.Internal || f.Owner != owner || (purpose != "" && f.Purpose != purpose) {
+			continue
+		}
+		out = append(out, *f)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt != out[j].CreatedAt {
+			return out[i].CreatedAt > out[j].CreatedAt
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out
+}
+
+// GetFile returns the file id owned by owner.
+func (m *Manager) GetFile(id, owner string) (*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[id]
+	if !ok || f.Owner != owner {
+		return nil, ErrNotFound
+	}
+	cp := *f
+	return &cp, nil
+}
+
+// OpenFile returns the content of the file id owned by owner.
+func (m *Manager) OpenFile(id, owner string) (io.ReadCloser, *File, error) {
+	f, err := m.GetFile(id, owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.mu.Lock()
+	path := m.fileDataPath(id)
+	m.mu.Unlock()
+	data, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, fmt.Errorf("batch: open file: %w", err)
+	}
+	return data, f, nil
+}
+
+// DeleteFile removes the file id owned by owner.
+func (m *Manager) DeleteFile(id, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[id]
+	if !ok || f.Owner != owner {
+		return ErrNotFound
+	}
+	m.removeFileLocked(id)
+	return nil
+}
+
+func (m *Manager) removeFileLocked(id string) {
+	delete(m.files, id)
+	_ = os.Remove(filepath.Join(m.dir, filesDir, id+metaExt))
+	_ = os.Remove(m.fileDataPath(id))
+}
+
+// CreateBatch creates a batch over the input file b.InputFileID. b carries the kind, owner,
+// caller, endpoint and metadata; an ID is assigned unless set. Input lines are validated by
+// the processor of the kind: a file with invalid lines yields a failed batch listing the
+// errors, otherwise the batch is queued for execution.
+func (m *Manager) CreateBatch(b Batch) (*Batch, error) {
+	m.mu.Lock()
+	p := m.processors[b.Kind]
+	input, ok := m.files[b.InputFileID]
+	dir := m.dir
+	m.mu.Unlock()
+	switch {
+	case dir == "":
+		return nil, ErrDisabled
+	case p == nil:
+		return nil, fmt.Errorf("%w: unsupported batch kind %q", ErrInvalid, b.Kind)
+	case !ok || input.Owner != b.Owner:
+		return nil, fmt.Errorf("%w: no such file: %s", ErrInvalid, b.InputFileID)
+	case input.Purpose != "batch":
+		return nil, fmt.Errorf("%w: file %s was not uploaded with purpose 'batch'", ErrInvalid, b.InputFileID)
+	}
+
+	if b.ID == "" {
+		b.ID = newID("batch_")
+	}
+	now := m.now()
+	b.CreatedAt = now.Unix()
+	b.ExpiresAt = now.Add(CompletionWindow).Unix()
+	b.Status = StatusValidating
+	b.Counts = Counts{}
+	b.Errors = nil
+
+	seen := make(map[string]struct{})
+	errScan := m.scanInput(b.InputFileID, func(lineNo int, line []byte) error {
+		customID, lineErr := p.Validate(&b, line)
+		switch {
+		case lineErr == nil && b.Counts.Total >= MaxRequests:
+			lineErr = &LineError{Code: "too_many_requests", Message: fmt.Sprintf("A batch may contain at most %d requests.", MaxRequests)}
+		case lineErr == nil:
+			if _, dup := seen[customID]; dup {
+				lineErr = &LineError{Code: "duplicate_custom_id", Message: fmt.Sprintf("The custom_id '%s' is used more than once.", customID), Param: "custom_id"}
+			}
+		}
+		if lineErr != nil {
+			lineErr.Line = lineNo
+			b.Errors = append(b.Errors, *lineErr)
+			if len(b.Errors) >= maxValidationErrors {
+				return io.EOF
+			}
+			return nil
+		}
+		seen[customID] = struct{}{}
+		b.Counts.Total++
+		return nil
+	})
+	if errScan != nil {
+		return nil, errScan
+	}
+	if len(b.Errors) == 0 && b.Counts.Total == 0 {
+		b.Errors = append(b.Errors, LineError{Code: "empty_file", Message: "The input file does not contain any requests."})
+	}
+	if len(b.Errors) > 0 {
+		b.Status = StatusFailed
+		b.FailedAt = b.CreatedAt
+		b.Counts.Total = 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := b.clone()
+	m.batches[b.ID] = stored
+	m.saveBatchLocked(stored)
+	m.signal()
+	return stored.clone(), nil
+}
+
+// scanInput calls fn with every non-blank line of a file and its 1-based line number. fn may
+// return io.EOF to stop early.
+func (m *Manager) scanInput(fileID string, fn func(int, []byte) error) error {
+	m.mu.Lock()
+	path := m.fileDataPath(fileID)
+	m.mu.Unlock()
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("batch: open input file %s: %w", fileID, err)
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if errFn := fn(lineNo, line); errFn != nil {
+			if errors.Is(errFn, io.EOF) {
+				return nil
+			}
+			return errFn
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("batch: read input file %s: %w", fileID, err)
+	}
+	return nil
+}
+
+// ListBatches returns the batches of kind owned by owner, newest first.
+func (m *Manager) ListBatches(kind, owner string) []*Batch {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*Batch
+	for _, b := range m.batches {
+		if b.Kind == kind && b.Owner == owner {
+			out = append(out, b.clone())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt != out[j].CreatedAt {
+			return out[i].CreatedAt > out[j].CreatedAt
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out
+}
+
+// GetBatch returns the batch id of kind owned by owner.
+func (m *Manager) GetBatch(kind, id, owner string) (*Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.getBatchLocked(kind, id, owner)
+	if err != nil {
+		return nil, err
+	}
+	return b.clone(), nil
+}
+
+func (m *Manager) getBatchLocked(kind, id, owner string) (*Batch, error) {
+	b, ok := m.batches[id]
+	if !ok || b.Kind != kind || b.Owner != owner {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+// CancelBatch stops a pending or running batch. Requests already running finish; the others
+// are skipped. Finished batches are returned unchanged.
+func (m *Manager) CancelBatch(kind, id, owner string) (*Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.getBatchLocked(kind, id, owner)
+	if err != nil {
+		return nil, err
+	}
+	if b.Status == StatusValidating || b.Status == StatusInProgress {
+		b.Status = StatusCancelling
+		b.CancellingAt = m.now().Unix()
+		m.saveBatchLocked(b)
+		m.signal()
+	}
+	return b.clone(), nil
+}
+
+// DeleteBatch removes a finished batch together with its internal files.
+func (m *Manager) DeleteBatch(kind, id, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.getBatchLocked(kind, id, owner)
+	if err != nil {
+		return err
+	}
+	if !b.Status.Terminal() {
+		return fmt.Errorf("%w: batch %s is still processing", ErrInvalid, id)
+	}
+	for _, fileID := range []string{b.InputFileID, b.OutputFileID, b.ErrorFileID} {
+		if f, ok := m.files[fileID]; ok && f.Internal {
+			m.removeFileLocked(fileID)
+		}
+	}
+	delete(m.batches, id)
+	_ = os.Remove(m.batchPath(id, metaExt))
+	_ = os.Remove(m.batchPath(id, outputExt))
+	_ = os.Remove(m.batchPath(id, errorsExt))
+	return nil
+}
+
+// signal wakes the dispatcher without blocking.
+func (m *Manager) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
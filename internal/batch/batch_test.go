@@ -0,0 +1,273 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+type testProcessor struct {
+	mu       sync.Mutex
+	executed []string
+	// block, when set, holds every request until it is closed.
+	block chan struct{}
+}
+
+func (p *testProcessor) Validate(_ *Batch, line []byte) (string, *LineError) {
+	customID := gjson.GetBytes(line, "custom_id").String()
+	if customID == "" {
+		return "", &LineError{Code: "missing_custom_id", Message: "custom_id is required"}
+	}
+	return customID, nil
+}
+
+func (p *testProcessor) Execute(ctx context.Context, _ *Batch, customID string, _ []byte) Result {
+	if p.block != nil {
+		select {
+		case <-p.block:
+		case <-ctx.Done():
+			return Result{}
+		}
+	}
+	p.mu.Lock()
+	p.executed = append(p.executed, customID)
+	p.mu.Unlock()
+	return Result{Line: []byte(fmt.Sprintf(`{"custom_id":%q}`, customID)), Failed: customID == "bad"}
+}
+
+func (p *testProcessor) Skip(_ *Batch, customID string, _ []byte, status Status) []byte {
+	return []byte(fmt.Sprintf(`{"custom_id":%q,"skipped":%q}`, customID, status))
+}
+
+func newTestManager(t *testing.T, dir string, p Processor) *Manager {
+	t.Helper()
+	m := New()
+	if err := m.Configure(config.BatchConfig{Concurrency: 1}, dir); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	m.Register("test", p)
+	return m
+}
+
+func createTestBatch(t *testing.T, m *Manager, input string) *Batch {
+	t.Helper()
+	f, err := m.CreateFile(File{Filename: "in.jsonl", Purpose: "batch", Owner: OwnerOf("key")}, strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	b, err := m.CreateBatch(Batch{Kind: "test", Owner: OwnerOf("key"), InputFileID: f.ID})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	return b
+}
+
+func waitForStatus(t *testing.T, m *Manager, id string, want Status) *Batch {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, err := m.GetBatch("test", id, OwnerOf("key"))
+		if err != nil {
+			t.Fatalf("GetBatch: %v", err)
+		}
+		if b.Status == want {
+			return b
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch %s stuck in %s, want %s", id, b.Status, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readFile(t *testing.T, m *Manager, id string) string {
+	t.Helper()
+	r, _, err := m.OpenFile(id, OwnerOf("key"))
+	if err != nil {
+		t.Fatalf("OpenFile(%s): %v", id, err)
+	}
+	defer func() { _ = r.Close() }()
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestManager_ValidatesInput(t *testing.T) {
+	m := newTestManager(t, t.TempDir(), &testProcessor{})
+	b := createTestBatch(t, m, "{\"custom_id\":\"a\"}\n{\"custom_id\":\"a\"}\n{}\n")
+	if b.Status != StatusFailed || len(b.Errors) != 2 {
+		t.Fatalf("expected a failed batch with two errors, got %s %+v", b.Status, b.Errors)
+	}
+	if b.Errors[0].Code != "duplicate_custom_id" || b.Errors[0].Line != 2 || b.Errors[1].Line != 3 {
+		t.Fatalf("unexpected errors %+v", b.Errors)
+	}
+	if _, err := m.GetBatch("test", b.ID, OwnerOf("other")); err != ErrNotFound {
+		t.Fatalf("expected another key to be refused, got %v", err)
+	}
+}
+
+func TestManager_RunsResumesAndYields(t *testing.T) {
+	dir := t.TempDir()
+	p := &testProcessor{block: make(chan struct{})}
+	m := newTestManager(t, dir, p)
+	var load atomic.Int64
+	load.Store(1)
+	m.SetLoad(load.Load)
+	b := createTestBatch(t, m, "{\"custom_id\":\"a\"}\n{\"custom_id\":\"bad\"}\n{\"custom_id\":\"c\"}\n")
+	m.Start()
+	waitForStatus(t, m, b.ID, StatusInProgress)
+
+	// Interactive traffic holds the batch back.
+	time.Sleep(3 * pollInterval)
+	m.mu.Lock()
+	yielding := m.busyLocked() && m.active == 0
+	m.mu.Unlock()
+	if !yielding {
+		t.Fatalf("expected the batch to yield to interactive traffic")
+	}
+	load.Store(0)
+	close(p.block)
+	waitForStatus(t, m, b.ID, StatusCompleted)
+	m.Stop()
+
+	// Simulate a crash after the first request: the remaining ones run after a restart.
+	p2 := &testProcessor{}
+	reopened := newTestManager(t, dir, p2)
+	resumed := createTestBatch(t, reopened, "{\"custom_id\":\"a\"}\n{\"custom_id\":\"c\"}\n")
+	if err := os.WriteFile(reopened.batchPath(resumed.ID, outputExt), []byte(`{"custom_id":"a"}`+"\n"+`{"custom_id":"c","trunc`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	reopened.Start()
+	defer reopened.Stop()
+	done := waitForStatus(t, reopened, resumed.ID, StatusCompleted)
+	if got := strings.Join(p2.executed, ","); got != "c" {
+		t.Fatalf("expected only the unfinished request to run, got %s", got)
+	}
+	if done.Counts != (Counts{Total: 2, Completed: 2}) {
+		t.Fatalf("unexpected counts %+v", done.Counts)
+	}
+	if out := readFile(t, reopened, done.OutputFileID); out != "{\"custom_id\":\"a\"}\n{\"custom_id\":\"c\"}\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+
+	first, err := reopened.GetBatch("test", b.ID, OwnerOf("key"))
+	if err != nil {
+		t.Fatalf("expected the first batch to be loaded from disk: %v", err)
+	}
+	if first.Counts != (Counts{Total: 3, Completed: 2, Failed: 1}) || first.ErrorFileID == "" {
+		t.Fatalf("unexpected first batch %+v", first)
+	}
+	if errs := readFile(t, reopened, first.ErrorFileID); errs != "{\"custom_id\":\"bad\"}\n" {
+		t.Fatalf("unexpected error file %q", errs)
+	}
+}
+
+func TestManager_CancelSkipsRemainingRequests(t *testing.T) {
+	p := &testProcessor{block: make(chan struct{})}
+	m := newTestManager(t, t.TempDir(), p)
+	b := createTestBatch(t, m, "{\"custom_id\":\"a\"}\n{\"custom_id\":\"b\"}\n{\"custom_id\":\"c\"}\n")
+	m.Start()
+	defer m.Stop()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		m.mu.Lock()
+		running := m.active == 1
+		m.mu.Unlock()
+		if running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the first request never started")
+		}
+	}
+	if _, err := m.CancelBatch("test", b.ID, OwnerOf("key")); err != nil {
+		t.Fatalf("CancelBatch: %v", err)
+	}
+	close(p.block)
+	done := waitForStatus(t, m, b.ID, StatusCancelled)
+	if done.Counts.Completed != 1 || done.CancelledAt == 0 {
+		t.Fatalf("expected the running request to finish, got %+v", done)
+	}
+	if errs := readFile(t, m, done.ErrorFileID); errs != "{\"custom_id\":\"b\",\"skipped\":\"cancelled\"}\n{\"custom_id\":\"c\",\"skipped\":\"cancelled\"}\n" {
+		t.Fatalf("unexpected error file %q", errs)
+	}
+}
+
+func TestManager_DeleteBatchRemovesInternalFiles(t *testing.T) {
+	p := &testProcessor{block: make(chan struct{})}
+	m := newTestManager(t, t.TempDir(), p)
+	f, err := m.CreateFile(File{Purpose: "batch", Owner: OwnerOf("key"), Internal: true}, strings.NewReader("{\"custom_id\":\"a\"}\n"))
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	if files := m.ListFiles(OwnerOf("key"), ""); len(files) != 0 {
+		t.Fatalf("expected internal files to be unlisted, got %+v", files)
+	}
+	b, err := m.CreateBatch(Batch{Kind: "test", Owner: OwnerOf("key"), InputFileID: f.ID})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	if err = m.DeleteBatch("test", b.ID, OwnerOf("key")); err == nil {
+		t.Fatalf("expected an unfinished batch to be kept")
+	}
+	close(p.block)
+	m.Start()
+	defer m.Stop()
+	done := waitForStatus(t, m, b.ID, StatusCompleted)
+	if err = m.DeleteBatch("test", b.ID, OwnerOf("key")); err != nil {
+		t.Fatalf("DeleteBatch: %v", err)
+	}
+	for _, id := range []string{f.ID, done.OutputFileID} {
+		if _, err = m.GetFile(id, OwnerOf("key")); err != ErrNotFound {
+			t.Fatalf("expected file %s to be removed, got %v", id, err)
+		}
+	}
+}
+
+func TestManager_FailsBatchWhenCallerIsRevoked(t *testing.T) {
+	dir := t.TempDir()
+	p := &testProcessor{}
+	m := newTestManager(t, dir, p)
+	f, err := m.CreateFile(File{Purpose: "batch", Owner: OwnerOf("key")}, strings.NewReader("{\"custom_id\":\"a\"}\n{\"custom_id\":\"b\"}\n"))
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	b, err := m.CreateBatch(Batch{Kind: "test", Owner: OwnerOf("key"), Caller: Caller{APIKey: "key"}, InputFileID: f.ID})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	data, err := os.ReadFile(m.batchPath(b.ID, metaExt))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if strings.Contains(string(data), `"key"`) {
+		t.Fatalf("expected the client key not to be persisted, got %s", data)
+	}
+
+	var resolved atomic.Int64
+	m.SetResolver(func(_ context.Context, owner string, caller Caller) (Caller, error) {
+		if owner != OwnerOf("key") {
+			t.Errorf("unexpected owner %q", owner)
+		}
+		if resolved.Add(1) > 1 {
+			return Caller{}, fmt.Errorf("key revoked")
+		}
+		return caller, nil
+	})
+	m.Start()
+	defer m.Stop()
+	done := waitForStatus(t, m, b.ID, StatusFailed)
+	if len(done.Errors) != 1 || done.Errors[0].Code != "invalid_api_key" {
+		t.Fatalf("expected an invalid_api_key error, got %+v", done.Errors)
+	}
+	if got := strings.Join(p.executed, ","); got != "a" {
+		t.Fatalf("expected only the request before the revocation to run, got %s", got)
+	}
+}
@@ -0,0 +1,424 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// pollInterval spaces the checks of a paused dispatcher.
+	pollInterval = 250 * time.Millisecond
+	// maxAttempts bounds how often a request asking for a retry is executed.
+	maxAttempts = 5
+)
+
+// errStopped reports that the manager is shutting down; the batch resumes on the next start.
+var errStopped = errors.New("batch: stopped")
+
+// Start launches the dispatcher executing queued batches. It is a no-op when already running.
+func (m *Manager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	go m.loop(ctx, m.done)
+}
+
+// Stop interrupts the dispatcher and waits for it to exit. Requests interrupted by the stop
+// are not recorded and run again when the batch resumes.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+func (m *Manager) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	for {
+		if b, p := m.next(); b != nil {
+			m.run(ctx, b, p)
+		} else {
+			select {
+			case <-ctx.Done():
+			case <-m.wake:
+			case <-time.After(time.Minute):
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// next returns the oldest unfinished batch that has a processor.
+func (m *Manager) next() (*Batch, Processor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cfg.Disabled {
+		return nil, nil
+	}
+	var next *Batch
+	for _, b := range m.batches {
+		if b.Status.Terminal() || m.processors[b.Kind] == nil {
+			continue
+		}
+		if next == nil || b.CreatedAt < next.CreatedAt || (b.CreatedAt == next.CreatedAt && b.ID < next.ID) {
+			next = b
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	return next, m.processors[next.Kind]
+}
+
+// run executes the remaining requests of b and finalizes it.
+func (m *Manager) run(ctx context.Context, b *Batch, p Processor) {
+	m.mu.Lock()
+	if b.Status == StatusValidating {
+		b.Status = StatusInProgress
+		b.InProgressAt = m.now().Unix()
+		m.saveBatchLocked(b)
+	}
+	snapshot := b.clone()
+	m.mu.Unlock()
+
+	finished, err := m.recover(b)
+	if err != nil {
+		m.fail(b, err)
+		return
+	}
+	outcome := StatusCompleted
+	var wg sync.WaitGroup
+	errScan := m.scanInput(b.InputFileID, func(_ int, line []byte) error {
+		customID, lineErr := p.Validate(snapshot, line)
+		if lineErr != nil || finished[customID] {
+			return nil
+		}
+		status, errAcquire := m.acquire(ctx, b)
+		if errAcquire != nil {
+			return errAcquire
+		}
+		if status != "" {
+			outcome = status
+			return io.EOF
+		}
+		if errResolve := m.resolveCaller(ctx, b); errResolve != nil {
+			m.release()
+			return errResolve
+		}
+		line = bytes.Clone(line)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer m.release()
+			m.execute(ctx, b, p, customID, line)
+		}()
+		return nil
+	})
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+	if errScan != nil {
+		m.fail(b, errScan)
+		return
+	}
+
+	m.mu.Lock()
+	if b.Status == StatusCancelling {
+		outcome = StatusCancelled
+	}
+	snapshot = b.clone()
+	m.mu.Unlock()
+	if outcome != StatusCompleted {
+		if finished, err = m.recover(b); err == nil {
+			err = m.scanInput(b.InputFileID, func(_ int, line []byte) error {
+				customID, lineErr := p.Validate(snapshot, line)
+				if lineErr != nil || finished[customID] {
+					return nil
+				}
+				if skipped := p.Skip(snapshot, customID, line, outcome); skipped != nil {
+					return m.appendLine(b.ID, errorsExt, skipped)
+				}
+				return nil
+			})
+		}
+		if err != nil {
+			m.fail(b, err)
+			return
+		}
+	}
+	m.finalize(b, outcome)
+}
+
+// acquire waits for a worker slot. It returns the final status when the batch must stop
+// instead, or errStopped when the manager shuts down.
+func (m *Manager) acquire(ctx context.Context, b *Batch) (Status, error) {
+	for {
+		if ctx.Err() != nil {
+			return "", errStopped
+		}
+		m.mu.Lock()
+		status := b.Status
+		expired := m.now().Unix() >= b.ExpiresAt
+		ready := !m.cfg.Disabled && m.active < m.concurrencyLocked() && !m.busyLocked()
+		if status == StatusInProgress && !expired && ready {
+			m.active++
+			m.mu.Unlock()
+			return "", nil
+		}
+		m.mu.Unlock()
+		switch {
+		case status == StatusCancelling:
+			return StatusCancelled, nil
+		case expired:
+			return StatusExpired, nil
+		}
+		select {
+		case <-ctx.Done():
+		case <-m.wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// resolveCaller re-authenticates the creator of b before one of its requests runs, so a client
+// key removed or revoked since the batch was created stops it.
+func (m *Manager) resolveCaller(ctx context.Context, b *Batch) error {
+	m.mu.Lock()
+	resolve, owner, caller := m.resolve, b.Owner, b.Caller
+	m.mu.Unlock()
+	if resolve == nil {
+		return nil
+	}
+	resolved, err := resolve(ctx, owner, caller)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+	m.mu.Lock()
+	b.Caller = resolved
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Manager) release() {
+	m.mu.Lock()
+	m.active--
+	m.mu.Unlock()
+	m.signal()
+}
+
+func (m *Manager) concurrencyLocked() int {
+	if m.cfg.Concurrency > 0 {
+		return m.cfg.Concurrency
+	}
+	return defaultConcurrency
+}
+
+// busyLocked reports whether interactive traffic currently pauses batch work.
+func (m *Manager) busyLocked() bool {
+	threshold := m.cfg.YieldThreshold
+	if threshold == 0 {
+		threshold = defaultYieldThreshold
+	}
+	return threshold > 0 && m.load != nil && m.load() >= int64(threshold)
+}
+
+// execute runs one request, retrying while the processor asks for it, and records the result.
+func (m *Manager) execute(ctx context.Context, b *Batch, p Processor, customID string, line []byte) {
+	for attempt := 1; ; attempt++ {
+		m.mu.Lock()
+		snapshot := b.clone()
+		m.mu.Unlock()
+		res := p.Execute(ctx, snapshot, customID, line)
+		if ctx.Err() != nil {
+			return
+		}
+		if res.RetryAfter > 0 && attempt < maxAttempts && snapshot.Status == StatusInProgress {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(res.RetryAfter):
+			}
+			continue
+		}
+		m.record(b, res)
+		return
+	}
+}
+
+func (m *Manager) record(b *Batch, res Result) {
+	ext := outputExt
+	if res.Failed {
+		ext = errorsExt
+	}
+	if err := m.appendLine(b.ID, ext, res.Line); err != nil {
+		log.Errorf("batch: failed to record a result of %s: %v", b.ID, err)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if res.Failed {
+		b.Counts.Failed++
+	} else {
+		b.Counts.Completed++
+	}
+	m.saveBatchLocked(b)
+}
+
+func (m *Manager) appendLine(batchID, ext string, line []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path := m.batchPath(batchID, ext)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(bytes.Clone(bytes.TrimSpace(line)), '\n'))
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+// recover returns the custom_ids already recorded for b. A line cut short by a crash is
+// dropped so its request runs again; the counts of a running batch are rebuilt from the files.
+func (m *Manager) recover(b *Batch) (map[string]bool, error) {
+	finished := make(map[string]bool)
+	var counts [2]int
+	for i, ext := range []string{outputExt, errorsExt} {
+		m.mu.Lock()
+		path := m.batchPath(b.ID, ext)
+		m.mu.Unlock()
+		n, err := recoverResults(path, finished)
+		if err != nil {
+			return nil, err
+		}
+		counts[i] = n
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if b.Status == StatusInProgress {
+		b.Counts.Completed, b.Counts.Failed = counts[0], counts[1]
+	}
+	return finished, nil
+}
+
+func recoverResults(path string, finished map[string]bool) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("batch: read results: %w", err)
+	}
+	if end := bytes.LastIndexByte(data, '\n') + 1; end < len(data) {
+		data = data[:end]
+		if err = os.Truncate(path, int64(end)); err != nil {
+			return 0, fmt.Errorf("batch: repair results: %w", err)
+		}
+	}
+	n := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	for scanner.Scan() {
+		if customID := gjson.GetBytes(scanner.Bytes(), "custom_id").String(); customID != "" {
+			finished[customID] = true
+			n++
+		}
+	}
+	return n, scanner.Err()
+}
+
+// finalize publishes the result files of b and moves it to status.
+func (m *Manager) finalize(b *Batch, status Status) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now().Unix()
+	b.FinalizingAt = now
+	m.publishResultsLocked(b)
+	b.Status = status
+	switch status {
+	case StatusCancelled:
+		b.CancelledAt = now
+	case StatusExpired:
+		b.ExpiredAt = now
+	default:
+		b.CompletedAt = now
+	}
+	m.saveBatchLocked(b)
+}
+
+func (m *Manager) publishResultsLocked(b *Batch) {
+	internal := false
+	if input, ok := m.files[b.InputFileID]; ok {
+		internal = input.Internal
+	}
+	b.OutputFileID = m.publishLocked(b, outputExt, internal)
+	b.ErrorFileID = m.publishLocked(b, errorsExt, internal)
+}
+
+// publishLocked turns a non-empty result file of b into a file owned by its creator.
+func (m *Manager) publishLocked(b *Batch, ext string, internal bool) string {
+	path := m.batchPath(b.ID, ext)
+	info, err := os.Stat(path)
+	if err != nil || info.Size() == 0 {
+		return ""
+	}
+	f := &File{
+		ID:        newID("file-"),
+		Bytes:     info.Size(),
+		CreatedAt: m.now().Unix(),
+		Filename:  b.ID + ext,
+		Purpose:   "batch_output",
+		Owner:     b.Owner,
+		Internal:  internal,
+	}
+	if err = os.Rename(path, m.fileDataPath(f.ID)); err != nil {
+		log.Errorf("batch: failed to publish results of %s: %v", b.ID, err)
+		return ""
+	}
+	if err = m.saveFileLocked(f); err != nil {
+		log.Error(err)
+		return ""
+	}
+	m.files[f.ID] = f
+	return f.ID
+}
+
+// fail ends b after an error unrelated to its requests, publishing the results recorded so far.
+func (m *Manager) fail(b *Batch, err error) {
+	log.Errorf("batch: %s failed: %v", b.ID, err)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publishResultsLocked(b)
+	b.Status = StatusFailed
+	b.FailedAt = m.now().Unix()
+	code := "server_error"
+	if errors.Is(err, ErrUnauthorized) {
+		code = "invalid_api_key"
+	}
+	b.Errors = append(b.Errors, LineError{Code: code, Message: err.Error()})
+	m.saveBatchLocked(b)
+}
@@ -0,0 +1,388 @@
+// Package budget tracks per-client-key token consumption and cost over daily, weekly and
+// monthly periods and checks it against the configured soft and hard budgets. Keys are stored
+// as SHA-256 hashes so the persisted state does not hold client secrets.
+package budget
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// saveDelay batches state writes triggered by usage records.
+const saveDelay = 5 * time.Second
+
+// Periods lists the tracked budget periods.
+var Periods = []string{config.BudgetPeriodDaily, config.BudgetPeriodWeekly, config.BudgetPeriodMonthly}
+
+var defaultTracker = NewTracker()
+
+func init() {
+	coreusage.RegisterPlugin(usagePlugin{tracker: defaultTracker})
+}
+
+// Default returns the process-wide tracker shared by the API handlers.
+func Default() *Tracker { return defaultTracker }
+
+// PeriodUsage is the consumption of one key during the current period. Cost is expressed in
+// the currency of the pricing table.
+type PeriodUsage struct {
+	Start  time.Time `json:"start"`
+	Tokens int64     `json:"tokens"`
+	Cost   float64   `json:"cost,omitempty"`
+}
+
+// Status describes a budget that a key has reached.
+type Status struct {
+	Period string
+	// Currency is set for cost budgets and empty for token budgets.
+	Currency string
+	Used     float64
+	Limit    float64
+	ResetsAt time.Time
+}
+
+func (s Status) describe() string {
+	if s.Currency != "" {
+		return fmt.Sprintf("%s cost budget: %.2f of %.2f %s used", s.Period, s.Used, s.Limit, s.Currency)
+	}
+	return fmt.Sprintf("%s token budget: %d of %d used", s.Period, int64(s.Used), int64(s.Limit))
+}
+
+// Warning formats a soft-limit status for a response header.
+func (s Status) Warning() string {
+	return fmt.Sprintf("%s, resets %s", s.describe(), s.ResetsAt.Format(time.RFC3339))
+}
+
+// Exceeded is returned when a hard budget has been reached.
+type Exceeded struct {
+	Status
+}
+
+// Error implements error.
+func (e *Exceeded) Error() string {
+	return fmt.Sprintf("%s exceeded for this API key, resets %s", e.describe(), e.ResetsAt.Format(time.RFC3339))
+}
+
+// KeyHash returns the identifier the tracker stores for a client API key.
+func KeyHash(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// isKeyHash reports whether key already is a KeyHash value.
+func isKeyHash(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+// Tracker accumulates token usage per client key and period.
+type Tracker struct {
+	mu        sync.Mutex
+	saveMu    sync.Mutex
+	usage     map[string]map[string]*PeriodUsage
+	now       func() time.Time
+	path      string
+	dirty     bool
+	saveTimer *time.Timer
+}
+
+// NewTracker constructs an empty in-memory tracker.
+func NewTracker() *Tracker {
+	return &Tracker{usage: make(map[string]map[string]*PeriodUsage), now: time.Now}
+}
+
+// PeriodStart returns the start of the period containing t. Periods are aligned to UTC.
+func PeriodStart(period string, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case config.BudgetPeriodWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case config.BudgetPeriodMonthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// PeriodEnd returns the end of the period starting at start.
+func PeriodEnd(period string, start time.Time) time.Time {
+	switch period {
+	case config.BudgetPeriodWeekly:
+		return start.AddDate(0, 0, 7)
+	case config.BudgetPeriodMonthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// current returns the usage of key for period, resetting it when the period has rolled over.
+// The caller must hold t.mu.
+func (t *Tracker) current(key, period string, now time.Time, create bool) *PeriodUsage {
+	periods := t.usage[key]
+	if periods == nil {
+		if !create {
+			return nil
+		}
+		periods = make(map[string]*PeriodUsage, len(Periods))
+		t.usage[key] = periods
+	}
+	start := PeriodStart(period, now)
+	usage := periods[period]
+	if usage == nil {
+		if !create {
+			return nil
+		}
+		usage = &PeriodUsage{Start: start}
+		periods[period] = usage
+	}
+	if !usage.Start.Equal(start) {
+		usage.Start = start
+		usage.Tokens = 0
+		usage.Cost = 0
+	}
+	return usage
+}
+
+// Check evaluates the budgets of key. It returns the soft budgets that have been reached and,
+// when a hard budget has been reached, the corresponding Exceeded error. Cost budgets are
+// expressed in the currency of the pricing table.
+func (t *Tracker) Check(key string, budgets []config.ClientBudget) ([]Status, *Exceeded) {
+	if t == nil || key == "" || len(budgets) == 0 {
+		return nil, nil
+	}
+	currency := pricing.Default().Currency()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	hash := KeyHash(key)
+	var warnings []Status
+	for _, budget := range budgets {
+		var used PeriodUsage
+		start := PeriodStart(budget.Period, now)
+		if usage := t.current(hash, budget.Period, now, false); usage != nil {
+			used = *usage
+		}
+		tokens := Status{Period: budget.Period, Used: float64(used.Tokens), ResetsAt: PeriodEnd(budget.Period, start)}
+		cost := Status{Period: budget.Period, Currency: currency, Used: used.Cost, ResetsAt: tokens.ResetsAt}
+		switch {
+		case budget.HardTokens > 0 && used.Tokens >= budget.HardTokens:
+			tokens.Limit = float64(budget.HardTokens)
+			return warnings, &Exceeded{Status: tokens}
+		case budget.HardCost > 0 && used.Cost >= budget.HardCost:
+			cost.Limit = budget.HardCost
+			return warnings, &Exceeded{Status: cost}
+		}
+		if budget.SoftTokens > 0 && used.Tokens >= budget.SoftTokens {
+			tokens.Limit = float64(budget.SoftTokens)
+			warnings = append(warnings, tokens)
+		}
+		if budget.SoftCost > 0 && used.Cost >= budget.SoftCost {
+			cost.Limit = budget.SoftCost
+			warnings = append(warnings, cost)
+		}
+	}
+	return warnings, nil
+}
+
+// Record adds the tokens and cost consumed by key to every period.
+func (t *Tracker) Record(key string, tokens int64, cost float64) {
+	if t == nil || key == "" || (tokens <= 0 && cost <= 0) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	hash := KeyHash(key)
+	for _, period := range Periods {
+		usage := t.current(hash, period, now, true)
+		usage.Tokens += max(tokens, 0)
+		usage.Cost += max(cost, 0)
+	}
+	t.scheduleSaveLocked()
+}
+
+// Usage returns the current-period usage of key.
+func (t *Tracker) Usage(key string) map[string]PeriodUsage {
+	return t.UsageByHash(KeyHash(key))
+}
+
+// UsageByHash returns the current-period usage of the key with the given KeyHash.
+func (t *Tracker) UsageByHash(hash string) map[string]PeriodUsage {
+	out := make(map[string]PeriodUsage, len(Periods))
+	if t == nil {
+		return out
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for _, period := range Periods {
+		usage := PeriodUsage{Start: PeriodStart(period, now)}
+		if current := t.current(hash, period, now, false); current != nil {
+			usage = *current
+		}
+		out[period] = usage
+	}
+	return out
+}
+
+// Keys returns the KeyHash of every client key with recorded usage, sorted.
+func (t *Tracker) Keys() []string {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := make([]string, 0, len(t.usage))
+	for key := range t.usage {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Reset clears the usage of key for period, or for every period when period is empty. The key
+// may be given in plain text or as its KeyHash.
+func (t *Tracker) Reset(key, period string) {
+	if t == nil || key == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	hash := key
+	if _, known := t.usage[hash]; !known {
+		hash = KeyHash(key)
+	}
+	periods := t.usage[hash]
+	if periods == nil {
+		return
+	}
+	if period == "" {
+		delete(t.usage, hash)
+	} else {
+		delete(periods, period)
+	}
+	t.scheduleSaveLocked()
+}
+
+// Load reads persisted state from path and persists subsequent changes there. A missing file
+// starts with empty state.
+func (t *Tracker) Load(path string) error {
+	if t == nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("budget: read state: %w", err)
+	}
+	state := make(map[string]map[string]*PeriodUsage)
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("budget: decode state: %w", err)
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.path = path
+	for key, periods := range state {
+		if key == "" || len(periods) == 0 {
+			continue
+		}
+		if !isKeyHash(key) {
+			// State written before keys were hashed.
+			key = KeyHash(key)
+			t.dirty = true
+		}
+		t.usage[key] = periods
+	}
+	return nil
+}
+
+// Save writes the current state to the path given to Load when it has changed.
+func (t *Tracker) Save() error {
+	if t == nil {
+		return nil
+	}
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+	t.mu.Lock()
+	if t.saveTimer != nil {
+		t.saveTimer.Stop()
+		t.saveTimer = nil
+	}
+	path := t.path
+	if path == "" || !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	data, err := json.MarshalIndent(t.usage, "", "  ")
+	t.dirty = false
+	t.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("budget: encode state: %w", err)
+	}
+	tmp := path + ".tmp"
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err == nil {
+		err = os.WriteFile(tmp, data, 0o600)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+		return fmt.Errorf("budget: write state: %w", err)
+	}
+	return nil
+}
+
+// scheduleSaveLocked arranges for the state to be written shortly. The caller must hold t.mu.
+func (t *Tracker) scheduleSaveLocked() {
+	t.dirty = true
+	if t.path == "" || t.saveTimer != nil {
+		return
+	}
+	t.saveTimer = time.AfterFunc(saveDelay, func() {
+		if err := t.Save(); err != nil {
+			log.Warnf("failed to persist budget state: %v", err)
+		}
+	})
+}
+
+// usagePlugin feeds reported token usage into the tracker.
+type usagePlugin struct {
+	tracker *Tracker
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (p usagePlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	p.tracker.Record(record.APIKey, tokens, pricing.Default().Cost(record))
+}
@@ -0,0 +1,114 @@
+package budget
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestTracker_SoftAndHardBudgets(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	tracker := NewTracker()
+	tracker.now = func() time.Time { return now }
+	budgets := []config.ClientBudget{{Period: config.BudgetPeriodDaily, SoftTokens: 100, HardTokens: 200}}
+
+	tracker.Record("k", 150, 0)
+	warnings, exceeded := tracker.Check("k", budgets)
+	if exceeded != nil || len(warnings) != 1 || warnings[0].Used != 150 {
+		t.Fatalf("expected a soft warning, got %+v %+v", warnings, exceeded)
+	}
+
+	tracker.Record("k", 50, 0)
+	if _, exceeded = tracker.Check("k", budgets); exceeded == nil {
+		t.Fatalf("expected the hard budget to be exceeded")
+	}
+	if want := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC); !exceeded.ResetsAt.Equal(want) {
+		t.Fatalf("expected reset at %s, got %s", want, exceeded.ResetsAt)
+	}
+
+	now = now.Add(24 * time.Hour)
+	if warnings, exceeded = tracker.Check("k", budgets); exceeded != nil || len(warnings) != 0 {
+		t.Fatalf("expected the daily budget to reset, got %+v %+v", warnings, exceeded)
+	}
+	if got := tracker.Usage("k")[config.BudgetPeriodMonthly].Tokens; got != 200 {
+		t.Fatalf("expected monthly usage to carry over, got %d", got)
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	at := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC) // Wednesday
+	if got := PeriodStart(config.BudgetPeriodWeekly, at); !got.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected week start %s", got)
+	}
+	if got := PeriodStart(config.BudgetPeriodMonthly, at); !got.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected month start %s", got)
+	}
+}
+
+func TestTracker_PersistsState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget-state.json")
+	tracker := NewTracker()
+	if err := tracker.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	tracker.Record("k", 42, 1.5)
+	if err := tracker.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	restored := NewTracker()
+	if err := restored.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := restored.Usage("k")[config.BudgetPeriodDaily]; got.Tokens != 42 || got.Cost != 1.5 {
+		t.Fatalf("expected restored usage 42 tokens and 1.5 cost, got %+v", got)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if strings.Contains(string(data), `"k"`) || !strings.Contains(string(data), KeyHash("k")) {
+		t.Fatalf("expected the state to be keyed by the key hash, got %s", data)
+	}
+}
+
+func TestTracker_CostBudgets(t *testing.T) {
+	tracker := NewTracker()
+	budgets := []config.ClientBudget{{Period: config.BudgetPeriodMonthly, SoftCost: 5, HardCost: 10}}
+
+	tracker.Record("k", 1000, 6)
+	warnings, exceeded := tracker.Check("k", budgets)
+	if exceeded != nil || len(warnings) != 1 || warnings[0].Currency == "" || warnings[0].Limit != 5 {
+		t.Fatalf("expected a soft cost warning, got %+v %+v", warnings, exceeded)
+	}
+	tracker.Record("k", 0, 4)
+	if _, exceeded = tracker.Check("k", budgets); exceeded == nil || exceeded.Limit != 10 {
+		t.Fatalf("expected the hard cost budget to be exceeded, got %+v", exceeded)
+	}
+	if !strings.Contains(exceeded.Error(), "cost budget") {
+		t.Fatalf("unexpected error %q", exceeded.Error())
+	}
+}
+
+func TestTracker_LoadHashesPlainKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget-state.json")
+	legacy := `{"plain-key":{"daily":{"start":"2026-03-04T00:00:00Z","tokens":7}}}`
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	tracker := NewTracker()
+	tracker.now = func() time.Time { return time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC) }
+	if err := tracker.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if keys := tracker.Keys(); len(keys) != 1 || keys[0] != KeyHash("plain-key") {
+		t.Fatalf("expected the legacy key to be hashed, got %v", keys)
+	}
+	if got := tracker.Usage("plain-key")[config.BudgetPeriodDaily].Tokens; got != 7 {
+		t.Fatalf("expected legacy usage 7, got %d", got)
+	}
+}
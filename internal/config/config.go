@@ -52,6 +52,18 @@ type Config struct {
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// UsageStorage persists usage statistics and bounds how long request details are kept.
+	UsageStorage UsageStorageConfig `yaml:"usage-storage,omitempty" json:"usage-storage,omitempty"`
+
+	// Pricing holds the model price table used to compute the cost of every request.
+	Pricing PricingConfig `yaml:"pricing,omitempty" json:"pricing,omitempty"`
+
+	// ResponsesStore keeps Responses API results so previous_response_id works with every backend.
+	ResponsesStore ResponsesStoreConfig `yaml:"responses-store,omitempty" json:"responses-store,omitempty"`
+
+	// Batch configures the asynchronous batch APIs and the workers executing them.
+	Batch BatchConfig `yaml:"batch,omitempty" json:"batch,omitempty"`
+
 	// DisableCooling disables quota cooldown scheduling when true.
 	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
 
@@ -66,6 +78,25 @@ type Config struct {
 	// Routing controls credential selection behavior.
 	Routing RoutingConfig `yaml:"routing" json:"routing"`
 
+	// ModelFallbacks maps a requested model to an ordered list of fallback models that are tried
+	// when every credential for the requested model fails or is cooling down.
+	ModelFallbacks map[string][]string `yaml:"model-fallbacks,omitempty" json:"model-fallbacks,omitempty"`
+
+	// CircuitBreaker configures the per credential and model circuit breaker for transient failures.
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit-breaker,omitempty" json:"circuit-breaker,omitempty"`
+
+	// Hedging configures hedged non-streaming requests for latency-sensitive models.
+	Hedging HedgingConfig `yaml:"hedging,omitempty" json:"hedging,omitempty"`
+
+	// HealthProbe configures background credential health probes.
+	HealthProbe HealthProbeConfig `yaml:"health-probe,omitempty" json:"health-probe,omitempty"`
+
+	// Metrics configures the Prometheus metrics endpoint.
+	Metrics MetricsConfig `yaml:"metrics,omitempty" json:"metrics,omitempty"`
+
+	// Tracing configures OpenTelemetry tracing.
+	Tracing TracingConfig `yaml:"tracing,omitempty" json:"tracing,omitempty"`
+
 	// WebsocketAuth enables or disables authentication for the WebSocket API.
 	WebsocketAuth bool `yaml:"ws-auth" json:"ws-auth"`
 
@@ -133,8 +164,244 @@ type QuotaExceeded struct {
 // RoutingConfig configures how credentials are selected for requests.
 type RoutingConfig struct {
 	// Strategy selects the credential selection strategy.
-	// Supported values: "round-robin" (default), "fill-first".
+	// Supported values: "round-robin" (default), "fill-first", "weighted", "latency".
 	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// SessionAffinity pins a conversation to the credential that served it first.
+	SessionAffinity SessionAffinityConfig `yaml:"session-affinity,omitempty" json:"session-affinity,omitempty"`
+
+	// QueueTimeoutSeconds bounds how long a request waits for a free slot when every eligible
+	// credential is at its max-concurrency. Values <= 0 use the default of 30 seconds.
+	QueueTimeoutSeconds int `yaml:"queue-timeout-seconds,omitempty" json:"queue-timeout-seconds,omitempty"`
+}
+
+// SessionAffinityConfig configures sticky conversation-to-credential routing.
+// Conversations are identified by the X-Session-Id header, the Claude metadata.user_id
+// field, or a hash of the system prompt and first message.
+type SessionAffinityConfig struct {
+	// Enabled toggles session affinity.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// TTLSeconds controls how long an idle conversation stays bound to its credential.
+	// Values <= 0 use the default of 3600 seconds.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+}
+
+// CircuitBreakerConfig configures the circuit breaker applied per credential and model.
+// Network errors, timeouts and 5xx responses count as failures; once the breaker opens the
+// model is skipped on that credential until a single half-open probe succeeds.
+type CircuitBreakerConfig struct {
+	// Enabled toggles the circuit breaker. When disabled, transient failures use a fixed 1 minute cooldown.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// FailureThreshold opens the circuit after this many consecutive failures (default 5).
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure-threshold,omitempty"`
+
+	// ErrorRateThreshold opens the circuit when the failure ratio within the window reaches
+	// this value (0-1). Zero disables the error-rate trigger.
+	ErrorRateThreshold float64 `yaml:"error-rate-threshold,omitempty" json:"error-rate-threshold,omitempty"`
+
+	// MinRequests is the minimum number of requests in the window before the error rate applies (default 10).
+	MinRequests int `yaml:"min-requests,omitempty" json:"min-requests,omitempty"`
+
+	// WindowSeconds is the error-rate observation window (default 60).
+	WindowSeconds int `yaml:"window-seconds,omitempty" json:"window-seconds,omitempty"`
+
+	// OpenSeconds is how long the circuit stays open before a half-open probe (default 30).
+	OpenSeconds int `yaml:"open-seconds,omitempty" json:"open-seconds,omitempty"`
+}
+
+// HedgingConfig configures hedged non-streaming requests. When the first attempt for a matching
+// model has not answered within the observed latency percentile, a second attempt is sent to
+// another credential (or provider) and the first response wins; the slower attempt is cancelled.
+type HedgingConfig struct {
+	// Enabled toggles hedged requests.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Models lists model names or wildcard patterns (e.g., "gemini-*-flash") that opt into hedging.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Percentile of recent completion times used as the hedge delay (0-1, default 0.95).
+	Percentile float64 `yaml:"percentile,omitempty" json:"percentile,omitempty"`
+
+	// MinSamples is the number of recent completions required before the percentile applies (default 20).
+	MinSamples int `yaml:"min-samples,omitempty" json:"min-samples,omitempty"`
+
+	// MinDelayMS is the lower bound for the hedge delay in milliseconds.
+	MinDelayMS int `yaml:"min-delay-ms,omitempty" json:"min-delay-ms,omitempty"`
+
+	// DefaultDelayMS is the hedge delay used until enough samples exist; 0 disables hedging until then.
+	DefaultDelayMS int `yaml:"default-delay-ms,omitempty" json:"default-delay-ms,omitempty"`
+}
+
+// HealthProbeConfig configures background probes that periodically send a minimal request with
+// each credential so revoked tokens and exhausted keys are detected before user traffic hits them.
+type HealthProbeConfig struct {
+	// Enabled toggles background health probes.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// IntervalSeconds is the time between probe rounds (default 1800).
+	IntervalSeconds int `yaml:"interval-seconds,omitempty" json:"interval-seconds,omitempty"`
+
+	// TimeoutSeconds bounds a single probe request (default 30).
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// Method selects the probe request: "generate" (one-token generation, default) or "count-tokens".
+	Method string `yaml:"method,omitempty" json:"method,omitempty"`
+
+	// Providers limits probing to these providers (e.g., "claude", "gemini-cli"); empty probes all.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// Models maps a provider to the model used for its probes. Providers without an entry use
+	// the first model available to the credential.
+	Models map[string]string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// HistorySize is the number of probe results kept per credential (default 20).
+	HistorySize int `yaml:"history-size,omitempty" json:"history-size,omitempty"`
+}
+
+// MetricsConfig configures the Prometheus metrics endpoint.
+type MetricsConfig struct {
+	// Enabled exposes metrics in the Prometheus text format.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Listen optionally serves /metrics on a separate address (e.g., "127.0.0.1:9090") instead
+	// of /v0/management/metrics on the API listener. Changing it requires a restart.
+	Listen string `yaml:"listen,omitempty" json:"listen,omitempty"`
+}
+
+// TracingConfig configures OpenTelemetry tracing of inbound requests, credential attempts,
+// translations and upstream HTTP calls. Changes require a restart.
+type TracingConfig struct {
+	// Enabled turns tracing on; it is off by default.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Exporter selects where spans are sent: "otlp-http" (default) or "stdout".
+	Exporter string `yaml:"exporter,omitempty" json:"exporter,omitempty"`
+
+	// Endpoint is the OTLP HTTP traces URL (default http://localhost:4318/v1/traces, or the
+	// standard OTEL_EXPORTER_OTLP_* environment variables).
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+
+	// Headers are sent with every OTLP export request, e.g. for collector authentication.
+	Headers map[string]string `yaml:"headers,omitempty" json:"-"`
+
+	// ServiceName is reported as service.name (default "cli-proxy-api").
+	ServiceName string `yaml:"service-name,omitempty" json:"service-name,omitempty"`
+
+	// SampleRatio is the fraction of new traces recorded (0-1, default 1). Inbound requests
+	// carrying a sampled W3C traceparent are always recorded.
+	SampleRatio float64 `yaml:"sample-ratio,omitempty" json:"sample-ratio,omitempty"`
+
+	// PropagateHosts lists upstream hosts or wildcard patterns (e.g., "*.internal.example.com")
+	// that receive the W3C trace context. Empty never propagates to upstreams.
+	PropagateHosts []string `yaml:"propagate-hosts,omitempty" json:"propagate-hosts,omitempty"`
+}
+
+// UsageStorageConfig configures durable usage statistics. Request details are appended to daily
+// segment files and folded into hourly and daily rollups; the /usage snapshot is computed from
+// the retained details and rollups, so history survives restarts with bounded memory.
+type UsageStorageConfig struct {
+	// Enabled persists usage statistics; changing it requires a restart.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Dir holds the segment files and the rollup checkpoint (default "usage" next to the
+	// configuration file, or under WRITABLE_PATH). Changing it requires a restart.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// DetailRetentionDays is how long individual request details are kept (default 7 when
+	// enabled, unlimited otherwise; -1 keeps them forever).
+	DetailRetentionDays int `yaml:"detail-retention-days,omitempty" json:"detail-retention-days,omitempty"`
+
+	// HourlyRetentionDays is how long hourly rollups are kept (default 90 when enabled,
+	// unlimited otherwise; -1 keeps them forever).
+	HourlyRetentionDays int `yaml:"hourly-retention-days,omitempty" json:"hourly-retention-days,omitempty"`
+
+	// DailyRetentionDays is how long daily rollups, which back the request and token totals,
+	// are kept (default unlimited).
+	DailyRetentionDays int `yaml:"daily-retention-days,omitempty" json:"daily-retention-days,omitempty"`
+}
+
+// ResponsesStoreConfig configures the store behind previous_response_id and the
+// /v1/responses/{id} endpoints. Responses are kept in memory unless persistence is enabled.
+type ResponsesStoreConfig struct {
+	// Disabled stops storing responses; previous_response_id is then forwarded as sent.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// Persist also writes stored responses to disk so they survive restarts.
+	Persist bool `yaml:"persist,omitempty" json:"persist,omitempty"`
+
+	// Dir holds persisted responses (default "responses" next to the configuration file, or
+	// under WRITABLE_PATH).
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// TTLHours is how long a stored response can be retrieved or continued (default 24).
+	TTLHours int `yaml:"ttl-hours,omitempty" json:"ttl-hours,omitempty"`
+
+	// MaxMemoryMB bounds the responses kept in memory (default 64). The least recently used
+	// ones are dropped first; persisted responses are then read back from disk.
+	MaxMemoryMB int `yaml:"max-memory-mb,omitempty" json:"max-memory-mb,omitempty"`
+}
+
+// BatchConfig configures the emulated OpenAI files and batches APIs and the Anthropic Message
+// Batches API. Batches are executed in the background by a small worker pool that pauses while
+// interactive requests are in flight.
+type BatchConfig struct {
+	// Disabled turns off the batch endpoints and stops batch processing.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// Dir holds uploaded files, results and batch state (default "batches" next to the
+	// configuration file, or under WRITABLE_PATH).
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// Concurrency is the number of batch requests executed at once (default 2).
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+
+	// YieldThreshold pauses batch work while at least this many interactive requests are in
+	// flight (default 1). A negative value never pauses.
+	YieldThreshold int `yaml:"yield-threshold,omitempty" json:"yield-threshold,omitempty"`
+
+	// MaxFileSizeMB limits uploaded files (default 200).
+	MaxFileSizeMB int `yaml:"max-file-size-mb,omitempty" json:"max-file-size-mb,omitempty"`
+}
+
+// PricingConfig is the price table used for cost accounting. Costs appear in the usage
+// statistics and export; requests without a matching price cost nothing.
+type PricingConfig struct {
+	// Currency labels the costs (default "USD"); no conversion is performed.
+	Currency string `yaml:"currency,omitempty" json:"currency,omitempty"`
+
+	// Models lists the prices; the first entry matching a request applies, so list specific
+	// entries before catch-alls.
+	Models []ModelPrice `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// ModelPrice prices one model, or a set of models, in currency units per million tokens.
+type ModelPrice struct {
+	// Model is the model name or a wildcard pattern (e.g., "gpt-5*").
+	Model string `yaml:"model" json:"model"`
+
+	// Provider restricts the entry to one provider (e.g., "claude", "gemini-cli").
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// AuthType restricts the entry to "api_key" or "oauth" credentials, e.g. to price
+	// subscription accounts at zero marginal cost.
+	AuthType string `yaml:"auth-type,omitempty" json:"auth-type,omitempty"`
+
+	// Input is the price of uncached input tokens.
+	Input float64 `yaml:"input" json:"input"`
+
+	// Output is the price of output tokens.
+	Output float64 `yaml:"output" json:"output"`
+
+	// CachedRead is the price of input tokens served from the prompt cache (default Input).
+	CachedRead *float64 `yaml:"cached-read,omitempty" json:"cached-read,omitempty"`
+
+	// CacheWrite is the price of input tokens written to the prompt cache (default Input).
+	CacheWrite *float64 `yaml:"cache-write,omitempty" json:"cache-write,omitempty"`
+
+	// Reasoning is the price of reasoning tokens (default Output).
+	Reasoning *float64 `yaml:"reasoning,omitempty" json:"reasoning,omitempty"`
 }
 
 // AmpModelMapping defines a model name mapping for Amp CLI requests.
@@ -211,6 +478,16 @@ type ClaudeKey struct {
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/claude-sonnet-4").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
+	// Priority sets the selection tier for this credential when routing.strategy is "weighted".
+	// Higher values are preferred; lower tiers are only used when every higher tier is unavailable.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets the relative share of traffic within a priority tier (defaults to 1).
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// MaxConcurrency caps the number of parallel requests sent with this credential (0 = unlimited).
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
+
 	// BaseURL is the base URL for the Claude API endpoint.
 	// If empty, the default Claude API URL will be used.
 	BaseURL string `yaml:"base-url" json:"base-url"`
@@ -246,6 +523,16 @@ type CodexKey struct {
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/gpt-5-codex").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
+	// Priority sets the selection tier for this credential when routing.strategy is "weighted".
+	// Higher values are preferred; lower tiers are only used when every higher tier is unavailable.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets the relative share of traffic within a priority tier (defaults to 1).
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// MaxConcurrency caps the number of parallel requests sent with this credential (0 = unlimited).
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
+
 	// BaseURL is the base URL for the Codex API endpoint.
 	// If empty, the default Codex API URL will be used.
 	BaseURL string `yaml:"base-url" json:"base-url"`
@@ -269,6 +556,16 @@ type GeminiKey struct {
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/gemini-3-pro-preview").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
+	// Priority sets the selection tier for this credential when routing.strategy is "weighted".
+	// Higher values are preferred; lower tiers are only used when every higher tier is unavailable.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets the relative share of traffic within a priority tier (defaults to 1).
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// MaxConcurrency caps the number of parallel requests sent with this credential (0 = unlimited).
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
+
 	// BaseURL optionally overrides the Gemini API endpoint.
 	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
 
@@ -311,6 +608,16 @@ type OpenAICompatibilityAPIKey struct {
 
 	// ProxyURL overrides the global proxy setting for this API key if provided.
 	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+
+	// Priority sets the selection tier for this credential when routing.strategy is "weighted".
+	// Higher values are preferred; lower tiers are only used when every higher tier is unavailable.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets the relative share of traffic within a priority tier (defaults to 1).
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// MaxConcurrency caps the number of parallel requests sent with this credential (0 = unlimited).
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
 }
 
 // OpenAICompatibilityModel represents a model configuration for OpenAI compatibility,
@@ -321,6 +628,9 @@ type OpenAICompatibilityModel struct {
 
 	// Alias is the model name alias that clients will use to reference this model.
 	Alias string `yaml:"alias" json:"alias"`
+
+	// Embedding marks the model as an embeddings model served through /v1/embeddings.
+	Embedding bool `yaml:"embedding,omitempty" json:"embedding,omitempty"`
 }
 
 // LoadConfig reads a YAML configuration file from the given path,
@@ -415,6 +725,9 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Sync request authentication providers with inline API keys for backwards compatibility.
 	syncInlineAccessProvider(&cfg)
 
+	// Sanitize client key policies.
+	cfg.SanitizeClientAPIKeys()
+
 	// Sanitize Gemini API key configuration and migrate legacy entries.
 	cfg.SanitizeGeminiKeys()
 
@@ -433,6 +746,9 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Normalize OAuth provider model exclusion map.
 	cfg.OAuthExcludedModels = NormalizeOAuthExcludedModels(cfg.OAuthExcludedModels)
 
+	// Normalize model fallback chains.
+	cfg.ModelFallbacks = NormalizeModelFallbacks(cfg.ModelFallbacks)
+
 	if cfg.legacyMigrationPending {
 		fmt.Println("Detected legacy configuration keys, attempting to persist the normalized config...")
 		if !optional && configFile != "" {
@@ -534,6 +850,92 @@ func (cfg *Config) SanitizeGeminiKeys() {
 	cfg.GeminiKey = out
 }
 
+// SanitizeClientAPIKeys trims client key entries, drops entries without a key and removes
+// duplicates. Provider names are lower-cased and prefixes normalized like credential prefixes.
+func (cfg *Config) SanitizeClientAPIKeys() {
+	if cfg == nil {
+		return
+	}
+
+	seen := make(map[string]struct{}, len(cfg.APIKeyEntries))
+	out := cfg.APIKeyEntries[:0]
+	for i := range cfg.APIKeyEntries {
+		entry := cfg.APIKeyEntries[i]
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		if entry.APIKey == "" {
+			continue
+		}
+		entry.Name = strings.TrimSpace(entry.Name)
+		entry.Models = NormalizeExcludedModels(entry.Models)
+		entry.Providers = NormalizeExcludedModels(entry.Providers)
+		prefixes := make([]string, 0, len(entry.Prefixes))
+		for _, prefix := range entry.Prefixes {
+			if normalized := normalizeModelPrefix(prefix); normalized != "" {
+				prefixes = append(prefixes, normalized)
+			}
+		}
+		entry.Prefixes = nil
+		if len(prefixes) > 0 {
+			entry.Prefixes = prefixes
+		}
+		entry.DefaultPrefix = normalizeModelPrefix(entry.DefaultPrefix)
+		if entry.RateLimit != nil {
+			limit := sanitizeClientRateLimit(*entry.RateLimit)
+			entry.RateLimit = &limit
+		}
+		entry.Budgets = SanitizeClientBudgets(entry.Budgets)
+		if _, exists := seen[entry.APIKey]; exists {
+			continue
+		}
+		seen[entry.APIKey] = struct{}{}
+		out = append(out, entry)
+	}
+	cfg.APIKeyEntries = out
+	cfg.ClientRateLimit = sanitizeClientRateLimit(cfg.ClientRateLimit)
+	cfg.ClientBudgets = SanitizeClientBudgets(cfg.ClientBudgets)
+}
+
+// SanitizeClientBudgets normalizes budget periods and drops budgets with an unknown period or
+// without any cap. When a period is listed more than once, the first entry wins.
+func SanitizeClientBudgets(budgets []ClientBudget) []ClientBudget {
+	if len(budgets) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(budgets))
+	out := make([]ClientBudget, 0, len(budgets))
+	for _, budget := range budgets {
+		budget.Period = strings.ToLower(strings.TrimSpace(budget.Period))
+		switch budget.Period {
+		case BudgetPeriodDaily, BudgetPeriodWeekly, BudgetPeriodMonthly:
+		default:
+			continue
+		}
+		budget.SoftTokens = max(budget.SoftTokens, 0)
+		budget.HardTokens = max(budget.HardTokens, 0)
+		budget.SoftCost = max(budget.SoftCost, 0)
+		budget.HardCost = max(budget.HardCost, 0)
+		if budget.SoftTokens == 0 && budget.HardTokens == 0 && budget.SoftCost == 0 && budget.HardCost == 0 {
+			continue
+		}
+		if _, exists := seen[budget.Period]; exists {
+			continue
+		}
+		seen[budget.Period] = struct{}{}
+		out = append(out, budget)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func sanitizeClientRateLimit(limit ClientRateLimit) ClientRateLimit {
+	limit.RequestsPerMinute = max(limit.RequestsPerMinute, 0)
+	limit.TokensPerMinute = max(limit.TokensPerMinute, 0)
+	limit.MaxConcurrent = max(limit.MaxConcurrent, 0)
+	return limit
+}
+
 func normalizeModelPrefix(prefix string) string {
 	trimmed := strings.TrimSpace(prefix)
 	trimmed = strings.Trim(trimmed, "/")
@@ -632,6 +1034,43 @@ func NormalizeOAuthExcludedModels(entries map[string][]string) map[string][]stri
 	return out
 }
 
+// NormalizeModelFallbacks trims model names, drops empty chains, duplicate targets and
+// self-references, and keys the map by lower-cased model name.
+func NormalizeModelFallbacks(entries map[string][]string) map[string][]string {
+	if len(entries) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(entries))
+	for model, chain := range entries {
+		key := strings.ToLower(strings.TrimSpace(model))
+		if key == "" {
+			continue
+		}
+		seen := map[string]struct{}{key: {}}
+		targets := make([]string, 0, len(chain))
+		for _, target := range chain {
+			trimmed := strings.TrimSpace(target)
+			lowered := strings.ToLower(trimmed)
+			if trimmed == "" {
+				continue
+			}
+			if _, exists := seen[lowered]; exists {
+				continue
+			}
+			seen[lowered] = struct{}{}
+			targets = append(targets, trimmed)
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		out[key] = targets
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
 // hashSecret hashes the given secret using bcrypt.
 func hashSecret(secret string) (string, error) {
 	// Use default cost for simplicity.
@@ -4,11 +4,21 @@
 // debug settings, proxy configuration, and API keys.
 package config
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 // SDKConfig represents the application's configuration, loaded from a YAML file.
 type SDKConfig struct {
 	// ProxyURL is the URL of an optional proxy server to use for outbound requests.
 	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
 
+	// PublicURL is the URL clients reach this proxy at, such as "https://proxy.example.com".
+	// Links returned to clients, like image URLs, start with it; when empty they use the scheme
+	// and host of the request.
+	PublicURL string `yaml:"public-url,omitempty" json:"public-url,omitempty"`
+
 	// ForceModelPrefix requires explicit model prefixes (e.g., "teamA/gemini-3-pro-preview")
 	// to target prefixed credentials. When false, unprefixed model requests may use prefixed
 	// credentials as well.
@@ -20,6 +30,21 @@ type SDKConfig struct {
 	// APIKeys is a list of keys for authenticating clients to this proxy server.
 	APIKeys []string `yaml:"api-keys" json:"api-keys"`
 
+	// APIKeyEntries defines client keys with an optional access policy restricting the models,
+	// providers and credential prefixes the key may use. Keys listed here authenticate like
+	// APIKeys; plain APIKeys are unrestricted.
+	APIKeyEntries []ClientAPIKey `yaml:"api-key-entries,omitempty" json:"api-key-entries,omitempty"`
+
+	// ClientRateLimit applies to client keys that do not define their own rate-limit.
+	ClientRateLimit ClientRateLimit `yaml:"client-rate-limit,omitempty" json:"client-rate-limit,omitempty"`
+
+	// ClientBudgets apply to client keys that do not define their own budgets.
+	ClientBudgets []ClientBudget `yaml:"client-budgets,omitempty" json:"client-budgets,omitempty"`
+
+	// JWTAuth enables the built-in bearer-token access provider, which accepts JWTs signed by
+	// an identity provider (for example an OIDC issuer) in addition to the client keys above.
+	JWTAuth JWTAuthConfig `yaml:"jwt-auth,omitempty" json:"jwt-auth,omitempty"`
+
 	// Access holds request authentication provider configuration.
 	Access AccessConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
 
@@ -27,6 +52,141 @@ type SDKConfig struct {
 	Streaming StreamingConfig `yaml:"streaming" json:"streaming"`
 }
 
+// ClientAPIKey is a client key together with its access policy. Empty lists leave the
+// corresponding dimension unrestricted.
+type ClientAPIKey struct {
+	// APIKey is the key clients present to this proxy server.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// Name labels the key in logs and usage statistics.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Models lists the model names the key may request; '*' matches any substring.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Providers lists the upstream providers (e.g. "gemini", "claude", "codex") the key may use.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// Prefixes lists the credential prefixes whose credentials the key may use. When set,
+	// credentials without a prefix are not available to the key.
+	Prefixes []string `yaml:"prefixes,omitempty" json:"prefixes,omitempty"`
+
+	// DefaultPrefix is applied to unprefixed model names (e.g. "gemini-2.5-pro" becomes
+	// "teamA/gemini-2.5-pro") when such a prefixed model exists.
+	DefaultPrefix string `yaml:"default-prefix,omitempty" json:"default-prefix,omitempty"`
+
+	// RateLimit overrides ClientRateLimit for this key.
+	RateLimit *ClientRateLimit `yaml:"rate-limit,omitempty" json:"rate-limit,omitempty"`
+
+	// Budgets override ClientBudgets for this key.
+	Budgets []ClientBudget `yaml:"budgets,omitempty" json:"budgets,omitempty"`
+}
+
+// ClientRateLimit bounds the traffic of a single client key. Zero values disable a limit.
+type ClientRateLimit struct {
+	// RequestsPerMinute caps the requests accepted in any 60 second window.
+	RequestsPerMinute int `yaml:"rpm,omitempty" json:"rpm,omitempty"`
+
+	// TokensPerMinute caps the tokens consumed in any 60 second window. Admission uses an
+	// estimate of the request's input tokens; reported usage is accounted once it arrives.
+	TokensPerMinute int `yaml:"tpm,omitempty" json:"tpm,omitempty"`
+
+	// MaxConcurrent caps the requests and streams in flight at the same time.
+	MaxConcurrent int `yaml:"max-concurrent,omitempty" json:"max-concurrent,omitempty"`
+}
+
+// Enabled reports whether any limit is set.
+func (l ClientRateLimit) Enabled() bool {
+	return l.RequestsPerMinute > 0 || l.TokensPerMinute > 0 || l.MaxConcurrent > 0
+}
+
+// Budget periods. Periods start at midnight UTC; weeks start on Monday.
+const (
+	BudgetPeriodDaily   = "daily"
+	BudgetPeriodWeekly  = "weekly"
+	BudgetPeriodMonthly = "monthly"
+)
+
+// ClientBudget caps the tokens and cost a client key may consume per period. Zero values
+// disable a cap.
+type ClientBudget struct {
+	// Period is one of "daily", "weekly" or "monthly".
+	Period string `yaml:"period" json:"period"`
+
+	// SoftTokens adds a warning header to responses once the period's usage reaches it.
+	SoftTokens int64 `yaml:"soft-tokens,omitempty" json:"soft-tokens,omitempty"`
+
+	// HardTokens rejects requests once the period's usage reaches it.
+	HardTokens int64 `yaml:"hard-tokens,omitempty" json:"hard-tokens,omitempty"`
+
+	// SoftCost adds a warning header once the period's cost, priced with the pricing table,
+	// reaches it.
+	SoftCost float64 `yaml:"soft-cost,omitempty" json:"soft-cost,omitempty"`
+
+	// HardCost rejects requests once the period's cost reaches it.
+	HardCost float64 `yaml:"hard-cost,omitempty" json:"hard-cost,omitempty"`
+}
+
+// JWTAuthConfig configures validation of JWT bearer tokens. The provider is enabled when
+// JWKSURL or JWKSFile is set.
+type JWTAuthConfig struct {
+	// JWKSURL is fetched for the signing keys, e.g. "https://sso.example.com/.well-known/jwks.json".
+	JWKSURL string `yaml:"jwks-url,omitempty" json:"jwks-url,omitempty"`
+
+	// JWKSFile reads the signing keys from a local JWKS document instead of JWKSURL.
+	JWKSFile string `yaml:"jwks-file,omitempty" json:"jwks-file,omitempty"`
+
+	// Issuer must match the token's "iss" claim when set.
+	Issuer string `yaml:"issuer,omitempty" json:"issuer,omitempty"`
+
+	// Audience lists accepted "aud" values; the token must carry at least one when set.
+	Audience []string `yaml:"audience,omitempty" json:"audience,omitempty"`
+
+	// PrincipalClaim names the claim identifying the caller (default "sub"). Nested claims use
+	// dotted paths.
+	PrincipalClaim string `yaml:"principal-claim,omitempty" json:"principal-claim,omitempty"`
+
+	// GroupsClaim names the claim listing the caller's groups (default "groups").
+	GroupsClaim string `yaml:"groups-claim,omitempty" json:"groups-claim,omitempty"`
+
+	// EmailClaim names the claim holding the caller's email address (default "email").
+	EmailClaim string `yaml:"email-claim,omitempty" json:"email-claim,omitempty"`
+
+	// LeewaySeconds tolerates clock skew when checking "exp" and "nbf" (default 60).
+	LeewaySeconds int `yaml:"leeway-seconds,omitempty" json:"leeway-seconds,omitempty"`
+
+	// RefreshSeconds controls how often JWKSURL is re-fetched (default 300). Unknown key IDs
+	// trigger an earlier refresh.
+	RefreshSeconds int `yaml:"refresh-seconds,omitempty" json:"refresh-seconds,omitempty"`
+
+	// Policies grant access policies based on claims; the first matching policy applies.
+	// Tokens matching no policy are unrestricted.
+	Policies []JWTClaimPolicy `yaml:"policies,omitempty" json:"policies,omitempty"`
+}
+
+// JWTClaimPolicy applies an access policy to tokens whose claim matches one of Values.
+type JWTClaimPolicy struct {
+	// Claim is the claim to inspect (dotted path); string and string-array claims are supported.
+	Claim string `yaml:"claim" json:"claim"`
+
+	// Values lists the claim values selecting this policy; '*' matches any substring.
+	Values []string `yaml:"values" json:"values"`
+
+	// Name labels callers matched by this policy in logs and usage statistics.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Models, Providers, Prefixes and DefaultPrefix behave as in ClientAPIKey.
+	Models        []string `yaml:"models,omitempty" json:"models,omitempty"`
+	Providers     []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+	Prefixes      []string `yaml:"prefixes,omitempty" json:"prefixes,omitempty"`
+	DefaultPrefix string   `yaml:"default-prefix,omitempty" json:"default-prefix,omitempty"`
+}
+
+// Enabled reports whether a key source is configured.
+func (c JWTAuthConfig) Enabled() bool {
+	return strings.TrimSpace(c.JWKSURL) != "" || strings.TrimSpace(c.JWKSFile) != ""
+}
+
 // StreamingConfig holds server streaming behavior configuration.
 type StreamingConfig struct {
 	// KeepAliveSeconds controls how often the server emits SSE heartbeats (": keep-alive\n\n").
@@ -37,6 +197,13 @@ type StreamingConfig struct {
 	// to allow auth rotation / transient recovery.
 	// nil means default (2). 0 disables bootstrap retries.
 	BootstrapRetries *int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
+
+	// ContinuationRetries controls how many times a stream that fails after bytes were sent may be
+	// resumed on another credential, with the partial assistant output replayed as a prefill.
+	// Only text is replayed: streams that already emitted a tool call, streams with more than one
+	// choice or candidate, OpenAI Responses streams and non-SSE Gemini streams fail as before.
+	// nil or 0 disables mid-stream failover.
+	ContinuationRetries *int `yaml:"continuation-retries,omitempty" json:"continuation-retries,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
@@ -69,6 +236,9 @@ const (
 
 	// DefaultAccessProviderName is applied when no provider name is supplied.
 	DefaultAccessProviderName = "config-inline"
+
+	// AccessProviderTypeJWT is the built-in provider validating JWT bearer tokens.
+	AccessProviderTypeJWT = "jwt"
 )
 
 // ConfigAPIKeyProvider returns the first inline API key provider if present.
@@ -87,6 +257,90 @@ func (c *SDKConfig) ConfigAPIKeyProvider() *AccessProvider {
 	return nil
 }
 
+// ClientAPIKeys returns every key accepted by the inline provider: APIKeys followed by the
+// keys of APIKeyEntries, trimmed and de-duplicated.
+func (c *SDKConfig) ClientAPIKeys() []string {
+	if c == nil {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(c.APIKeys)+len(c.APIKeyEntries))
+	out := make([]string, 0, len(c.APIKeys)+len(c.APIKeyEntries))
+	add := func(key string) {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return
+		}
+		if _, exists := seen[key]; exists {
+			return
+		}
+		seen[key] = struct{}{}
+		out = append(out, key)
+	}
+	for _, key := range c.APIKeys {
+		add(key)
+	}
+	for i := range c.APIKeyEntries {
+		add(c.APIKeyEntries[i].APIKey)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// RateLimitFor returns the rate limit applying to a client key: the entry's own limit when
+// it defines one, otherwise ClientRateLimit.
+func (c *SDKConfig) RateLimitFor(key string) ClientRateLimit {
+	if c == nil {
+		return ClientRateLimit{}
+	}
+	key = strings.TrimSpace(key)
+	for i := range c.APIKeyEntries {
+		entry := &c.APIKeyEntries[i]
+		if entry.RateLimit != nil && strings.TrimSpace(entry.APIKey) == key {
+			return *entry.RateLimit
+		}
+	}
+	return c.ClientRateLimit
+}
+
+// BudgetsFor returns the budgets applying to a client key: the entry's own budgets when it
+// defines any, otherwise ClientBudgets.
+func (c *SDKConfig) BudgetsFor(key string) []ClientBudget {
+	if c == nil {
+		return nil
+	}
+	key = strings.TrimSpace(key)
+	for i := range c.APIKeyEntries {
+		entry := &c.APIKeyEntries[i]
+		if len(entry.Budgets) > 0 && strings.TrimSpace(entry.APIKey) == key {
+			return entry.Budgets
+		}
+	}
+	return c.ClientBudgets
+}
+
+// JWTAccessProvider returns the provider configuration for JWTAuth, or nil when it is not
+// enabled. The settings are carried in Config so that changes are detected on reload.
+func (c *SDKConfig) JWTAccessProvider() *AccessProvider {
+	if c == nil || !c.JWTAuth.Enabled() {
+		return nil
+	}
+	data, err := json.Marshal(c.JWTAuth)
+	if err != nil {
+		return nil
+	}
+	var settings map[string]any
+	if err = json.Unmarshal(data, &settings); err != nil {
+		return nil
+	}
+	return &AccessProvider{
+		Name:   AccessProviderTypeJWT,
+		Type:   AccessProviderTypeJWT,
+		Config: settings,
+	}
+}
+
 // MakeInlineAPIKeyProvider constructs an inline API key provider configuration.
 // It returns nil when no keys are supplied.
 func MakeInlineAPIKeyProvider(keys []string) *AccessProvider {
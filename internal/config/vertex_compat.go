@@ -16,6 +16,16 @@ type VertexCompatKey struct {
 	// Prefix optionally namespaces model aliases for this credential (e.g., "teamA/vertex-pro").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
+	// Priority sets the selection tier for this credential when routing.strategy is "weighted".
+	// Higher values are preferred; lower tiers are only used when every higher tier is unavailable.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets the relative share of traffic within a priority tier (defaults to 1).
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// MaxConcurrency caps the number of parallel requests sent with this credential (0 = unlimited).
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
+
 	// BaseURL is the base URL for the Vertex-compatible API endpoint.
 	// The executor will append "/v1/publishers/google/models/{model}:action" to this.
 	// Example: "https://zenmux.ai/api" becomes "https://zenmux.ai/api/v1/publishers/google/models/..."
@@ -21,6 +21,8 @@ var aiAPIPrefixes = []string{
 	"/v1/completions",
 	"/v1/messages",
 	"/v1/responses",
+	"/v1/embeddings",
+	"/v1/images/",
 	"/v1beta/models/",
 	"/api/provider/",
 }
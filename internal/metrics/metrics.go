@@ -0,0 +1,242 @@
+// Package metrics exports proxy metrics in the Prometheus text exposition format. Usage
+// records arrive through a coreusage.Plugin, upstream attempts through a coreauth.Hook, and
+// credential gauges are read from the coreauth.Manager on every scrape.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// contentType is the media type of the text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var defaultMetrics = New()
+
+func init() {
+	coreusage.RegisterPlugin(usagePlugin{metrics: defaultMetrics})
+	sdktranslator.SetErrorObserver(defaultMetrics.TranslatorError)
+}
+
+// Default returns the process-wide metrics shared by the server and the API handlers.
+func Default() *Metrics { return defaultMetrics }
+
+// Metrics holds the exported metric families.
+type Metrics struct {
+	requests         *family
+	tokens           *family
+	duration         *family
+	ttft             *family
+	streamsActive    *family
+	streamRetries    *family
+	upstreamAttempts *family
+	upstreamErrors   *family
+	translatorErrors *family
+	credentialStatus *family
+	cooldown         *family
+
+	authMu sync.RWMutex
+	auth   *coreauth.Manager
+	now    func() time.Time
+}
+
+// New constructs an empty metrics set.
+func New() *Metrics {
+	return &Metrics{
+		requests: newFamily(kindCounter, "cliproxy_requests_total",
+			"Upstream requests reported in usage records.", "provider", "model", "auth_index", "client_key", "result"),
+		tokens: newFamily(kindCounter, "cliproxy_tokens_total",
+			"Tokens reported in usage records.", "provider", "model", "auth_index", "client_key", "type"),
+		duration: newFamily(kindHistogram, "cliproxy_request_duration_seconds",
+			"Client request latency until the response or stream completed.", "handler", "model", "stream", "status"),
+		ttft: newFamily(kindHistogram, "cliproxy_time_to_first_token_seconds",
+			"Time until the first streamed chunk was sent to the client.", "handler", "model"),
+		streamsActive: newFamily(kindGauge, "cliproxy_streams_active",
+			"Streaming responses currently open.", "handler"),
+		streamRetries: newFamily(kindCounter, "cliproxy_stream_retries_total",
+			"Stream retries before the first byte (bootstrap) and mid-stream resumes (continuation).", "handler", "kind"),
+		upstreamAttempts: newFamily(kindCounter, "cliproxy_upstream_attempts_total",
+			"Upstream execution attempts; failed attempts are retried on another credential while attempts remain.", "provider", "model", "result"),
+		upstreamErrors: newFamily(kindCounter, "cliproxy_upstream_errors_total",
+			"Failed upstream attempts by HTTP status.", "provider", "status"),
+		translatorErrors: newFamily(kindCounter, "cliproxy_translator_errors_total",
+			"Request or response translations that failed.", "from", "to", "stage"),
+		credentialStatus: newFamily(kindGauge, "cliproxy_credential_status",
+			"Credentials by lifecycle status (1 for the current status).", "provider", "auth_index", "status"),
+		cooldown: newFamily(kindGauge, "cliproxy_credential_cooldown_seconds",
+			"Remaining cooldown of a credential, or of one of its models when model is set.", "provider", "auth_index", "model"),
+		now: time.Now,
+	}
+}
+
+// SetAuthManager sets the manager whose credentials are reported on scrape.
+func (m *Metrics) SetAuthManager(manager *coreauth.Manager) {
+	m.authMu.Lock()
+	m.auth = manager
+	m.authMu.Unlock()
+}
+
+// AuthHook returns a coreauth.Hook counting upstream attempts.
+func (m *Metrics) AuthHook() coreauth.Hook { return authHook{metrics: m} }
+
+// ObserveRequest records the latency of a completed client request.
+func (m *Metrics) ObserveRequest(handler, model string, stream bool, status int, elapsed time.Duration) {
+	m.duration.observe(elapsed.Seconds(), handler, model, strconv.FormatBool(stream), strconv.Itoa(status))
+}
+
+// ObserveFirstToken records the time to first token of a stream.
+func (m *Metrics) ObserveFirstToken(handler, model string, elapsed time.Duration) {
+	m.ttft.observe(elapsed.Seconds(), handler, model)
+}
+
+// StreamOpened counts a stream as active until the returned function is called.
+func (m *Metrics) StreamOpened(handler string) (closed func()) {
+	m.streamsActive.add(1, handler)
+	var once sync.Once
+	return func() { once.Do(func() { m.streamsActive.add(-1, handler) }) }
+}
+
+// StreamRetried counts a bootstrap or continuation retry of a stream.
+func (m *Metrics) StreamRetried(handler, kind string) {
+	m.streamRetries.add(1, handler, kind)
+}
+
+// TranslatorError counts a failed translation; it is installed as the translator error observer.
+func (m *Metrics) TranslatorError(from, to sdktranslator.Format, stage string) {
+	m.translatorErrors.add(1, from.String(), to.String(), stage)
+}
+
+// ServeHTTP writes all metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+	m.Write(&buf)
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// Write renders all metrics, refreshing the credential gauges first.
+func (m *Metrics) Write(buf *bytes.Buffer) {
+	m.collectCredentials()
+	for _, f := range []*family{
+		m.requests, m.tokens, m.duration, m.ttft, m.streamsActive, m.streamRetries,
+		m.upstreamAttempts, m.upstreamErrors, m.translatorErrors, m.credentialStatus, m.cooldown,
+	} {
+		f.write(buf)
+	}
+}
+
+// collectCredentials rebuilds the credential status and cooldown gauges from the auth manager.
+func (m *Metrics) collectCredentials() {
+	m.authMu.RLock()
+	manager := m.auth
+	m.authMu.RUnlock()
+	m.credentialStatus.reset()
+	m.cooldown.reset()
+	if manager == nil {
+		return
+	}
+	now := m.now()
+	for _, auth := range manager.List() {
+		if auth == nil {
+			continue
+		}
+		index := auth.EnsureIndex()
+		status := string(auth.Status)
+		if auth.Disabled {
+			status = string(coreauth.StatusDisabled)
+		}
+		if status == "" {
+			status = string(coreauth.StatusUnknown)
+		}
+		m.credentialStatus.set(1, auth.Provider, index, status)
+		if remaining := auth.NextRetryAfter.Sub(now); auth.Unavailable && remaining > 0 {
+			m.cooldown.set(remaining.Seconds(), auth.Provider, index, "")
+		}
+		for model, state := range auth.ModelStates {
+			if state == nil || !state.Unavailable {
+				continue
+			}
+			if remaining := state.NextRetryAfter.Sub(now); remaining > 0 {
+				m.cooldown.set(remaining.Seconds(), auth.Provider, index, model)
+			}
+		}
+	}
+}
+
+// usagePlugin counts requests and tokens from usage records.
+type usagePlugin struct {
+	metrics *Metrics
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (p usagePlugin) HandleUsage(ctx context.Context, record coreusage.Record) {
+	m := p.metrics
+	client := clientKeyLabel(ctx, record.APIKey)
+	result := "success"
+	if record.Failed {
+		result = "failure"
+	}
+	m.requests.add(1, record.Provider, record.Model, record.AuthIndex, client, result)
+	for _, item := range []struct {
+		kind  string
+		value int64
+	}{
+		{"input", record.Detail.InputTokens},
+		{"output", record.Detail.OutputTokens},
+		{"reasoning", record.Detail.ReasoningTokens},
+		{"cached", record.Detail.CachedTokens},
+		{"cache_write", record.Detail.CacheWriteTokens},
+	} {
+		if item.value > 0 {
+			m.tokens.add(float64(item.value), record.Provider, record.Model, record.AuthIndex, client, item.kind)
+		}
+	}
+}
+
+// clientKeyLabel returns the label of the caller's key (an api-key-entries name, a virtual key
+// label or a JWT policy name), falling back to a masked key so secrets never reach a scrape.
+func clientKeyLabel(ctx context.Context, apiKey string) string {
+	if ctx != nil {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+			if raw, exists := ginCtx.Get("accessMetadata"); exists {
+				metadata, _ := raw.(map[string]string)
+				if name := strings.TrimSpace(metadata[sdkaccess.MetadataKeyName]); name != "" {
+					return name
+				}
+			}
+		}
+	}
+	return util.HideAPIKey(apiKey)
+}
+
+// authHook counts upstream attempts reported by the auth manager.
+type authHook struct {
+	coreauth.NoopHook
+	metrics *Metrics
+}
+
+// OnResult implements coreauth.Hook.
+func (h authHook) OnResult(_ context.Context, result coreauth.Result) {
+	if result.Success {
+		h.metrics.upstreamAttempts.add(1, result.Provider, result.Model, "success")
+		return
+	}
+	h.metrics.upstreamAttempts.add(1, result.Provider, result.Model, "failure")
+	status := "unknown"
+	if result.Error != nil && result.Error.HTTPStatus > 0 {
+		status = strconv.Itoa(result.Error.HTTPStatus)
+	}
+	h.metrics.upstreamErrors.add(1, result.Provider, status)
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func render(m *Metrics) string {
+	var buf bytes.Buffer
+	m.Write(&buf)
+	return buf.String()
+}
+
+func TestMetrics_UsageAndUpstreamCounters(t *testing.T) {
+	m := New()
+	plugin := usagePlugin{metrics: m}
+	record := coreusage.Record{
+		Provider:  "gemini",
+		Model:     "gemini-2.5-pro",
+		APIKey:    "sk-secret-client-key",
+		AuthIndex: "3",
+		Detail:    coreusage.Detail{InputTokens: 10, OutputTokens: 5},
+	}
+	plugin.HandleUsage(context.Background(), record)
+	plugin.HandleUsage(context.Background(), record)
+
+	hook := m.AuthHook()
+	hook.OnResult(context.Background(), coreauth.Result{Provider: "gemini", Model: "gemini-2.5-pro", Success: true})
+	hook.OnResult(context.Background(), coreauth.Result{Provider: "gemini", Model: "gemini-2.5-pro", Error: &coreauth.Error{HTTPStatus: 429}})
+
+	out := render(m)
+	for _, want := range []string{
+		`cliproxy_requests_total{provider="gemini",model="gemini-2.5-pro",auth_index="3",client_key="sk-s...-key",result="success"} 2`,
+		`cliproxy_tokens_total{provider="gemini",model="gemini-2.5-pro",auth_index="3",client_key="sk-s...-key",type="input"} 20`,
+		`cliproxy_tokens_total{provider="gemini",model="gemini-2.5-pro",auth_index="3",client_key="sk-s...-key",type="output"} 10`,
+		`cliproxy_upstream_attempts_total{provider="gemini",model="gemini-2.5-pro",result="failure"} 1`,
+		`cliproxy_upstream_errors_total{provider="gemini",status="429"} 1`,
+		"# TYPE cliproxy_request_duration_seconds histogram",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, record.APIKey) {
+		t.Fatalf("output exposes the raw client key")
+	}
+}
+
+func TestMetrics_LatencyHistogramAndStreams(t *testing.T) {
+	m := New()
+	m.ObserveRequest("openai", "gpt-5", true, 200, 300*time.Millisecond)
+	m.ObserveFirstToken("openai", "gpt-5", 2*time.Second)
+	closed := m.StreamOpened("openai")
+
+	out := render(m)
+	for _, want := range []string{
+		`cliproxy_request_duration_seconds_bucket{handler="openai",model="gpt-5",stream="true",status="200",le="0.25"} 0`,
+		`cliproxy_request_duration_seconds_bucket{handler="openai",model="gpt-5",stream="true",status="200",le="0.5"} 1`,
+		`cliproxy_request_duration_seconds_count{handler="openai",model="gpt-5",stream="true",status="200"} 1`,
+		`cliproxy_time_to_first_token_seconds_bucket{handler="openai",model="gpt-5",le="+Inf"} 1`,
+		`cliproxy_streams_active{handler="openai"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+
+	closed()
+	closed()
+	if out = render(m); !strings.Contains(out, `cliproxy_streams_active{handler="openai"} 0`) {
+		t.Fatalf("stream gauge not decremented once\n%s", out)
+	}
+}
+
+func TestMetrics_TranslatorErrors(t *testing.T) {
+	registry := sdktranslator.NewRegistry()
+	registry.Register("openai", "gemini", func(string, []byte, bool) []byte { panic("boom") }, sdktranslator.ResponseTransform{})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected the translator panic to propagate")
+			}
+		}()
+		registry.TranslateRequest("openai", "gemini", "gemini-2.5-pro", []byte(`{}`), false)
+	}()
+
+	registry.Register("openai", "claude", func(string, []byte, bool) []byte { return []byte(`{"truncated":`) }, sdktranslator.ResponseTransform{
+		NonStream: func(context.Context, string, []byte, []byte, []byte, *any) string { return `{"id":"ok"}` },
+	})
+	registry.TranslateRequest("openai", "claude", "claude-sonnet-4", []byte(`{}`), false)
+	registry.TranslateNonStream(context.Background(), "claude", "openai", "claude-sonnet-4", nil, nil, []byte(`{}`), nil)
+
+	out := render(Default())
+	for _, want := range []string{
+		`cliproxy_translator_errors_total{from="openai",to="gemini",stage="request"} 1`,
+		`cliproxy_translator_errors_total{from="openai",to="claude",stage="request"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `stage="non-stream"`) {
+		t.Fatalf("valid translations must not count as errors\n%s", out)
+	}
+}
@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// latencyBuckets are the histogram upper bounds, in seconds, used for request latency and time
+// to first token.
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+
+// family is a metric with a fixed label set and one series per distinct label values.
+type family struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newFamily(kind, name, help string, labels ...string) *family {
+	f := &family{name: name, help: help, kind: kind, labels: labels, series: make(map[string]*series)}
+	if kind == kindHistogram {
+		f.buckets = latencyBuckets
+	}
+	return f
+}
+
+// get returns the series for values, creating it when needed. The caller must hold f.mu.
+func (f *family) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// add increases a counter or gauge.
+func (f *family) add(delta float64, values ...string) {
+	f.mu.Lock()
+	f.get(values).value += delta
+	f.mu.Unlock()
+}
+
+// set replaces a gauge value.
+func (f *family) set(value float64, values ...string) {
+	f.mu.Lock()
+	f.get(values).value = value
+	f.mu.Unlock()
+}
+
+// observe records a histogram sample.
+func (f *family) observe(value float64, values ...string) {
+	f.mu.Lock()
+	s := f.get(values)
+	for i, bound := range f.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+	f.mu.Unlock()
+}
+
+// reset drops every series; used for gauges rebuilt on each scrape.
+func (f *family) reset() {
+	f.mu.Lock()
+	f.series = make(map[string]*series)
+	f.mu.Unlock()
+}
+
+// write renders the family in the Prometheus text exposition format.
+func (f *family) write(w io.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != kindHistogram {
+			_, _ = fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelString(s.values, ""), formatValue(s.value))
+			continue
+		}
+		for i, bound := range f.buckets {
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelString(s.values, formatValue(bound)), s.counts[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelString(s.values, "+Inf"), s.count)
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelString(s.values, ""), formatValue(s.sum))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labelString(s.values, ""), s.count)
+	}
+}
+
+func (f *family) labelString(values []string, le string) string {
+	if len(f.labels) == 0 && le == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range f.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	if le != "" {
+		if len(f.labels) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(`le="`)
+		b.WriteString(le)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string { return labelEscaper.Replace(value) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
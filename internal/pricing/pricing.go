@@ -0,0 +1,118 @@
+// Package pricing computes the cost of usage records from the configured model price table.
+package pricing
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const (
+	defaultCurrency = "USD"
+	perTokens       = 1_000_000
+)
+
+var defaultTable = NewTable()
+
+// Default returns the process-wide price table shared by the usage statistics.
+func Default() *Table { return defaultTable }
+
+// Table resolves prices for usage records.
+type Table struct {
+	mu       sync.RWMutex
+	currency string
+	prices   []config.ModelPrice
+}
+
+// NewTable constructs an empty price table under which every request is free.
+func NewTable() *Table { return &Table{currency: defaultCurrency} }
+
+// SetConfig replaces the price table.
+func (t *Table) SetConfig(cfg config.PricingConfig) {
+	currency := strings.TrimSpace(cfg.Currency)
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	prices := make([]config.ModelPrice, 0, len(cfg.Models))
+	for _, price := range cfg.Models {
+		price.Model = strings.ToLower(strings.TrimSpace(price.Model))
+		price.Provider = strings.ToLower(strings.TrimSpace(price.Provider))
+		price.AuthType = strings.ToLower(strings.TrimSpace(price.AuthType))
+		if price.Model != "" {
+			prices = append(prices, price)
+		}
+	}
+	t.mu.Lock()
+	t.currency = currency
+	t.prices = prices
+	t.mu.Unlock()
+}
+
+// Currency returns the currency costs are expressed in.
+func (t *Table) Currency() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.currency
+}
+
+// Lookup returns the first price matching the provider, model and credential type.
+func (t *Table) Lookup(provider, model, authType string) (config.ModelPrice, bool) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	model = strings.ToLower(strings.TrimSpace(model))
+	authType = strings.ToLower(strings.TrimSpace(authType))
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, price := range t.prices {
+		if price.Provider != "" && price.Provider != provider {
+			continue
+		}
+		if price.AuthType != "" && price.AuthType != authType {
+			continue
+		}
+		if sdkaccess.MatchPattern(price.Model, model) {
+			return price, true
+		}
+	}
+	return config.ModelPrice{}, false
+}
+
+// Cost returns the cost of a usage record, or zero when no price matches.
+func (t *Table) Cost(record coreusage.Record) float64 {
+	price, ok := t.Lookup(record.Provider, record.Model, record.AuthType)
+	if !ok {
+		return 0
+	}
+	return Compute(price, record.Provider, record.Detail)
+}
+
+// Compute prices a token breakdown. Upstreams differ in what their counts include, which is
+// normalised here so no token is billed twice:
+//   - cached reads are part of the input count except in Claude usage, which reports them apart;
+//   - reasoning tokens are part of the output count unless the total counts them separately,
+//     as Gemini does.
+func Compute(price config.ModelPrice, provider string, detail coreusage.Detail) float64 {
+	input := detail.InputTokens
+	if !strings.EqualFold(provider, "claude") {
+		input -= detail.CachedTokens
+	}
+	output := detail.OutputTokens
+	if detail.ReasoningTokens > 0 && detail.TotalTokens < detail.InputTokens+detail.OutputTokens+detail.ReasoningTokens {
+		output -= detail.ReasoningTokens
+	}
+	cost := float64(max(input, 0))*price.Input +
+		float64(max(output, 0))*price.Output +
+		float64(detail.CachedTokens)*priceOr(price.CachedRead, price.Input) +
+		float64(detail.CacheWriteTokens)*priceOr(price.CacheWrite, price.Input) +
+		float64(detail.ReasoningTokens)*priceOr(price.Reasoning, price.Output)
+	return cost / perTokens
+}
+
+func priceOr(value *float64, fallback float64) float64 {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
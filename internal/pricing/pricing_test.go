@@ -0,0 +1,57 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func price(v float64) *float64 { return &v }
+
+func TestTable_LookupOrderAndAuthType(t *testing.T) {
+	table := NewTable()
+	table.SetConfig(config.PricingConfig{Models: []config.ModelPrice{
+		{Model: "*", AuthType: "oauth"},
+		{Model: "gpt-5*", Provider: "codex", Input: 1.25, Output: 10},
+		{Model: "GPT-5*", Input: 2, Output: 12},
+	}})
+
+	record := coreusage.Record{Provider: "codex", Model: "gpt-5-mini", AuthType: "oauth", Detail: coreusage.Detail{InputTokens: 1000}}
+	if cost := table.Cost(record); cost != 0 {
+		t.Fatalf("expected subscription credential to cost nothing, got %v", cost)
+	}
+	record.AuthType = "api_key"
+	if got, ok := table.Lookup(record.Provider, record.Model, record.AuthType); !ok || got.Input != 1.25 {
+		t.Fatalf("expected the provider specific price, got %+v (%t)", got, ok)
+	}
+	if got, ok := table.Lookup("openai-compatibility", "gpt-5", "api_key"); !ok || got.Input != 2 {
+		t.Fatalf("expected the generic price, got %+v (%t)", got, ok)
+	}
+	if _, ok := table.Lookup("gemini", "gemini-2.5-pro", "api_key"); ok {
+		t.Fatalf("expected no price for an unlisted model")
+	}
+}
+
+func TestCompute_NormalisesProviderCounts(t *testing.T) {
+	p := config.ModelPrice{Input: 3, Output: 15, CachedRead: price(0.3), CacheWrite: price(3.75)}
+
+	// OpenAI style: cached tokens are part of the prompt, reasoning part of the completion.
+	openai := coreusage.Detail{InputTokens: 1_000_000, CachedTokens: 400_000, OutputTokens: 200_000, ReasoningTokens: 100_000, TotalTokens: 1_200_000}
+	if got, want := Compute(p, "codex", openai), 0.6*3+0.4*0.3+0.1*15+0.1*15; math.Abs(got-want) > 1e-9 {
+		t.Fatalf("openai cost = %v, want %v", got, want)
+	}
+
+	// Claude style: cache reads and writes are reported apart from input tokens.
+	claude := coreusage.Detail{InputTokens: 1_000_000, CachedTokens: 400_000, CacheWriteTokens: 100_000, OutputTokens: 200_000, TotalTokens: 1_200_000}
+	if got, want := Compute(p, "claude", claude), 1*3+0.4*0.3+0.1*3.75+0.2*15; math.Abs(got-want) > 1e-9 {
+		t.Fatalf("claude cost = %v, want %v", got, want)
+	}
+
+	// Gemini style: thoughts are counted outside the candidates and included in the total.
+	gemini := coreusage.Detail{InputTokens: 1_000_000, OutputTokens: 200_000, ReasoningTokens: 100_000, TotalTokens: 1_300_000}
+	if got, want := Compute(p, "gemini", gemini), 1*3+0.2*15+0.1*15; math.Abs(got-want) > 1e-9 {
+		t.Fatalf("gemini cost = %v, want %v", got, want)
+	}
+}
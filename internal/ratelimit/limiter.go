@@ -0,0 +1,318 @@
+// Package ratelimit enforces per-client-key limits on requests per minute, tokens per minute
+// and concurrent requests for callers of the proxy API.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// window is the sliding window used for the per-minute limits.
+const window = time.Minute
+
+// concurrencyRetryAfter is suggested to callers rejected for too many in-flight requests,
+// which have no natural expiry.
+const concurrencyRetryAfter = time.Second
+
+// Limit names reported in rejections and snapshots.
+const (
+	LimitRequests    = "rpm"
+	LimitTokens      = "tpm"
+	LimitConcurrency = "max-concurrent"
+)
+
+var defaultLimiter = NewLimiter()
+
+func init() {
+	coreusage.RegisterPlugin(usagePlugin{limiter: defaultLimiter})
+}
+
+// Default returns the process-wide limiter shared by the API handlers.
+func Default() *Limiter { return defaultLimiter }
+
+// Rejection describes a request refused by the limiter.
+type Rejection struct {
+	// Limit names the exceeded limit (LimitRequests, LimitTokens or LimitConcurrency).
+	Limit string
+	// Max is the configured value of the exceeded limit.
+	Max int
+	// RetryAfter is the time after which the request is expected to be admitted.
+	RetryAfter time.Duration
+}
+
+// Error implements error.
+func (r *Rejection) Error() string {
+	switch r.Limit {
+	case LimitConcurrency:
+		return fmt.Sprintf("rate limit exceeded: at most %d concurrent requests allowed for this API key", r.Max)
+	case LimitTokens:
+		return fmt.Sprintf("rate limit exceeded: at most %d tokens per minute allowed for this API key", r.Max)
+	default:
+		return fmt.Sprintf("rate limit exceeded: at most %d requests per minute allowed for this API key", r.Max)
+	}
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to whole seconds, at least one.
+func (r *Rejection) RetryAfterSeconds() int {
+	seconds := int(math.Ceil(r.RetryAfter.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// KeyUsage is a snapshot of the live counters of one client key.
+type KeyUsage struct {
+	Key           string                 `json:"key"`
+	Limits        config.ClientRateLimit `json:"limits"`
+	Requests      int                    `json:"requests_last_minute"`
+	Tokens        int64                  `json:"tokens_last_minute"`
+	PendingTokens int64                  `json:"pending_tokens"`
+	InFlight      int                    `json:"in_flight"`
+	Rejected      map[string]int64       `json:"rejected"`
+}
+
+// Limiter tracks sliding-window usage per client key.
+type Limiter struct {
+	mu   sync.Mutex
+	keys map[string]*keyState
+	now  func() time.Time
+	// lastSweep is when keys idle for a whole window were last dropped.
+	lastSweep time.Time
+}
+
+type keyState struct {
+	limits   config.ClientRateLimit
+	requests []time.Time
+	tokens   []tokenEvent
+	pending  int64
+	inFlight int
+	rejected map[string]int64
+	// reserved holds the token estimate of each admitted request by request ID until its
+	// reported usage replaces it or the request finishes.
+	reserved map[string]int64
+}
+
+type tokenEvent struct {
+	at     time.Time
+	tokens int64
+}
+
+// NewLimiter constructs an empty limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{keys: make(map[string]*keyState), now: time.Now}
+}
+
+// Acquire admits request requestID of key under limits, reserving estimatedTokens against the
+// tokens-per-minute budget until the request reports its usage or finishes. The returned
+// release function must be called once the request (or stream) completes; it is safe to call
+// more than once.
+func (l *Limiter) Acquire(key, requestID string, limits config.ClientRateLimit, estimatedTokens int64) (func(), *Rejection) {
+	if l == nil || key == "" || !limits.Enabled() {
+		return func() {}, nil
+	}
+	if estimatedTokens < 0 {
+		estimatedTokens = 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweepLocked(now)
+	state := l.keys[key]
+	if state == nil {
+		state = &keyState{rejected: make(map[string]int64), reserved: make(map[string]int64)}
+		l.keys[key] = state
+	}
+	state.limits = limits
+	state.prune(now)
+
+	if rejection := state.admit(now, estimatedTokens); rejection != nil {
+		state.rejected[rejection.Limit]++
+		return nil, rejection
+	}
+	state.requests = append(state.requests, now)
+	state.inFlight++
+	state.pending += estimatedTokens
+	// A request ID already reserved, or none, cannot be matched to its usage; its estimate then
+	// stays pending until the request finishes.
+	tracked := requestID != ""
+	if _, exists := state.reserved[requestID]; exists {
+		tracked = false
+	}
+	if tracked {
+		state.reserved[requestID] = estimatedTokens
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			state.inFlight--
+			if !tracked {
+				state.pending -= estimatedTokens
+			} else {
+				state.unreserve(requestID)
+			}
+			l.mu.Unlock()
+		})
+	}, nil
+}
+
+// unreserve releases the pending estimate of requestID, if still reserved.
+func (s *keyState) unreserve(requestID string) {
+	if reserved, ok := s.reserved[requestID]; ok {
+		s.pending -= reserved
+		delete(s.reserved, requestID)
+	}
+}
+
+// sweepLocked drops, at most once per window, the keys with nothing in flight and nothing left
+// in the window, so the map does not keep every key ever seen.
+func (l *Limiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < window {
+		return
+	}
+	l.lastSweep = now
+	for key, state := range l.keys {
+		state.prune(now)
+		if state.inFlight == 0 && len(state.requests) == 0 && len(state.tokens) == 0 {
+			delete(l.keys, key)
+		}
+	}
+}
+
+// admit checks the limits in order of concurrency, requests and tokens.
+func (s *keyState) admit(now time.Time, estimatedTokens int64) *Rejection {
+	limits := s.limits
+	if limits.MaxConcurrent > 0 && s.inFlight >= limits.MaxConcurrent {
+		return &Rejection{Limit: LimitConcurrency, Max: limits.MaxConcurrent, RetryAfter: concurrencyRetryAfter}
+	}
+	if limits.RequestsPerMinute > 0 && len(s.requests) >= limits.RequestsPerMinute {
+		oldest := s.requests[len(s.requests)-limits.RequestsPerMinute]
+		return &Rejection{Limit: LimitRequests, Max: limits.RequestsPerMinute, RetryAfter: oldest.Add(window).Sub(now)}
+	}
+	if limits.TokensPerMinute > 0 {
+		budget := int64(limits.TokensPerMinute)
+		used := s.pending
+		for _, event := range s.tokens {
+			used += event.tokens
+		}
+		// A request larger than the whole budget is admitted once the window is idle rather
+		// than being rejected forever.
+		if used > 0 && used+estimatedTokens > budget {
+			return &Rejection{Limit: LimitTokens, Max: limits.TokensPerMinute, RetryAfter: s.tokenRetryAfter(now, used+estimatedTokens-budget)}
+		}
+	}
+	return nil
+}
+
+// tokenRetryAfter returns how long until excess tokens leave the window. Pending estimates
+// are released when their requests finish, so a full window is assumed for them.
+func (s *keyState) tokenRetryAfter(now time.Time, excess int64) time.Duration {
+	for _, event := range s.tokens {
+		excess -= event.tokens
+		if excess <= 0 {
+			return event.at.Add(window).Sub(now)
+		}
+	}
+	return window
+}
+
+func (s *keyState) prune(now time.Time) {
+	cutoff := now.Add(-window)
+	drop := 0
+	for drop < len(s.requests) && !s.requests[drop].After(cutoff) {
+		drop++
+	}
+	s.requests = s.requests[drop:]
+	drop = 0
+	for drop < len(s.tokens) && !s.tokens[drop].at.After(cutoff) {
+		drop++
+	}
+	s.tokens = s.tokens[drop:]
+}
+
+// RecordTokens accounts tokens reported by a usage record of request requestID against key.
+// The reported tokens replace the estimate reserved for the request, so it is not counted twice.
+func (l *Limiter) RecordTokens(key, requestID string, tokens int64) {
+	if l == nil || key == "" || tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state := l.keys[key]
+	if state == nil {
+		return
+	}
+	if requestID != "" {
+		state.unreserve(requestID)
+	}
+	if state.limits.TokensPerMinute <= 0 {
+		return
+	}
+	now := l.now()
+	state.prune(now)
+	state.tokens = append(state.tokens, tokenEvent{at: now, tokens: tokens})
+}
+
+// Snapshot returns the live counters of every rate-limited client key, sorted by key.
+func (l *Limiter) Snapshot() []KeyUsage {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	out := make([]KeyUsage, 0, len(l.keys))
+	for key, state := range l.keys {
+		state.prune(now)
+		if !state.limits.Enabled() && state.inFlight == 0 {
+			continue
+		}
+		usage := KeyUsage{
+			Key:           key,
+			Limits:        state.limits,
+			Requests:      len(state.requests),
+			PendingTokens: state.pending,
+			InFlight:      state.inFlight,
+			Rejected:      make(map[string]int64, len(state.rejected)),
+		}
+		for _, event := range state.tokens {
+			usage.Tokens += event.tokens
+		}
+		for limit, count := range state.rejected {
+			usage.Rejected[limit] = count
+		}
+		out = append(out, usage)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// EstimateInputTokens approximates the input tokens of a request payload at four bytes per
+// token.
+func EstimateInputTokens(payload []byte) int64 {
+	return int64((len(payload) + 3) / 4)
+}
+
+// usagePlugin feeds reported token usage into the limiter.
+type usagePlugin struct {
+	limiter *Limiter
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (p usagePlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	p.limiter.RecordTokens(record.APIKey, record.RequestID, tokens)
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func newTestLimiter(now *time.Time) *Limiter {
+	l := NewLimiter()
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestLimiter_RequestsPerMinute(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newTestLimiter(&now)
+	limits := config.ClientRateLimit{RequestsPerMinute: 2}
+
+	for i := 0; i < 2; i++ {
+		release, rejection := l.Acquire("k", "", limits, 0)
+		if rejection != nil {
+			t.Fatalf("request %d rejected: %v", i, rejection)
+		}
+		release()
+		now = now.Add(10 * time.Second)
+	}
+	_, rejection := l.Acquire("k", "", limits, 0)
+	if rejection == nil || rejection.Limit != LimitRequests {
+		t.Fatalf("expected rpm rejection, got %+v", rejection)
+	}
+	if got := rejection.RetryAfterSeconds(); got != 40 {
+		t.Fatalf("expected retry after 40s, got %d", got)
+	}
+
+	now = now.Add(41 * time.Second)
+	if _, rejection = l.Acquire("k", "", limits, 0); rejection != nil {
+		t.Fatalf("request after window rejected: %v", rejection)
+	}
+}
+
+func TestLimiter_MaxConcurrent(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newTestLimiter(&now)
+	limits := config.ClientRateLimit{MaxConcurrent: 1}
+
+	release, rejection := l.Acquire("k", "", limits, 0)
+	if rejection != nil {
+		t.Fatalf("first request rejected: %v", rejection)
+	}
+	if _, rejection = l.Acquire("k", "", limits, 0); rejection == nil || rejection.Limit != LimitConcurrency {
+		t.Fatalf("expected concurrency rejection, got %+v", rejection)
+	}
+	release()
+	release()
+	if _, rejection = l.Acquire("k", "", limits, 0); rejection != nil {
+		t.Fatalf("request after release rejected: %v", rejection)
+	}
+	if snapshot := l.Snapshot(); len(snapshot) != 1 || snapshot[0].InFlight != 1 || snapshot[0].Rejected[LimitConcurrency] != 1 {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+}
+
+func TestLimiter_TokensPerMinute(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newTestLimiter(&now)
+	limits := config.ClientRateLimit{TokensPerMinute: 1000}
+
+	// Oversized requests are admitted on an idle window.
+	release, rejection := l.Acquire("k", "", limits, 1500)
+	if rejection != nil {
+		t.Fatalf("request on idle window rejected: %v", rejection)
+	}
+	l.RecordTokens("k", "", 1200)
+	release()
+
+	now = now.Add(20 * time.Second)
+	_, rejection = l.Acquire("k", "", limits, 100)
+	if rejection == nil || rejection.Limit != LimitTokens {
+		t.Fatalf("expected tpm rejection, got %+v", rejection)
+	}
+	if got := rejection.RetryAfterSeconds(); got != 40 {
+		t.Fatalf("expected retry after 40s, got %d", got)
+	}
+
+	now = now.Add(41 * time.Second)
+	if _, rejection = l.Acquire("k", "", limits, 100); rejection != nil {
+		t.Fatalf("request after window rejected: %v", rejection)
+	}
+}
+
+func TestLimiter_ReportedTokensReplaceEstimate(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newTestLimiter(&now)
+	limits := config.ClientRateLimit{TokensPerMinute: 1000}
+
+	release, rejection := l.Acquire("k", "req-1", limits, 400)
+	if rejection != nil {
+		t.Fatalf("first request rejected: %v", rejection)
+	}
+	l.RecordTokens("k", "req-1", 500)
+	// 500 reported tokens and the 400 estimate must not both count before release.
+	releaseSecond, rejection := l.Acquire("k", "req-2", limits, 400)
+	if rejection != nil {
+		t.Fatalf("expected the estimate to be replaced by the reported tokens, got %v", rejection)
+	}
+	release()
+	releaseSecond()
+	if snapshot := l.Snapshot(); len(snapshot) != 1 || snapshot[0].PendingTokens != 0 || snapshot[0].Tokens != 500 {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+}
+
+func TestLimiter_DropsIdleKeys(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newTestLimiter(&now)
+	limits := config.ClientRateLimit{RequestsPerMinute: 10}
+
+	release, _ := l.Acquire("idle", "", limits, 0)
+	release()
+	held, _ := l.Acquire("busy", "", limits, 0)
+	now = now.Add(2 * window)
+	release, _ = l.Acquire("other", "", limits, 0)
+	release()
+
+	l.mu.Lock()
+	_, idle := l.keys["idle"]
+	_, busy := l.keys["busy"]
+	l.mu.Unlock()
+	if idle || !busy {
+		t.Fatalf("expected only the idle key to be dropped, idle kept %v, busy kept %v", idle, busy)
+	}
+	held()
+}
@@ -192,6 +192,20 @@ func GetGeminiModels() []*ModelInfo {
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true, Levels: []string{"low", "high"}},
 		},
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    1752019200,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Text embeddings with up to 3072 dimensions",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"embedContent", "batchEmbedContents"},
+			Embedding:                  true,
+		},
 	}
 }
 
@@ -287,6 +301,34 @@ func GetGeminiVertexModels() []*ModelInfo {
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true, Levels: []string{"low", "high"}},
 		},
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    1752019200,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Text embeddings with up to 3072 dimensions",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"embedContent", "batchEmbedContents"},
+			Embedding:                  true,
+		},
+		{
+			ID:                         "text-embedding-005",
+			Object:                     "model",
+			Created:                    1731974400,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/text-embedding-005",
+			Version:                    "005",
+			DisplayName:                "Text Embedding 005",
+			Description:                "English and code text embeddings with up to 768 dimensions",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"embedContent", "batchEmbedContents"},
+			Embedding:                  true,
+		},
 	}
 }
 
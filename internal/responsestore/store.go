@@ -0,0 +1,359 @@
+// Package responsestore keeps the input and output items of Responses API calls so that
+// previous_response_id can be resolved by the proxy, whatever backend serves the request.
+package responsestore
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultTTL = 24 * time.Hour
+	// defaultMaxMemory bounds the records kept in memory when no limit is configured.
+	defaultMaxMemory = 64 << 20
+	// pruneInterval spaces the sweeps dropping expired responses.
+	pruneInterval = 10 * time.Minute
+	// maxChainDepth bounds how many previous responses are followed when expanding history.
+	maxChainDepth = 1000
+	fileExt       = ".json"
+)
+
+// validID restricts response ids to characters safe to use as file names.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// ErrNotFound is returned for unknown, expired or foreign response ids.
+var ErrNotFound = errors.New("response not found")
+
+var defaultStore = New()
+
+// Default returns the process-wide store shared by the Responses API handlers.
+func Default() *Store { return defaultStore }
+
+// Record is one stored response.
+type Record struct {
+	ID                 string `json:"id"`
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
+	// Owner is a hash of the client API key that created the response; other keys cannot read it.
+	Owner string `json:"owner,omitempty"`
+	// Input holds the input items sent with this call, without the expanded history.
+	Input json.RawMessage `json:"input"`
+	// Response is the response object returned to the client, output items included.
+	Response  json.RawMessage `json:"response"`
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// Store keeps records in memory and, when a directory is configured, as one file per response.
+// The records in memory are bounded by size: the least recently used ones are dropped first and,
+// when persisted, read back from disk on demand.
+type Store struct {
+	mu      sync.Mutex
+	enabled bool
+	ttl     time.Duration
+	dir     string
+	// items indexes the elements of lru, which hold *Record values, most recently used first.
+	items     map[string]*list.Element
+	lru       *list.List
+	size      int64
+	maxSize   int64
+	now       func() time.Time
+	lastPrune time.Time
+}
+
+// New constructs an enabled in-memory store.
+func New() *Store {
+	return &Store{
+		enabled: true,
+		ttl:     defaultTTL,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+		maxSize: defaultMaxMemory,
+		now:     time.Now,
+	}
+}
+
+// Configure applies cfg. defaultDir is used for persistence when cfg.Dir is empty.
+func (s *Store) Configure(cfg config.ResponsesStoreConfig, defaultDir string) error {
+	ttl := defaultTTL
+	if cfg.TTLHours > 0 {
+		ttl = time.Duration(cfg.TTLHours) * time.Hour
+	}
+	dir := ""
+	if cfg.Persist {
+		dir = strings.TrimSpace(cfg.Dir)
+		if dir == "" {
+			dir = defaultDir
+		}
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("responsestore: create directory: %w", err)
+		}
+	}
+	maxSize := int64(defaultMaxMemory)
+	if cfg.MaxMemoryMB > 0 {
+		maxSize = int64(cfg.MaxMemoryMB) << 20
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enabled = !cfg.Disabled
+	s.ttl = ttl
+	s.dir = dir
+	s.maxSize = maxSize
+	s.evictLocked()
+	return nil
+}
+
+// Enabled reports whether responses are stored and previous_response_id is resolved.
+func (s *Store) Enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enabled
+}
+
+// OwnerOf returns the owner value stored for a client API key.
+func OwnerOf(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// Put stores a record, stamping its creation and expiry times. The record is cached under the
+// lock; the file write and the pruning of expired files happen outside of it.
+func (s *Store) Put(rec Record) error {
+	if !validID.MatchString(rec.ID) {
+		return fmt.Errorf("responsestore: invalid response id %q", rec.ID)
+	}
+	s.mu.Lock()
+	if !s.enabled {
+		s.mu.Unlock()
+		return nil
+	}
+	now := s.now()
+	rec.CreatedAt = now
+	rec.ExpiresAt = now.Add(s.ttl)
+	s.cacheLocked(&rec)
+	expired := s.pruneLocked(now)
+	dir, ttl := s.dir, s.ttl
+	s.mu.Unlock()
+
+	if dir == "" {
+		return nil
+	}
+	if expired != nil {
+		defer pruneDir(dir, expired, now.Add(-ttl))
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("responsestore: encode response: %w", err)
+	}
+	if err = writeFileAtomic(filepath.Join(dir, rec.ID+fileExt), data); err != nil {
+		return fmt.Errorf("responsestore: write response: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file of its own and renames it over path, so
+// concurrent writes of the same record never interleave.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Get returns the record id when it exists, has not expired and belongs to owner. Records
+// evicted from memory are read back from disk without holding the lock.
+func (s *Store) Get(id, owner string) (*Record, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	s.mu.Lock()
+	elem, ok := s.items[id]
+	var rec *Record
+	if ok {
+		s.lru.MoveToFront(elem)
+		rec = elem.Value.(*Record)
+	}
+	dir := s.dir
+	s.mu.Unlock()
+
+	if !ok && dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, id+fileExt))
+		if err == nil {
+			var loaded Record
+			if json.Unmarshal(data, &loaded) == nil && loaded.ID == id {
+				rec, ok = &loaded, true
+				s.mu.Lock()
+				if _, cached := s.items[id]; !cached {
+					s.cacheLocked(rec)
+				}
+				s.mu.Unlock()
+			}
+		}
+	}
+	// An empty owner marks a record created without a client key; only such callers see it.
+	if !ok || s.now().After(rec.ExpiresAt) || rec.Owner != owner {
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+// Delete removes the record id owned by owner.
+func (s *Store) Delete(id, owner string) error {
+	if _, err := s.Get(id, owner); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.uncacheLocked(id)
+	dir := s.dir
+	s.mu.Unlock()
+	if dir != "" {
+		_ = os.Remove(filepath.Join(dir, id+fileExt))
+	}
+	return nil
+}
+
+// cacheLocked keeps rec in memory as the most recently used record.
+func (s *Store) cacheLocked(rec *Record) {
+	s.uncacheLocked(rec.ID)
+	s.items[rec.ID] = s.lru.PushFront(rec)
+	s.size += recordSize(rec)
+	s.evictLocked()
+}
+
+func (s *Store) uncacheLocked(id string) {
+	if elem, ok := s.items[id]; ok {
+		s.lru.Remove(elem)
+		delete(s.items, id)
+		s.size -= recordSize(elem.Value.(*Record))
+	}
+}
+
+// evictLocked drops the least recently used records beyond the memory limit, keeping the most
+// recent one. Persisted records stay on disk.
+func (s *Store) evictLocked() {
+	for s.size > s.maxSize && s.lru.Len() > 1 {
+		s.uncacheLocked(s.lru.Back().Value.(*Record).ID)
+	}
+}
+
+func recordSize(rec *Record) int64 {
+	return int64(len(rec.ID) + len(rec.PreviousResponseID) + len(rec.Owner) + len(rec.Input) + len(rec.Response))
+}
+
+// History returns the conversation leading up to and including response id, oldest first:
+// the input items and the output items of every response in the previous_response_id chain.
+// Item ids are dropped and reasoning items without encrypted content are skipped, since
+// backends cannot resolve items they did not store.
+func (s *Store) History(id, owner string) ([]json.RawMessage, error) {
+	var chain []*Record
+	for next := id; next != "" && len(chain) < maxChainDepth; {
+		rec, err := s.Get(next, owner)
+		if err != nil {
+			if len(chain) == 0 {
+				return nil, err
+			}
+			// An expired ancestor truncates the history instead of failing the request.
+			break
+		}
+		chain = append(chain, rec)
+		next = rec.PreviousResponseID
+	}
+	var items []json.RawMessage
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, item := range gjson.ParseBytes(chain[i].Input).Array() {
+			items = appendHistoryItem(items, item)
+		}
+		for _, item := range gjson.GetBytes(chain[i].Response, "output").Array() {
+			items = appendHistoryItem(items, item)
+		}
+	}
+	return items, nil
+}
+
+func appendHistoryItem(items []json.RawMessage, item gjson.Result) []json.RawMessage {
+	if item.Get("type").String() == "reasoning" && item.Get("encrypted_content").String() == "" {
+		return items
+	}
+	raw, _ := sjson.Delete(item.Raw, "id")
+	return append(items, json.RawMessage(raw))
+}
+
+// pruneLocked drops expired records from memory at most once per pruneInterval. It returns the
+// ids of the dropped records, non-nil when a sweep ran, for pruneDir to remove from disk.
+func (s *Store) pruneLocked(now time.Time) []string {
+	if now.Sub(s.lastPrune) < pruneInterval {
+		return nil
+	}
+	s.lastPrune = now
+	expired := []string{}
+	for elem := s.lru.Front(); elem != nil; {
+		rec := elem.Value.(*Record)
+		elem = elem.Next()
+		if now.After(rec.ExpiresAt) {
+			s.uncacheLocked(rec.ID)
+			expired = append(expired, rec.ID)
+		}
+	}
+	return expired
+}
+
+// pruneDir removes the files of the expired records and of the records last written before
+// cutoff, including temporary files left behind by interrupted writes.
+func pruneDir(dir string, expired []string, cutoff time.Time) {
+	for _, id := range expired {
+		_ = os.Remove(filepath.Join(dir, id+fileExt))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || (!strings.HasSuffix(entry.Name(), fileExt) && !strings.HasSuffix(entry.Name(), ".tmp")) {
+			continue
+		}
+		if info, errInfo := entry.Info(); errInfo == nil && info.ModTime().Before(cutoff) {
+			_ = os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
+
+// NormalizeInput returns the input of a Responses request as an array of items; a string
+// input becomes a single user message.
+func NormalizeInput(input gjson.Result) json.RawMessage {
+	switch {
+	case input.IsArray():
+		return json.RawMessage(input.Raw)
+	case input.Type == gjson.String:
+		item, _ := sjson.Set(`{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}`, "content.0.text", input.String())
+		return json.RawMessage("[" + item + "]")
+	default:
+		return json.RawMessage("[]")
+	}
+}
@@ -0,0 +1,138 @@
+package responsestore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestStore_HistoryFollowsChain(t *testing.T) {
+	store := New()
+	owner := OwnerOf("client-key")
+	put := func(id, previous, input, output string) {
+		t.Helper()
+		err := store.Put(Record{
+			ID:                 id,
+			PreviousResponseID: previous,
+			Owner:              owner,
+			Input:              NormalizeInput(gjson.Parse(input)),
+			Response:           []byte(`{"id":"` + id + `","output":` + output + `}`),
+		})
+		if err != nil {
+			t.Fatalf("Put(%s): %v", id, err)
+		}
+	}
+	put("resp_1", "", `"hello"`, `[{"id":"rs_1","type":"reasoning","summary":[]},{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"output_text","text":"hi"}]}]`)
+	put("resp_2", "resp_1", `[{"type":"message","role":"user","content":[{"type":"input_text","text":"again"}]}]`, `[{"id":"msg_2","type":"message","role":"assistant","content":[{"type":"output_text","text":"sure"}]}]`)
+
+	items, err := store.History("resp_2", owner)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	var texts []string
+	for _, item := range items {
+		parsed := gjson.ParseBytes(item)
+		if parsed.Get("id").Exists() {
+			t.Fatalf("expected item ids to be dropped, got %s", item)
+		}
+		texts = append(texts, parsed.Get("role").String()+":"+parsed.Get("content.0.text").String())
+	}
+	if got := strings.Join(texts, ","); got != "user:hello,assistant:hi,user:again,assistant:sure" {
+		t.Fatalf("unexpected history %s", got)
+	}
+
+	if _, err = store.History("resp_2", OwnerOf("other-key")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected another client key to be refused, got %v", err)
+	}
+}
+
+func TestStore_PersistsAndExpires(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	store := New()
+	store.now = func() time.Time { return now }
+	if err := store.Configure(config.ResponsesStoreConfig{Persist: true, TTLHours: 1}, dir); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if err := store.Put(Record{ID: "resp_disk", Input: []byte(`[]`), Response: []byte(`{"id":"resp_disk"}`)}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	reopened := New()
+	reopened.now = store.now
+	if err := reopened.Configure(config.ResponsesStoreConfig{Persist: true, TTLHours: 1}, dir); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if _, err := reopened.Get("resp_disk", ""); err != nil {
+		t.Fatalf("expected the persisted response, got %v", err)
+	}
+	if _, err := reopened.Get("resp_disk", OwnerOf("client-key")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a response stored without a client key to be hidden from keyed clients, got %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, err := reopened.Get("resp_disk", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the response to expire, got %v", err)
+	}
+	if err := store.Put(Record{ID: "resp_next", Input: []byte(`[]`), Response: []byte(`{"id":"resp_next"}`)}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "resp_disk"+fileExt)); !os.IsNotExist(err) {
+		t.Fatalf("expected the expired response file to be pruned, got %v", err)
+	}
+	if _, err := reopened.Get("../config", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected an invalid id to be rejected, got %v", err)
+	}
+}
+
+func TestStore_EvictsLeastRecentlyUsedAndReadsFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	store := New()
+	if err := store.Configure(config.ResponsesStoreConfig{Persist: true}, dir); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	owner := OwnerOf("client-key")
+	response := []byte(`{"output":[]}`)
+	for _, id := range []string{"resp_a", "resp_b", "resp_c"} {
+		if err := store.Put(Record{ID: id, Owner: owner, Input: []byte(`[]`), Response: response}); err != nil {
+			t.Fatalf("Put(%s): %v", id, err)
+		}
+	}
+	// Keep room for two records and touch resp_a, so resp_b is the least recently used.
+	store.mu.Lock()
+	store.maxSize = 2 * recordSize(store.items["resp_a"].Value.(*Record))
+	store.mu.Unlock()
+	if _, err := store.Get("resp_a", owner); err != nil {
+		t.Fatalf("Get(resp_a): %v", err)
+	}
+	store.mu.Lock()
+	store.evictLocked()
+	_, cachedB := store.items["resp_b"]
+	cached := store.lru.Len()
+	store.mu.Unlock()
+	if cachedB || cached != 2 {
+		t.Fatalf("expected resp_b to be evicted, got %d cached records (resp_b cached: %v)", cached, cachedB)
+	}
+	if _, err := store.Get("resp_b", owner); err != nil {
+		t.Fatalf("expected an evicted record to be read back from disk: %v", err)
+	}
+
+	memory := New()
+	memory.maxSize = 1
+	for _, id := range []string{"resp_a", "resp_b"} {
+		if err := memory.Put(Record{ID: id, Owner: owner, Input: []byte(`[]`), Response: response}); err != nil {
+			t.Fatalf("Put(%s): %v", id, err)
+		}
+	}
+	if _, err := memory.Get("resp_a", owner); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected an in-memory store to drop evicted records, got %v", err)
+	}
+	if _, err := memory.Get("resp_b", owner); err != nil {
+		t.Fatalf("expected the latest record to be kept: %v", err)
+	}
+}
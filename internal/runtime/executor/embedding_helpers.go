@@ -0,0 +1,333 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+const (
+	// geminiEmbedBatchSize is the number of requests batchEmbedContents accepts per call.
+	geminiEmbedBatchSize = 100
+	// vertexEmbedBatchSize is the number of instances :predict accepts per call for the
+	// text-embedding models; Gemini embedding models on Vertex take a single instance.
+	vertexEmbedBatchSize = 250
+)
+
+// embeddingRequest is the provider neutral form of an embeddings request.
+type embeddingRequest struct {
+	Inputs     []string
+	Dimensions int64
+	TaskType   string
+	Title      string
+	// EncodingFormat is the OpenAI encoding_format, "float" or "base64".
+	EncodingFormat string
+}
+
+// embeddingResult holds one vector per input, in input order, and the prompt tokens consumed.
+type embeddingResult struct {
+	Vectors      [][]float64
+	PromptTokens int64
+}
+
+// detail returns the usage of the embeddings call.
+func (r embeddingResult) detail() usage.Detail {
+	return usage.Detail{InputTokens: r.PromptTokens, TotalTokens: r.PromptTokens}
+}
+
+// parseEmbeddingRequest reads an OpenAI embeddings request or a Gemini batchEmbedContents request.
+// When textOnly is false the request is forwarded unchanged, so OpenAI inputs that are not strings,
+// such as token arrays, are accepted and counted with empty text.
+func parseEmbeddingRequest(from sdktranslator.Format, payload []byte, textOnly bool) (embeddingRequest, error) {
+	root := gjson.ParseBytes(payload)
+	var req embeddingRequest
+	if from == sdktranslator.FromString("gemini") {
+		for _, item := range root.Get("requests").Array() {
+			var parts []string
+			for _, part := range item.Get("content.parts").Array() {
+				parts = append(parts, part.Get("text").String())
+			}
+			req.Inputs = append(req.Inputs, strings.Join(parts, "\n"))
+			if req.Dimensions == 0 {
+				req.Dimensions = item.Get("outputDimensionality").Int()
+			}
+			if req.TaskType == "" {
+				req.TaskType = item.Get("taskType").String()
+			}
+			if req.Title == "" {
+				req.Title = item.Get("title").String()
+			}
+		}
+	} else {
+		input := root.Get("input")
+		switch {
+		case input.IsArray():
+			for _, item := range input.Array() {
+				if item.Type != gjson.String {
+					if textOnly {
+						return req, statusErr{code: http.StatusBadRequest, msg: "embeddings: only string inputs are supported"}
+					}
+					req.Inputs = append(req.Inputs, "")
+					continue
+				}
+				req.Inputs = append(req.Inputs, item.String())
+			}
+		case input.Type == gjson.String:
+			req.Inputs = []string{input.String()}
+		}
+		req.Dimensions = root.Get("dimensions").Int()
+		req.EncodingFormat = root.Get("encoding_format").String()
+	}
+	if len(req.Inputs) == 0 {
+		return req, statusErr{code: http.StatusBadRequest, msg: "embeddings: input is required"}
+	}
+	return req, nil
+}
+
+// formatEmbeddingResponse renders result in the schema of the source format.
+func formatEmbeddingResponse(from sdktranslator.Format, model string, req embeddingRequest, result embeddingResult) []byte {
+	if from == sdktranslator.FromString("gemini") {
+		type values struct {
+			Values []float64 `json:"values"`
+		}
+		embeddings := make([]values, len(result.Vectors))
+		for i, vector := range result.Vectors {
+			embeddings[i] = values{Values: vector}
+		}
+		out, _ := json.Marshal(map[string]any{"embeddings": embeddings})
+		return out
+	}
+	type item struct {
+		Object    string `json:"object"`
+		Index     int    `json:"index"`
+		Embedding any    `json:"embedding"`
+	}
+	data := make([]item, len(result.Vectors))
+	for i, vector := range result.Vectors {
+		var embedding any = vector
+		if req.EncodingFormat == "base64" {
+			embedding = encodeEmbeddingBase64(vector)
+		}
+		data[i] = item{Object: "embedding", Index: i, Embedding: embedding}
+	}
+	out, _ := json.Marshal(map[string]any{
+		"object": "list",
+		"data":   data,
+		"model":  model,
+		"usage":  map[string]int64{"prompt_tokens": result.PromptTokens, "total_tokens": result.PromptTokens},
+	})
+	return out
+}
+
+// encodeEmbeddingBase64 packs a vector as little-endian float32 values, as OpenAI does.
+func encodeEmbeddingBase64(vector []float64) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// embedInBatches splits the inputs into upstream calls of at most size inputs and joins the results.
+func embedInBatches(req embeddingRequest, size int, fn func(batch embeddingRequest) (embeddingResult, error)) (embeddingResult, error) {
+	var result embeddingResult
+	for start := 0; start < len(req.Inputs); start += size {
+		batch := req
+		batch.Inputs = req.Inputs[start:min(start+size, len(req.Inputs))]
+		part, err := fn(batch)
+		if err != nil {
+			return embeddingResult{}, err
+		}
+		if len(part.Vectors) != len(batch.Inputs) {
+			return embeddingResult{}, statusErr{code: http.StatusBadGateway, msg: "embeddings: upstream returned an unexpected number of vectors"}
+		}
+		result.Vectors = append(result.Vectors, part.Vectors...)
+		result.PromptTokens += part.PromptTokens
+	}
+	return result, nil
+}
+
+// geminiBatchEmbedBody builds a batchEmbedContents request.
+func geminiBatchEmbedBody(model string, req embeddingRequest) []byte {
+	requests := make([]map[string]any, len(req.Inputs))
+	for i, input := range req.Inputs {
+		item := map[string]any{
+			"model":   "models/" + model,
+			"content": map[string]any{"parts": []map[string]string{{"text": input}}},
+		}
+		if req.Dimensions > 0 {
+			item["outputDimensionality"] = req.Dimensions
+		}
+		if req.TaskType != "" {
+			item["taskType"] = req.TaskType
+		}
+		if req.Title != "" {
+			item["title"] = req.Title
+		}
+		requests[i] = item
+	}
+	out, _ := json.Marshal(map[string]any{"requests": requests})
+	return out
+}
+
+// parseGeminiBatchEmbed reads a batchEmbedContents response. The API reports no usage, so
+// prompt tokens are estimated from the inputs.
+func parseGeminiBatchEmbed(data []byte, inputs []string) embeddingResult {
+	var result embeddingResult
+	for _, embedding := range gjson.GetBytes(data, "embeddings").Array() {
+		result.Vectors = append(result.Vectors, floatValues(embedding.Get("values")))
+	}
+	result.PromptTokens = estimateEmbeddingTokens(inputs)
+	return result
+}
+
+// vertexPredictBody builds a :predict request for a Vertex embedding model.
+func vertexPredictBody(req embeddingRequest) []byte {
+	instances := make([]map[string]string, len(req.Inputs))
+	for i, input := range req.Inputs {
+		instance := map[string]string{"content": input}
+		if req.TaskType != "" {
+			instance["task_type"] = req.TaskType
+		}
+		if req.Title != "" {
+			instance["title"] = req.Title
+		}
+		instances[i] = instance
+	}
+	body := map[string]any{"instances": instances}
+	if req.Dimensions > 0 {
+		body["parameters"] = map[string]any{"outputDimensionality": req.Dimensions}
+	}
+	out, _ := json.Marshal(body)
+	return out
+}
+
+// parseVertexPredict reads a :predict response of a Vertex embedding model.
+func parseVertexPredict(data []byte) embeddingResult {
+	var result embeddingResult
+	for _, prediction := range gjson.GetBytes(data, "predictions").Array() {
+		result.Vectors = append(result.Vectors, floatValues(prediction.Get("embeddings.values")))
+		result.PromptTokens += prediction.Get("embeddings.statistics.token_count").Int()
+	}
+	return result
+}
+
+// vertexEmbedBatchSizeFor returns how many instances one :predict call of model accepts.
+func vertexEmbedBatchSizeFor(model string) int {
+	if strings.HasPrefix(strings.ToLower(model), "gemini-") {
+		return 1
+	}
+	return vertexEmbedBatchSize
+}
+
+// openAIEmbeddingBody builds an OpenAI embeddings request.
+func openAIEmbeddingBody(model string, req embeddingRequest) []byte {
+	body := map[string]any{"model": model, "input": req.Inputs}
+	if req.Dimensions > 0 {
+		body["dimensions"] = req.Dimensions
+	}
+	out, _ := json.Marshal(body)
+	return out
+}
+
+// parseOpenAIEmbeddings reads an OpenAI embeddings response with float vectors.
+func parseOpenAIEmbeddings(data []byte) embeddingResult {
+	items := gjson.GetBytes(data, "data").Array()
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Get("index").Int() < items[j].Get("index").Int() })
+	result := embeddingResult{PromptTokens: gjson.GetBytes(data, "usage.prompt_tokens").Int()}
+	for _, item := range items {
+		result.Vectors = append(result.Vectors, floatValues(item.Get("embedding")))
+	}
+	return result
+}
+
+func floatValues(values gjson.Result) []float64 {
+	array := values.Array()
+	out := make([]float64, len(array))
+	for i, v := range array {
+		out[i] = v.Float()
+	}
+	return out
+}
+
+// estimateEmbeddingTokens approximates the tokens of the inputs with the o200k encoding.
+func estimateEmbeddingTokens(inputs []string) int64 {
+	enc, err := tokenizer.Get(tokenizer.O200kBase)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, input := range inputs {
+		if count, errCount := enc.Count(input); errCount == nil {
+			total += int64(count)
+		}
+	}
+	return total
+}
+
+// postEmbeddingRequest sends one upstream embeddings call and returns the response body.
+// authorize sets the credentials on the request.
+func postEmbeddingRequest(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, provider, url string, body []byte, authorize func(*http.Request)) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	authorize(httpReq)
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  provider,
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, cfg, err)
+		return nil, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("%s executor: close response body error: %v", provider, errClose)
+		}
+	}()
+	recordAPIResponseMetadata(ctx, cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, cfg, err)
+		return nil, err
+	}
+	appendAPIResponseChunk(ctx, cfg, data)
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, summarizeErrorBody(httpResp.Header.Get("Content-Type"), data))
+		return nil, statusErr{code: httpResp.StatusCode, msg: string(data)}
+	}
+	return data, nil
+}
@@ -0,0 +1,28 @@
+package executor
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func TestParseEmbeddingRequest_TokenArrays(t *testing.T) {
+	openai := sdktranslator.FromString("openai")
+	payload := []byte(`{"model":"m","input":[[1,2,3],[4,5]]}`)
+
+	req, err := parseEmbeddingRequest(openai, payload, false)
+	if err != nil {
+		t.Fatalf("forwarded request: %v", err)
+	}
+	if len(req.Inputs) != 2 {
+		t.Fatalf("inputs = %d, want 2", len(req.Inputs))
+	}
+
+	_, err = parseEmbeddingRequest(openai, payload, true)
+	var se statusErr
+	if !errors.As(err, &se) || se.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("translated request error = %v, want 400", err)
+	}
+}
@@ -82,7 +82,8 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	// Official Gemini API via API key or OAuth bearer
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	reporter.setRoute(from, to, false)
+	body := sdktranslator.TranslateRequestContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
 	body = ApplyThinkingMetadata(body, req.Metadata, req.Model)
 	body = util.ApplyDefaultThinkingIfNeeded(req.Model, body)
 	body = util.NormalizeGeminiThinkingBudget(req.Model, body)
@@ -177,7 +178,8 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	reporter.setRoute(from, to, true)
+	body := sdktranslator.TranslateRequestContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 	body = ApplyThinkingMetadata(body, req.Metadata, req.Model)
 	body = util.ApplyDefaultThinkingIfNeeded(req.Model, body)
 	body = util.NormalizeGeminiThinkingBudget(req.Model, body)
@@ -246,6 +248,7 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	stream = out
 	go func() {
 		defer close(out)
+		defer reporter.finish(ctx)
 		defer func() {
 			if errClose := httpResp.Body.Close(); errClose != nil {
 				log.Errorf("gemini executor: close response body error: %v", errClose)
@@ -255,6 +258,7 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 		scanner.Buffer(nil, streamScannerBuffer)
 		var param any
 		for scanner.Scan() {
+			reporter.markFirstChunk()
 			line := scanner.Bytes()
 			appendAPIResponseChunk(ctx, e.cfg, line)
 			filtered := FilterSSEUsageMetadata(line)
@@ -289,7 +293,7 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	translatedReq := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	translatedReq := sdktranslator.TranslateRequestContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
 	translatedReq = ApplyThinkingMetadata(translatedReq, req.Metadata, req.Model)
 	translatedReq = util.StripThinkingConfigIfUnsupported(req.Model, translatedReq)
 	translatedReq = fixGeminiImageAspectRatio(req.Model, translatedReq)
@@ -357,6 +361,43 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
 }
 
+// Embed computes embeddings with batchEmbedContents, splitting large inputs into several calls.
+func (e *GeminiExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	apiKey, bearer := geminiCreds(auth)
+
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("gemini")
+	reporter.setRoute(from, to, false)
+	embedReq, err := parseEmbeddingRequest(from, req.Payload, true)
+	if err != nil {
+		return resp, err
+	}
+	upstreamModel := util.ResolveOriginalModel(req.Model, req.Metadata)
+	url := fmt.Sprintf("%s/%s/models/%s:%s", resolveGeminiBaseURL(auth), glAPIVersion, upstreamModel, "batchEmbedContents")
+	result, err := embedInBatches(embedReq, geminiEmbedBatchSize, func(batch embeddingRequest) (embeddingResult, error) {
+		data, errPost := postEmbeddingRequest(ctx, e.cfg, auth, e.Identifier(), url, geminiBatchEmbedBody(upstreamModel, batch), func(httpReq *http.Request) {
+			if apiKey != "" {
+				httpReq.Header.Set("x-goog-api-key", apiKey)
+			} else if bearer != "" {
+				httpReq.Header.Set("Authorization", "Bearer "+bearer)
+			}
+			applyGeminiHeaders(httpReq, auth)
+		})
+		if errPost != nil {
+			return embeddingResult{}, errPost
+		}
+		return parseGeminiBatchEmbed(data, batch.Inputs), nil
+	})
+	if err != nil {
+		return resp, err
+	}
+	reporter.publish(ctx, result.detail())
+	return cliproxyexecutor.Response{Payload: formatEmbeddingResponse(from, req.Model, embedReq, result)}, nil
+}
+
 // Refresh refreshes the authentication credentials (no-op for Gemini API key).
 func (e *GeminiExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
 	return auth, nil
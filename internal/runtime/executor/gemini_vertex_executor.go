@@ -109,6 +109,63 @@ func (e *GeminiVertexExecutor) CountTokens(ctx context.Context, auth *cliproxyau
 	return e.countTokensWithAPIKey(ctx, auth, req, opts, apiKey, baseURL)
 }
 
+// Embed computes embeddings with the :predict method of the Vertex embedding models.
+func (e *GeminiVertexExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("gemini")
+	reporter.setRoute(from, to, false)
+	embedReq, err := parseEmbeddingRequest(from, req.Payload, true)
+	if err != nil {
+		return resp, err
+	}
+	upstreamModel := util.ResolveOriginalModel(req.Model, req.Metadata)
+
+	var url string
+	var authorize func(*http.Request)
+	apiKey, baseURL := vertexAPICreds(auth)
+	if apiKey != "" {
+		if baseURL == "" {
+			baseURL = "https://generativelanguage.googleapis.com"
+		}
+		url = fmt.Sprintf("%s/%s/publishers/google/models/%s:%s", baseURL, vertexAPIVersion, upstreamModel, "predict")
+		authorize = func(httpReq *http.Request) {
+			httpReq.Header.Set("x-goog-api-key", apiKey)
+			applyGeminiHeaders(httpReq, auth)
+		}
+	} else {
+		projectID, location, saJSON, errCreds := vertexCreds(auth)
+		if errCreds != nil {
+			return resp, errCreds
+		}
+		token, errTok := vertexAccessToken(ctx, e.cfg, auth, saJSON)
+		if errTok != nil {
+			log.Errorf("vertex executor: access token error: %v", errTok)
+			return resp, statusErr{code: 500, msg: "internal server error"}
+		}
+		url = fmt.Sprintf("%s/%s/projects/%s/locations/%s/publishers/google/models/%s:%s", vertexBaseURL(location), vertexAPIVersion, projectID, location, upstreamModel, "predict")
+		authorize = func(httpReq *http.Request) {
+			httpReq.Header.Set("Authorization", "Bearer "+token)
+			applyGeminiHeaders(httpReq, auth)
+		}
+	}
+
+	result, err := embedInBatches(embedReq, vertexEmbedBatchSizeFor(upstreamModel), func(batch embeddingRequest) (embeddingResult, error) {
+		data, errPost := postEmbeddingRequest(ctx, e.cfg, auth, e.Identifier(), url, vertexPredictBody(batch), authorize)
+		if errPost != nil {
+			return embeddingResult{}, errPost
+		}
+		return parseVertexPredict(data), nil
+	})
+	if err != nil {
+		return resp, err
+	}
+	reporter.publish(ctx, result.detail())
+	return cliproxyexecutor.Response{Payload: formatEmbeddingResponse(from, req.Model, embedReq, result)}, nil
+}
+
 // Refresh refreshes the authentication credentials (no-op for Vertex).
 func (e *GeminiVertexExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
 	return auth, nil
@@ -124,7 +181,8 @@ func (e *GeminiVertexExecutor) executeWithServiceAccount(ctx context.Context, au
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	reporter.setRoute(from, to, false)
+	body := sdktranslator.TranslateRequestContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
 	if budgetOverride, includeOverride, ok := util.ResolveThinkingConfigFromMetadata(req.Model, req.Metadata); ok && util.ModelSupportsThinking(req.Model) {
 		if budgetOverride != nil {
 			norm := util.NormalizeThinkingBudget(req.Model, *budgetOverride)
@@ -224,7 +282,8 @@ func (e *GeminiVertexExecutor) executeWithAPIKey(ctx context.Context, auth *clip
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	reporter.setRoute(from, to, false)
+	body := sdktranslator.TranslateRequestContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
 	if budgetOverride, includeOverride, ok := util.ResolveThinkingConfigFromMetadata(req.Model, req.Metadata); ok && util.ModelSupportsThinking(req.Model) {
 		if budgetOverride != nil {
 			norm := util.NormalizeThinkingBudget(req.Model, *budgetOverride)
@@ -325,7 +384,8 @@ func (e *GeminiVertexExecutor) executeStreamWithServiceAccount(ctx context.Conte
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	reporter.setRoute(from, to, true)
+	body := sdktranslator.TranslateRequestContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 	if budgetOverride, includeOverride, ok := util.ResolveThinkingConfigFromMetadata(req.Model, req.Metadata); ok && util.ModelSupportsThinking(req.Model) {
 		if budgetOverride != nil {
 			norm := util.NormalizeThinkingBudget(req.Model, *budgetOverride)
@@ -401,6 +461,7 @@ func (e *GeminiVertexExecutor) executeStreamWithServiceAccount(ctx context.Conte
 	stream = out
 	go func() {
 		defer close(out)
+		defer reporter.finish(ctx)
 		defer func() {
 			if errClose := httpResp.Body.Close(); errClose != nil {
 				log.Errorf("vertex executor: close response body error: %v", errClose)
@@ -410,6 +471,7 @@ func (e *GeminiVertexExecutor) executeStreamWithServiceAccount(ctx context.Conte
 		scanner.Buffer(nil, streamScannerBuffer)
 		var param any
 		for scanner.Scan() {
+			reporter.markFirstChunk()
 			line := scanner.Bytes()
 			appendAPIResponseChunk(ctx, e.cfg, line)
 			if detail, ok := parseGeminiStreamUsage(line); ok {
@@ -442,7 +504,8 @@ func (e *GeminiVertexExecutor) executeStreamWithAPIKey(ctx context.Context, auth
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	reporter.setRoute(from, to, true)
+	body := sdktranslator.TranslateRequestContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 	if budgetOverride, includeOverride, ok := util.ResolveThinkingConfigFromMetadata(req.Model, req.Metadata); ok && util.ModelSupportsThinking(req.Model) {
 		if budgetOverride != nil {
 			norm := util.NormalizeThinkingBudget(req.Model, *budgetOverride)
@@ -518,6 +581,7 @@ func (e *GeminiVertexExecutor) executeStreamWithAPIKey(ctx context.Context, auth
 	stream = out
 	go func() {
 		defer close(out)
+		defer reporter.finish(ctx)
 		defer func() {
 			if errClose := httpResp.Body.Close(); errClose != nil {
 				log.Errorf("vertex executor: close response body error: %v", errClose)
@@ -527,6 +591,7 @@ func (e *GeminiVertexExecutor) executeStreamWithAPIKey(ctx context.Context, auth
 		scanner.Buffer(nil, streamScannerBuffer)
 		var param any
 		for scanner.Scan() {
+			reporter.markFirstChunk()
 			line := scanner.Bytes()
 			appendAPIResponseChunk(ctx, e.cfg, line)
 			if detail, ok := parseGeminiStreamUsage(line); ok {
@@ -556,7 +621,7 @@ func (e *GeminiVertexExecutor) countTokensWithServiceAccount(ctx context.Context
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	translatedReq := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	translatedReq := sdktranslator.TranslateRequestContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
 	if budgetOverride, includeOverride, ok := util.ResolveThinkingConfigFromMetadata(req.Model, req.Metadata); ok && util.ModelSupportsThinking(req.Model) {
 		if budgetOverride != nil {
 			norm := util.NormalizeThinkingBudget(req.Model, *budgetOverride)
@@ -645,7 +710,7 @@ func (e *GeminiVertexExecutor) countTokensWithAPIKey(ctx context.Context, auth *
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	translatedReq := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	translatedReq := sdktranslator.TranslateRequestContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
 	if budgetOverride, includeOverride, ok := util.ResolveThinkingConfigFromMetadata(req.Model, req.Metadata); ok && util.ModelSupportsThinking(req.Model) {
 		if budgetOverride != nil {
 			norm := util.NormalizeThinkingBudget(req.Model, *budgetOverride)
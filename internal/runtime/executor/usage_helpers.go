@@ -3,14 +3,20 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -20,10 +26,25 @@ type usageReporter struct {
 	model       string
 	authID      string
 	authIndex   string
+	authType    string
 	apiKey      string
 	source      string
+	affinity    string
+	requestID   string
+	attempt     int
 	requestedAt time.Time
 	once        sync.Once
+
+	// Set by setRoute before the request is sent.
+	sourceFormat string
+	targetFormat string
+	stream       bool
+	// firstChunk holds the UnixNano time of the first stream event, or 0.
+	firstChunk atomic.Int64
+	// held keeps the usage reported by a stream until finish, so the record carries the
+	// latency of the whole stream rather than of its first usage event.
+	heldMu sync.Mutex
+	held   *usage.Detail
 }
 
 func newUsageReporter(ctx context.Context, provider, model string, auth *cliproxyauth.Auth) *usageReporter {
@@ -34,20 +55,45 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 		requestedAt: time.Now(),
 		apiKey:      apiKey,
 		source:      resolveUsageSource(auth, apiKey),
+		affinity:    cliproxyauth.SessionAffinityFromContext(ctx),
+		requestID:   logging.GetRequestID(ctx),
+		attempt:     cliproxyauth.AttemptFromContext(ctx),
 	}
 	if auth != nil {
 		reporter.authID = auth.ID
 		reporter.authIndex = auth.EnsureIndex()
+		reporter.authType, _ = auth.AccountInfo()
 	}
 	return reporter
 }
 
+// setRoute records the client and upstream schemas and whether the request streams.
+func (r *usageReporter) setRoute(from, to sdktranslator.Format, stream bool) {
+	if r == nil {
+		return
+	}
+	r.sourceFormat, r.targetFormat, r.stream = from.String(), to.String(), stream
+}
+
+// markFirstChunk records the arrival of the first upstream stream event; later calls are ignored.
+func (r *usageReporter) markFirstChunk() {
+	if r == nil {
+		return
+	}
+	r.firstChunk.CompareAndSwap(0, time.Now().UnixNano())
+}
+
 func (r *usageReporter) publish(ctx context.Context, detail usage.Detail) {
-	r.publishWithOutcome(ctx, detail, false)
+	r.publishWithOutcome(ctx, detail, http.StatusOK, "")
 }
 
+// publishFailure reports a stream that failed after it started.
 func (r *usageReporter) publishFailure(ctx context.Context) {
-	r.publishWithOutcome(ctx, usage.Detail{}, true)
+	class := "stream"
+	if ctx != nil && errors.Is(ctx.Err(), context.Canceled) {
+		class = "canceled"
+	}
+	r.publishWithOutcome(ctx, usage.Detail{}, http.StatusOK, class)
 }
 
 func (r *usageReporter) trackFailure(ctx context.Context, errPtr *error) {
@@ -55,35 +101,61 @@ func (r *usageReporter) trackFailure(ctx context.Context, errPtr *error) {
 		return
 	}
 	if *errPtr != nil {
-		r.publishFailure(ctx)
+		status, class := classifyUsageError(*errPtr)
+		r.publishWithOutcome(ctx, usage.Detail{}, status, class)
 	}
 }
 
-func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Detail, failed bool) {
+// publishWithOutcome publishes the record once; a non-empty errorClass marks it failed.
+func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Detail, status int, errorClass string) {
 	if r == nil {
 		return
 	}
+	failed := errorClass != ""
 	if detail.TotalTokens == 0 {
 		total := detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens
 		if total > 0 {
 			detail.TotalTokens = total
 		}
 	}
-	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.TotalTokens == 0 && !failed {
+	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.CacheWriteTokens == 0 && detail.TotalTokens == 0 && !failed {
 		return
 	}
+	if r.stream {
+		// Streams publish from finish; the first usage reported wins, including over a
+		// failure that follows it.
+		r.heldMu.Lock()
+		held := r.held
+		if held == nil && !failed {
+			r.held = &detail
+		}
+		r.heldMu.Unlock()
+		if !failed {
+			return
+		}
+		if held != nil {
+			r.finish(ctx)
+			return
+		}
+	}
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
-			Provider:    r.provider,
-			Model:       r.model,
-			Source:      r.source,
-			APIKey:      r.apiKey,
-			AuthID:      r.authID,
-			AuthIndex:   r.authIndex,
-			RequestedAt: r.requestedAt,
-			Failed:      failed,
-			Detail:      detail,
-		})
+		usage.PublishRecord(ctx, r.record(detail, status, errorClass))
+	})
+}
+
+// finish publishes the usage held back by a stream once the stream ends.
+func (r *usageReporter) finish(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	r.heldMu.Lock()
+	held := r.held
+	r.heldMu.Unlock()
+	if held == nil {
+		return
+	}
+	r.once.Do(func() {
+		usage.PublishRecord(ctx, r.record(*held, http.StatusOK, ""))
 	})
 }
 
@@ -95,21 +167,68 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 	if r == nil {
 		return
 	}
+	r.finish(ctx)
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
-			Provider:    r.provider,
-			Model:       r.model,
-			Source:      r.source,
-			APIKey:      r.apiKey,
-			AuthID:      r.authID,
-			AuthIndex:   r.authIndex,
-			RequestedAt: r.requestedAt,
-			Failed:      false,
-			Detail:      usage.Detail{},
-		})
+		usage.PublishRecord(ctx, r.record(usage.Detail{}, http.StatusOK, ""))
 	})
 }
 
+func (r *usageReporter) record(detail usage.Detail, status int, errorClass string) usage.Record {
+	now := time.Now()
+	record := usage.Record{
+		Provider:        r.provider,
+		Model:           r.model,
+		Source:          r.source,
+		APIKey:          r.apiKey,
+		AuthID:          r.authID,
+		AuthIndex:       r.authIndex,
+		AuthType:        r.authType,
+		RequestedAt:     r.requestedAt,
+		Failed:          errorClass != "",
+		SessionAffinity: r.affinity,
+		Detail:          detail,
+		RequestID:       r.requestID,
+		Latency:         now.Sub(r.requestedAt),
+		StatusCode:      status,
+		ErrorClass:      errorClass,
+		Stream:          r.stream,
+		Attempt:         r.attempt,
+		SourceFormat:    r.sourceFormat,
+		TargetFormat:    r.targetFormat,
+	}
+	if first := r.firstChunk.Load(); first != 0 {
+		record.TimeToFirstToken = time.Unix(0, first).Sub(r.requestedAt)
+	}
+	return record
+}
+
+// classifyUsageError maps an execution error to the upstream status and a coarse error class.
+func classifyUsageError(err error) (int, string) {
+	status := 0
+	var coder interface{ StatusCode() int }
+	if errors.As(err, &coder) {
+		status = coder.StatusCode()
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status, "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return status, "timeout"
+	case status == http.StatusTooManyRequests:
+		return status, "rate_limit"
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return status, "auth"
+	case status >= http.StatusInternalServerError:
+		return status, "upstream"
+	case status >= http.StatusBadRequest:
+		return status, "client"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return status, "timeout"
+	}
+	return status, "network"
+}
+
 func apiKeyFromContext(ctx context.Context) string {
 	if ctx == nil {
 		return ""
@@ -242,13 +361,10 @@ func parseClaudeUsage(data []byte) usage.Detail {
 		return usage.Detail{}
 	}
 	detail := usage.Detail{
-		InputTokens:  usageNode.Get("input_tokens").Int(),
-		OutputTokens: usageNode.Get("output_tokens").Int(),
-		CachedTokens: usageNode.Get("cache_read_input_tokens").Int(),
-	}
-	if detail.CachedTokens == 0 {
-		// fall back to creation tokens when read tokens are absent
-		detail.CachedTokens = usageNode.Get("cache_creation_input_tokens").Int()
+		InputTokens:      usageNode.Get("input_tokens").Int(),
+		OutputTokens:     usageNode.Get("output_tokens").Int(),
+		CachedTokens:     usageNode.Get("cache_read_input_tokens").Int(),
+		CacheWriteTokens: usageNode.Get("cache_creation_input_tokens").Int(),
 	}
 	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
 	return detail
@@ -264,12 +380,10 @@ func parseClaudeStreamUsage(line []byte) (usage.Detail, bool) {
 		return usage.Detail{}, false
 	}
 	detail := usage.Detail{
-		InputTokens:  usageNode.Get("input_tokens").Int(),
-		OutputTokens: usageNode.Get("output_tokens").Int(),
-		CachedTokens: usageNode.Get("cache_read_input_tokens").Int(),
-	}
-	if detail.CachedTokens == 0 {
-		detail.CachedTokens = usageNode.Get("cache_creation_input_tokens").Int()
+		InputTokens:      usageNode.Get("input_tokens").Int(),
+		OutputTokens:     usageNode.Get("output_tokens").Int(),
+		CachedTokens:     usageNode.Get("cache_read_input_tokens").Int(),
+		CacheWriteTokens: usageNode.Get("cache_creation_input_tokens").Int(),
 	}
 	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
 	return detail, true
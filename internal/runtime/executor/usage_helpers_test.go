@@ -0,0 +1,142 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// capturePlugin forwards the records of one request to a channel.
+type capturePlugin struct {
+	requestID string
+	records   chan usage.Record
+}
+
+func (p *capturePlugin) HandleUsage(_ context.Context, record usage.Record) {
+	if record.RequestID == p.requestID {
+		p.records <- record
+	}
+}
+
+func captureUsage(t *testing.T) *capturePlugin {
+	t.Helper()
+	plugin := &capturePlugin{requestID: t.Name(), records: make(chan usage.Record, 4)}
+	usage.RegisterPlugin(plugin)
+	return plugin
+}
+
+func (p *capturePlugin) next(t *testing.T) usage.Record {
+	t.Helper()
+	select {
+	case record := <-p.records:
+		return record
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no usage record published")
+		return usage.Record{}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestUsageReporter_Record(t *testing.T) {
+	requestedAt := time.Now().Add(-time.Second)
+	r := &usageReporter{provider: "gemini", model: "gemini-2.5-pro", apiKey: "key", attempt: 2, requestedAt: requestedAt}
+	r.setRoute(sdktranslator.FromString("openai"), sdktranslator.FromString("gemini"), true)
+
+	record := r.record(usage.Detail{InputTokens: 3}, http.StatusOK, "")
+	if record.Failed || !record.Stream || record.Attempt != 2 || record.SourceFormat != "openai" || record.TargetFormat != "gemini" {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	if record.Latency < time.Second || record.TimeToFirstToken != 0 {
+		t.Fatalf("expected the latency since the request and no TTFT, got %v and %v", record.Latency, record.TimeToFirstToken)
+	}
+
+	r.firstChunk.Store(requestedAt.Add(200 * time.Millisecond).UnixNano())
+	record = r.record(usage.Detail{}, http.StatusTooManyRequests, "rate_limit")
+	if !record.Failed || record.StatusCode != http.StatusTooManyRequests || record.ErrorClass != "rate_limit" {
+		t.Fatalf("expected a failed record, got %+v", record)
+	}
+	if record.TimeToFirstToken != 200*time.Millisecond {
+		t.Fatalf("expected a 200ms TTFT, got %v", record.TimeToFirstToken)
+	}
+}
+
+func TestUsageReporter_StreamPublishesWhenFinished(t *testing.T) {
+	plugin := captureUsage(t)
+	r := &usageReporter{model: "gemini-2.5-pro", requestID: t.Name(), requestedAt: time.Now(), stream: true}
+	r.markFirstChunk()
+	r.publish(context.Background(), usage.Detail{InputTokens: 1})
+	firstUsage := time.Since(r.requestedAt)
+	r.publish(context.Background(), usage.Detail{InputTokens: 2})
+	time.Sleep(50 * time.Millisecond)
+	r.finish(context.Background())
+	r.ensurePublished(context.Background())
+
+	record := plugin.next(t)
+	if record.Detail.InputTokens != 1 || record.Detail.TotalTokens != 1 {
+		t.Fatalf("expected the first usage to be published, got %+v", record.Detail)
+	}
+	if record.Latency < firstUsage+50*time.Millisecond {
+		t.Fatalf("expected the latency to cover the whole stream, got %v", record.Latency)
+	}
+	if record.TimeToFirstToken > firstUsage {
+		t.Fatalf("expected the TTFT to precede the first usage, got %v", record.TimeToFirstToken)
+	}
+	select {
+	case extra := <-plugin.records:
+		t.Fatalf("expected a single record, got another %+v", extra)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestUsageReporter_StreamFailureKeepsReportedUsage(t *testing.T) {
+	plugin := captureUsage(t)
+	r := &usageReporter{model: "gemini-2.5-pro", requestID: t.Name(), requestedAt: time.Now(), stream: true}
+	r.publish(context.Background(), usage.Detail{OutputTokens: 4})
+	r.publishFailure(context.Background())
+	if record := plugin.next(t); record.Failed || record.Detail.OutputTokens != 4 {
+		t.Fatalf("expected the reported usage to win over the failure, got %+v", record)
+	}
+
+	failing := &usageReporter{model: "gemini-2.5-pro", requestID: t.Name(), requestedAt: time.Now(), stream: true}
+	failing.publishFailure(context.Background())
+	failing.finish(context.Background())
+	if record := plugin.next(t); !record.Failed || record.ErrorClass != "stream" {
+		t.Fatalf("expected a failed stream record, got %+v", record)
+	}
+}
+
+func TestClassifyUsageError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		class  string
+	}{
+		{"canceled", fmt.Errorf("send: %w", context.Canceled), 0, "canceled"},
+		{"deadline", context.DeadlineExceeded, 0, "timeout"},
+		{"rate limit", statusErr{code: http.StatusTooManyRequests}, http.StatusTooManyRequests, "rate_limit"},
+		{"unauthorized", statusErr{code: http.StatusUnauthorized}, http.StatusUnauthorized, "auth"},
+		{"forbidden", fmt.Errorf("wrapped: %w", statusErr{code: http.StatusForbidden}), http.StatusForbidden, "auth"},
+		{"upstream", statusErr{code: http.StatusBadGateway}, http.StatusBadGateway, "upstream"},
+		{"client", statusErr{code: http.StatusBadRequest}, http.StatusBadRequest, "client"},
+		{"network timeout", timeoutError{}, 0, "timeout"},
+		{"network", errors.New("connection reset"), 0, "network"},
+	}
+	for _, tt := range tests {
+		status, class := classifyUsageError(tt.err)
+		if status != tt.status || class != tt.class {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, status, class, tt.status, tt.class)
+		}
+	}
+}
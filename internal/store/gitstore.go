@@ -19,6 +19,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/plumbing/transport/http"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
 )
 
 // GitTokenStore persists token records and auth metadata using git as the backing storage.
@@ -31,8 +32,14 @@ type GitTokenStore struct {
 	remote    string
 	username  string
 	password  string
+	// statePush schedules the next push of runtime state changes; nil when none is pending.
+	statePush *time.Timer
 }
 
+// runtimeStatePushDelay batches runtime state changes into one commit instead of pushing
+// every cooldown transition.
+const runtimeStatePushDelay = time.Minute
+
 // NewGitTokenStore creates a token store that saves credentials to disk through the
 // TokenStorage implementation embedded in the token record.
 func NewGitTokenStore(remote, username, password string) *GitTokenStore {
@@ -394,6 +401,84 @@ func (s *GitTokenStore) PersistAuthFiles(_ context.Context, message string, path
 	return s.commitAndPushLocked(message, filtered...)
 }
 
+// LoadStates reads the persisted auth runtime state from the repository.
+func (s *GitTokenStore) LoadStates(_ context.Context) (map[string]*cliproxyauth.RuntimeState, error) {
+	if err := s.EnsureRepository(); err != nil {
+		return nil, err
+	}
+	path := s.runtimeStatePath()
+	if path == "" {
+		return nil, fmt.Errorf("git token store: repository path not configured")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states, err := readRuntimeStates(path)
+	if err != nil {
+		return nil, fmt.Errorf("git token store: %w", err)
+	}
+	return states, nil
+}
+
+// SaveState persists the runtime state for an auth; a nil state removes it. Changes are written
+// locally right away and pushed together once runtimeStatePushDelay has passed.
+func (s *GitTokenStore) SaveState(_ context.Context, id string, state *cliproxyauth.RuntimeState) error {
+	if err := s.EnsureRepository(); err != nil {
+		return err
+	}
+	path := s.runtimeStatePath()
+	if path == "" {
+		return fmt.Errorf("git token store: repository path not configured")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := updateRuntimeStates(path, id, state); err != nil {
+		return fmt.Errorf("git token store: %w", err)
+	}
+	if s.statePush == nil {
+		s.statePush = time.AfterFunc(runtimeStatePushDelay, s.pushRuntimeState)
+	}
+	return nil
+}
+
+// FlushStates commits and pushes the runtime state changes still waiting for the push delay,
+// so a shutdown inside the delay does not lose them.
+func (s *GitTokenStore) FlushStates(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.statePush == nil {
+		return nil
+	}
+	s.statePush.Stop()
+	return s.pushRuntimeStateLocked()
+}
+
+// pushRuntimeState commits and pushes the runtime state changes accumulated since the last push.
+func (s *GitTokenStore) pushRuntimeState() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.pushRuntimeStateLocked(); err != nil {
+		log.Warnf("git token store: failed to push runtime state: %v", err)
+	}
+}
+
+func (s *GitTokenStore) pushRuntimeStateLocked() error {
+	s.statePush = nil
+	rel, err := s.relativeToRepo(s.runtimeStatePath())
+	if err != nil {
+		return err
+	}
+	return s.commitAndPushLocked("Update runtime state", rel)
+}
+
+// runtimeStatePath places the state file in its own directory next to the auth and config dirs.
+func (s *GitTokenStore) runtimeStatePath() string {
+	repoDir := s.repoDirSnapshot()
+	if repoDir == "" {
+		return ""
+	}
+	return filepath.Join(repoDir, "state", runtimeStateFileName)
+}
+
 func (s *GitTokenStore) resolveDeletePath(id string) (string, error) {
 	if strings.ContainsRune(id, os.PathSeparator) || filepath.IsAbs(id) {
 		return id, nil
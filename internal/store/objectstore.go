@@ -25,6 +25,7 @@ import (
 const (
 	objectStoreConfigKey  = "config/config.yaml"
 	objectStoreAuthPrefix = "auths"
+	objectStoreStateKey   = "state/" + runtimeStateFileName
 )
 
 // ObjectStoreConfig captures configuration for the object storage-backed token store.
@@ -324,6 +325,55 @@ func (s *ObjectTokenStore) PersistConfig(ctx context.Context) error {
 	return s.putObject(ctx, objectStoreConfigKey, data, "application/x-yaml")
 }
 
+// LoadStates downloads the persisted auth runtime state and mirrors it to the local workspace.
+func (s *ObjectTokenStore) LoadStates(ctx context.Context) (map[string]*cliproxyauth.RuntimeState, error) {
+	key := s.prefixedKey(objectStoreStateKey)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, err := s.client.GetObject(ctx, s.cfg.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("object store: fetch runtime state: %w", err)
+	}
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if isObjectNotFound(err) {
+			return make(map[string]*cliproxyauth.RuntimeState), nil
+		}
+		return nil, fmt.Errorf("object store: read runtime state: %w", err)
+	}
+	states, err := decodeRuntimeStates(data)
+	if err != nil {
+		return nil, fmt.Errorf("object store: %w", err)
+	}
+	local := s.runtimeStatePath()
+	if errMkdir := os.MkdirAll(filepath.Dir(local), 0o700); errMkdir != nil {
+		return nil, fmt.Errorf("object store: prepare state directory: %w", errMkdir)
+	}
+	if errWrite := os.WriteFile(local, data, 0o600); errWrite != nil {
+		return nil, fmt.Errorf("object store: write runtime state: %w", errWrite)
+	}
+	return states, nil
+}
+
+// SaveState persists the runtime state for an auth and uploads it; a nil state removes it.
+func (s *ObjectTokenStore) SaveState(ctx context.Context, id string, state *cliproxyauth.RuntimeState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := updateRuntimeStates(s.runtimeStatePath(), id, state)
+	if err != nil {
+		return fmt.Errorf("object store: %w", err)
+	}
+	if len(data) == 0 {
+		return s.deleteObject(ctx, objectStoreStateKey)
+	}
+	return s.putObject(ctx, objectStoreStateKey, data, "application/json")
+}
+
+func (s *ObjectTokenStore) runtimeStatePath() string {
+	return filepath.Join(s.spoolRoot, "state", runtimeStateFileName)
+}
+
 func (s *ObjectTokenStore) ensureBucket(ctx context.Context) error {
 	exists, err := s.client.BucketExists(ctx, s.cfg.Bucket)
 	if err != nil {
@@ -22,6 +22,7 @@ import (
 const (
 	defaultConfigTable = "config_store"
 	defaultAuthTable   = "auth_store"
+	defaultStateTable  = "auth_state_store"
 	defaultConfigKey   = "config"
 )
 
@@ -31,6 +32,7 @@ type PostgresStoreConfig struct {
 	Schema      string
 	ConfigTable string
 	AuthTable   string
+	StateTable  string
 	SpoolDir    string
 }
 
@@ -58,6 +60,9 @@ func NewPostgresStore(ctx context.Context, cfg PostgresStoreConfig) (*PostgresSt
 	if cfg.AuthTable == "" {
 		cfg.AuthTable = defaultAuthTable
 	}
+	if cfg.StateTable == "" {
+		cfg.StateTable = defaultStateTable
+	}
 
 	spoolRoot := strings.TrimSpace(cfg.SpoolDir)
 	if spoolRoot == "" {
@@ -140,6 +145,16 @@ func (s *PostgresStore) EnsureSchema(ctx context.Context) error {
 	`, authTable)); err != nil {
 		return fmt.Errorf("postgres store: create auth table: %w", err)
 	}
+	stateTable := s.fullTableName(s.cfg.StateTable)
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			content JSONB NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`, stateTable)); err != nil {
+		return fmt.Errorf("postgres store: create state table: %w", err)
+	}
 	return nil
 }
 
@@ -342,6 +357,63 @@ func (s *PostgresStore) Delete(ctx context.Context, id string) error {
 	return s.deleteAuthRecord(ctx, relID)
 }
 
+// LoadStates returns the persisted auth runtime state keyed by auth ID.
+func (s *PostgresStore) LoadStates(ctx context.Context) (map[string]*cliproxyauth.RuntimeState, error) {
+	query := fmt.Sprintf("SELECT id, content FROM %s", s.fullTableName(s.cfg.StateTable))
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("postgres store: list runtime state: %w", err)
+	}
+	defer rows.Close()
+
+	states := make(map[string]*cliproxyauth.RuntimeState)
+	for rows.Next() {
+		var (
+			id      string
+			payload string
+		)
+		if err = rows.Scan(&id, &payload); err != nil {
+			return nil, fmt.Errorf("postgres store: scan runtime state row: %w", err)
+		}
+		state := &cliproxyauth.RuntimeState{}
+		if err = json.Unmarshal([]byte(payload), state); err != nil {
+			log.WithError(err).Warnf("postgres store: skipping runtime state %s with invalid json", id)
+			continue
+		}
+		states[id] = state
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres store: iterate runtime state rows: %w", err)
+	}
+	return states, nil
+}
+
+// SaveState upserts the runtime state for an auth; a nil state removes the record.
+func (s *PostgresStore) SaveState(ctx context.Context, id string, state *cliproxyauth.RuntimeState) error {
+	table := s.fullTableName(s.cfg.StateTable)
+	if state == nil {
+		query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", table)
+		if _, err := s.db.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("postgres store: delete runtime state: %w", err)
+		}
+		return nil
+	}
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("postgres store: marshal runtime state: %w", err)
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, content, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (id)
+		DO UPDATE SET content = EXCLUDED.content, updated_at = NOW()
+	`, table)
+	if _, err = s.db.ExecContext(ctx, query, id, json.RawMessage(payload)); err != nil {
+		return fmt.Errorf("postgres store: upsert runtime state: %w", err)
+	}
+	return nil
+}
+
 // PersistAuthFiles stores the provided auth file changes in PostgreSQL.
 func (s *PostgresStore) PersistAuthFiles(ctx context.Context, _ string, paths ...string) error {
 	if len(paths) == 0 {
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// runtimeStateFileName is the local file holding auth runtime state (cooldowns and model
+// states) for the git and object stores. It lives outside the auth directory so the watcher
+// and auth listing never treat it as a credential.
+const runtimeStateFileName = "runtime-state.json"
+
+func readRuntimeStates(path string) (map[string]*cliproxyauth.RuntimeState, error) {
+	states := make(map[string]*cliproxyauth.RuntimeState)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return states, nil
+		}
+		return nil, fmt.Errorf("read runtime state: %w", err)
+	}
+	return decodeRuntimeStates(data)
+}
+
+func decodeRuntimeStates(data []byte) (map[string]*cliproxyauth.RuntimeState, error) {
+	states := make(map[string]*cliproxyauth.RuntimeState)
+	if len(data) == 0 {
+		return states, nil
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("decode runtime state: %w", err)
+	}
+	return states, nil
+}
+
+// updateRuntimeStates applies a single auth state change to the local state file and returns
+// the encoded document; an empty result means the file was removed.
+func updateRuntimeStates(path, id string, state *cliproxyauth.RuntimeState) ([]byte, error) {
+	states, err := readRuntimeStates(path)
+	if err != nil {
+		states = make(map[string]*cliproxyauth.RuntimeState)
+	}
+	if state == nil {
+		delete(states, id)
+	} else {
+		states[id] = state
+	}
+	if len(states) == 0 {
+		if errRemove := os.Remove(path); errRemove != nil && !os.IsNotExist(errRemove) {
+			return nil, fmt.Errorf("remove runtime state: %w", errRemove)
+		}
+		return nil, nil
+	}
+	raw, err := json.Marshal(states)
+	if err != nil {
+		return nil, fmt.Errorf("encode runtime state: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create runtime state dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, raw, 0o600); err != nil {
+		return nil, fmt.Errorf("write runtime state: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("rename runtime state: %w", err)
+	}
+	return raw, nil
+}
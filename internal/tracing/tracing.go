@@ -0,0 +1,203 @@
+// Package tracing configures OpenTelemetry tracing for the proxy: the tracer provider and
+// exporter, the inbound gin middleware and the instrumented upstream transport. Spans for
+// request routing, credential attempts and translations are created by the packages doing
+// that work through the global tracer provider, so they cost nothing while tracing is off.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifies the tracer used across the proxy.
+const InstrumentationName = "github.com/router-for-me/CLIProxyAPI/v6"
+
+const (
+	exporterOTLPHTTP   = "otlp-http"
+	exporterStdout     = "stdout"
+	defaultServiceName = "cli-proxy-api"
+)
+
+// enabled reports whether Setup installed a recording tracer provider.
+var enabled atomic.Bool
+
+// propagateHosts holds the upstream host patterns that receive the trace context.
+var propagateHosts atomic.Pointer[[]string]
+
+// Enabled reports whether tracing is active.
+func Enabled() bool { return enabled.Load() }
+
+// Tracer returns the proxy tracer from the global provider.
+func Tracer() trace.Tracer { return otel.Tracer(InstrumentationName) }
+
+// Setup installs the global tracer provider described by cfg and returns a function flushing
+// and stopping it. When tracing is disabled the no-op provider stays in place.
+func Setup(cfg config.TracingConfig) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if !cfg.Enabled {
+		return noop, nil
+	}
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		return noop, err
+	}
+	serviceName := strings.TrimSpace(cfg.ServiceName)
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+		attribute.String("service.version", buildinfo.Version),
+	))
+	if err != nil {
+		return noop, fmt.Errorf("tracing: build resource: %w", err)
+	}
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	hosts := make([]string, 0, len(cfg.PropagateHosts))
+	for _, host := range cfg.PropagateHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	propagateHosts.Store(&hosts)
+	enabled.Store(true)
+	return provider.Shutdown, nil
+}
+
+func newExporter(cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Exporter)) {
+	case exporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "", exporterOTLPHTTP:
+		var opts []otlptracehttp.Option
+		if endpoint := strings.TrimSpace(cfg.Endpoint); endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("tracing: unsupported exporter %q", cfg.Exporter)
+	}
+}
+
+// Middleware starts a server span for every inbound request, continuing the caller's W3C trace
+// context when present.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		ctx, span := Tracer().Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+			),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// WrapTransport instruments upstream calls made through base (http.DefaultTransport when nil)
+// with a client span lasting until the response headers arrive, and injects the trace context
+// for hosts listed in propagate-hosts.
+func WrapTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if _, ok := base.(*transport); ok {
+		return base
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return t.base.RoundTrip(req)
+	}
+	ctx, span := Tracer().Start(ctx, "upstream "+req.Method+" "+req.URL.Host,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.path", req.URL.Path),
+		),
+	)
+	defer span.End()
+	req = req.WithContext(ctx)
+	if shouldPropagate(req.URL.Hostname()) {
+		req = req.Clone(ctx)
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// shouldPropagate reports whether host may receive the trace context.
+func shouldPropagate(host string) bool {
+	hosts := propagateHosts.Load()
+	if hosts == nil {
+		return false
+	}
+	host = strings.ToLower(host)
+	for _, pattern := range *hosts {
+		if sdkaccess.MatchPattern(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
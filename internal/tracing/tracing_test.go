@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func installRecorder(t *testing.T, hosts ...string) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	propagateHosts.Store(&hosts)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		propagateHosts.Store(nil)
+	})
+	return recorder
+}
+
+func TestTransport_PropagatesOnlyToListedHosts(t *testing.T) {
+	recorder := installRecorder(t, "127.0.0.1")
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	ctx, span := Tracer().Start(context.Background(), "parent")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL+"/v1/models", nil)
+	resp, err := WrapTransport(nil).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	_ = resp.Body.Close()
+	span.End()
+
+	if traceparent == "" {
+		t.Fatalf("trace context not propagated to a listed host")
+	}
+	if req.Header.Get("traceparent") != "" {
+		t.Fatalf("caller's request was modified")
+	}
+	if got := len(recorder.Ended()); got != 2 {
+		t.Fatalf("expected parent and upstream spans, got %d", got)
+	}
+
+	propagateHosts.Store(&[]string{"*.example.com"})
+	traceparent = ""
+	ctx, span = Tracer().Start(context.Background(), "parent")
+	req, _ = http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL, nil)
+	resp, err = WrapTransport(nil).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	_ = resp.Body.Close()
+	span.End()
+	if traceparent != "" {
+		t.Fatalf("trace context propagated to an unlisted host")
+	}
+}
+
+func TestMiddleware_ContinuesInboundTrace(t *testing.T) {
+	recorder := installRecorder(t)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Middleware())
+	engine.POST("/v1/chat/completions", func(c *gin.Context) { c.Status(http.StatusBadGateway) })
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "POST /v1/chat/completions" {
+		t.Fatalf("unexpected span name %q", span.Name())
+	}
+	if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("inbound trace not continued: %s", span.SpanContext().TraceID())
+	}
+	if span.Status().Code.String() != "Error" {
+		t.Fatalf("expected error status for 502, got %v", span.Status())
+	}
+}
@@ -12,7 +12,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
 )
 
 var statisticsEnabled atomic.Bool
@@ -22,7 +26,7 @@ func init() {
 	coreusage.RegisterPlugin(NewLoggerPlugin())
 }
 
-// LoggerPlugin collects in-memory request statistics for usage analysis.
+// LoggerPlugin collects request statistics for usage analysis.
 // It implements coreusage.Plugin to receive usage records emitted by the runtime.
 type LoggerPlugin struct {
 	stats *RequestStatistics
@@ -56,61 +60,80 @@ func SetStatisticsEnabled(enabled bool) { statisticsEnabled.Store(enabled) }
 // StatisticsEnabled reports the current recording state.
 func StatisticsEnabled() bool { return statisticsEnabled.Load() }
 
-// RequestStatistics maintains aggregated request metrics in memory.
-type RequestStatistics struct {
-	mu sync.RWMutex
+// checkpointDelay batches rollup checkpoints triggered by usage records.
+const checkpointDelay = 5 * time.Second
 
-	totalRequests int64
-	successCount  int64
-	failureCount  int64
-	totalTokens   int64
+// pruneInterval is how often expired details and rollups are dropped while recording.
+const pruneInterval = time.Hour
 
-	apis map[string]*apiStats
+// RequestStatistics maintains aggregated request metrics. Every request is folded into hourly
+// and daily rollups, and its detail is kept while within the detail retention. With a Store
+// attached, details are persisted as they arrive and the rollups are checkpointed, so the
+// statistics survive restarts.
+type RequestStatistics struct {
+	mu sync.Mutex
 
-	requestsByDay  map[string]int64
-	requestsByHour map[int]int64
-	tokensByDay    map[string]int64
-	tokensByHour   map[int]int64
-}
+	rollups Rollups
+	details map[string]map[string][]RequestDetail
 
-// apiStats holds aggregated metrics for a single API key.
-type apiStats struct {
-	TotalRequests int64
-	TotalTokens   int64
-	Models        map[string]*modelStats
-}
+	retention Retention
+	lastPrune time.Time
+	now       func() time.Time
 
-// modelStats holds aggregated metrics for a specific model within an API.
-type modelStats struct {
-	TotalRequests int64
-	TotalTokens   int64
-	Details       []RequestDetail
+	store        Store
+	storeFailing bool
+	dirty        bool
+	saveTimer    *time.Timer
 }
 
 // RequestDetail stores the timestamp and token usage for a single request.
 type RequestDetail struct {
-	Timestamp time.Time  `json:"timestamp"`
-	Source    string     `json:"source"`
-	AuthIndex string     `json:"auth_index"`
-	Tokens    TokenStats `json:"tokens"`
-	Failed    bool       `json:"failed"`
+	Timestamp       time.Time  `json:"timestamp"`
+	Source          string     `json:"source"`
+	AuthIndex       string     `json:"auth_index"`
+	Tokens          TokenStats `json:"tokens"`
+	Failed          bool       `json:"failed"`
+	SessionAffinity string     `json:"session_affinity,omitempty"`
+	Hedged          bool       `json:"hedged,omitempty"`
+	// Cost is the price of the request under the pricing table in effect when it was recorded.
+	Cost float64 `json:"cost,omitempty"`
+
+	RequestID string `json:"request_id,omitempty"`
+	// LatencyMs is the upstream latency until usage was reported; TTFTMs the time to the
+	// first stream event.
+	LatencyMs    int64  `json:"latency_ms,omitempty"`
+	TTFTMs       int64  `json:"ttft_ms,omitempty"`
+	StatusCode   int    `json:"status_code,omitempty"`
+	ErrorClass   string `json:"error_class,omitempty"`
+	Stream       bool   `json:"stream,omitempty"`
+	Attempt      int    `json:"attempt,omitempty"`
+	SourceFormat string `json:"source_format,omitempty"`
+	TargetFormat string `json:"target_format,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
 type TokenStats struct {
-	InputTokens     int64 `json:"input_tokens"`
-	OutputTokens    int64 `json:"output_tokens"`
-	ReasoningTokens int64 `json:"reasoning_tokens"`
-	CachedTokens    int64 `json:"cached_tokens"`
-	TotalTokens     int64 `json:"total_tokens"`
+	InputTokens      int64 `json:"input_tokens"`
+	OutputTokens     int64 `json:"output_tokens"`
+	ReasoningTokens  int64 `json:"reasoning_tokens"`
+	CachedTokens     int64 `json:"cached_tokens"`
+	CacheWriteTokens int64 `json:"cache_write_tokens,omitempty"`
+	TotalTokens      int64 `json:"total_tokens"`
 }
 
-// StatisticsSnapshot represents an immutable view of the aggregated metrics.
+// StatisticsSnapshot represents an immutable view of the aggregated metrics. Totals and the
+// per-day maps come from the daily rollups, the per-hour maps from the hourly rollups and the
+// details from the retained request details.
 type StatisticsSnapshot struct {
 	TotalRequests int64 `json:"total_requests"`
 	SuccessCount  int64 `json:"success_count"`
 	FailureCount  int64 `json:"failure_count"`
 	TotalTokens   int64 `json:"total_tokens"`
+	// HedgedRequests counts requests that fired a hedged attempt on a second credential.
+	HedgedRequests int64 `json:"hedged_requests"`
+	// TotalCost is the cost of all requests in Currency.
+	TotalCost float64 `json:"total_cost"`
+	Currency  string  `json:"currency,omitempty"`
 
 	APIs map[string]APISnapshot `json:"apis"`
 
@@ -118,12 +141,18 @@ type StatisticsSnapshot struct {
 	RequestsByHour map[string]int64 `json:"requests_by_hour"`
 	TokensByDay    map[string]int64 `json:"tokens_by_day"`
 	TokensByHour   map[string]int64 `json:"tokens_by_hour"`
+
+	CostByDay   map[string]float64 `json:"cost_by_day"`
+	CostByModel map[string]float64 `json:"cost_by_model"`
+	// CostByCredential is keyed by credential auth index.
+	CostByCredential map[string]float64 `json:"cost_by_credential"`
 }
 
 // APISnapshot summarises metrics for a single API key.
 type APISnapshot struct {
 	TotalRequests int64                    `json:"total_requests"`
 	TotalTokens   int64                    `json:"total_tokens"`
+	TotalCost     float64                  `json:"total_cost"`
 	Models        map[string]ModelSnapshot `json:"models"`
 }
 
@@ -131,6 +160,7 @@ type APISnapshot struct {
 type ModelSnapshot struct {
 	TotalRequests int64           `json:"total_requests"`
 	TotalTokens   int64           `json:"total_tokens"`
+	TotalCost     float64         `json:"total_cost"`
 	Details       []RequestDetail `json:"details"`
 }
 
@@ -139,15 +169,87 @@ var defaultRequestStatistics = NewRequestStatistics()
 // GetRequestStatistics returns the shared statistics store.
 func GetRequestStatistics() *RequestStatistics { return defaultRequestStatistics }
 
-// NewRequestStatistics constructs an empty statistics store.
+// NewRequestStatistics constructs an empty in-memory statistics store that keeps everything.
 func NewRequestStatistics() *RequestStatistics {
 	return &RequestStatistics{
-		apis:           make(map[string]*apiStats),
-		requestsByDay:  make(map[string]int64),
-		requestsByHour: make(map[int]int64),
-		tokensByDay:    make(map[string]int64),
-		tokensByHour:   make(map[int]int64),
+		rollups: newRollups(),
+		details: make(map[string]map[string][]RequestDetail),
+		now:     time.Now,
+	}
+}
+
+// SetRetention changes how long details and rollups are kept and drops what has expired.
+func (s *RequestStatistics) SetRetention(retention Retention) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = retention
+	s.pruneLocked(s.now())
+}
+
+// SetStore attaches a persistent store and restores the statistics it holds: the checkpointed
+// rollups, the entries appended after that checkpoint and the details still within retention.
+// Statistics recorded before the call are kept and included in the next checkpoint.
+func (s *RequestStatistics) SetStore(store Store) error {
+	if s == nil || store == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var pending, retained []Entry
+	restored, err := store.Restore(func(entry Entry, isPending bool) {
+		if isPending {
+			pending = append(pending, entry)
+		}
+		retained = append(retained, entry)
+	})
+	if err != nil {
+		return err
+	}
+	rollups := newRollups()
+	rollups.merge(restored)
+	for _, entry := range pending {
+		rollups.add(entry.API, entry.Model, entry.Detail)
+	}
+	rollups.merge(s.rollups)
+	s.rollups = rollups
+	for _, entry := range retained {
+		s.keepDetailLocked(entry.API, entry.Model, entry.Detail)
+	}
+	s.store = store
+	s.pruneLocked(s.now())
+	if len(pending) > 0 || s.dirty {
+		s.scheduleCheckpointLocked()
+	}
+	return nil
+}
+
+// Close writes a final checkpoint and closes the attached store. The in-memory statistics are
+// cleared, since the store holds them and restores them when attached again.
+func (s *RequestStatistics) Close() error {
+	if s == nil {
+		return nil
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saveTimer != nil {
+		s.saveTimer.Stop()
+		s.saveTimer = nil
+	}
+	if s.store == nil {
+		return nil
+	}
+	err := s.checkpointLocked()
+	if errClose := s.store.Close(); err == nil {
+		err = errClose
+	}
+	s.store = nil
+	s.rollups = newRollups()
+	s.details = make(map[string]map[string][]RequestDetail)
+	s.dirty = false
+	return err
 }
 
 // Record ingests a new usage record and updates the aggregates.
@@ -163,8 +265,11 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		timestamp = time.Now()
 	}
 	detail := normaliseDetail(record.Detail)
-	totalTokens := detail.TotalTokens
-	statsKey := record.APIKey
+	statsKey := resolveKeyLabel(ctx)
+	if statsKey == "" && record.APIKey != "" {
+		// Unlabelled keys are listed and persisted masked, never in plain text.
+		statsKey = util.HideAPIKey(record.APIKey)
+	}
 	if statsKey == "" {
 		statsKey = resolveAPIIdentifier(ctx, record)
 	}
@@ -172,55 +277,137 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 	if !failed {
 		failed = !resolveSuccess(ctx)
 	}
-	success := !failed
 	modelName := record.Model
 	if modelName == "" {
 		modelName = "unknown"
 	}
-	dayKey := timestamp.Format("2006-01-02")
-	hourKey := timestamp.Hour()
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.totalRequests++
-	if success {
-		s.successCount++
-	} else {
-		s.failureCount++
+	s.addLocked(statsKey, modelName, RequestDetail{
+		Timestamp:       timestamp,
+		Source:          record.Source,
+		AuthIndex:       record.AuthIndex,
+		Tokens:          detail,
+		Failed:          failed,
+		SessionAffinity: record.SessionAffinity,
+		Hedged:          record.Hedged,
+		Cost:            pricing.Default().Cost(record),
+		RequestID:       record.RequestID,
+		LatencyMs:       record.Latency.Milliseconds(),
+		TTFTMs:          record.TimeToFirstToken.Milliseconds(),
+		StatusCode:      record.StatusCode,
+		ErrorClass:      record.ErrorClass,
+		Stream:          record.Stream,
+		Attempt:         record.Attempt,
+		SourceFormat:    record.SourceFormat,
+		TargetFormat:    record.TargetFormat,
+	})
+}
+
+// addLocked folds a detail into the rollups, keeps it while within retention and appends it to
+// the store. The caller must hold s.mu.
+func (s *RequestStatistics) addLocked(api, model string, detail RequestDetail) {
+	now := s.now()
+	timestamp := detail.Timestamp.Local()
+	if s.retention.Hourly == 0 || !timestamp.Before(now.Add(-s.retention.Hourly)) {
+		s.rollups.Hourly.bucket(timestamp.Format(hourLayout), api, model).add(detail)
+	}
+	if s.retention.Daily == 0 || !timestamp.Before(now.Add(-s.retention.Daily)) {
+		s.rollups.Daily.bucket(timestamp.Format(dayLayout), api, model).add(detail)
+	}
+	s.keepDetailLocked(api, model, detail)
+	if s.store != nil {
+		if err := s.store.Append(Entry{API: api, Model: model, Detail: detail}); err != nil {
+			if !s.storeFailing {
+				log.Warnf("failed to persist usage detail: %v", err)
+			}
+			s.storeFailing = true
+		} else {
+			s.storeFailing = false
+		}
+		s.scheduleCheckpointLocked()
+	}
+	if now.Sub(s.lastPrune) >= pruneInterval {
+		s.pruneLocked(now)
 	}
-	s.totalTokens += totalTokens
+}
 
-	stats, ok := s.apis[statsKey]
+// keepDetailLocked retains a detail unless it is already past the detail retention.
+func (s *RequestStatistics) keepDetailLocked(api, model string, detail RequestDetail) {
+	if s.retention.Details > 0 && detail.Timestamp.Before(s.now().Add(-s.retention.Details)) {
+		return
+	}
+	models, ok := s.details[api]
 	if !ok {
-		stats = &apiStats{Models: make(map[string]*modelStats)}
-		s.apis[statsKey] = stats
-	}
-	s.updateAPIStats(stats, modelName, RequestDetail{
-		Timestamp: timestamp,
-		Source:    record.Source,
-		AuthIndex: record.AuthIndex,
-		Tokens:    detail,
-		Failed:    failed,
-	})
+		models = make(map[string][]RequestDetail)
+		s.details[api] = models
+	}
+	models[model] = append(models[model], detail)
+}
 
-	s.requestsByDay[dayKey]++
-	s.requestsByHour[hourKey]++
-	s.tokensByDay[dayKey] += totalTokens
-	s.tokensByHour[hourKey] += totalTokens
+// pruneLocked drops details and rollups past their retention, in memory and in the store.
+func (s *RequestStatistics) pruneLocked(now time.Time) {
+	s.lastPrune = now
+	if s.retention.Details > 0 {
+		cutoff := now.Add(-s.retention.Details)
+		for api, models := range s.details {
+			for model, details := range models {
+				kept := details[:0]
+				for _, detail := range details {
+					if !detail.Timestamp.Before(cutoff) {
+						kept = append(kept, detail)
+					}
+				}
+				if len(kept) == 0 {
+					delete(models, model)
+					continue
+				}
+				models[model] = kept
+			}
+			if len(models) == 0 {
+				delete(s.details, api)
+			}
+		}
+		if s.store != nil {
+			if err := s.store.Prune(cutoff); err != nil {
+				log.Warnf("failed to prune usage store: %v", err)
+			}
+		}
+	}
+	if s.retention.Hourly > 0 {
+		s.rollups.Hourly.prune(hourLayout, now.Add(-s.retention.Hourly))
+	}
+	if s.retention.Daily > 0 {
+		s.rollups.Daily.prune(dayLayout, now.Add(-s.retention.Daily))
+	}
 }
 
-func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail RequestDetail) {
-	stats.TotalRequests++
-	stats.TotalTokens += detail.Tokens.TotalTokens
-	modelStatsValue, ok := stats.Models[model]
-	if !ok {
-		modelStatsValue = &modelStats{}
-		stats.Models[model] = modelStatsValue
+func (s *RequestStatistics) scheduleCheckpointLocked() {
+	s.dirty = true
+	if s.store == nil || s.saveTimer != nil {
+		return
+	}
+	s.saveTimer = time.AfterFunc(checkpointDelay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.saveTimer = nil
+		if err := s.checkpointLocked(); err != nil {
+			log.Warnf("failed to checkpoint usage statistics: %v", err)
+		}
+	})
+}
+
+func (s *RequestStatistics) checkpointLocked() error {
+	if s.store == nil || !s.dirty {
+		return nil
+	}
+	if err := s.store.Checkpoint(s.rollups); err != nil {
+		return err
 	}
-	modelStatsValue.TotalRequests++
-	modelStatsValue.TotalTokens += detail.Tokens.TotalTokens
-	modelStatsValue.Details = append(modelStatsValue.Details, detail)
+	s.dirty = false
+	return nil
 }
 
 // Snapshot returns a copy of the aggregated metrics for external consumption.
@@ -230,65 +417,107 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 		return result
 	}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	result.TotalRequests = s.totalRequests
-	result.SuccessCount = s.successCount
-	result.FailureCount = s.failureCount
-	result.TotalTokens = s.totalTokens
+	result.Currency = pricing.Default().Currency()
+	result.APIs = make(map[string]APISnapshot)
+	result.RequestsByDay = make(map[string]int64, len(s.rollups.Daily))
+	result.TokensByDay = make(map[string]int64, len(s.rollups.Daily))
+	result.CostByDay = make(map[string]float64, len(s.rollups.Daily))
+	result.CostByModel = make(map[string]float64)
+	result.CostByCredential = make(map[string]float64)
+	for day, apis := range s.rollups.Daily {
+		for apiName, models := range apis {
+			apiSnapshot := snapshotAPI(result.APIs, apiName)
+			for modelName, rollup := range models {
+				if rollup == nil {
+					continue
+				}
+				result.TotalRequests += rollup.Requests
+				result.FailureCount += rollup.Failures
+				result.TotalTokens += rollup.Tokens.TotalTokens
+				result.HedgedRequests += rollup.Hedged
+				result.RequestsByDay[day] += rollup.Requests
+				result.TokensByDay[day] += rollup.Tokens.TotalTokens
+				result.TotalCost += rollup.Cost
+				result.CostByDay[day] += rollup.Cost
+				result.CostByModel[modelName] += rollup.Cost
+				for authIndex, cost := range rollup.CostByAuth {
+					result.CostByCredential[authIndex] += cost
+				}
 
-	result.APIs = make(map[string]APISnapshot, len(s.apis))
-	for apiName, stats := range s.apis {
-		apiSnapshot := APISnapshot{
-			TotalRequests: stats.TotalRequests,
-			TotalTokens:   stats.TotalTokens,
-			Models:        make(map[string]ModelSnapshot, len(stats.Models)),
-		}
-		for modelName, modelStatsValue := range stats.Models {
-			requestDetails := make([]RequestDetail, len(modelStatsValue.Details))
-			copy(requestDetails, modelStatsValue.Details)
-			apiSnapshot.Models[modelName] = ModelSnapshot{
-				TotalRequests: modelStatsValue.TotalRequests,
-				TotalTokens:   modelStatsValue.TotalTokens,
-				Details:       requestDetails,
+				apiSnapshot.TotalRequests += rollup.Requests
+				apiSnapshot.TotalTokens += rollup.Tokens.TotalTokens
+				apiSnapshot.TotalCost += rollup.Cost
+				modelSnapshot := apiSnapshot.Models[modelName]
+				modelSnapshot.TotalRequests += rollup.Requests
+				modelSnapshot.TotalTokens += rollup.Tokens.TotalTokens
+				modelSnapshot.TotalCost += rollup.Cost
+				apiSnapshot.Models[modelName] = modelSnapshot
 			}
+			result.APIs[apiName] = apiSnapshot
 		}
-		result.APIs[apiName] = apiSnapshot
 	}
+	result.SuccessCount = result.TotalRequests - result.FailureCount
 
-	result.RequestsByDay = make(map[string]int64, len(s.requestsByDay))
-	for k, v := range s.requestsByDay {
-		result.RequestsByDay[k] = v
-	}
-
-	result.RequestsByHour = make(map[string]int64, len(s.requestsByHour))
-	for hour, v := range s.requestsByHour {
-		key := formatHour(hour)
-		result.RequestsByHour[key] = v
+	for apiName, models := range s.details {
+		apiSnapshot := snapshotAPI(result.APIs, apiName)
+		for modelName, details := range models {
+			modelSnapshot := apiSnapshot.Models[modelName]
+			modelSnapshot.Details = append(make([]RequestDetail, 0, len(details)), details...)
+			apiSnapshot.Models[modelName] = modelSnapshot
+		}
+		result.APIs[apiName] = apiSnapshot
 	}
-
-	result.TokensByDay = make(map[string]int64, len(s.tokensByDay))
-	for k, v := range s.tokensByDay {
-		result.TokensByDay[k] = v
+	for apiName, apiSnapshot := range result.APIs {
+		for modelName, modelSnapshot := range apiSnapshot.Models {
+			if modelSnapshot.Details == nil {
+				modelSnapshot.Details = []RequestDetail{}
+				apiSnapshot.Models[modelName] = modelSnapshot
+			}
+		}
+		result.APIs[apiName] = apiSnapshot
 	}
 
-	result.TokensByHour = make(map[string]int64, len(s.tokensByHour))
-	for hour, v := range s.tokensByHour {
-		key := formatHour(hour)
-		result.TokensByHour[key] = v
+	result.RequestsByHour = make(map[string]int64)
+	result.TokensByHour = make(map[string]int64)
+	for period, apis := range s.rollups.Hourly {
+		start, err := time.ParseInLocation(hourLayout, period, time.Local)
+		if err != nil {
+			continue
+		}
+		key := formatHour(start.Hour())
+		for _, models := range apis {
+			for _, rollup := range models {
+				if rollup == nil {
+					continue
+				}
+				result.RequestsByHour[key] += rollup.Requests
+				result.TokensByHour[key] += rollup.Tokens.TotalTokens
+			}
+		}
 	}
 
 	return result
 }
 
+// snapshotAPI returns the snapshot of apiName in apis, or a new empty one.
+func snapshotAPI(apis map[string]APISnapshot, apiName string) APISnapshot {
+	apiSnapshot, ok := apis[apiName]
+	if !ok {
+		apiSnapshot = APISnapshot{Models: make(map[string]ModelSnapshot)}
+	}
+	return apiSnapshot
+}
+
 type MergeResult struct {
 	Added   int64 `json:"added"`
 	Skipped int64 `json:"skipped"`
 }
 
 // MergeSnapshot merges an exported statistics snapshot into the current store.
-// Existing data is preserved and duplicate request details are skipped.
+// Existing data is preserved and request details duplicating a retained detail are skipped.
 func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResult {
 	result := MergeResult{}
 	if s == nil {
@@ -299,15 +528,9 @@ func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResu
 	defer s.mu.Unlock()
 
 	seen := make(map[string]struct{})
-	for apiName, stats := range s.apis {
-		if stats == nil {
-			continue
-		}
-		for modelName, modelStatsValue := range stats.Models {
-			if modelStatsValue == nil {
-				continue
-			}
-			for _, detail := range modelStatsValue.Details {
+	for apiName, models := range s.details {
+		for modelName, details := range models {
+			for _, detail := range details {
 				seen[dedupKey(apiName, modelName, detail)] = struct{}{}
 			}
 		}
@@ -318,13 +541,6 @@ func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResu
 		if apiName == "" {
 			continue
 		}
-		stats, ok := s.apis[apiName]
-		if !ok || stats == nil {
-			stats = &apiStats{Models: make(map[string]*modelStats)}
-			s.apis[apiName] = stats
-		} else if stats.Models == nil {
-			stats.Models = make(map[string]*modelStats)
-		}
 		for modelName, modelSnapshot := range apiSnapshot.Models {
 			modelName = strings.TrimSpace(modelName)
 			if modelName == "" {
@@ -341,7 +557,7 @@ func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResu
 					continue
 				}
 				seen[key] = struct{}{}
-				s.recordImported(apiName, modelName, stats, detail)
+				s.addLocked(apiName, modelName, detail)
 				result.Added++
 			}
 		}
@@ -350,31 +566,6 @@ func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResu
 	return result
 }
 
-func (s *RequestStatistics) recordImported(apiName, modelName string, stats *apiStats, detail RequestDetail) {
-	totalTokens := detail.Tokens.TotalTokens
-	if totalTokens < 0 {
-		totalTokens = 0
-	}
-
-	s.totalRequests++
-	if detail.Failed {
-		s.failureCount++
-	} else {
-		s.successCount++
-	}
-	s.totalTokens += totalTokens
-
-	s.updateAPIStats(stats, modelName, detail)
-
-	dayKey := detail.Timestamp.Format("2006-01-02")
-	hourKey := detail.Timestamp.Hour()
-
-	s.requestsByDay[dayKey]++
-	s.requestsByHour[hourKey]++
-	s.tokensByDay[dayKey] += totalTokens
-	s.tokensByHour[hourKey] += totalTokens
-}
-
 func dedupKey(apiName, modelName string, detail RequestDetail) string {
 	timestamp := detail.Timestamp.UTC().Format(time.RFC3339Nano)
 	tokens := normaliseTokenStats(detail.Tokens)
@@ -394,6 +585,25 @@ func dedupKey(apiName, modelName string, detail RequestDetail) string {
 	)
 }
 
+// resolveKeyLabel returns the label of the caller's client key (an api-key-entries name, a
+// virtual key label or a JWT policy name), so that statistics group by label instead of by
+// raw key.
+func resolveKeyLabel(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return ""
+	}
+	raw, exists := ginCtx.Get("accessMetadata")
+	if !exists {
+		return ""
+	}
+	metadata, _ := raw.(map[string]string)
+	return strings.TrimSpace(metadata[sdkaccess.MetadataKeyName])
+}
+
 func resolveAPIIdentifier(ctx context.Context, record coreusage.Record) string {
 	if ctx != nil {
 		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
@@ -438,11 +648,12 @@ const httpStatusBadRequest = 400
 
 func normaliseDetail(detail coreusage.Detail) TokenStats {
 	tokens := TokenStats{
-		InputTokens:     detail.InputTokens,
-		OutputTokens:    detail.OutputTokens,
-		ReasoningTokens: detail.ReasoningTokens,
-		CachedTokens:    detail.CachedTokens,
-		TotalTokens:     detail.TotalTokens,
+		InputTokens:      detail.InputTokens,
+		OutputTokens:     detail.OutputTokens,
+		ReasoningTokens:  detail.ReasoningTokens,
+		CachedTokens:     detail.CachedTokens,
+		CacheWriteTokens: detail.CacheWriteTokens,
+		TotalTokens:      detail.TotalTokens,
 	}
 	if tokens.TotalTokens == 0 {
 		tokens.TotalTokens = detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens
@@ -0,0 +1,173 @@
+package usage
+
+import (
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	// hourLayout and dayLayout key the rollups, in local time.
+	hourLayout = "2006-01-02T15"
+	dayLayout  = "2006-01-02"
+
+	defaultDetailRetentionDays = 7
+	defaultHourlyRetentionDays = 90
+)
+
+// Rollup aggregates the requests of one API and model over an hour or a day.
+type Rollup struct {
+	Requests int64      `json:"requests"`
+	Failures int64      `json:"failures,omitempty"`
+	Hedged   int64      `json:"hedged,omitempty"`
+	Tokens   TokenStats `json:"tokens"`
+	Cost     float64    `json:"cost,omitempty"`
+	// CostByAuth splits Cost by credential auth index.
+	CostByAuth map[string]float64 `json:"cost_by_auth,omitempty"`
+}
+
+// RollupTable maps a period key to API name, model and rollup.
+type RollupTable map[string]map[string]map[string]*Rollup
+
+// Rollups holds the hourly ("2006-01-02T15") and daily ("2006-01-02") aggregates.
+type Rollups struct {
+	Hourly RollupTable `json:"hourly"`
+	Daily  RollupTable `json:"daily"`
+}
+
+func newRollups() Rollups {
+	return Rollups{Hourly: make(RollupTable), Daily: make(RollupTable)}
+}
+
+// add folds one request detail into the hourly and daily rollups.
+func (r *Rollups) add(api, model string, detail RequestDetail) {
+	timestamp := detail.Timestamp.Local()
+	r.Hourly.bucket(timestamp.Format(hourLayout), api, model).add(detail)
+	r.Daily.bucket(timestamp.Format(dayLayout), api, model).add(detail)
+}
+
+// merge adds other into r.
+func (r *Rollups) merge(other Rollups) {
+	r.Hourly.merge(other.Hourly)
+	r.Daily.merge(other.Daily)
+}
+
+func (r *Rollup) add(detail RequestDetail) {
+	r.Requests++
+	if detail.Failed {
+		r.Failures++
+	}
+	if detail.Hedged {
+		r.Hedged++
+	}
+	r.Tokens.InputTokens += detail.Tokens.InputTokens
+	r.Tokens.OutputTokens += detail.Tokens.OutputTokens
+	r.Tokens.ReasoningTokens += detail.Tokens.ReasoningTokens
+	r.Tokens.CachedTokens += detail.Tokens.CachedTokens
+	r.Tokens.CacheWriteTokens += detail.Tokens.CacheWriteTokens
+	if detail.Tokens.TotalTokens > 0 {
+		r.Tokens.TotalTokens += detail.Tokens.TotalTokens
+	}
+	r.addCost(detail.AuthIndex, detail.Cost)
+}
+
+func (r *Rollup) addCost(authIndex string, cost float64) {
+	if cost == 0 {
+		return
+	}
+	r.Cost += cost
+	if authIndex == "" {
+		return
+	}
+	if r.CostByAuth == nil {
+		r.CostByAuth = make(map[string]float64)
+	}
+	r.CostByAuth[authIndex] += cost
+}
+
+func (r *Rollup) merge(other *Rollup) {
+	r.Requests += other.Requests
+	r.Failures += other.Failures
+	r.Hedged += other.Hedged
+	r.Tokens.InputTokens += other.Tokens.InputTokens
+	r.Tokens.OutputTokens += other.Tokens.OutputTokens
+	r.Tokens.ReasoningTokens += other.Tokens.ReasoningTokens
+	r.Tokens.CachedTokens += other.Tokens.CachedTokens
+	r.Tokens.CacheWriteTokens += other.Tokens.CacheWriteTokens
+	r.Tokens.TotalTokens += other.Tokens.TotalTokens
+	r.Cost += other.Cost
+	for authIndex, cost := range other.CostByAuth {
+		if r.CostByAuth == nil {
+			r.CostByAuth = make(map[string]float64)
+		}
+		r.CostByAuth[authIndex] += cost
+	}
+}
+
+// bucket returns the rollup for period, API and model, creating it when needed.
+func (t RollupTable) bucket(period, api, model string) *Rollup {
+	apis, ok := t[period]
+	if !ok {
+		apis = make(map[string]map[string]*Rollup)
+		t[period] = apis
+	}
+	models, ok := apis[api]
+	if !ok {
+		models = make(map[string]*Rollup)
+		apis[api] = models
+	}
+	rollup, ok := models[model]
+	if !ok {
+		rollup = &Rollup{}
+		models[model] = rollup
+	}
+	return rollup
+}
+
+func (t RollupTable) merge(other RollupTable) {
+	for period, apis := range other {
+		for api, models := range apis {
+			for model, rollup := range models {
+				if rollup != nil {
+					t.bucket(period, api, model).merge(rollup)
+				}
+			}
+		}
+	}
+}
+
+// prune drops the periods, keyed with layout, that start before cutoff.
+func (t RollupTable) prune(layout string, cutoff time.Time) {
+	for period := range t {
+		start, err := time.ParseInLocation(layout, period, time.Local)
+		if err == nil && start.Before(cutoff) {
+			delete(t, period)
+		}
+	}
+}
+
+// Retention bounds how long statistics are kept; a zero duration keeps them forever.
+type Retention struct {
+	Details time.Duration
+	Hourly  time.Duration
+	Daily   time.Duration
+}
+
+// RetentionFromConfig resolves the configured retention, applying the defaults of durable
+// storage when it is enabled.
+func RetentionFromConfig(cfg config.UsageStorageConfig) Retention {
+	days := func(value, fallback int) time.Duration {
+		if value == 0 && cfg.Enabled {
+			value = fallback
+		}
+		if value <= 0 {
+			return 0
+		}
+		return time.Duration(value) * 24 * time.Hour
+	}
+	return Retention{
+		Details: days(cfg.DetailRetentionDays, defaultDetailRetentionDays),
+		Hourly:  days(cfg.HourlyRetentionDays, defaultHourlyRetentionDays),
+		Daily:   days(cfg.DailyRetentionDays, 0),
+	}
+}
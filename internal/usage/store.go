@@ -0,0 +1,266 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	segmentDir     = "segments"
+	segmentExt     = ".jsonl"
+	segmentLayout  = "2006-01-02"
+	checkpointFile = "checkpoint.json"
+)
+
+// Entry is a request detail as persisted by a Store.
+type Entry struct {
+	API    string        `json:"api"`
+	Model  string        `json:"model"`
+	Detail RequestDetail `json:"detail"`
+}
+
+// Store persists usage statistics across restarts. Raw entries are appended as they are
+// recorded; rollups are checkpointed periodically together with the position of the last
+// appended entry, so entries appended after the checkpoint can be folded in again on restore.
+// RequestStatistics serialises all calls, so implementations need not be safe for concurrent use.
+type Store interface {
+	// Append persists one request detail. It may buffer the entry until the next Checkpoint
+	// or Close.
+	Append(entry Entry) error
+	// Checkpoint persists rollups covering every entry appended so far.
+	Checkpoint(rollups Rollups) error
+	// Restore returns the last checkpointed rollups and calls fn for every stored entry,
+	// with pending set for entries appended after that checkpoint.
+	Restore(fn func(entry Entry, pending bool)) (Rollups, error)
+	// Prune drops entries appended before cutoff.
+	Prune(cutoff time.Time) error
+	// Close flushes and releases the store.
+	Close() error
+}
+
+// SegmentStore is a Store writing entries as JSON lines to one append-only segment file per
+// UTC day and the rollups to a checkpoint file next to them.
+type SegmentStore struct {
+	dir      string
+	now      func() time.Time
+	file     *os.File
+	fileName string
+	// buf holds appended entries until the next checkpoint, so recording does not block on disk.
+	buf *bufio.Writer
+	// sizes tracks the length of every segment, which is the checkpoint position.
+	sizes map[string]int64
+}
+
+type checkpoint struct {
+	Rollups Rollups          `json:"rollups"`
+	Offsets map[string]int64 `json:"offsets"`
+}
+
+// OpenSegmentStore opens or creates a segment store in dir.
+func OpenSegmentStore(dir string) (*SegmentStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, segmentDir), 0o700); err != nil {
+		return nil, fmt.Errorf("usage: create store directory: %w", err)
+	}
+	return &SegmentStore{dir: dir, now: time.Now, sizes: make(map[string]int64)}, nil
+}
+
+// Append implements Store.
+func (st *SegmentStore) Append(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("usage: encode entry: %w", err)
+	}
+	name := st.now().UTC().Format(segmentLayout) + segmentExt
+	if st.file == nil || st.fileName != name {
+		if err = st.openSegment(name); err != nil {
+			return err
+		}
+	}
+	n, err := st.buf.Write(append(line, '\n'))
+	st.sizes[name] += int64(n)
+	if err != nil {
+		return fmt.Errorf("usage: append entry: %w", err)
+	}
+	return nil
+}
+
+// flush writes the buffered entries to the current segment.
+func (st *SegmentStore) flush() error {
+	if st.buf == nil {
+		return nil
+	}
+	if err := st.buf.Flush(); err != nil {
+		return fmt.Errorf("usage: append entry: %w", err)
+	}
+	return nil
+}
+
+// closeSegment flushes and closes the current segment.
+func (st *SegmentStore) closeSegment() error {
+	if st.file == nil {
+		return nil
+	}
+	err := st.flush()
+	if errSync := st.file.Sync(); err == nil {
+		err = errSync
+	}
+	if errClose := st.file.Close(); err == nil {
+		err = errClose
+	}
+	st.file, st.fileName, st.buf = nil, "", nil
+	return err
+}
+
+func (st *SegmentStore) openSegment(name string) error {
+	if err := st.closeSegment(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(st.dir, segmentDir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("usage: open segment: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("usage: stat segment: %w", err)
+	}
+	st.file, st.fileName, st.buf = file, name, bufio.NewWriter(file)
+	st.sizes[name] = info.Size()
+	return nil
+}
+
+// Checkpoint implements Store. Buffered entries are written first, so the recorded offsets
+// never point past the data on disk.
+func (st *SegmentStore) Checkpoint(rollups Rollups) error {
+	if err := st.flush(); err != nil {
+		return err
+	}
+	offsets := make(map[string]int64, len(st.sizes))
+	for name, size := range st.sizes {
+		offsets[name] = size
+	}
+	data, err := json.Marshal(checkpoint{Rollups: rollups, Offsets: offsets})
+	if err != nil {
+		return fmt.Errorf("usage: encode checkpoint: %w", err)
+	}
+	path := filepath.Join(st.dir, checkpointFile)
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		return fmt.Errorf("usage: write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Restore implements Store. A segment ending in a partially written line, left by a crash, is
+// truncated to its last complete entry.
+func (st *SegmentStore) Restore(fn func(entry Entry, pending bool)) (Rollups, error) {
+	var cp checkpoint
+	data, err := os.ReadFile(filepath.Join(st.dir, checkpointFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Rollups{}, fmt.Errorf("usage: read checkpoint: %w", err)
+	}
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &cp); err != nil {
+			return Rollups{}, fmt.Errorf("usage: decode checkpoint: %w", err)
+		}
+	}
+	names, err := st.segments()
+	if err != nil {
+		return Rollups{}, err
+	}
+	for _, name := range names {
+		size, errRead := readSegment(filepath.Join(st.dir, segmentDir, name), cp.Offsets[name], fn)
+		if errRead != nil {
+			return Rollups{}, errRead
+		}
+		st.sizes[name] = size
+	}
+	return cp.Rollups, nil
+}
+
+// readSegment calls fn for every entry in the segment at path and returns the length of its
+// complete lines.
+func readSegment(path string, offset int64, fn func(entry Entry, pending bool)) (int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("usage: open segment: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	reader := bufio.NewReader(file)
+	var pos int64
+	for {
+		line, errRead := reader.ReadBytes('\n')
+		if errRead == io.EOF {
+			if len(line) > 0 {
+				if err = file.Truncate(pos); err != nil {
+					return 0, fmt.Errorf("usage: truncate segment: %w", err)
+				}
+			}
+			return pos, nil
+		}
+		if errRead != nil {
+			return 0, fmt.Errorf("usage: read segment: %w", errRead)
+		}
+		start := pos
+		pos += int64(len(line))
+		var entry Entry
+		if json.Unmarshal(line, &entry) != nil {
+			continue
+		}
+		fn(entry, start >= offset)
+	}
+}
+
+// Prune implements Store. Segments are removed once every entry in them is older than cutoff.
+func (st *SegmentStore) Prune(cutoff time.Time) error {
+	names, err := st.segments()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		day, errParse := time.Parse(segmentLayout, strings.TrimSuffix(name, segmentExt))
+		if errParse != nil || day.AddDate(0, 0, 1).After(cutoff) {
+			continue
+		}
+		if name == st.fileName {
+			_ = st.closeSegment()
+		}
+		if err = os.Remove(filepath.Join(st.dir, segmentDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("usage: remove segment: %w", err)
+		}
+		delete(st.sizes, name)
+	}
+	return nil
+}
+
+// Close implements Store.
+func (st *SegmentStore) Close() error {
+	return st.closeSegment()
+}
+
+// segments lists the segment file names in chronological order.
+func (st *SegmentStore) segments() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(st.dir, segmentDir))
+	if err != nil {
+		return nil, fmt.Errorf("usage: list segments: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), segmentExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,134 @@
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func openStats(t *testing.T, dir string, retention Retention) *RequestStatistics {
+	t.Helper()
+	store, err := OpenSegmentStore(dir)
+	if err != nil {
+		t.Fatalf("OpenSegmentStore: %v", err)
+	}
+	stats := NewRequestStatistics()
+	stats.SetRetention(retention)
+	if err = stats.SetStore(store); err != nil {
+		t.Fatalf("SetStore: %v", err)
+	}
+	return stats
+}
+
+func record(stats *RequestStatistics, at time.Time, tokens int64) {
+	stats.Record(context.Background(), coreusage.Record{
+		APIKey:      "sk-team-a-0001",
+		Model:       "gemini-2.5-pro",
+		RequestedAt: at,
+		Detail:      coreusage.Detail{InputTokens: tokens},
+	})
+}
+
+func TestSegmentStore_RestoresAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	stats := openStats(t, dir, Retention{})
+	record(stats, now, 10)
+	stats.mu.Lock()
+	if err := stats.checkpointLocked(); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	stats.mu.Unlock()
+	record(stats, now, 20)
+	stats.mu.Lock()
+	if err := stats.store.(*SegmentStore).flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	stats.mu.Unlock()
+
+	// Simulate a crash: the second entry is only in the segment, and a torn write follows it.
+	segment := filepath.Join(dir, segmentDir, now.UTC().Format(segmentLayout)+segmentExt)
+	file, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("open segment: %v", err)
+	}
+	_, _ = file.WriteString(`{"api":"team-a","mod`)
+	_ = file.Close()
+
+	restored := openStats(t, dir, Retention{})
+	snapshot := restored.Snapshot()
+	if snapshot.TotalRequests != 2 || snapshot.TotalTokens != 30 {
+		t.Fatalf("expected 2 requests and 30 tokens, got %d and %d", snapshot.TotalRequests, snapshot.TotalTokens)
+	}
+	if got := len(snapshot.APIs["sk-t...0001"].Models["gemini-2.5-pro"].Details); got != 2 {
+		t.Fatalf("expected 2 restored details, got %d", got)
+	}
+
+	record(restored, now, 5)
+	if err = restored.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	reopened := openStats(t, dir, Retention{})
+	defer func() { _ = reopened.Close() }()
+	if snapshot = reopened.Snapshot(); snapshot.TotalRequests != 3 || snapshot.TotalTokens != 35 {
+		t.Fatalf("expected 3 requests and 35 tokens after a clean restart, got %d and %d", snapshot.TotalRequests, snapshot.TotalTokens)
+	}
+}
+
+func TestSegmentStore_BuffersEntriesUntilCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	stats := openStats(t, dir, Retention{})
+	defer func() { _ = stats.Close() }()
+	record(stats, now, 10)
+
+	segment := filepath.Join(dir, segmentDir, now.UTC().Format(segmentLayout)+segmentExt)
+	if data, _ := os.ReadFile(segment); len(data) != 0 {
+		t.Fatalf("expected the entry to be buffered, got %q", data)
+	}
+	stats.mu.Lock()
+	if err := stats.checkpointLocked(); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	stats.mu.Unlock()
+	data, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatalf("read segment: %v", err)
+	}
+	if strings.Contains(string(data), "sk-team-a-0001") || !strings.Contains(string(data), `"api":"sk-t...0001"`) {
+		t.Fatalf("expected the entry to be written with a masked key, got %q", data)
+	}
+}
+
+func TestRequestStatistics_RetentionKeepsRollups(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	stats := openStats(t, dir, Retention{Details: 24 * time.Hour, Hourly: 48 * time.Hour})
+	defer func() { _ = stats.Close() }()
+	record(stats, now.AddDate(0, 0, -5), 7)
+	record(stats, now, 3)
+
+	snapshot := stats.Snapshot()
+	if snapshot.TotalRequests != 2 || snapshot.TotalTokens != 10 {
+		t.Fatalf("expected rollups to cover both requests, got %d requests and %d tokens", snapshot.TotalRequests, snapshot.TotalTokens)
+	}
+	model := snapshot.APIs["sk-t...0001"].Models["gemini-2.5-pro"]
+	if model.TotalRequests != 2 || len(model.Details) != 1 {
+		t.Fatalf("expected 2 requests with 1 retained detail, got %d and %d", model.TotalRequests, len(model.Details))
+	}
+	var hourly int64
+	for _, count := range snapshot.RequestsByHour {
+		hourly += count
+	}
+	if hourly != 1 {
+		t.Fatalf("expected the expired hourly rollup to be pruned, got %d hourly requests", hourly)
+	}
+	if len(snapshot.RequestsByDay) != 2 {
+		t.Fatalf("expected 2 daily rollups, got %v", snapshot.RequestsByDay)
+	}
+}
@@ -48,6 +48,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.ProxyURL != newCfg.ProxyURL {
 		changes = append(changes, fmt.Sprintf("proxy-url: %s -> %s", formatProxyURL(oldCfg.ProxyURL), formatProxyURL(newCfg.ProxyURL)))
 	}
+	if oldCfg.PublicURL != newCfg.PublicURL {
+		changes = append(changes, fmt.Sprintf("public-url: %s -> %s", oldCfg.PublicURL, newCfg.PublicURL))
+	}
 	if oldCfg.WebsocketAuth != newCfg.WebsocketAuth {
 		changes = append(changes, fmt.Sprintf("ws-auth: %t -> %t", oldCfg.WebsocketAuth, newCfg.WebsocketAuth))
 	}
@@ -63,12 +66,176 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 		changes = append(changes, fmt.Sprintf("quota-exceeded.switch-preview-model: %t -> %t", oldCfg.QuotaExceeded.SwitchPreviewModel, newCfg.QuotaExceeded.SwitchPreviewModel))
 	}
 
+	// Routing
+	if oldCfg.Routing.SessionAffinity.Enabled != newCfg.Routing.SessionAffinity.Enabled {
+		changes = append(changes, fmt.Sprintf("routing.session-affinity.enabled: %t -> %t", oldCfg.Routing.SessionAffinity.Enabled, newCfg.Routing.SessionAffinity.Enabled))
+	}
+	if oldCfg.Routing.SessionAffinity.TTLSeconds != newCfg.Routing.SessionAffinity.TTLSeconds {
+		changes = append(changes, fmt.Sprintf("routing.session-affinity.ttl-seconds: %d -> %d", oldCfg.Routing.SessionAffinity.TTLSeconds, newCfg.Routing.SessionAffinity.TTLSeconds))
+	}
+	if oldCfg.Routing.QueueTimeoutSeconds != newCfg.Routing.QueueTimeoutSeconds {
+		changes = append(changes, fmt.Sprintf("routing.queue-timeout-seconds: %d -> %d", oldCfg.Routing.QueueTimeoutSeconds, newCfg.Routing.QueueTimeoutSeconds))
+	}
+
+	// Circuit breaker
+	if oldCfg.CircuitBreaker.Enabled != newCfg.CircuitBreaker.Enabled {
+		changes = append(changes, fmt.Sprintf("circuit-breaker.enabled: %t -> %t", oldCfg.CircuitBreaker.Enabled, newCfg.CircuitBreaker.Enabled))
+	}
+	if oldCfg.CircuitBreaker.FailureThreshold != newCfg.CircuitBreaker.FailureThreshold {
+		changes = append(changes, fmt.Sprintf("circuit-breaker.failure-threshold: %d -> %d", oldCfg.CircuitBreaker.FailureThreshold, newCfg.CircuitBreaker.FailureThreshold))
+	}
+	if oldCfg.CircuitBreaker.ErrorRateThreshold != newCfg.CircuitBreaker.ErrorRateThreshold {
+		changes = append(changes, fmt.Sprintf("circuit-breaker.error-rate-threshold: %g -> %g", oldCfg.CircuitBreaker.ErrorRateThreshold, newCfg.CircuitBreaker.ErrorRateThreshold))
+	}
+	if oldCfg.CircuitBreaker.MinRequests != newCfg.CircuitBreaker.MinRequests {
+		changes = append(changes, fmt.Sprintf("circuit-breaker.min-requests: %d -> %d", oldCfg.CircuitBreaker.MinRequests, newCfg.CircuitBreaker.MinRequests))
+	}
+	if oldCfg.CircuitBreaker.WindowSeconds != newCfg.CircuitBreaker.WindowSeconds {
+		changes = append(changes, fmt.Sprintf("circuit-breaker.window-seconds: %d -> %d", oldCfg.CircuitBreaker.WindowSeconds, newCfg.CircuitBreaker.WindowSeconds))
+	}
+	if oldCfg.CircuitBreaker.OpenSeconds != newCfg.CircuitBreaker.OpenSeconds {
+		changes = append(changes, fmt.Sprintf("circuit-breaker.open-seconds: %d -> %d", oldCfg.CircuitBreaker.OpenSeconds, newCfg.CircuitBreaker.OpenSeconds))
+	}
+
+	// Hedging
+	if oldCfg.Hedging.Enabled != newCfg.Hedging.Enabled {
+		changes = append(changes, fmt.Sprintf("hedging.enabled: %t -> %t", oldCfg.Hedging.Enabled, newCfg.Hedging.Enabled))
+	}
+	if !reflect.DeepEqual(trimStrings(oldCfg.Hedging.Models), trimStrings(newCfg.Hedging.Models)) {
+		changes = append(changes, fmt.Sprintf("hedging.models: %v -> %v", trimStrings(oldCfg.Hedging.Models), trimStrings(newCfg.Hedging.Models)))
+	}
+	if oldCfg.Hedging.Percentile != newCfg.Hedging.Percentile {
+		changes = append(changes, fmt.Sprintf("hedging.percentile: %g -> %g", oldCfg.Hedging.Percentile, newCfg.Hedging.Percentile))
+	}
+	if oldCfg.Hedging.MinSamples != newCfg.Hedging.MinSamples {
+		changes = append(changes, fmt.Sprintf("hedging.min-samples: %d -> %d", oldCfg.Hedging.MinSamples, newCfg.Hedging.MinSamples))
+	}
+	if oldCfg.Hedging.MinDelayMS != newCfg.Hedging.MinDelayMS {
+		changes = append(changes, fmt.Sprintf("hedging.min-delay-ms: %d -> %d", oldCfg.Hedging.MinDelayMS, newCfg.Hedging.MinDelayMS))
+	}
+	if oldCfg.Hedging.DefaultDelayMS != newCfg.Hedging.DefaultDelayMS {
+		changes = append(changes, fmt.Sprintf("hedging.default-delay-ms: %d -> %d", oldCfg.Hedging.DefaultDelayMS, newCfg.Hedging.DefaultDelayMS))
+	}
+
+	// Health probes
+	if oldCfg.HealthProbe.Enabled != newCfg.HealthProbe.Enabled {
+		changes = append(changes, fmt.Sprintf("health-probe.enabled: %t -> %t", oldCfg.HealthProbe.Enabled, newCfg.HealthProbe.Enabled))
+	}
+	if oldCfg.HealthProbe.IntervalSeconds != newCfg.HealthProbe.IntervalSeconds {
+		changes = append(changes, fmt.Sprintf("health-probe.interval-seconds: %d -> %d", oldCfg.HealthProbe.IntervalSeconds, newCfg.HealthProbe.IntervalSeconds))
+	}
+	if oldCfg.HealthProbe.TimeoutSeconds != newCfg.HealthProbe.TimeoutSeconds {
+		changes = append(changes, fmt.Sprintf("health-probe.timeout-seconds: %d -> %d", oldCfg.HealthProbe.TimeoutSeconds, newCfg.HealthProbe.TimeoutSeconds))
+	}
+	if strings.TrimSpace(oldCfg.HealthProbe.Method) != strings.TrimSpace(newCfg.HealthProbe.Method) {
+		changes = append(changes, fmt.Sprintf("health-probe.method: %s -> %s", strings.TrimSpace(oldCfg.HealthProbe.Method), strings.TrimSpace(newCfg.HealthProbe.Method)))
+	}
+	if !reflect.DeepEqual(trimStrings(oldCfg.HealthProbe.Providers), trimStrings(newCfg.HealthProbe.Providers)) {
+		changes = append(changes, fmt.Sprintf("health-probe.providers: %v -> %v", trimStrings(oldCfg.HealthProbe.Providers), trimStrings(newCfg.HealthProbe.Providers)))
+	}
+	if !reflect.DeepEqual(oldCfg.HealthProbe.Models, newCfg.HealthProbe.Models) {
+		changes = append(changes, "health-probe.models: updated")
+	}
+	if oldCfg.HealthProbe.HistorySize != newCfg.HealthProbe.HistorySize {
+		changes = append(changes, fmt.Sprintf("health-probe.history-size: %d -> %d", oldCfg.HealthProbe.HistorySize, newCfg.HealthProbe.HistorySize))
+	}
+
+	// Metrics
+	if oldCfg.Metrics.Enabled != newCfg.Metrics.Enabled {
+		changes = append(changes, fmt.Sprintf("metrics.enabled: %t -> %t", oldCfg.Metrics.Enabled, newCfg.Metrics.Enabled))
+	}
+	if strings.TrimSpace(oldCfg.Metrics.Listen) != strings.TrimSpace(newCfg.Metrics.Listen) {
+		changes = append(changes, fmt.Sprintf("metrics.listen: %s -> %s (restart required)", strings.TrimSpace(oldCfg.Metrics.Listen), strings.TrimSpace(newCfg.Metrics.Listen)))
+	}
+
+	// Tracing
+	if !reflect.DeepEqual(oldCfg.Tracing, newCfg.Tracing) {
+		changes = append(changes, "tracing: updated (restart required)")
+	}
+
+	// Usage storage
+	if oldCfg.UsageStorage.Enabled != newCfg.UsageStorage.Enabled {
+		changes = append(changes, fmt.Sprintf("usage-storage.enabled: %t -> %t (restart required)", oldCfg.UsageStorage.Enabled, newCfg.UsageStorage.Enabled))
+	}
+	if strings.TrimSpace(oldCfg.UsageStorage.Dir) != strings.TrimSpace(newCfg.UsageStorage.Dir) {
+		changes = append(changes, fmt.Sprintf("usage-storage.dir: %s -> %s (restart required)", strings.TrimSpace(oldCfg.UsageStorage.Dir), strings.TrimSpace(newCfg.UsageStorage.Dir)))
+	}
+	if oldCfg.UsageStorage.DetailRetentionDays != newCfg.UsageStorage.DetailRetentionDays ||
+		oldCfg.UsageStorage.HourlyRetentionDays != newCfg.UsageStorage.HourlyRetentionDays ||
+		oldCfg.UsageStorage.DailyRetentionDays != newCfg.UsageStorage.DailyRetentionDays {
+		changes = append(changes, fmt.Sprintf("usage-storage.retention-days: details %d -> %d, hourly %d -> %d, daily %d -> %d",
+			oldCfg.UsageStorage.DetailRetentionDays, newCfg.UsageStorage.DetailRetentionDays,
+			oldCfg.UsageStorage.HourlyRetentionDays, newCfg.UsageStorage.HourlyRetentionDays,
+			oldCfg.UsageStorage.DailyRetentionDays, newCfg.UsageStorage.DailyRetentionDays))
+	}
+
+	// Responses store
+	if oldCfg.ResponsesStore != newCfg.ResponsesStore {
+		changes = append(changes, fmt.Sprintf("responses-store: disabled %t -> %t, persist %t -> %t, dir %s -> %s, ttl-hours %d -> %d",
+			oldCfg.ResponsesStore.Disabled, newCfg.ResponsesStore.Disabled,
+			oldCfg.ResponsesStore.Persist, newCfg.ResponsesStore.Persist,
+			strings.TrimSpace(oldCfg.ResponsesStore.Dir), strings.TrimSpace(newCfg.ResponsesStore.Dir),
+			oldCfg.ResponsesStore.TTLHours, newCfg.ResponsesStore.TTLHours))
+	}
+
+	// Batch
+	if oldCfg.Batch != newCfg.Batch {
+		changes = append(changes, fmt.Sprintf("batch: disabled %t -> %t, dir %s -> %s, concurrency %d -> %d, yield-threshold %d -> %d, max-file-size-mb %d -> %d",
+			oldCfg.Batch.Disabled, newCfg.Batch.Disabled,
+			strings.TrimSpace(oldCfg.Batch.Dir), strings.TrimSpace(newCfg.Batch.Dir),
+			oldCfg.Batch.Concurrency, newCfg.Batch.Concurrency,
+			oldCfg.Batch.YieldThreshold, newCfg.Batch.YieldThreshold,
+			oldCfg.Batch.MaxFileSizeMB, newCfg.Batch.MaxFileSizeMB))
+	}
+
+	// Pricing
+	if !reflect.DeepEqual(oldCfg.Pricing, newCfg.Pricing) {
+		changes = append(changes, fmt.Sprintf("pricing: updated (%d -> %d model prices)", len(oldCfg.Pricing.Models), len(newCfg.Pricing.Models)))
+	}
+
+	if !reflect.DeepEqual(oldCfg.ModelFallbacks, newCfg.ModelFallbacks) {
+		changes = append(changes, fmt.Sprintf("model-fallbacks: updated (%d -> %d entries)", len(oldCfg.ModelFallbacks), len(newCfg.ModelFallbacks)))
+	}
+
 	// API keys (redacted) and counts
 	if len(oldCfg.APIKeys) != len(newCfg.APIKeys) {
 		changes = append(changes, fmt.Sprintf("api-keys count: %d -> %d", len(oldCfg.APIKeys), len(newCfg.APIKeys)))
 	} else if !reflect.DeepEqual(trimStrings(oldCfg.APIKeys), trimStrings(newCfg.APIKeys)) {
 		changes = append(changes, "api-keys: values updated (count unchanged, redacted)")
 	}
+	if len(oldCfg.APIKeyEntries) != len(newCfg.APIKeyEntries) {
+		changes = append(changes, fmt.Sprintf("api-key-entries count: %d -> %d", len(oldCfg.APIKeyEntries), len(newCfg.APIKeyEntries)))
+	} else {
+		for i := range oldCfg.APIKeyEntries {
+			o := oldCfg.APIKeyEntries[i]
+			n := newCfg.APIKeyEntries[i]
+			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
+				changes = append(changes, fmt.Sprintf("api-key-entries[%d].api-key: updated", i))
+			}
+			if o.Name != n.Name {
+				changes = append(changes, fmt.Sprintf("api-key-entries[%d].name: %s -> %s", i, o.Name, n.Name))
+			}
+			if !reflect.DeepEqual(o.Models, n.Models) || !reflect.DeepEqual(o.Providers, n.Providers) || !reflect.DeepEqual(o.Prefixes, n.Prefixes) || o.DefaultPrefix != n.DefaultPrefix {
+				changes = append(changes, fmt.Sprintf("api-key-entries[%d]: access policy updated", i))
+			}
+			if !reflect.DeepEqual(o.RateLimit, n.RateLimit) {
+				changes = append(changes, fmt.Sprintf("api-key-entries[%d]: rate-limit updated", i))
+			}
+			if !reflect.DeepEqual(o.Budgets, n.Budgets) {
+				changes = append(changes, fmt.Sprintf("api-key-entries[%d]: budgets updated", i))
+			}
+		}
+	}
+	if oldCfg.ClientRateLimit != newCfg.ClientRateLimit {
+		o, n := oldCfg.ClientRateLimit, newCfg.ClientRateLimit
+		changes = append(changes, fmt.Sprintf("client-rate-limit: rpm %d -> %d, tpm %d -> %d, max-concurrent %d -> %d", o.RequestsPerMinute, n.RequestsPerMinute, o.TokensPerMinute, n.TokensPerMinute, o.MaxConcurrent, n.MaxConcurrent))
+	}
+	if !reflect.DeepEqual(oldCfg.JWTAuth, newCfg.JWTAuth) {
+		changes = append(changes, fmt.Sprintf("jwt-auth: updated (enabled %t -> %t)", oldCfg.JWTAuth.Enabled(), newCfg.JWTAuth.Enabled()))
+	}
+	if !reflect.DeepEqual(oldCfg.ClientBudgets, newCfg.ClientBudgets) {
+		changes = append(changes, "client-budgets: updated")
+	}
 	if len(oldCfg.GeminiKey) != len(newCfg.GeminiKey) {
 		changes = append(changes, fmt.Sprintf("gemini-api-key count: %d -> %d", len(oldCfg.GeminiKey), len(newCfg.GeminiKey)))
 	} else {
@@ -84,6 +251,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.Prefix) != strings.TrimSpace(n.Prefix) {
 				changes = append(changes, fmt.Sprintf("gemini[%d].prefix: %s -> %s", i, strings.TrimSpace(o.Prefix), strings.TrimSpace(n.Prefix)))
 			}
+			if o.Priority != n.Priority {
+				changes = append(changes, fmt.Sprintf("gemini[%d].priority: %d -> %d", i, o.Priority, n.Priority))
+			}
+			if o.Weight != n.Weight {
+				changes = append(changes, fmt.Sprintf("gemini[%d].weight: %d -> %d", i, o.Weight, n.Weight))
+			}
+			if o.MaxConcurrency != n.MaxConcurrency {
+				changes = append(changes, fmt.Sprintf("gemini[%d].max-concurrency: %d -> %d", i, o.MaxConcurrency, n.MaxConcurrency))
+			}
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("gemini[%d].api-key: updated", i))
 			}
@@ -114,6 +290,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.Prefix) != strings.TrimSpace(n.Prefix) {
 				changes = append(changes, fmt.Sprintf("claude[%d].prefix: %s -> %s", i, strings.TrimSpace(o.Prefix), strings.TrimSpace(n.Prefix)))
 			}
+			if o.Priority != n.Priority {
+				changes = append(changes, fmt.Sprintf("claude[%d].priority: %d -> %d", i, o.Priority, n.Priority))
+			}
+			if o.Weight != n.Weight {
+				changes = append(changes, fmt.Sprintf("claude[%d].weight: %d -> %d", i, o.Weight, n.Weight))
+			}
+			if o.MaxConcurrency != n.MaxConcurrency {
+				changes = append(changes, fmt.Sprintf("claude[%d].max-concurrency: %d -> %d", i, o.MaxConcurrency, n.MaxConcurrency))
+			}
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("claude[%d].api-key: updated", i))
 			}
@@ -144,6 +329,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.Prefix) != strings.TrimSpace(n.Prefix) {
 				changes = append(changes, fmt.Sprintf("codex[%d].prefix: %s -> %s", i, strings.TrimSpace(o.Prefix), strings.TrimSpace(n.Prefix)))
 			}
+			if o.Priority != n.Priority {
+				changes = append(changes, fmt.Sprintf("codex[%d].priority: %d -> %d", i, o.Priority, n.Priority))
+			}
+			if o.Weight != n.Weight {
+				changes = append(changes, fmt.Sprintf("codex[%d].weight: %d -> %d", i, o.Weight, n.Weight))
+			}
+			if o.MaxConcurrency != n.MaxConcurrency {
+				changes = append(changes, fmt.Sprintf("codex[%d].max-concurrency: %d -> %d", i, o.MaxConcurrency, n.MaxConcurrency))
+			}
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("codex[%d].api-key: updated", i))
 			}
@@ -237,6 +431,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.Prefix) != strings.TrimSpace(n.Prefix) {
 				changes = append(changes, fmt.Sprintf("vertex[%d].prefix: %s -> %s", i, strings.TrimSpace(o.Prefix), strings.TrimSpace(n.Prefix)))
 			}
+			if o.Priority != n.Priority {
+				changes = append(changes, fmt.Sprintf("vertex[%d].priority: %d -> %d", i, o.Priority, n.Priority))
+			}
+			if o.Weight != n.Weight {
+				changes = append(changes, fmt.Sprintf("vertex[%d].weight: %d -> %d", i, o.Weight, n.Weight))
+			}
+			if o.MaxConcurrency != n.MaxConcurrency {
+				changes = append(changes, fmt.Sprintf("vertex[%d].max-concurrency: %d -> %d", i, o.MaxConcurrency, n.MaxConcurrency))
+			}
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("vertex[%d].api-key: updated", i))
 			}
@@ -20,7 +20,11 @@ func ComputeOpenAICompatModelsHash(models []config.OpenAICompatibilityModel) str
 			if name == "" && alias == "" {
 				continue
 			}
-			out(strings.ToLower(name) + "|" + strings.ToLower(alias))
+			key := strings.ToLower(name) + "|" + strings.ToLower(alias)
+			if model.Embedding {
+				key += "|embedding"
+			}
+			out(key)
 		}
 	})
 	return hashJoined(keys)
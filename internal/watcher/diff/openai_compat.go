@@ -75,6 +75,9 @@ func describeOpenAICompatibilityUpdate(oldEntry, newEntry config.OpenAICompatibi
 	if !equalStringMap(oldEntry.Headers, newEntry.Headers) {
 		details = append(details, "headers updated")
 	}
+	if !equalRoutingHints(oldEntry.APIKeyEntries, newEntry.APIKeyEntries) {
+		details = append(details, "priority/weight/max-concurrency updated")
+	}
 	if len(details) == 0 {
 		return ""
 	}
@@ -91,6 +94,18 @@ func countAPIKeys(entry config.OpenAICompatibility) int {
 	return count
 }
 
+func equalRoutingHints(oldEntries, newEntries []config.OpenAICompatibilityAPIKey) bool {
+	if len(oldEntries) != len(newEntries) {
+		return false
+	}
+	for i := range oldEntries {
+		if oldEntries[i].Priority != newEntries[i].Priority || oldEntries[i].Weight != newEntries[i].Weight || oldEntries[i].MaxConcurrency != newEntries[i].MaxConcurrency {
+			return false
+		}
+	}
+	return true
+}
+
 func countOpenAIModels(models []config.OpenAICompatibilityModel) int {
 	count := 0
 	for _, model := range models {
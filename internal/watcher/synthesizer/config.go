@@ -63,6 +63,8 @@ func (s *ConfigSynthesizer) synthesizeGeminiKeys(ctx *SynthesisContext) []*corea
 			attrs["base_url"] = base
 		}
 		addConfigHeadersToAttrs(entry.Headers, attrs)
+		addRoutingAttrs(entry.Priority, entry.Weight, attrs)
+		addConcurrencyAttrs(entry.MaxConcurrency, attrs)
 		a := &coreauth.Auth{
 			ID:         id,
 			Provider:   "gemini",
@@ -107,6 +109,8 @@ func (s *ConfigSynthesizer) synthesizeClaudeKeys(ctx *SynthesisContext) []*corea
 			attrs["models_hash"] = hash
 		}
 		addConfigHeadersToAttrs(ck.Headers, attrs)
+		addRoutingAttrs(ck.Priority, ck.Weight, attrs)
+		addConcurrencyAttrs(ck.MaxConcurrency, attrs)
 		proxyURL := strings.TrimSpace(ck.ProxyURL)
 		a := &coreauth.Auth{
 			ID:         id,
@@ -148,6 +152,8 @@ func (s *ConfigSynthesizer) synthesizeCodexKeys(ctx *SynthesisContext) []*coreau
 			attrs["base_url"] = ck.BaseURL
 		}
 		addConfigHeadersToAttrs(ck.Headers, attrs)
+		addRoutingAttrs(ck.Priority, ck.Weight, attrs)
+		addConcurrencyAttrs(ck.MaxConcurrency, attrs)
 		proxyURL := strings.TrimSpace(ck.ProxyURL)
 		a := &coreauth.Auth{
 			ID:         id,
@@ -203,6 +209,8 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 				attrs["models_hash"] = hash
 			}
 			addConfigHeadersToAttrs(compat.Headers, attrs)
+			addRoutingAttrs(entry.Priority, entry.Weight, attrs)
+			addConcurrencyAttrs(entry.MaxConcurrency, attrs)
 			a := &coreauth.Auth{
 				ID:         id,
 				Provider:   providerName,
@@ -276,6 +284,8 @@ func (s *ConfigSynthesizer) synthesizeVertexCompat(ctx *SynthesisContext) []*cor
 			attrs["models_hash"] = hash
 		}
 		addConfigHeadersToAttrs(compat.Headers, attrs)
+		addRoutingAttrs(compat.Priority, compat.Weight, attrs)
+		addConcurrencyAttrs(compat.MaxConcurrency, attrs)
 		a := &coreauth.Auth{
 			ID:         id,
 			Provider:   providerName,
@@ -611,3 +611,62 @@ func TestConfigSynthesizer_AllProviders(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigSynthesizer_RoutingHints(t *testing.T) {
+	synth := NewConfigSynthesizer()
+	ctx := &SynthesisContext{
+		Config: &config.Config{
+			ClaudeKey: []config.ClaudeKey{
+				{APIKey: "paid", Priority: 10, Weight: 2},
+				{APIKey: "default"},
+			},
+		},
+		Now:         time.Now(),
+		IDGenerator: NewStableIDGenerator(),
+	}
+
+	auths, err := synth.Synthesize(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(auths) != 2 {
+		t.Fatalf("expected 2 auths, got %d", len(auths))
+	}
+	if auths[0].Attributes["priority"] != "10" || auths[0].Attributes["weight"] != "2" {
+		t.Errorf("expected priority 10 and weight 2, got %q and %q", auths[0].Attributes["priority"], auths[0].Attributes["weight"])
+	}
+	if _, ok := auths[1].Attributes["priority"]; ok {
+		t.Errorf("expected no priority attribute for default entry")
+	}
+	if _, ok := auths[1].Attributes["weight"]; ok {
+		t.Errorf("expected no weight attribute for default entry")
+	}
+}
+
+func TestConfigSynthesizer_MaxConcurrency(t *testing.T) {
+	synth := NewConfigSynthesizer()
+	ctx := &SynthesisContext{
+		Config: &config.Config{
+			CodexKey: []config.CodexKey{
+				{APIKey: "limited", BaseURL: "https://api.example.com", MaxConcurrency: 3},
+				{APIKey: "unlimited", BaseURL: "https://api.example.com"},
+			},
+		},
+		Now:         time.Now(),
+		IDGenerator: NewStableIDGenerator(),
+	}
+
+	auths, err := synth.Synthesize(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(auths) != 2 {
+		t.Fatalf("expected 2 auths, got %d", len(auths))
+	}
+	if got := auths[0].Attributes["max_concurrency"]; got != "3" {
+		t.Errorf("expected max_concurrency 3, got %q", got)
+	}
+	if _, ok := auths[1].Attributes["max_concurrency"]; ok {
+		t.Errorf("expected no max_concurrency attribute for unlimited entry")
+	}
+}
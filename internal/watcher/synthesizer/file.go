@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -86,20 +87,28 @@ func (s *FileSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth, e
 			}
 		}
 
+		attrs := map[string]string{
+			"source": full,
+			"path":   full,
+		}
+		addRoutingAttrs(intFromMetadata(metadata, "priority"), intFromMetadata(metadata, "weight"), attrs)
+		maxConcurrency := intFromMetadata(metadata, "max_concurrency")
+		if maxConcurrency == 0 {
+			maxConcurrency = intFromMetadata(metadata, "max-concurrency")
+		}
+		addConcurrencyAttrs(maxConcurrency, attrs)
+
 		a := &coreauth.Auth{
-			ID:       id,
-			Provider: provider,
-			Label:    label,
-			Prefix:   prefix,
-			Status:   coreauth.StatusActive,
-			Attributes: map[string]string{
-				"source": full,
-				"path":   full,
-			},
-			ProxyURL:  proxyURL,
-			Metadata:  metadata,
-			CreatedAt: now,
-			UpdatedAt: now,
+			ID:         id,
+			Provider:   provider,
+			Label:      label,
+			Prefix:     prefix,
+			Status:     coreauth.StatusActive,
+			Attributes: attrs,
+			ProxyURL:   proxyURL,
+			Metadata:   metadata,
+			CreatedAt:  now,
+			UpdatedAt:  now,
 		}
 		ApplyAuthExcludedModelsMeta(a, cfg, nil, "oauth")
 		if provider == "gemini-cli" {
@@ -160,6 +169,11 @@ func SynthesizeGeminiVirtualAuths(primary *coreauth.Auth, metadata map[string]an
 		if authPath != "" {
 			attrs["path"] = authPath
 		}
+		for _, key := range []string{"priority", "weight", "max_concurrency"} {
+			if v := primary.Attributes[key]; v != "" {
+				attrs[key] = v
+			}
+		}
 		metadataCopy := map[string]any{
 			"email":             email,
 			"project_id":        projectID,
@@ -222,3 +236,27 @@ func buildGeminiVirtualID(baseID, projectID string) string {
 	replacer := strings.NewReplacer("/", "_", "\\", "_", " ", "_")
 	return fmt.Sprintf("%s::%s", baseID, replacer.Replace(project))
 }
+
+// intFromMetadata reads an integer value from auth file metadata, accepting JSON numbers or numeric strings.
+func intFromMetadata(metadata map[string]any, key string) int {
+	if metadata == nil {
+		return 0
+	}
+	switch v := metadata[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i)
+		}
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return i
+		}
+	}
+	return 0
+}
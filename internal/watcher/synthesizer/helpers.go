@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
@@ -108,3 +109,25 @@ func addConfigHeadersToAttrs(headers map[string]string, attrs map[string]string)
 		attrs["header:"+key] = val
 	}
 }
+
+// addRoutingAttrs records credential selection hints (priority tier and weight) in auth attributes.
+// Zero values are omitted so selectors fall back to their defaults.
+func addRoutingAttrs(priority, weight int, attrs map[string]string) {
+	if attrs == nil {
+		return
+	}
+	if priority != 0 {
+		attrs["priority"] = strconv.Itoa(priority)
+	}
+	if weight > 0 {
+		attrs["weight"] = strconv.Itoa(weight)
+	}
+}
+
+// addConcurrencyAttrs records the credential's max-concurrency in auth attributes; zero (unlimited) is omitted.
+func addConcurrencyAttrs(maxConcurrency int, attrs map[string]string) {
+	if attrs == nil || maxConcurrency <= 0 {
+		return
+	}
+	attrs["max_concurrency"] = strconv.Itoa(maxConcurrency)
+}
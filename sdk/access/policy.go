@@ -0,0 +1,157 @@
+package access
+
+import (
+	"strings"
+)
+
+// Result metadata keys describing the access policy of an authenticated client key. List
+// values are comma separated; a missing key leaves that dimension unrestricted.
+const (
+	// MetadataKeyName labels the client key.
+	MetadataKeyName = "key-name"
+	// MetadataAllowedModels lists model name patterns the key may request.
+	MetadataAllowedModels = "allowed-models"
+	// MetadataAllowedProviders lists upstream providers the key may use.
+	MetadataAllowedProviders = "allowed-providers"
+	// MetadataAllowedPrefixes lists credential prefixes the key may use.
+	MetadataAllowedPrefixes = "allowed-prefixes"
+	// MetadataDefaultPrefix is applied to unprefixed model names.
+	MetadataDefaultPrefix = "default-prefix"
+)
+
+// Policy restricts what an authenticated client key may use.
+type Policy struct {
+	Name          string
+	Models        []string
+	Providers     []string
+	Prefixes      []string
+	DefaultPrefix string
+}
+
+// PolicyFromMetadata decodes the policy carried in Result.Metadata.
+func PolicyFromMetadata(metadata map[string]string) Policy {
+	if len(metadata) == 0 {
+		return Policy{}
+	}
+	return Policy{
+		Name:          strings.TrimSpace(metadata[MetadataKeyName]),
+		Models:        splitPolicyList(metadata[MetadataAllowedModels]),
+		Providers:     splitPolicyList(metadata[MetadataAllowedProviders]),
+		Prefixes:      splitPolicyList(metadata[MetadataAllowedPrefixes]),
+		DefaultPrefix: strings.TrimSpace(metadata[MetadataDefaultPrefix]),
+	}
+}
+
+// Metadata encodes the policy for Result.Metadata, omitting unrestricted dimensions.
+func (p Policy) Metadata() map[string]string {
+	out := make(map[string]string)
+	if p.Name != "" {
+		out[MetadataKeyName] = p.Name
+	}
+	if len(p.Models) > 0 {
+		out[MetadataAllowedModels] = strings.Join(p.Models, ",")
+	}
+	if len(p.Providers) > 0 {
+		out[MetadataAllowedProviders] = strings.Join(p.Providers, ",")
+	}
+	if len(p.Prefixes) > 0 {
+		out[MetadataAllowedPrefixes] = strings.Join(p.Prefixes, ",")
+	}
+	if p.DefaultPrefix != "" {
+		out[MetadataDefaultPrefix] = p.DefaultPrefix
+	}
+	return out
+}
+
+// Restricted reports whether the policy limits models, providers or prefixes.
+func (p Policy) Restricted() bool {
+	return len(p.Models) > 0 || len(p.Providers) > 0 || len(p.Prefixes) > 0
+}
+
+// AllowsModel reports whether model matches one of the allowed patterns. Prefixed names
+// ("teamA/gemini-2.5-pro") also match patterns written for the bare model name.
+func (p Policy) AllowsModel(model string) bool {
+	if len(p.Models) == 0 {
+		return true
+	}
+	model = strings.ToLower(strings.TrimSpace(model))
+	base := model
+	if idx := strings.Index(model, "/"); idx >= 0 {
+		base = model[idx+1:]
+	}
+	for _, pattern := range p.Models {
+		pattern = strings.ToLower(pattern)
+		if MatchPattern(pattern, model) || MatchPattern(pattern, base) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsProvider reports whether the upstream provider may be used.
+func (p Policy) AllowsProvider(provider string) bool {
+	return len(p.Providers) == 0 || containsFold(p.Providers, provider)
+}
+
+// AllowsPrefix reports whether credentials with the given prefix may be used. Unprefixed
+// credentials are only allowed when the policy does not restrict prefixes.
+func (p Policy) AllowsPrefix(prefix string) bool {
+	if len(p.Prefixes) == 0 {
+		return true
+	}
+	prefix = strings.TrimSpace(prefix)
+	return prefix != "" && containsFold(p.Prefixes, prefix)
+}
+
+func splitPolicyList(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			out = append(out, trimmed)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func containsFold(values []string, value string) bool {
+	value = strings.TrimSpace(value)
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPattern reports whether value matches pattern, where '*' matches any substring. The
+// comparison is case-sensitive.
+func MatchPattern(pattern, value string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == value
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	last := parts[len(parts)-1]
+	if !strings.HasSuffix(value, last) {
+		return false
+	}
+	value = value[:len(value)-len(last)]
+	for _, segment := range parts[1 : len(parts)-1] {
+		idx := strings.Index(value, segment)
+		if idx < 0 {
+			return false
+		}
+		value = value[idx+len(segment):]
+	}
+	return true
+}
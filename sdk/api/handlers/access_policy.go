@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// accessPolicyFromGin returns the access policy attached to the caller's client key by the
+// access provider, or an unrestricted policy.
+func accessPolicyFromGin(c *gin.Context) sdkaccess.Policy {
+	if c == nil {
+		return sdkaccess.Policy{}
+	}
+	raw, exists := c.Get("accessMetadata")
+	if !exists {
+		return sdkaccess.Policy{}
+	}
+	metadata, _ := raw.(map[string]string)
+	return sdkaccess.PolicyFromMetadata(metadata)
+}
+
+func accessPolicyFromContext(ctx context.Context) sdkaccess.Policy {
+	if ctx == nil {
+		return sdkaccess.Policy{}
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	return accessPolicyFromGin(ginCtx)
+}
+
+// applyDefaultPrefix rewrites an unprefixed model name to the policy's default prefix when
+// such a prefixed model is registered.
+func applyDefaultPrefix(policy sdkaccess.Policy, model string) string {
+	if policy.DefaultPrefix == "" || model == "" || strings.Contains(model, "/") {
+		return model
+	}
+	candidate := policy.DefaultPrefix + "/" + model
+	normalized, _ := normalizeModelMetadata(candidate)
+	if len(util.GetProviderName(normalized)) == 0 {
+		return model
+	}
+	return candidate
+}
+
+// accessDeniedError builds a 403 whose body follows the error format of the client API.
+func accessDeniedError(handlerType, message string) *interfaces.ErrorMessage {
+	return clientFormatError(handlerType, http.StatusForbidden, message, "permission_error", "model_not_allowed", "PERMISSION_DENIED")
+}
+
+type errorFormatKey struct{}
+
+// WithErrorFormat makes the errors the proxy raises itself for requests run with ctx (access
+// policy, rate limit and budget rejections) follow the error format of the client API
+// handlerType. Handlers executing through another API's handler type use it to keep the
+// error format of their own clients.
+func WithErrorFormat(ctx context.Context, handlerType string) context.Context {
+	return context.WithValue(ctx, errorFormatKey{}, handlerType)
+}
+
+// errorFormat returns the client API whose error format applies to requests run with ctx.
+func errorFormat(ctx context.Context, handlerType string) string {
+	if ctx != nil {
+		if format, ok := ctx.Value(errorFormatKey{}).(string); ok && format != "" {
+			return format
+		}
+	}
+	return handlerType
+}
+
+// clientFormatError builds an error whose body follows the error format of the client API:
+// errType and code for OpenAI, errType for Claude and status for Gemini.
+func clientFormatError(handlerType string, httpStatus int, message, errType, code, status string) *interfaces.ErrorMessage {
+	var payload any
+	switch handlerType {
+	case constant.Claude:
+		payload = gin.H{"type": "error", "error": gin.H{"type": errType, "message": message}}
+	case constant.Gemini, constant.GeminiCLI:
+		payload = gin.H{"error": gin.H{"code": httpStatus, "message": message, "status": status}}
+	default:
+		payload = ErrorResponse{Error: ErrorDetail{Message: message, Type: errType, Code: code}}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		body = []byte(message)
+	}
+	return &interfaces.ErrorMessage{StatusCode: httpStatus, Error: errors.New(string(body))}
+}
+
+// accessDeniedFromError converts a credential-prefix denial reported by the auth manager into
+// a client-format 403.
+func accessDeniedFromError(handlerType string, err error) *interfaces.ErrorMessage {
+	var authErr *coreauth.Error
+	if !errors.As(err, &authErr) || authErr == nil || authErr.Code != "auth_not_allowed" {
+		return nil
+	}
+	return accessDeniedError(handlerType, authErr.Message)
+}
+
+// enforceAccessPolicy checks the requested model and its providers against the caller's policy
+// and returns the providers the caller may use.
+func enforceAccessPolicy(policy sdkaccess.Policy, handlerType, requestedModel, normalizedModel string, providers []string) ([]string, *interfaces.ErrorMessage) {
+	if !policy.Restricted() {
+		return providers, nil
+	}
+	if !policy.AllowsModel(normalizedModel) && !policy.AllowsModel(requestedModel) {
+		return nil, accessDeniedError(handlerType, fmt.Sprintf("model %s is not allowed for this API key", requestedModel))
+	}
+	allowed := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if policy.AllowsProvider(provider) {
+			allowed = append(allowed, provider)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, accessDeniedError(handlerType, fmt.Sprintf("model %s is not available to this API key", requestedModel))
+	}
+	return allowed, nil
+}
+
+// FilterModelsForClient removes the models the caller's client key may not use from a model
+// listing. Entries are identified by their "id", or by "name" for Gemini listings.
+func (h *BaseAPIHandler) FilterModelsForClient(c *gin.Context, models []map[string]any) []map[string]any {
+	policy := accessPolicyFromGin(c)
+	if !policy.Restricted() {
+		return models
+	}
+	var reachable map[string]struct{}
+	if (len(policy.Providers) > 0 || len(policy.Prefixes) > 0) && h.AuthManager != nil {
+		reachable = make(map[string]struct{})
+		modelRegistry := registry.GetGlobalRegistry()
+		for _, auth := range h.AuthManager.List() {
+			if auth == nil || auth.Disabled || !policy.AllowsProvider(auth.Provider) || !policy.AllowsPrefix(auth.Prefix) {
+				continue
+			}
+			for _, info := range modelRegistry.GetModelsForClient(auth.ID) {
+				if info != nil {
+					reachable[info.ID] = struct{}{}
+				}
+			}
+		}
+	}
+	filtered := make([]map[string]any, 0, len(models))
+	for _, model := range models {
+		id, _ := model["id"].(string)
+		if id == "" {
+			name, _ := model["name"].(string)
+			id = strings.TrimPrefix(name, "models/")
+		}
+		if id == "" || !policy.AllowsModel(id) {
+			continue
+		}
+		if reachable != nil {
+			if _, ok := reachable[id]; !ok {
+				continue
+			}
+		}
+		filtered = append(filtered, model)
+	}
+	return filtered
+}
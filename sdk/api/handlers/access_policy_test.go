@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+type recordingAuthExecutor struct {
+	mu      sync.Mutex
+	authIDs []string
+}
+
+func (e *recordingAuthExecutor) Identifier() string { return "codex" }
+
+func (e *recordingAuthExecutor) Execute(_ context.Context, auth *coreauth.Auth, _ coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.authIDs = append(e.authIDs, auth.ID)
+	e.mu.Unlock()
+	return coreexecutor.Response{Payload: []byte(`{"ok":true}`)}, nil
+}
+
+func (e *recordingAuthExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *recordingAuthExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *recordingAuthExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func newAccessPolicyTestHandler(t *testing.T, executor *recordingAuthExecutor) *BaseAPIHandler {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auths := []*coreauth.Auth{
+		{ID: "policy-team-a", Provider: "codex", Prefix: "teamA", Status: coreauth.StatusActive},
+		{ID: "policy-shared", Provider: "codex", Status: coreauth.StatusActive},
+	}
+	for _, auth := range auths {
+		if _, err := manager.Register(context.Background(), auth); err != nil {
+			t.Fatalf("manager.Register(%s): %v", auth.ID, err)
+		}
+	}
+	registry.GetGlobalRegistry().RegisterClient("policy-team-a", "codex", []*registry.ModelInfo{{ID: "policy-model"}, {ID: "teamA/policy-model"}})
+	registry.GetGlobalRegistry().RegisterClient("policy-shared", "codex", []*registry.ModelInfo{{ID: "policy-model"}, {ID: "policy-shared-model"}})
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient("policy-team-a")
+		registry.GetGlobalRegistry().UnregisterClient("policy-shared")
+	})
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+}
+
+func policyContext(policy sdkaccess.Policy) (context.Context, *gin.Context) {
+	gin.SetMode(gin.TestMode)
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	ginCtx.Set("accessMetadata", policy.Metadata())
+	return context.WithValue(context.Background(), "gin", ginCtx), ginCtx
+}
+
+func TestExecuteWithAuthManager_DeniesModelOutsidePolicy(t *testing.T) {
+	executor := &recordingAuthExecutor{}
+	handler := newAccessPolicyTestHandler(t, executor)
+	ctx, _ := policyContext(sdkaccess.Policy{Models: []string{"policy-shared-*"}})
+
+	_, errMsg := handler.ExecuteWithAuthManager(ctx, "claude", "policy-model", []byte(`{"model":"policy-model"}`), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %+v", errMsg)
+	}
+	body := BuildErrorResponseBody(errMsg.StatusCode, errMsg.Error.Error())
+	if gjson.GetBytes(body, "type").String() != "error" || gjson.GetBytes(body, "error.type").String() != "permission_error" {
+		t.Fatalf("expected a Claude permission error, got %s", body)
+	}
+	if len(executor.authIDs) != 0 {
+		t.Fatalf("denied request reached the executor")
+	}
+
+	if _, errMsg = handler.ExecuteWithAuthManager(ctx, "claude", "policy-shared-model", []byte(`{"model":"policy-shared-model"}`), ""); errMsg != nil {
+		t.Fatalf("allowed model rejected: %+v", errMsg)
+	}
+}
+
+func TestExecuteWithAuthManager_RestrictsCredentialPrefixes(t *testing.T) {
+	executor := &recordingAuthExecutor{}
+	handler := newAccessPolicyTestHandler(t, executor)
+	ctx, _ := policyContext(sdkaccess.Policy{Prefixes: []string{"teamA"}})
+
+	for i := 0; i < 4; i++ {
+		if _, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "policy-model", []byte(`{"model":"policy-model"}`), ""); errMsg != nil {
+			t.Fatalf("request %d failed: %+v", i, errMsg)
+		}
+	}
+	for _, id := range executor.authIDs {
+		if id != "policy-team-a" {
+			t.Fatalf("request served by %s outside the allowed prefixes", id)
+		}
+	}
+
+	_, errMsg := handler.ExecuteWithAuthManager(ctx, "gemini", "policy-shared-model", []byte(`{"model":"policy-shared-model"}`), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a model only served by unprefixed credentials, got %+v", errMsg)
+	}
+	body := BuildErrorResponseBody(errMsg.StatusCode, errMsg.Error.Error())
+	if gjson.GetBytes(body, "error.status").String() != "PERMISSION_DENIED" {
+		t.Fatalf("expected a Gemini permission error, got %s", body)
+	}
+}
+
+func TestFilterModelsForClient(t *testing.T) {
+	handler := newAccessPolicyTestHandler(t, &recordingAuthExecutor{})
+	_, ginCtx := policyContext(sdkaccess.Policy{Prefixes: []string{"teamA"}, DefaultPrefix: "teamA"})
+	models := []map[string]any{
+		{"id": "policy-model"},
+		{"id": "teamA/policy-model"},
+		{"id": "policy-shared-model"},
+	}
+	filtered := handler.FilterModelsForClient(ginCtx, models)
+	if len(filtered) != 2 || filtered[0]["id"] != "policy-model" || filtered[1]["id"] != "teamA/policy-model" {
+		t.Fatalf("unexpected filtered models: %v", filtered)
+	}
+
+	_, unrestricted := policyContext(sdkaccess.Policy{})
+	if got := handler.FilterModelsForClient(unrestricted, models); len(got) != len(models) {
+		t.Fatalf("unrestricted key lost models: %v", got)
+	}
+}
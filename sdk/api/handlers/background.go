@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+type backgroundContextKey struct{}
+
+// interactiveInFlight counts client requests currently executing, background work excluded.
+var interactiveInFlight atomic.Int64
+
+// InteractiveInFlight returns the number of client requests currently executing. Background
+// work such as batches uses it to yield to interactive traffic.
+func InteractiveInFlight() int64 {
+	return interactiveInFlight.Load()
+}
+
+// trackInteractive counts a request as interactive unless it runs in a background context.
+// The returned function must be called once the request has finished.
+func trackInteractive(ctx context.Context) func() {
+	if isBackground(ctx) {
+		return func() {}
+	}
+	interactiveInFlight.Add(1)
+	return func() { interactiveInFlight.Add(-1) }
+}
+
+func isBackground(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	background, _ := ctx.Value(backgroundContextKey{}).(bool)
+	return background
+}
+
+// BackgroundContext creates the execution context for a request issued by the proxy itself on
+// behalf of a client, such as a batch line. The Gin context carries values (for example
+// "apiKey" and "accessMetadata") so budgets, rate limits, access policies and usage apply as
+// for the original caller; the response writer discards output. Requests executed with this
+// context do not count as interactive traffic.
+//
+// Parameters:
+//   - parent: The parent context; cancelling it aborts the request
+//   - handler: The API handler the request is attributed to
+//   - path: The endpoint path the request emulates
+//   - requestID: The request ID used for logs and usage
+//   - values: Values set on the Gin context
+//
+// Returns:
+//   - *gin.Context: The detached Gin context
+//   - context.Context: The execution context
+//   - APIHandlerCancelFunc: A function to cancel the context and log the response
+func (h *BaseAPIHandler) BackgroundContext(parent context.Context, handler interfaces.APIHandler, path, requestID string, values map[string]any) (*gin.Context, context.Context, APIHandlerCancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	parent = context.WithValue(parent, backgroundContextKey{}, true)
+	if requestID != "" {
+		parent = logging.WithRequestID(parent, requestID)
+	}
+	c := &gin.Context{Writer: newDiscardWriter()}
+	c.Request, _ = http.NewRequestWithContext(parent, http.MethodPost, path, http.NoBody)
+	for key, value := range values {
+		c.Set(key, value)
+	}
+	ctx, cancel := h.GetContextWithCancel(handler, c, parent)
+	return c, ctx, cancel
+}
+
+// discardWriter is the response writer of background requests. It tracks the status and size
+// like Gin's own writer and drops the body, since no client reads it.
+type discardWriter struct {
+	header http.Header
+	status int
+	size   int
+}
+
+func newDiscardWriter() *discardWriter {
+	return &discardWriter{header: make(http.Header), status: http.StatusOK, size: -1}
+}
+
+func (w *discardWriter) Header() http.Header { return w.header }
+
+func (w *discardWriter) WriteHeader(code int) {
+	if code > 0 && !w.Written() {
+		w.status = code
+	}
+}
+
+func (w *discardWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+	}
+}
+
+func (w *discardWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	w.size += len(data)
+	return len(data), nil
+}
+
+func (w *discardWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	w.size += len(s)
+	return len(s), nil
+}
+
+func (w *discardWriter) Status() int { return w.status }
+
+func (w *discardWriter) Size() int { return w.size }
+
+func (w *discardWriter) Written() bool { return w.size != -1 }
+
+func (w *discardWriter) Flush() { w.WriteHeaderNow() }
+
+func (w *discardWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("handlers: background requests have no connection")
+}
+
+func (w *discardWriter) CloseNotify() <-chan bool { return make(chan bool) }
+
+func (w *discardWriter) Pusher() http.Pusher { return nil }
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type loadProbeExecutor struct {
+	recordingAuthExecutor
+	seen []int64
+}
+
+func (e *loadProbeExecutor) Execute(ctx context.Context, auth *coreauth.Auth, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	e.seen = append(e.seen, InteractiveInFlight())
+	return e.recordingAuthExecutor.Execute(ctx, auth, req, opts)
+}
+
+func TestBackgroundContext_ActsAsCallerWithoutCountingAsInteractive(t *testing.T) {
+	executor := &loadProbeExecutor{}
+	handler := newAccessPolicyTestHandler(t, &executor.recordingAuthExecutor)
+	handler.AuthManager.RegisterExecutor(executor)
+
+	ctx, _ := policyContext(sdkaccess.Policy{})
+	if _, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "policy-model", []byte(`{}`), ""); errMsg != nil {
+		t.Fatalf("interactive request failed: %+v", errMsg)
+	}
+
+	metadata := sdkaccess.Policy{Models: []string{"policy-shared-*"}}.Metadata()
+	_, bgCtx, cancel := handler.BackgroundContext(context.Background(), nil, "/v1/chat/completions", "batch_req_test", map[string]any{"accessMetadata": metadata})
+	defer cancel()
+	if _, errMsg := handler.ExecuteWithAuthManager(bgCtx, "openai", "policy-model", []byte(`{}`), ""); errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected the caller's access policy to apply, got %+v", errMsg)
+	}
+	if _, errMsg := handler.ExecuteWithAuthManager(bgCtx, "openai", "policy-shared-model", []byte(`{}`), ""); errMsg != nil {
+		t.Fatalf("background request failed: %+v", errMsg)
+	}
+
+	if len(executor.seen) != 2 || executor.seen[0] != 1 || executor.seen[1] != 0 {
+		t.Fatalf("expected only the interactive request to be counted, got %v", executor.seen)
+	}
+	if InteractiveInFlight() != 0 {
+		t.Fatalf("expected no request in flight, got %d", InteractiveInFlight())
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtualkeys"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/budget"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+)
+
+// budgetWarningHeader carries one warning per soft budget the client key has reached.
+const budgetWarningHeader = "X-Budget-Warning"
+
+// checkClientBudget rejects requests of a client key that has reached a hard budget and adds
+// warning headers for the soft budgets it has reached.
+func (h *BaseAPIHandler) checkClientBudget(ctx context.Context, handlerType, key string) *interfaces.ErrorMessage {
+	warnings, exceeded := budget.Default().Check(key, virtualkeys.Default().BudgetsFor(h.Cfg, key))
+	if exceeded != nil {
+		return budgetExceededError(handlerType, exceeded)
+	}
+	if len(warnings) == 0 {
+		return nil
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		for _, warning := range warnings {
+			ginCtx.Writer.Header().Add(budgetWarningHeader, warning.Warning())
+		}
+	}
+	return nil
+}
+
+// budgetExceededError builds a 429 in the error format of the client API with Retry-After set
+// to the end of the exhausted budget period.
+func budgetExceededError(handlerType string, exceeded *budget.Exceeded) *interfaces.ErrorMessage {
+	msg := clientFormatError(handlerType, http.StatusTooManyRequests, exceeded.Error(), "budget_exceeded", "budget_exceeded", "RESOURCE_EXHAUSTED")
+	retryAfter := int(math.Ceil(time.Until(exceeded.ResetsAt).Seconds()))
+	msg.Addon = http.Header{"Retry-After": []string{strconv.Itoa(max(retryAfter, 1))}}
+	return msg
+}
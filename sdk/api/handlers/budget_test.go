@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/budget"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestExecuteWithAuthManager_EnforcesClientBudgets(t *testing.T) {
+	executor := &recordingAuthExecutor{}
+	handler := newAccessPolicyTestHandler(t, executor)
+	handler.Cfg = &sdkconfig.SDKConfig{
+		APIKeyEntries: []sdkconfig.ClientAPIKey{{
+			APIKey:  "budget-test-key",
+			Budgets: []sdkconfig.ClientBudget{{Period: "daily", SoftTokens: 10, HardTokens: 20}},
+		}},
+	}
+	t.Cleanup(func() { budget.Default().Reset("budget-test-key", "") })
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	ginCtx.Set("apiKey", "budget-test-key")
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+
+	budget.Default().Record("budget-test-key", 15, 0)
+	if _, errMsg := handler.ExecuteWithAuthManager(ctx, "claude", "policy-model", []byte(`{}`), ""); errMsg != nil {
+		t.Fatalf("request under the hard budget rejected: %+v", errMsg)
+	}
+	if recorder.Header().Get("X-Budget-Warning") == "" {
+		t.Fatalf("expected a soft budget warning header")
+	}
+
+	budget.Default().Record("budget-test-key", 5, 0)
+	_, errMsg := handler.ExecuteWithAuthManager(ctx, "claude", "policy-model", []byte(`{}`), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusTooManyRequests || errMsg.Addon.Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %+v", errMsg)
+	}
+	body := BuildErrorResponseBody(errMsg.StatusCode, errMsg.Error.Error())
+	if gjson.GetBytes(body, "error.type").String() != "budget_exceeded" {
+		t.Fatalf("expected a Claude budget error, got %s", body)
+	}
+}
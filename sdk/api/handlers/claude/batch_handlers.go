@@ -0,0 +1,437 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultMessageBatchesLimit = 20
+	maxMessageBatchesLimit     = 1000
+	// defaultBatchRetryAfter delays a rate limited batch request without a Retry-After hint.
+	defaultBatchRetryAfter = 10 * time.Second
+)
+
+// validCustomID matches the custom_id format accepted by the Message Batches API.
+var validCustomID = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ClaudeBatchAPIHandler serves the /v1/messages/batches endpoints and executes the requests of
+// message batches through the regular Messages path, so they can be served by any backend.
+type ClaudeBatchAPIHandler struct {
+	*handlers.BaseAPIHandler
+	messages *ClaudeCodeAPIHandler
+}
+
+// NewClaudeBatchAPIHandler creates a new Claude message batches handlers instance.
+//
+// Parameters:
+//   - apiHandlers: The base API handler instance.
+//
+// Returns:
+//   - *ClaudeBatchAPIHandler: A new Claude message batches handler instance.
+func NewClaudeBatchAPIHandler(apiHandlers *handlers.BaseAPIHandler) *ClaudeBatchAPIHandler {
+	return &ClaudeBatchAPIHandler{
+		BaseAPIHandler: apiHandlers,
+		messages:       NewClaudeCodeAPIHandler(apiHandlers),
+	}
+}
+
+// HandlerType returns the identifier for this handler implementation.
+func (h *ClaudeBatchAPIHandler) HandlerType() string {
+	return Claude
+}
+
+// Models returns a list of models supported by this handler.
+func (h *ClaudeBatchAPIHandler) Models() []map[string]any {
+	return registry.GetGlobalRegistry().GetAvailableModels("claude")
+}
+
+// CreateMessageBatch handles POST /v1/messages/batches. The requests run in the background
+// with the client key of the caller.
+//
+// Parameters:
+//   - c: The Gin context for the request.
+func (h *ClaudeBatchAPIHandler) CreateMessageBatch(c *gin.Context) {
+	if !messageBatchesEnabled(c) {
+		return
+	}
+	rawJSON, err := c.GetRawData()
+	requests := gjson.GetBytes(rawJSON, "requests")
+	if err != nil || !requests.IsArray() || len(requests.Array()) == 0 {
+		writeClaudeBatchError(c, http.StatusBadRequest, "invalid_request_error", "requests: a non-empty array is required")
+		return
+	}
+	var input bytes.Buffer
+	for _, req := range requests.Array() {
+		if errCompact := json.Compact(&input, []byte(req.Raw)); errCompact != nil {
+			writeClaudeBatchError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("requests: %v", errCompact))
+			return
+		}
+		input.WriteByte('\n')
+	}
+
+	caller := batch.CallerFromGin(c)
+	owner := batch.OwnerOf(caller.APIKey)
+	manager := batch.Default()
+	f, err := manager.CreateFile(batch.File{Filename: "message_batch.jsonl", Purpose: "batch", Owner: owner, Internal: true}, &input)
+	if err != nil {
+		writeClaudeManagerError(c, err)
+		return
+	}
+	b, err := manager.CreateBatch(batch.Batch{
+		ID:               "msgbatch_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Kind:             batch.KindClaude,
+		Owner:            owner,
+		Caller:           caller,
+		Endpoint:         "/v1/messages",
+		InputFileID:      f.ID,
+		CompletionWindow: "24h",
+	})
+	if err != nil {
+		_ = manager.DeleteFile(f.ID, owner)
+		writeClaudeManagerError(c, err)
+		return
+	}
+	if b.Status == batch.StatusFailed {
+		_ = manager.DeleteBatch(batch.KindClaude, b.ID, owner)
+		lineErr := b.Errors[0]
+		message := lineErr.Message
+		if lineErr.Line > 0 {
+			message = fmt.Sprintf("requests.%d: %s", lineErr.Line-1, lineErr.Message)
+		}
+		writeClaudeBatchError(c, http.StatusBadRequest, "invalid_request_error", message)
+		return
+	}
+	c.JSON(http.StatusOK, messageBatchObject(b))
+}
+
+// ListMessageBatches handles GET /v1/messages/batches, newest first. It supports the limit,
+// after_id and before_id query parameters.
+//
+// Parameters:
+//   - c: The Gin context for the request.
+func (h *ClaudeBatchAPIHandler) ListMessageBatches(c *gin.Context) {
+	if !messageBatchesEnabled(c) {
+		return
+	}
+	limit := defaultMessageBatchesLimit
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > maxMessageBatchesLimit {
+			writeClaudeBatchError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("limit: must be between 1 and %d", maxMessageBatchesLimit))
+			return
+		}
+		limit = parsed
+	}
+	batches := batch.Default().ListBatches(batch.KindClaude, batchOwner(c))
+	start, end := 0, len(batches)
+	for i, b := range batches {
+		if b.ID == c.Query("after_id") {
+			start = i + 1
+		}
+		if b.ID == c.Query("before_id") {
+			end = i
+		}
+	}
+	if start > end {
+		start = end
+	}
+	hasMore := false
+	if c.Query("before_id") != "" && c.Query("after_id") == "" {
+		if end-start > limit {
+			start, hasMore = end-limit, true
+		}
+	} else if end-start > limit {
+		end, hasMore = start+limit, true
+	}
+
+	data := make([]gin.H, 0, end-start)
+	for _, b := range batches[start:end] {
+		data = append(data, messageBatchObject(b))
+	}
+	list := gin.H{"data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(data) > 0 {
+		list["first_id"] = batches[start].ID
+		list["last_id"] = batches[end-1].ID
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetMessageBatch handles GET /v1/messages/batches/{id}.
+//
+// Parameters:
+//   - c: The Gin context for the request.
+func (h *ClaudeBatchAPIHandler) GetMessageBatch(c *gin.Context) {
+	if !messageBatchesEnabled(c) {
+		return
+	}
+	b, err := batch.Default().GetBatch(batch.KindClaude, c.Param("id"), batchOwner(c))
+	if err != nil {
+		writeClaudeManagerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, messageBatchObject(b))
+}
+
+// CancelMessageBatch handles POST /v1/messages/batches/{id}/cancel. Requests already running
+// finish; the others are reported as canceled once the batch has ended.
+//
+// Parameters:
+//   - c: The Gin context for the request.
+func (h *ClaudeBatchAPIHandler) CancelMessageBatch(c *gin.Context) {
+	if !messageBatchesEnabled(c) {
+		return
+	}
+	b, err := batch.Default().CancelBatch(batch.KindClaude, c.Param("id"), batchOwner(c))
+	if err != nil {
+		writeClaudeManagerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, messageBatchObject(b))
+}
+
+// DeleteMessageBatch handles DELETE /v1/messages/batches/{id}. Only ended batches can be
+// deleted; their results are removed as well.
+//
+// Parameters:
+//   - c: The Gin context for the request.
+func (h *ClaudeBatchAPIHandler) DeleteMessageBatch(c *gin.Context) {
+	if !messageBatchesEnabled(c) {
+		return
+	}
+	id := c.Param("id")
+	if err := batch.Default().DeleteBatch(batch.KindClaude, id, batchOwner(c)); err != nil {
+		writeClaudeManagerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "type": "message_batch_deleted"})
+}
+
+// MessageBatchResults handles GET /v1/messages/batches/{id}/results and streams the results
+// of an ended batch as JSONL, one entry per request in no particular order.
+//
+// Parameters:
+//   - c: The Gin context for the request.
+func (h *ClaudeBatchAPIHandler) MessageBatchResults(c *gin.Context) {
+	if !messageBatchesEnabled(c) {
+		return
+	}
+	owner := batchOwner(c)
+	manager := batch.Default()
+	b, err := manager.GetBatch(batch.KindClaude, c.Param("id"), owner)
+	if err != nil {
+		writeClaudeManagerError(c, err)
+		return
+	}
+	if !b.Status.Terminal() {
+		writeClaudeBatchError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Message batch %s is still processing; results are available once it has ended.", b.ID))
+		return
+	}
+	c.Header("Content-Type", "application/x-jsonl")
+	c.Status(http.StatusOK)
+	for _, fileID := range []string{b.OutputFileID, b.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		data, _, errOpen := manager.OpenFile(fileID, owner)
+		if errOpen != nil {
+			continue
+		}
+		_, _ = io.Copy(c.Writer, data)
+		_ = data.Close()
+	}
+}
+
+// Validate implements batch.Processor. It checks one request of a message batch.
+func (h *ClaudeBatchAPIHandler) Validate(_ *batch.Batch, line []byte) (string, *batch.LineError) {
+	parsed := gjson.ParseBytes(line)
+	if !gjson.ValidBytes(line) || !parsed.IsObject() {
+		return "", &batch.LineError{Code: "invalid_request_error", Message: "each request must be an object"}
+	}
+	customID := parsed.Get("custom_id").String()
+	if !validCustomID.MatchString(customID) {
+		return "", &batch.LineError{Code: "invalid_request_error", Message: "custom_id: must be 1 to 64 letters, digits, underscores or hyphens", Param: "custom_id"}
+	}
+	params := parsed.Get("params")
+	if !params.IsObject() {
+		return "", &batch.LineError{Code: "invalid_request_error", Message: "params: Field required", Param: "params"}
+	}
+	if params.Get("model").String() == "" {
+		return "", &batch.LineError{Code: "invalid_request_error", Message: "params.model: Field required", Param: "params.model"}
+	}
+	if !params.Get("messages").IsArray() {
+		return "", &batch.LineError{Code: "invalid_request_error", Message: "params.messages: Field required", Param: "params.messages"}
+	}
+	return customID, nil
+}
+
+// Execute implements batch.Processor. It runs one request as the batch creator through the
+// Messages path and returns its result entry.
+func (h *ClaudeBatchAPIHandler) Execute(ctx context.Context, b *batch.Batch, customID string, line []byte) batch.Result {
+	params := []byte(gjson.GetBytes(line, "params").Raw)
+	params, _ = sjson.DeleteBytes(params, "stream")
+	requestID := "msgbatchreq_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	_, cliCtx, cliCancel := h.BackgroundContext(ctx, h.messages, b.Endpoint, requestID, b.Caller.Values())
+	resp, errMsg := h.messages.executeMessage(cliCtx, params, "")
+
+	out, _ := sjson.SetBytes([]byte(`{"custom_id":"","result":{"type":"succeeded"}}`), "custom_id", customID)
+	if errMsg == nil {
+		cliCancel()
+		out, _ = sjson.SetRawBytes(out, "result.message", resp)
+		return batch.Result{Line: out}
+	}
+
+	cliCancel(errMsg.Error)
+	status := http.StatusInternalServerError
+	if errMsg.StatusCode > 0 {
+		status = errMsg.StatusCode
+	}
+	errText := http.StatusText(status)
+	if errMsg.Error != nil {
+		errText = errMsg.Error.Error()
+	}
+	out, _ = sjson.SetBytes(out, "result.type", "errored")
+	out, _ = sjson.SetRawBytes(out, "result.error", claudeErrorBody(status, errText))
+	result := batch.Result{Line: out, Failed: true}
+	if status == http.StatusTooManyRequests {
+		result.RetryAfter = defaultBatchRetryAfter
+		if seconds, errAtoi := strconv.Atoi(errMsg.Addon.Get("Retry-After")); errAtoi == nil && seconds > 0 {
+			result.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+	return result
+}
+
+// Skip implements batch.Processor. Requests that did not run are reported as canceled or
+// expired.
+func (h *ClaudeBatchAPIHandler) Skip(_ *batch.Batch, customID string, _ []byte, status batch.Status) []byte {
+	resultType := "canceled"
+	if status == batch.StatusExpired {
+		resultType = "expired"
+	}
+	out, _ := sjson.SetBytes([]byte(`{"custom_id":"","result":{"type":""}}`), "custom_id", customID)
+	out, _ = sjson.SetBytes(out, "result.type", resultType)
+	return out
+}
+
+// claudeErrorBody returns an Anthropic error object for a failed request, keeping errors that
+// already use that format.
+func claudeErrorBody(status int, errText string) []byte {
+	if trimmed := strings.TrimSpace(errText); gjson.Valid(trimmed) && gjson.Get(trimmed, "type").String() == "error" {
+		return []byte(trimmed)
+	}
+	errType := "api_error"
+	switch status {
+	case http.StatusBadRequest:
+		errType = "invalid_request_error"
+	case http.StatusUnauthorized:
+		errType = "authentication_error"
+	case http.StatusForbidden:
+		errType = "permission_error"
+	case http.StatusNotFound:
+		errType = "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		errType = "request_too_large"
+	case http.StatusTooManyRequests:
+		errType = "rate_limit_error"
+	case 529:
+		errType = "overloaded_error"
+	}
+	body, _ := json.Marshal(claudeErrorResponse{Type: "error", Error: claudeErrorDetail{Type: errType, Message: errText}})
+	return body
+}
+
+func messageBatchObject(b *batch.Batch) gin.H {
+	remaining := b.Counts.Total - b.Counts.Completed - b.Counts.Failed
+	counts := gin.H{"processing": 0, "succeeded": b.Counts.Completed, "errored": b.Counts.Failed, "canceled": 0, "expired": 0}
+	obj := gin.H{
+		"id":                  b.ID,
+		"type":                "message_batch",
+		"processing_status":   "in_progress",
+		"request_counts":      counts,
+		"ended_at":            nil,
+		"created_at":          rfc3339(b.CreatedAt),
+		"expires_at":          rfc3339(b.ExpiresAt),
+		"archived_at":         nil,
+		"cancel_initiated_at": rfc3339(b.CancellingAt),
+		"results_url":         nil,
+	}
+	switch b.Status {
+	case batch.StatusCancelled:
+		counts["canceled"] = remaining
+	case batch.StatusExpired:
+		counts["expired"] = remaining
+	case batch.StatusFailed:
+		counts["errored"] = b.Counts.Failed + remaining
+	case batch.StatusCancelling:
+		obj["processing_status"] = "canceling"
+		counts["processing"] = remaining
+	default:
+		counts["processing"] = remaining
+	}
+	if b.Status.Terminal() {
+		obj["processing_status"] = "ended"
+		obj["ended_at"] = rfc3339(max(b.CompletedAt, b.CancelledAt, b.ExpiredAt, b.FailedAt))
+		// Relative, since Host and X-Forwarded-Proto are set by the client.
+		obj["results_url"] = "/v1/messages/batches/" + b.ID + "/results"
+	}
+	return obj
+}
+
+func rfc3339(unix int64) any {
+	if unix == 0 {
+		return nil
+	}
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+func messageBatchesEnabled(c *gin.Context) bool {
+	if batch.Default().Enabled() {
+		return true
+	}
+	writeClaudeBatchError(c, http.StatusNotFound, "not_found_error", "The Message Batches API is disabled.")
+	return false
+}
+
+// batchOwner identifies the client key that may access message batches.
+func batchOwner(c *gin.Context) string {
+	return batch.OwnerOf(batch.CallerFromGin(c).APIKey)
+}
+
+func writeClaudeManagerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, batch.ErrNotFound):
+		writeClaudeBatchError(c, http.StatusNotFound, "not_found_error", fmt.Sprintf("Message batch %s not found.", c.Param("id")))
+	case errors.Is(err, batch.ErrDisabled):
+		writeClaudeBatchError(c, http.StatusNotFound, "not_found_error", "The Message Batches API is disabled.")
+	case errors.Is(err, batch.ErrInvalid):
+		writeClaudeBatchError(c, http.StatusBadRequest, "invalid_request_error", strings.TrimPrefix(err.Error(), batch.ErrInvalid.Error()+": "))
+	case errors.Is(err, batch.ErrFileTooLarge):
+		writeClaudeBatchError(c, http.StatusRequestEntityTooLarge, "request_too_large", strings.TrimPrefix(err.Error(), batch.ErrFileTooLarge.Error()+": "))
+	default:
+		writeClaudeBatchError(c, http.StatusInternalServerError, "api_error", err.Error())
+	}
+}
+
+func writeClaudeBatchError(c *gin.Context, status int, errType, message string) {
+	c.JSON(status, claudeErrorResponse{Type: "error", Error: claudeErrorDetail{Type: errType, Message: message}})
+}
@@ -0,0 +1,253 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const testBatchModel = "test-batch-model"
+
+// batchExecutor answers every Messages request with a fixed message.
+type batchExecutor struct{}
+
+func (batchExecutor) Identifier() string { return "claude" }
+
+func (batchExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{Payload: []byte(`{"id":"msg_batch_test","type":"message","role":"assistant","content":[{"type":"text","text":"done"}]}`)}, nil
+}
+
+func (batchExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (batchExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (batchExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+// newBatchTestRouter serves the Message Batches API from a fresh directory, authenticating
+// every request with the client key in the X-Test-Key header. The dispatcher is not started.
+func newBatchTestRouter(t *testing.T) (*gin.Engine, string) {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(batchExecutor{})
+	if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: "batch-test", Provider: "claude", Status: coreauth.StatusActive}); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient("batch-test", "claude", []*registry.ModelInfo{{ID: testBatchModel}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("batch-test") })
+
+	dir := t.TempDir()
+	if err := batch.Default().Configure(config.BatchConfig{Concurrency: 1}, dir); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	t.Cleanup(batch.Default().Stop)
+
+	gin.SetMode(gin.TestMode)
+	h := NewClaudeBatchAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	batch.Default().Register(batch.KindClaude, h)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("apiKey", c.GetHeader("X-Test-Key")) })
+	router.POST("/v1/messages/batches", h.CreateMessageBatch)
+	router.GET("/v1/messages/batches", h.ListMessageBatches)
+	router.GET("/v1/messages/batches/:id", h.GetMessageBatch)
+	router.POST("/v1/messages/batches/:id/cancel", h.CancelMessageBatch)
+	router.GET("/v1/messages/batches/:id/results", h.MessageBatchResults)
+	return router, dir
+}
+
+func serveBatches(router *gin.Engine, method, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-Key", key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// batchRequests returns a create body with one valid request per custom id.
+func batchRequests(customIDs ...string) string {
+	body := `{"requests":[]}`
+	for _, id := range customIDs {
+		req, _ := sjson.Set(`{"params":{"model":"`+testBatchModel+`","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}}`, "custom_id", id)
+		body, _ = sjson.SetRaw(body, "requests.-1", req)
+	}
+	return body
+}
+
+func createMessageBatch(t *testing.T, router *gin.Engine, key string, customIDs ...string) string {
+	t.Helper()
+	rec := serveBatches(router, http.MethodPost, "/v1/messages/batches", key, batchRequests(customIDs...))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected create response %d: %s", rec.Code, rec.Body.String())
+	}
+	return gjson.Get(rec.Body.String(), "id").String()
+}
+
+func TestCreateMessageBatch_ReportsInvalidRequestIndex(t *testing.T) {
+	router, _ := newBatchTestRouter(t)
+	tests := []struct {
+		name    string
+		body    string
+		message string
+	}{
+		{"missing params", `{"requests":[{"custom_id":"a","params":{"model":"m","messages":[]}},{"custom_id":"b"}]}`, "requests.1: params: Field required"},
+		{"invalid custom id", `{"requests":[{"custom_id":"a b","params":{"model":"m","messages":[]}}]}`, "requests.0: custom_id: must be 1 to 64 letters, digits, underscores or hyphens"},
+		{"missing model", `{"requests":[{"custom_id":"a","params":{"model":"m","messages":[]}},{"custom_id":"b","params":{"model":"m","messages":[]}},{"custom_id":"c","params":{"messages":[]}}]}`, "requests.2: params.model: Field required"},
+	}
+	for _, tt := range tests {
+		rec := serveBatches(router, http.MethodPost, "/v1/messages/batches", "key-validate", tt.body)
+		if rec.Code != http.StatusBadRequest || gjson.Get(rec.Body.String(), "error.type").String() != "invalid_request_error" {
+			t.Fatalf("%s: unexpected response %d: %s", tt.name, rec.Code, rec.Body.String())
+		}
+		if got := gjson.Get(rec.Body.String(), "error.message").String(); got != tt.message {
+			t.Errorf("%s: got message %q, want %q", tt.name, got, tt.message)
+		}
+	}
+	if rec := serveBatches(router, http.MethodGet, "/v1/messages/batches", "key-validate", ""); gjson.Get(rec.Body.String(), "data.#").Int() != 0 {
+		t.Fatalf("expected rejected batches not to be kept, got %s", rec.Body.String())
+	}
+}
+
+func TestListMessageBatches_Paginates(t *testing.T) {
+	router, _ := newBatchTestRouter(t)
+	ids := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		ids = append(ids, createMessageBatch(t, router, "key-list", fmt.Sprintf("req-%d", i)))
+	}
+	// Created within the same second, so newest first falls back to descending ids.
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+
+	page := func(query string) (string, bool) {
+		t.Helper()
+		rec := serveBatches(router, http.MethodGet, "/v1/messages/batches"+query, "key-list", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected list response %d: %s", rec.Code, rec.Body.String())
+		}
+		var got []string
+		for _, item := range gjson.Get(rec.Body.String(), "data.#.id").Array() {
+			got = append(got, item.String())
+		}
+		if len(got) > 0 && (gjson.Get(rec.Body.String(), "first_id").String() != got[0] || gjson.Get(rec.Body.String(), "last_id").String() != got[len(got)-1]) {
+			t.Fatalf("unexpected first_id/last_id in %s", rec.Body.String())
+		}
+		return strings.Join(got, ","), gjson.Get(rec.Body.String(), "has_more").Bool()
+	}
+	tests := []struct {
+		query   string
+		want    []string
+		hasMore bool
+	}{
+		{"?limit=2", ids[0:2], true},
+		{"?limit=2&after_id=" + ids[1], ids[2:4], true},
+		{"?limit=2&after_id=" + ids[3], ids[4:5], false},
+		{"?limit=2&before_id=" + ids[4], ids[2:4], true},
+		{"?limit=2&before_id=" + ids[2], ids[0:2], false},
+		{"?after_id=" + ids[0] + "&before_id=" + ids[3], ids[1:3], false},
+	}
+	for _, tt := range tests {
+		got, hasMore := page(tt.query)
+		if want := strings.Join(tt.want, ","); got != want || hasMore != tt.hasMore {
+			t.Errorf("%s: got %s (has_more %v), want %s (has_more %v)", tt.query, got, hasMore, want, tt.hasMore)
+		}
+	}
+	if got, _ := page(""); got != strings.Join(ids, ",") {
+		t.Errorf("expected every batch by default, got %s", got)
+	}
+	if rec := serveBatches(router, http.MethodGet, "/v1/messages/batches?limit=0", "key-list", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid limit to be refused, got %d", rec.Code)
+	}
+}
+
+func TestMessageBatchResults_IncludesCanceledAndExpiredRequests(t *testing.T) {
+	router, dir := newBatchTestRouter(t)
+	canceled := createMessageBatch(t, router, "key-results", "a1", "a2")
+	expired := createMessageBatch(t, router, "key-results", "b1")
+	succeeded := createMessageBatch(t, router, "key-results", "c1")
+
+	if rec := serveBatches(router, http.MethodPost, "/v1/messages/batches/"+canceled+"/cancel", "key-results", ""); gjson.Get(rec.Body.String(), "processing_status").String() != "canceling" {
+		t.Fatalf("unexpected cancel response %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveBatches(router, http.MethodGet, "/v1/messages/batches/"+canceled+"/results", "key-results", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the results of an unfinished batch to be refused, got %d", rec.Code)
+	}
+
+	// Let the completion window of one batch pass, then reload the stored batches.
+	metaPath := filepath.Join(dir, "batches", expired+".json")
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	data, _ = sjson.SetBytes(data, "expires_at", time.Now().Add(-time.Minute).Unix())
+	if err = os.WriteFile(metaPath, data, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err = batch.Default().Configure(config.BatchConfig{Concurrency: 1}, t.TempDir()); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if err = batch.Default().Configure(config.BatchConfig{Concurrency: 1}, dir); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	batch.Default().Start()
+
+	results := func(id string) string {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			rec := serveBatches(router, http.MethodGet, "/v1/messages/batches/"+id, "key-results", "")
+			if gjson.Get(rec.Body.String(), "processing_status").String() == "ended" {
+				if url := gjson.Get(rec.Body.String(), "results_url").String(); url != "/v1/messages/batches/"+id+"/results" {
+					t.Fatalf("expected a relative results_url, got %q", url)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("batch %s never ended: %s", id, rec.Body.String())
+			}
+		}
+		rec := serveBatches(router, http.MethodGet, "/v1/messages/batches/"+id+"/results", "key-results", "")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-jsonl" {
+			t.Fatalf("unexpected results response %d: %s", rec.Code, rec.Body.String())
+		}
+		var entries []string
+		for _, line := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n") {
+			entries = append(entries, gjson.Get(line, "custom_id").String()+":"+gjson.Get(line, "result.type").String())
+		}
+		sort.Strings(entries)
+		return strings.Join(entries, ",")
+	}
+	if got := results(canceled); got != "a1:canceled,a2:canceled" {
+		t.Errorf("unexpected canceled results %s", got)
+	}
+	if got := results(expired); got != "b1:expired" {
+		t.Errorf("unexpected expired results %s", got)
+	}
+	if got := results(succeeded); got != "c1:succeeded" {
+		t.Errorf("unexpected succeeded results %s", got)
+	}
+	rec := serveBatches(router, http.MethodGet, "/v1/messages/batches/"+expired, "key-results", "")
+	if counts := gjson.Get(rec.Body.String(), "request_counts"); counts.Get("expired").Int() != 1 || counts.Get("processing").Int() != 0 {
+		t.Errorf("unexpected request counts %s", counts.Raw)
+	}
+}
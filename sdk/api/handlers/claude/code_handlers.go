@@ -129,7 +129,7 @@ func (h *ClaudeCodeAPIHandler) ClaudeCountTokens(c *gin.Context) {
 //   - c: The Gin context for the request.
 func (h *ClaudeCodeAPIHandler) ClaudeModels(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"data": h.Models(),
+		"data": h.FilterModelsForClient(c, h.Models()),
 	})
 }
 
@@ -147,15 +147,26 @@ func (h *ClaudeCodeAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSO
 	alt := h.GetAlt(c)
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 
-	modelName := gjson.GetBytes(rawJSON, "model").String()
-
-	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
+	resp, errMsg := h.executeMessage(cliCtx, rawJSON, alt)
 	if errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
 
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
+// executeMessage runs a non-streaming Messages request and returns the response body.
+func (h *ClaudeCodeAPIHandler) executeMessage(ctx context.Context, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+
+	resp, errMsg := h.ExecuteWithAuthManager(ctx, h.HandlerType(), modelName, rawJSON, alt)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
 	// Decompress gzipped responses - Claude API sometimes returns gzip without Content-Encoding header
 	// This fixes title generation and other non-streaming responses that arrive compressed
 	if len(resp) >= 2 && resp[0] == 0x1f && resp[1] == 0x8b {
@@ -171,9 +182,7 @@ func (h *ClaudeCodeAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSO
 			}
 		}
 	}
-
-	_, _ = c.Writer.Write(resp)
-	cliCancel()
+	return resp, nil
 }
 
 // handleStreamingResponse streams Claude-compatible responses backed by Gemini.
@@ -17,6 +17,8 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 )
 
 // GeminiAPIHandler contains the handlers for Gemini API endpoints.
@@ -48,7 +50,7 @@ func (h *GeminiAPIHandler) Models() []map[string]any {
 // GeminiModels handles the Gemini models listing endpoint.
 // It returns a JSON response containing available Gemini models and their specifications.
 func (h *GeminiAPIHandler) GeminiModels(c *gin.Context) {
-	rawModels := h.Models()
+	rawModels := h.FilterModelsForClient(c, h.Models())
 	normalizedModels := make([]map[string]any, 0, len(rawModels))
 	defaultMethods := []string{"generateContent"}
 	for _, model := range rawModels {
@@ -211,6 +213,10 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 		h.handleStreamGenerateContent(c, action[0], rawJSON)
 	case "countTokens":
 		h.handleCountTokens(c, action[0], rawJSON)
+	case "embedContent":
+		h.handleEmbedContent(c, action[0], rawJSON)
+	case "batchEmbedContents":
+		h.handleBatchEmbedContents(c, action[0], rawJSON)
 	}
 }
 
@@ -323,6 +329,46 @@ func (h *GeminiAPIHandler) handleCountTokens(c *gin.Context, modelName string, r
 	cliCancel()
 }
 
+// handleEmbedContent handles single embedding requests by running them as a batch of one.
+//
+// Parameters:
+//   - c: The Gin context for the request
+//   - modelName: The name of the embedding model
+//   - rawJSON: The raw JSON request body containing the content to embed
+func (h *GeminiAPIHandler) handleEmbedContent(c *gin.Context, modelName string, rawJSON []byte) {
+	c.Header("Content-Type", "application/json")
+	batch, _ := sjson.SetRawBytes([]byte(`{"requests":[]}`), "requests.0", rawJSON)
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteEmbedWithAuthManager(cliCtx, h.HandlerType(), modelName, batch)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	out, _ := sjson.SetRawBytes([]byte(`{}`), "embedding", []byte(gjson.GetBytes(resp, "embeddings.0").Raw))
+	_, _ = c.Writer.Write(out)
+	cliCancel()
+}
+
+// handleBatchEmbedContents handles batch embedding requests for Gemini embedding models.
+//
+// Parameters:
+//   - c: The Gin context for the request
+//   - modelName: The name of the embedding model
+//   - rawJSON: The raw JSON request body containing the requests to embed
+func (h *GeminiAPIHandler) handleBatchEmbedContents(c *gin.Context, modelName string, rawJSON []byte) {
+	c.Header("Content-Type", "application/json")
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteEmbedWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
 // handleGenerateContent handles non-streaming content generation requests for Gemini models.
 // This function processes the request synchronously and returns the complete generated
 // response in a single API call. It supports various generation parameters and
@@ -15,11 +15,17 @@ import (
 	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 )
 
@@ -45,6 +51,9 @@ type ErrorDetail struct {
 
 const idempotencyKeyMetadataKey = "idempotency_key"
 
+// servedModelHeader reports the model that actually served a request after a model fallback.
+const servedModelHeader = "X-CPA-SERVED-MODEL"
+
 const (
 	defaultStreamingKeepAliveSeconds = 0
 	defaultStreamingBootstrapRetries = 0
@@ -125,7 +134,7 @@ func StreamingBootstrapRetries(cfg *config.SDKConfig) int {
 	return retries
 }
 
-func requestExecutionMetadata(ctx context.Context) map[string]any {
+func requestExecutionMetadata(ctx context.Context, rawJSON []byte) map[string]any {
 	// Idempotency-Key is an optional client-supplied header used to correlate retries.
 	// It is forwarded as execution metadata; when absent we generate a UUID.
 	key := ""
@@ -137,7 +146,14 @@ func requestExecutionMetadata(ctx context.Context) map[string]any {
 	if key == "" {
 		key = uuid.NewString()
 	}
-	return map[string]any{idempotencyKeyMetadataKey: key}
+	meta := map[string]any{idempotencyKeyMetadataKey: key}
+	if session := sessionAffinityKey(ctx, rawJSON); session != "" {
+		meta[coreauth.SessionAffinityMetadataKey] = session
+	}
+	if policy := accessPolicyFromContext(ctx); policy.Restricted() {
+		meta[coreauth.AccessPolicyMetadataKey] = policy
+	}
+	return meta
 }
 
 func mergeMetadata(base, overlay map[string]any) map[string]any {
@@ -240,6 +256,11 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 			parentCtx = logging.WithRequestID(parentCtx, requestID)
 		}
 	}
+	if requestCtx != nil {
+		if span := trace.SpanFromContext(requestCtx); span.SpanContext().IsValid() {
+			parentCtx = trace.ContextWithSpan(parentCtx, span)
+		}
+	}
 	newCtx, cancel := context.WithCancel(parentCtx)
 	if requestCtx != nil && requestCtx != parentCtx {
 		go func() {
@@ -318,11 +339,16 @@ func appendAPIResponse(c *gin.Context, data []byte) {
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, handlerType, modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
-	reqMeta := requestExecutionMetadata(ctx)
+	release, errMsg := h.admitClientRequest(ctx, handlerType, rawJSON)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	defer release()
+	reqMeta := requestExecutionMetadata(ctx, rawJSON)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
 		Payload: cloneBytes(rawJSON),
@@ -337,8 +363,14 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
+	ctx, fallbackTrace := coreauth.WithModelFallbackTrace(ctx)
+	start := time.Now()
 	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
 	if err != nil {
+		if denied := accessDeniedFromError(errorFormat(ctx, handlerType), err); denied != nil {
+			metrics.Default().ObserveRequest(handlerType, normalizedModel, false, denied.StatusCode, time.Since(start))
+			return nil, denied
+		}
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
 			if code := se.StatusCode(); code > 0 {
@@ -351,19 +383,22 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 				addon = hdr.Clone()
 			}
 		}
+		metrics.Default().ObserveRequest(handlerType, normalizedModel, false, status, time.Since(start))
 		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
+	metrics.Default().ObserveRequest(handlerType, normalizedModel, false, http.StatusOK, time.Since(start))
+	setServedModelHeader(ctx, fallbackTrace)
 	return cloneBytes(resp.Payload), nil
 }
 
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, handlerType, modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
-	reqMeta := requestExecutionMetadata(ctx)
+	reqMeta := requestExecutionMetadata(ctx, rawJSON)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
 		Payload: cloneBytes(rawJSON),
@@ -380,6 +415,9 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
 	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
 	if err != nil {
+		if denied := accessDeniedFromError(errorFormat(ctx, handlerType), err); denied != nil {
+			return nil, denied
+		}
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
 			if code := se.StatusCode(); code > 0 {
@@ -397,17 +435,74 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 	return cloneBytes(resp.Payload), nil
 }
 
+// ExecuteEmbedWithAuthManager executes an embeddings request via the core auth manager.
+// rawJSON is an OpenAI embeddings request or a Gemini batchEmbedContents request, depending on handlerType.
+func (h *BaseAPIHandler) ExecuteEmbedWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, handlerType, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	release, errMsg := h.admitClientRequest(ctx, handlerType, rawJSON)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	defer release()
+	reqMeta := requestExecutionMetadata(ctx, rawJSON)
+	req := coreexecutor.Request{
+		Model:   normalizedModel,
+		Payload: cloneBytes(rawJSON),
+	}
+	if cloned := cloneMetadata(metadata); cloned != nil {
+		req.Metadata = cloned
+	}
+	opts := coreexecutor.Options{
+		Stream:          false,
+		OriginalRequest: cloneBytes(rawJSON),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+	}
+	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
+	start := time.Now()
+	resp, err := h.AuthManager.ExecuteEmbed(ctx, providers, req, opts)
+	if err != nil {
+		if denied := accessDeniedFromError(errorFormat(ctx, handlerType), err); denied != nil {
+			metrics.Default().ObserveRequest(handlerType, normalizedModel, false, denied.StatusCode, time.Since(start))
+			return nil, denied
+		}
+		status := http.StatusInternalServerError
+		if code := statusFromError(err); code > 0 {
+			status = code
+		}
+		var addon http.Header
+		if he, ok := err.(interface{ Headers() http.Header }); ok && he != nil {
+			if hdr := he.Headers(); hdr != nil {
+				addon = hdr.Clone()
+			}
+		}
+		metrics.Default().ObserveRequest(handlerType, normalizedModel, false, status, time.Since(start))
+		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
+	}
+	metrics.Default().ObserveRequest(handlerType, normalizedModel, false, http.StatusOK, time.Since(start))
+	return cloneBytes(resp.Payload), nil
+}
+
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, handlerType, modelName)
+	if errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+	release, errMsg := h.admitClientRequest(ctx, handlerType, rawJSON)
 	if errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		errChan <- errMsg
 		close(errChan)
 		return nil, errChan
 	}
-	reqMeta := requestExecutionMetadata(ctx)
+	reqMeta := requestExecutionMetadata(ctx, rawJSON)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
 		Payload: cloneBytes(rawJSON),
@@ -422,9 +517,19 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
+	ctx, fallbackTrace := coreauth.WithModelFallbackTrace(ctx)
+	ctx, streamAuthTrace := coreauth.WithStreamAuthTrace(ctx)
+	start := time.Now()
 	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
 	if err != nil {
+		release()
 		errChan := make(chan *interfaces.ErrorMessage, 1)
+		if denied := accessDeniedFromError(errorFormat(ctx, handlerType), err); denied != nil {
+			metrics.Default().ObserveRequest(handlerType, normalizedModel, true, denied.StatusCode, time.Since(start))
+			errChan <- denied
+			close(errChan)
+			return nil, errChan
+		}
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
 			if code := se.StatusCode(); code > 0 {
@@ -437,18 +542,33 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 				addon = hdr.Clone()
 			}
 		}
+		metrics.Default().ObserveRequest(handlerType, normalizedModel, true, status, time.Since(start))
 		errChan <- &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 		close(errChan)
 		return nil, errChan
 	}
 	dataChan := make(chan []byte)
 	errChan := make(chan *interfaces.ErrorMessage, 1)
+	streamClosed := metrics.Default().StreamOpened(handlerType)
 	go func() {
 		defer close(dataChan)
 		defer close(errChan)
+		defer release()
+		defer streamClosed()
+		finalStatus := http.StatusOK
+		defer func() {
+			metrics.Default().ObserveRequest(handlerType, normalizedModel, true, finalStatus, time.Since(start))
+		}()
 		sentPayload := false
 		bootstrapRetries := 0
 		maxBootstrapRetries := StreamingBootstrapRetries(h.Cfg)
+		continuationRetries := 0
+		maxContinuationRetries := StreamingContinuationRetries(h.Cfg)
+		var continuation *streamContinuation
+		var failedAuths []string
+		if maxContinuationRetries > 0 {
+			continuation = newStreamContinuation(handlerType, alt)
+		}
 
 		bootstrapEligible := func(err error) bool {
 			status := statusFromError(err)
@@ -488,6 +608,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					if !sentPayload {
 						if bootstrapRetries < maxBootstrapRetries && bootstrapEligible(streamErr) {
 							bootstrapRetries++
+							metrics.Default().StreamRetried(handlerType, "bootstrap")
 							retryChunks, retryErr := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
 							if retryErr == nil {
 								chunks = retryChunks
@@ -496,6 +617,23 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 							streamErr = retryErr
 						}
 					}
+					// Mid-stream failover: resume on another credential with the text the client
+					// already received as a prefill. A failed resume reports the original error.
+					if sentPayload && continuation.resumable() && continuationRetries < maxContinuationRetries && bootstrapEligible(streamErr) {
+						continuationRetries++
+						metrics.Default().StreamRetried(handlerType, "continuation")
+						if authID := streamAuthTrace.AuthID(); authID != "" {
+							failedAuths = append(failedAuths, authID)
+						}
+						if resumeReq, resumeOpts, ok := continuation.resume(req, opts, failedAuths); ok {
+							resumedChunks, resumeErr := h.AuthManager.ExecuteStream(ctx, providers, resumeReq, resumeOpts)
+							if resumeErr == nil {
+								chunks = resumedChunks
+								continue outer
+							}
+							log.Debugf("stream continuation failed: %v", resumeErr)
+						}
+					}
 
 					status := http.StatusInternalServerError
 					if se, ok := streamErr.(interface{ StatusCode() int }); ok && se != nil {
@@ -509,12 +647,25 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 							addon = hdr.Clone()
 						}
 					}
+					finalStatus = status
 					errChan <- &interfaces.ErrorMessage{StatusCode: status, Error: streamErr, Addon: addon}
 					return
 				}
 				if len(chunk.Payload) > 0 {
+					if !sentPayload {
+						setServedModelHeader(ctx, fallbackTrace)
+						metrics.Default().ObserveFirstToken(handlerType, normalizedModel, time.Since(start))
+					}
+					payload := cloneBytes(chunk.Payload)
+					if continuation != nil {
+						payload = continuation.rewrite(payload)
+						continuation.observe(payload)
+						if len(payload) == 0 {
+							continue
+						}
+					}
 					sentPayload = true
-					dataChan <- cloneBytes(chunk.Payload)
+					dataChan <- payload
 				}
 			}
 		}
@@ -522,6 +673,17 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	return dataChan, errChan
 }
 
+// setServedModelHeader reports the fallback model that served the request, if any.
+func setServedModelHeader(ctx context.Context, trace *coreauth.ModelFallbackTrace) {
+	served := trace.ServedModel()
+	if served == "" || ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(servedModelHeader, served)
+	}
+}
+
 func statusFromError(err error) int {
 	if err == nil {
 		return 0
@@ -534,9 +696,25 @@ func statusFromError(err error) int {
 	return 0
 }
 
-func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string, normalizedModel string, metadata map[string]any, err *interfaces.ErrorMessage) {
+func (h *BaseAPIHandler) getRequestDetails(ctx context.Context, handlerType, modelName string) (providers []string, normalizedModel string, metadata map[string]any, err *interfaces.ErrorMessage) {
+	_, span := tracing.Tracer().Start(ctx, "getRequestDetails", trace.WithAttributes(
+		attribute.String("cliproxy.handler", handlerType),
+		attribute.String("cliproxy.requested_model", modelName),
+	))
+	defer func() {
+		span.SetAttributes(
+			attribute.String("cliproxy.model", normalizedModel),
+			attribute.StringSlice("cliproxy.providers", providers),
+		)
+		if err != nil {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", err.StatusCode))
+		}
+		span.End()
+	}()
+	policy := accessPolicyFromContext(ctx)
+
 	// Resolve "auto" model to an actual available model first
-	resolvedModelName := util.ResolveAutoModel(modelName)
+	resolvedModelName := applyDefaultPrefix(policy, util.ResolveAutoModel(modelName))
 
 	// Normalize the model name to handle dynamic thinking suffixes before determining the provider.
 	normalizedModel, metadata = normalizeModelMetadata(resolvedModelName)
@@ -561,6 +739,11 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 		return nil, "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
 	}
 
+	providers, err = enforceAccessPolicy(policy, errorFormat(ctx, handlerType), resolvedModelName, normalizedModel, providers)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
 	// If it's a dynamic model, the normalizedModel was already set to extractedModelName.
 	// If it's a non-dynamic model, normalizedModel was set by normalizeModelMetadata.
 	// So, normalizedModel is already correctly set at this point.
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+type midStreamFailExecutor struct {
+	mu       sync.Mutex
+	first    []string
+	resumed  []string
+	authIDs  []string
+	payloads [][]byte
+}
+
+func (e *midStreamFailExecutor) Identifier() string { return "codex" }
+
+func (e *midStreamFailExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "Execute not implemented"}
+}
+
+func (e *midStreamFailExecutor) ExecuteStream(_ context.Context, auth *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.mu.Lock()
+	e.authIDs = append(e.authIDs, auth.ID)
+	e.payloads = append(e.payloads, req.Payload)
+	call := len(e.authIDs)
+	e.mu.Unlock()
+
+	chunks := e.resumed
+	if call == 1 {
+		chunks = e.first
+	}
+	ch := make(chan coreexecutor.StreamChunk, len(chunks)+1)
+	for _, chunk := range chunks {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(chunk)}
+	}
+	if call == 1 {
+		ch <- coreexecutor.StreamChunk{Err: &coreauth.Error{Code: "upstream_reset", Message: "stream reset", HTTPStatus: http.StatusBadGateway}}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (e *midStreamFailExecutor) Refresh(ctx context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *midStreamFailExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func newContinuationTestHandler(t *testing.T, executor *midStreamFailExecutor) *BaseAPIHandler {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	for _, id := range []string{"resume-auth1", "resume-auth2"} {
+		auth := &coreauth.Auth{ID: id, Provider: "codex", Status: coreauth.StatusActive}
+		if _, err := manager.Register(context.Background(), auth); err != nil {
+			t.Fatalf("manager.Register(%s): %v", id, err)
+		}
+		registry.GetGlobalRegistry().RegisterClient(id, "codex", []*registry.ModelInfo{{ID: "resume-model"}})
+	}
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient("resume-auth1")
+		registry.GetGlobalRegistry().UnregisterClient("resume-auth2")
+	})
+	retries := 1
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		Streaming: sdkconfig.StreamingConfig{ContinuationRetries: &retries},
+	}, manager)
+}
+
+func collectStream(t *testing.T, dataChan <-chan []byte, errChan <-chan *interfaces.ErrorMessage) ([]string, *interfaces.ErrorMessage) {
+	t.Helper()
+	var chunks []string
+	for chunk := range dataChan {
+		chunks = append(chunks, string(chunk))
+	}
+	var streamErr *interfaces.ErrorMessage
+	for msg := range errChan {
+		if msg != nil {
+			streamErr = msg
+		}
+	}
+	return chunks, streamErr
+}
+
+func TestExecuteStreamWithAuthManager_ResumesMidStreamOnAnotherAuth(t *testing.T) {
+	executor := &midStreamFailExecutor{
+		first: []string{`{"id":"chatcmpl-1","created":100,"choices":[{"index":0,"delta":{"role":"assistant","content":"Hello "},"finish_reason":null}]}`},
+		resumed: []string{
+			`{"id":"chatcmpl-2","created":200,"choices":[{"index":0,"delta":{"role":"assistant","content":"world"},"finish_reason":null}]}`,
+			`{"id":"chatcmpl-2","created":200,"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		},
+	}
+	handler := newContinuationTestHandler(t, executor)
+	request := `{"model":"resume-model","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "resume-model", []byte(request), "")
+	chunks, streamErr := collectStream(t, dataChan, errChan)
+	if streamErr != nil {
+		t.Fatalf("unexpected error: %+v", streamErr)
+	}
+
+	if len(executor.authIDs) != 2 || executor.authIDs[0] == executor.authIDs[1] {
+		t.Fatalf("expected the stream to resume on a different auth, got %v", executor.authIDs)
+	}
+	prefill := gjson.GetBytes(executor.payloads[1], "messages.1")
+	if prefill.Get("role").String() != "assistant" || prefill.Get("content").String() != "Hello" {
+		t.Fatalf("unexpected continuation prefill: %s", prefill.Raw)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d: %v", len(chunks), chunks)
+	}
+	resumed := gjson.Parse(chunks[1])
+	if resumed.Get("id").String() != "chatcmpl-1" || resumed.Get("created").Int() != 100 {
+		t.Fatalf("resumed chunk kept its own identity: %s", chunks[1])
+	}
+	if resumed.Get("choices.0.delta.role").Exists() || resumed.Get("choices.0.delta.content").String() != "world" {
+		t.Fatalf("unexpected resumed delta: %s", chunks[1])
+	}
+}
+
+func TestExecuteStreamWithAuthManager_DoesNotResumeAfterToolCall(t *testing.T) {
+	executor := &midStreamFailExecutor{
+		first: []string{`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"lookup","arguments":"{\"q\""}}]}}]}`},
+	}
+	handler := newContinuationTestHandler(t, executor)
+	request := `{"model":"resume-model","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "resume-model", []byte(request), "")
+	_, streamErr := collectStream(t, dataChan, errChan)
+	if streamErr == nil || streamErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected the upstream error to be forwarded, got %+v", streamErr)
+	}
+	if len(executor.authIDs) != 1 {
+		t.Fatalf("expected no continuation attempt, got %d calls", len(executor.authIDs))
+	}
+}
+
+func TestStreamContinuation_ClaudeSplice(t *testing.T) {
+	continuation := newStreamContinuation("claude", "")
+	original := []string{
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n",
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n",
+		"event: content_block_delta\n",
+		"data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Partial \"}}\n",
+	}
+	for _, chunk := range original {
+		continuation.observe(continuation.rewrite([]byte(chunk)))
+	}
+	if !continuation.resumable() {
+		t.Fatalf("expected text-only stream to be resumable")
+	}
+	req, _, ok := continuation.resume(coreexecutor.Request{Payload: []byte(`{"messages":[{"role":"user","content":"hi"}]}`)}, coreexecutor.Options{}, []string{"a"})
+	if !ok || gjson.GetBytes(req.Payload, "messages.1.content").String() != "Partial" {
+		t.Fatalf("unexpected continuation request: %s", req.Payload)
+	}
+
+	var out strings.Builder
+	resumed := []string{
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_2\"}}\n\n",
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"answer\"}}\n\n",
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n",
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+	}
+	for _, chunk := range resumed {
+		rewritten := continuation.rewrite([]byte(chunk))
+		continuation.observe(rewritten)
+		out.Write(rewritten)
+	}
+	got := out.String()
+	if strings.Contains(got, "msg_2") {
+		t.Fatalf("resumed message_start was forwarded: %s", got)
+	}
+	for _, want := range []string{
+		`{"type":"content_block_stop","index":0}`,
+		`"type":"content_block_start","index":1`,
+		`"type":"content_block_delta","index":1`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_stop"}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %s in spliced stream:\n%s", want, got)
+		}
+	}
+	if continuation.resumable() {
+		t.Fatalf("finished stream must not be resumable")
+	}
+}
@@ -0,0 +1,548 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	responsesconverter "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/openai/responses"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultFilesLimit   = 10000
+	defaultBatchesLimit = 20
+	maxBatchesLimit     = 100
+	maxBatchMetadata    = 16
+	// defaultBatchRetryAfter delays a rate limited batch request without a Retry-After hint.
+	defaultBatchRetryAfter = 10 * time.Second
+)
+
+// batchEndpoints lists the endpoints a batch can target.
+var batchEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/responses":        true,
+	"/v1/embeddings":       true,
+}
+
+// OpenAIBatchAPIHandler serves the /v1/files and /v1/batches endpoints and executes the
+// requests of OpenAI batches through the regular handlers.
+type OpenAIBatchAPIHandler struct {
+	*handlers.BaseAPIHandler
+	chat      *OpenAIAPIHandler
+	responses *OpenAIResponsesAPIHandler
+}
+
+// NewOpenAIBatchAPIHandler creates a new OpenAI batch API handlers instance.
+//
+// Parameters:
+//   - apiHandlers: The base API handlers instance
+//
+// Returns:
+//   - *OpenAIBatchAPIHandler: A new OpenAI batch API handlers instance
+func NewOpenAIBatchAPIHandler(apiHandlers *handlers.BaseAPIHandler) *OpenAIBatchAPIHandler {
+	return &OpenAIBatchAPIHandler{
+		BaseAPIHandler: apiHandlers,
+		chat:           NewOpenAIAPIHandler(apiHandlers),
+		responses:      NewOpenAIResponsesAPIHandler(apiHandlers),
+	}
+}
+
+// HandlerType returns the identifier for this handler implementation.
+func (h *OpenAIBatchAPIHandler) HandlerType() string {
+	return OpenAI
+}
+
+// Models returns the OpenAI-compatible model metadata supported by this handler.
+func (h *OpenAIBatchAPIHandler) Models() []map[string]any {
+	return registry.GetGlobalRegistry().GetAvailableModels("openai")
+}
+
+// UploadFile handles POST /v1/files. Only files with purpose "batch" are accepted.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIBatchAPIHandler) UploadFile(c *gin.Context) {
+	if !batchAPIEnabled(c) {
+		return
+	}
+	if purpose := c.PostForm("purpose"); purpose != "batch" {
+		writeResponsesError(c, http.StatusBadRequest, fmt.Sprintf("Invalid purpose '%s': only 'batch' is supported.", purpose))
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		writeResponsesError(c, http.StatusBadRequest, fmt.Sprintf("Invalid file: %v", err))
+		return
+	}
+	data, err := header.Open()
+	if err != nil {
+		writeResponsesError(c, http.StatusBadRequest, fmt.Sprintf("Invalid file: %v", err))
+		return
+	}
+	defer func() { _ = data.Close() }()
+	f, err := batch.Default().CreateFile(batch.File{Filename: header.Filename, Purpose: "batch", Owner: batchOwner(c)}, data)
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fileObject(f))
+}
+
+// ListFiles handles GET /v1/files. It supports the purpose, order, limit and after query
+// parameters.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIBatchAPIHandler) ListFiles(c *gin.Context) {
+	if !batchAPIEnabled(c) {
+		return
+	}
+	files := batch.Default().ListFiles(batchOwner(c), c.Query("purpose"))
+	if c.Query("order") == "asc" {
+		for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+			files[i], files[j] = files[j], files[i]
+		}
+	}
+	ids := make([]string, len(files))
+	for i := range files {
+		ids[i] = files[i].ID
+	}
+	start, end, hasMore, ok := listPage(c, ids, defaultFilesLimit, defaultFilesLimit)
+	if !ok {
+		return
+	}
+	data := make([]gin.H, 0, end-start)
+	for i := start; i < end; i++ {
+		data = append(data, fileObject(&files[i]))
+	}
+	c.JSON(http.StatusOK, listObject(data, ids[start:end], hasMore))
+}
+
+// GetFile handles GET /v1/files/{id}.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIBatchAPIHandler) GetFile(c *gin.Context) {
+	if !batchAPIEnabled(c) {
+		return
+	}
+	f, err := batch.Default().GetFile(c.Param("id"), batchOwner(c))
+	if err != nil {
+		writeFileNotFound(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fileObject(f))
+}
+
+// FileContent handles GET /v1/files/{id}/content and returns the file as uploaded or written.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIBatchAPIHandler) FileContent(c *gin.Context) {
+	if !batchAPIEnabled(c) {
+		return
+	}
+	data, f, err := batch.Default().OpenFile(c.Param("id"), batchOwner(c))
+	if err != nil {
+		writeFileNotFound(c, err)
+		return
+	}
+	defer func() { _ = data.Close() }()
+	c.DataFromReader(http.StatusOK, f.Bytes, "application/jsonl", data, nil)
+}
+
+// DeleteFile handles DELETE /v1/files/{id}.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIBatchAPIHandler) DeleteFile(c *gin.Context) {
+	if !batchAPIEnabled(c) {
+		return
+	}
+	id := c.Param("id")
+	if err := batch.Default().DeleteFile(id, batchOwner(c)); err != nil {
+		writeFileNotFound(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "file", "deleted": true})
+}
+
+// CreateBatch handles POST /v1/batches. The batch runs in the background with the client key
+// of the caller; its progress is reported by GetBatch.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIBatchAPIHandler) CreateBatch(c *gin.Context) {
+	if !batchAPIEnabled(c) {
+		return
+	}
+	rawJSON, err := c.GetRawData()
+	if err != nil || !gjson.ValidBytes(rawJSON) {
+		writeResponsesError(c, http.StatusBadRequest, "Invalid request: the body must be a JSON object.")
+		return
+	}
+	endpoint := gjson.GetBytes(rawJSON, "endpoint").String()
+	if !batchEndpoints[endpoint] {
+		writeResponsesError(c, http.StatusBadRequest, fmt.Sprintf("Invalid endpoint '%s': supported endpoints are /v1/chat/completions, /v1/completions, /v1/responses and /v1/embeddings.", endpoint))
+		return
+	}
+	if window := gjson.GetBytes(rawJSON, "completion_window").String(); window != "24h" {
+		writeResponsesError(c, http.StatusBadRequest, fmt.Sprintf("Invalid completion_window '%s': only '24h' is supported.", window))
+		return
+	}
+	var metadata map[string]string
+	if raw := gjson.GetBytes(rawJSON, "metadata"); raw.IsObject() {
+		metadata = make(map[string]string)
+		raw.ForEach(func(key, value gjson.Result) bool {
+			metadata[key.String()] = value.String()
+			return true
+		})
+		if len(metadata) > maxBatchMetadata {
+			writeResponsesError(c, http.StatusBadRequest, fmt.Sprintf("Invalid metadata: at most %d keys are allowed.", maxBatchMetadata))
+			return
+		}
+	}
+	caller := batch.CallerFromGin(c)
+	b, err := batch.Default().CreateBatch(batch.Batch{
+		Kind:             batch.KindOpenAI,
+		Owner:            batch.OwnerOf(caller.APIKey),
+		Caller:           caller,
+		Endpoint:         endpoint,
+		InputFileID:      gjson.GetBytes(rawJSON, "input_file_id").String(),
+		CompletionWindow: "24h",
+		Metadata:         metadata,
+	})
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, batchObject(b))
+}
+
+// ListBatches handles GET /v1/batches. It supports the limit and after query parameters.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIBatchAPIHandler) ListBatches(c *gin.Context) {
+	if !batchAPIEnabled(c) {
+		return
+	}
+	batches := batch.Default().ListBatches(batch.KindOpenAI, batchOwner(c))
+	ids := make([]string, len(batches))
+	for i, b := range batches {
+		ids[i] = b.ID
+	}
+	start, end, hasMore, ok := listPage(c, ids, defaultBatchesLimit, maxBatchesLimit)
+	if !ok {
+		return
+	}
+	data := make([]gin.H, 0, end-start)
+	for _, b := range batches[start:end] {
+		data = append(data, batchObject(b))
+	}
+	c.JSON(http.StatusOK, listObject(data, ids[start:end], hasMore))
+}
+
+// GetBatch handles GET /v1/batches/{id}.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIBatchAPIHandler) GetBatch(c *gin.Context) {
+	if !batchAPIEnabled(c) {
+		return
+	}
+	b, err := batch.Default().GetBatch(batch.KindOpenAI, c.Param("id"), batchOwner(c))
+	if err != nil {
+		writeBatchNotFound(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, batchObject(b))
+}
+
+// CancelBatch handles POST /v1/batches/{id}/cancel. Requests already running finish; the
+// batch then moves to cancelled.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIBatchAPIHandler) CancelBatch(c *gin.Context) {
+	if !batchAPIEnabled(c) {
+		return
+	}
+	b, err := batch.Default().CancelBatch(batch.KindOpenAI, c.Param("id"), batchOwner(c))
+	if err != nil {
+		writeBatchNotFound(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, batchObject(b))
+}
+
+// Validate implements batch.Processor. It checks one line of a batch input file.
+func (h *OpenAIBatchAPIHandler) Validate(b *batch.Batch, line []byte) (string, *batch.LineError) {
+	parsed := gjson.ParseBytes(line)
+	if !gjson.ValidBytes(line) || !parsed.IsObject() {
+		return "", &batch.LineError{Code: "invalid_json_line", Message: "This line is not parseable as valid JSON."}
+	}
+	customID := parsed.Get("custom_id")
+	if customID.Type != gjson.String || customID.String() == "" {
+		return "", &batch.LineError{Code: "missing_required_parameter", Message: "Missing required parameter: 'custom_id'.", Param: "custom_id"}
+	}
+	if !strings.EqualFold(parsed.Get("method").String(), http.MethodPost) {
+		return "", &batch.LineError{Code: "invalid_value", Message: "Only POST requests are supported.", Param: "method"}
+	}
+	if url := parsed.Get("url").String(); url != b.Endpoint {
+		return "", &batch.LineError{Code: "mismatched_endpoint", Message: fmt.Sprintf("The url '%s' does not match the batch endpoint '%s'.", url, b.Endpoint), Param: "url"}
+	}
+	body := parsed.Get("body")
+	if !body.IsObject() {
+		return "", &batch.LineError{Code: "missing_required_parameter", Message: "Missing required parameter: 'body'.", Param: "body"}
+	}
+	if body.Get("model").String() == "" {
+		return "", &batch.LineError{Code: "missing_required_parameter", Message: "Missing required parameter: 'body.model'.", Param: "body.model"}
+	}
+	return customID.String(), nil
+}
+
+// Execute implements batch.Processor. It runs one request as the batch creator and returns
+// the output line in the OpenAI batch output format.
+func (h *OpenAIBatchAPIHandler) Execute(ctx context.Context, b *batch.Batch, customID string, line []byte) batch.Result {
+	body := []byte(gjson.GetBytes(line, "body").Raw)
+	body, _ = sjson.DeleteBytes(body, "stream")
+	body, _ = sjson.DeleteBytes(body, "stream_options")
+	requestID := "batch_req_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	var handler interfaces.APIHandler = h.chat
+	if b.Endpoint == "/v1/responses" {
+		handler = h.responses
+	}
+	c, cliCtx, cliCancel := h.BackgroundContext(ctx, handler, b.Endpoint, requestID, b.Caller.Values())
+	resp, errMsg := h.executeBatchRequest(c, cliCtx, b.Endpoint, body)
+
+	out, _ := sjson.SetBytes([]byte(`{"id":"","custom_id":"","response":{"status_code":200,"request_id":""},"error":null}`), "id", requestID)
+	out, _ = sjson.SetBytes(out, "custom_id", customID)
+	out, _ = sjson.SetBytes(out, "response.request_id", requestID)
+	if errMsg == nil {
+		cliCancel()
+		if !gjson.ValidBytes(resp) {
+			resp, _ = sjson.SetBytes([]byte(`{}`), "content", string(resp))
+		}
+		out, _ = sjson.SetRawBytes(out, "response.body", resp)
+		return batch.Result{Line: out}
+	}
+
+	cliCancel(errMsg.Error)
+	status := http.StatusInternalServerError
+	if errMsg.StatusCode > 0 {
+		status = errMsg.StatusCode
+	}
+	errText := http.StatusText(status)
+	if errMsg.Error != nil {
+		errText = errMsg.Error.Error()
+	}
+	out, _ = sjson.SetBytes(out, "response.status_code", status)
+	out, _ = sjson.SetRawBytes(out, "response.body", handlers.BuildErrorResponseBody(status, errText))
+	result := batch.Result{Line: out, Failed: true}
+	if status == http.StatusTooManyRequests {
+		result.RetryAfter = defaultBatchRetryAfter
+		if seconds, errAtoi := strconv.Atoi(errMsg.Addon.Get("Retry-After")); errAtoi == nil && seconds > 0 {
+			result.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+	return result
+}
+
+// executeBatchRequest runs a request body against endpoint the way its interactive handler
+// would, without streaming.
+func (h *OpenAIBatchAPIHandler) executeBatchRequest(c *gin.Context, ctx context.Context, endpoint string, body []byte) ([]byte, *interfaces.ErrorMessage) {
+	modelName := gjson.GetBytes(body, "model").String()
+	switch endpoint {
+	case "/v1/embeddings":
+		return h.ExecuteEmbedWithAuthManager(ctx, OpenAI, modelName, body)
+	case "/v1/completions":
+		resp, errMsg := h.ExecuteWithAuthManager(ctx, OpenAI, modelName, convertCompletionsRequestToChatCompletions(body), "")
+		if errMsg != nil {
+			return nil, errMsg
+		}
+		return convertChatCompletionsResponseToCompletions(resp), nil
+	case "/v1/responses":
+		expanded, errMsg := h.responses.expandPreviousResponse(c, body)
+		if errMsg != nil {
+			return nil, errMsg
+		}
+		resp, errMsg := h.ExecuteWithAuthManager(ctx, OpenaiResponse, modelName, expanded, "")
+		if errMsg != nil {
+			return nil, errMsg
+		}
+		h.responses.storeResponse(c, body, resp)
+		return resp, nil
+	default:
+		if shouldTreatAsResponsesFormat(body) {
+			body = responsesconverter.ConvertOpenAIResponsesRequestToOpenAIChatCompletions(modelName, body, false)
+		}
+		return h.ExecuteWithAuthManager(ctx, OpenAI, modelName, body, "")
+	}
+}
+
+// Skip implements batch.Processor. Requests left when the completion window expires are
+// reported in the error file; those of a cancelled batch are not.
+func (h *OpenAIBatchAPIHandler) Skip(_ *batch.Batch, customID string, _ []byte, status batch.Status) []byte {
+	if status != batch.StatusExpired {
+		return nil
+	}
+	out, _ := sjson.SetBytes([]byte(`{"id":"","custom_id":"","response":null,"error":{"code":"batch_expired","message":"This request could not be executed before the completion window expired."}}`), "custom_id", customID)
+	out, _ = sjson.SetBytes(out, "id", "batch_req_"+strings.ReplaceAll(uuid.NewString(), "-", ""))
+	return out
+}
+
+func fileObject(f *batch.File) gin.H {
+	return gin.H{
+		"id":         f.ID,
+		"object":     "file",
+		"bytes":      f.Bytes,
+		"created_at": f.CreatedAt,
+		"filename":   f.Filename,
+		"purpose":    f.Purpose,
+		"status":     "processed",
+	}
+}
+
+func batchObject(b *batch.Batch) gin.H {
+	obj := gin.H{
+		"id":                b.ID,
+		"object":            "batch",
+		"endpoint":          b.Endpoint,
+		"errors":            nil,
+		"input_file_id":     b.InputFileID,
+		"completion_window": b.CompletionWindow,
+		"status":            b.Status,
+		"output_file_id":    optionalString(b.OutputFileID),
+		"error_file_id":     optionalString(b.ErrorFileID),
+		"created_at":        b.CreatedAt,
+		"in_progress_at":    optionalTime(b.InProgressAt),
+		"expires_at":        b.ExpiresAt,
+		"finalizing_at":     optionalTime(b.FinalizingAt),
+		"completed_at":      optionalTime(b.CompletedAt),
+		"failed_at":         optionalTime(b.FailedAt),
+		"expired_at":        optionalTime(b.ExpiredAt),
+		"cancelling_at":     optionalTime(b.CancellingAt),
+		"cancelled_at":      optionalTime(b.CancelledAt),
+		"request_counts": gin.H{
+			"total":     b.Counts.Total,
+			"completed": b.Counts.Completed,
+			"failed":    b.Counts.Failed,
+		},
+		"metadata": b.Metadata,
+	}
+	if len(b.Errors) > 0 {
+		obj["errors"] = gin.H{"object": "list", "data": b.Errors}
+	}
+	return obj
+}
+
+func optionalString(v string) any {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+func optionalTime(v int64) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+func listObject(data []gin.H, ids []string, hasMore bool) gin.H {
+	list := gin.H{"object": "list", "data": data, "first_id": nil, "last_id": nil, "has_more": hasMore}
+	if len(ids) > 0 {
+		list["first_id"] = ids[0]
+		list["last_id"] = ids[len(ids)-1]
+	}
+	return list
+}
+
+// listPage applies the limit and after query parameters to ids. It writes an error response
+// and returns ok=false when the parameters are invalid.
+func listPage(c *gin.Context, ids []string, defaultLimit, maxLimit int) (start, end int, hasMore, ok bool) {
+	limit := defaultLimit
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > maxLimit {
+			writeResponsesError(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+			return 0, 0, false, false
+		}
+		limit = parsed
+	}
+	if after := c.Query("after"); after != "" {
+		for i, id := range ids {
+			if id == after {
+				start = i + 1
+				break
+			}
+		}
+	}
+	end = len(ids)
+	if end-start > limit {
+		end = start + limit
+		hasMore = true
+	}
+	return start, end, hasMore, true
+}
+
+func batchAPIEnabled(c *gin.Context) bool {
+	if batch.Default().Enabled() {
+		return true
+	}
+	writeResponsesError(c, http.StatusNotFound, "The batch API is disabled.")
+	return false
+}
+
+// batchOwner identifies the client key that may access files and batches.
+func batchOwner(c *gin.Context) string {
+	return batch.OwnerOf(batch.CallerFromGin(c).APIKey)
+}
+
+func writeFileNotFound(c *gin.Context, err error) {
+	if errors.Is(err, batch.ErrNotFound) {
+		writeResponsesError(c, http.StatusNotFound, fmt.Sprintf("No such File object: %s", c.Param("id")))
+		return
+	}
+	writeBatchError(c, err)
+}
+
+func writeBatchNotFound(c *gin.Context, err error) {
+	if errors.Is(err, batch.ErrNotFound) {
+		writeResponsesError(c, http.StatusNotFound, fmt.Sprintf("No such Batch object: %s", c.Param("id")))
+		return
+	}
+	writeBatchError(c, err)
+}
+
+func writeBatchError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, batch.ErrInvalid):
+		writeResponsesError(c, http.StatusBadRequest, strings.TrimPrefix(err.Error(), batch.ErrInvalid.Error()+": "))
+	case errors.Is(err, batch.ErrFileTooLarge):
+		writeResponsesError(c, http.StatusRequestEntityTooLarge, strings.TrimPrefix(err.Error(), batch.ErrFileTooLarge.Error()+": "))
+	case errors.Is(err, batch.ErrNotFound):
+		writeResponsesError(c, http.StatusNotFound, fmt.Sprintf("No such object: %s", c.Param("id")))
+	case errors.Is(err, batch.ErrDisabled):
+		writeResponsesError(c, http.StatusNotFound, "The batch API is disabled.")
+	default:
+		writeResponsesError(c, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,507 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// defaultImageModel serves image requests that do not name a model.
+	defaultImageModel = "gemini-2.5-flash-image"
+	// maxImagesPerRequest bounds n, as the OpenAI API does.
+	maxImagesPerRequest = 10
+	// maxImageUploadBytes bounds the body of an edit request.
+	maxImageUploadBytes = 32 << 20
+	// imageURLTTL is how long images returned as URLs stay available.
+	imageURLTTL = time.Hour
+	// maxStoredImages and maxStoredImageBytes bound the images kept for URLs; the oldest
+	// images are dropped first.
+	maxStoredImages     = 256
+	maxStoredImageBytes = 256 << 20
+	// imageEvictInterval spaces the sweeps dropping expired images.
+	imageEvictInterval = time.Minute
+)
+
+// geminiAspectRatios lists the aspect ratios Gemini image models accept.
+var geminiAspectRatios = []string{"1:1", "2:3", "3:2", "3:4", "4:3", "4:5", "5:4", "9:16", "16:9", "21:9"}
+
+// imageRequest is an OpenAI images request reduced to what Gemini image models accept.
+type imageRequest struct {
+	Model          string
+	Prompt         string
+	N              int
+	Size           string
+	ResponseFormat string
+	Images         []inlineImage
+	Mask           *inlineImage
+}
+
+// inlineImage is a base64 encoded image.
+type inlineImage struct {
+	MimeType string
+	Data     string
+}
+
+func (i inlineImage) part() map[string]any {
+	return map[string]any{"inlineData": map[string]string{"mimeType": i.MimeType, "data": i.Data}}
+}
+
+// ImageGenerations handles the /v1/images/generations endpoint.
+// The prompt is sent to a Gemini image model and the generated images are returned as
+// base64 data or as temporary URLs served by the proxy.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) ImageGenerations(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		writeImageError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	req := imageRequest{
+		Model:          gjson.GetBytes(rawJSON, "model").String(),
+		Prompt:         gjson.GetBytes(rawJSON, "prompt").String(),
+		N:              int(gjson.GetBytes(rawJSON, "n").Int()),
+		Size:           gjson.GetBytes(rawJSON, "size").String(),
+		ResponseFormat: gjson.GetBytes(rawJSON, "response_format").String(),
+	}
+	h.generateImages(c, req)
+}
+
+// ImageEdits handles the /v1/images/edits endpoint.
+// It accepts the multipart form of the OpenAI API, or a JSON body whose images are data URLs.
+// Gemini has no mask support, so a mask is sent as an extra image with an instruction.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) ImageEdits(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageUploadBytes)
+	var req imageRequest
+	var err error
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		req, err = parseMultipartImageEdit(c)
+	} else {
+		req, err = parseJSONImageEdit(c)
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeImageError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Invalid request: the request body exceeds %d bytes", tooLarge.Limit))
+		return
+	}
+	if err != nil {
+		writeImageError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	if len(req.Images) == 0 {
+		writeImageError(c, http.StatusBadRequest, "Invalid request: image is required")
+		return
+	}
+	h.generateImages(c, req)
+}
+
+// ImageFile serves an image previously returned as a URL.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) ImageFile(c *gin.Context) {
+	image, ok := generatedImages.get(c.Param("id"))
+	if !ok {
+		writeImageError(c, http.StatusNotFound, "image not found or expired")
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Data(http.StatusOK, image.mimeType, image.data)
+}
+
+func (h *OpenAIAPIHandler) generateImages(c *gin.Context, req imageRequest) {
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeImageError(c, http.StatusBadRequest, "Invalid request: prompt is required")
+		return
+	}
+	if req.N <= 0 {
+		req.N = 1
+	}
+	if req.N > maxImagesPerRequest {
+		writeImageError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: n must be at most %d", maxImagesPerRequest))
+		return
+	}
+	if req.ResponseFormat != "" && req.ResponseFormat != "b64_json" && req.ResponseFormat != "url" {
+		writeImageError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: unsupported response_format %q", req.ResponseFormat))
+		return
+	}
+	if strings.TrimSpace(req.Model) == "" {
+		req.Model = defaultImageModel
+	}
+	payload, err := buildGeminiImageRequest(req)
+	if err != nil {
+		writeImageError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	// Without a base URL the client could not fetch the images, so they are returned inline.
+	baseURL := ""
+	if req.ResponseFormat == "url" {
+		baseURL = imageBaseURL(c, h.Cfg.PublicURL)
+	}
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	// The request runs as a Gemini request, but rejections must reach OpenAI clients as such.
+	cliCtx = handlers.WithErrorFormat(cliCtx, OpenAI)
+	data := make([]gin.H, 0, req.N)
+	var inputTokens, outputTokens, totalTokens int64
+	// Gemini image models return one image per call, so n images take n requests.
+	for i := 0; i < req.N; i++ {
+		resp, errMsg := h.ExecuteWithAuthManager(cliCtx, Gemini, req.Model, payload, "")
+		if errMsg != nil {
+			h.WriteErrorResponse(c, errMsg)
+			cliCancel(errMsg.Error)
+			return
+		}
+		images, revisedPrompt := geminiImagesFromResponse(resp)
+		for _, image := range images {
+			entry := gin.H{}
+			if baseURL != "" {
+				raw, errDecode := base64.StdEncoding.DecodeString(image.Data)
+				if errDecode != nil {
+					continue
+				}
+				entry["url"] = baseURL + imagePath(generatedImages.put(image.MimeType, raw))
+			} else {
+				entry["b64_json"] = image.Data
+			}
+			if revisedPrompt != "" {
+				entry["revised_prompt"] = revisedPrompt
+			}
+			data = append(data, entry)
+		}
+		usage := gjson.GetBytes(resp, "usageMetadata")
+		inputTokens += usage.Get("promptTokenCount").Int()
+		outputTokens += usage.Get("candidatesTokenCount").Int()
+		totalTokens += usage.Get("totalTokenCount").Int()
+	}
+	if len(data) == 0 {
+		errMsg := &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: fmt.Errorf("model %s returned no image", req.Model)}
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"created": time.Now().Unix(),
+		"data":    data,
+		"usage": gin.H{
+			"input_tokens":  inputTokens,
+			"output_tokens": outputTokens,
+			"total_tokens":  totalTokens,
+		},
+	})
+	cliCancel()
+}
+
+// buildGeminiImageRequest converts an images request into a Gemini generateContent request.
+func buildGeminiImageRequest(req imageRequest) ([]byte, error) {
+	out := []byte(`{"contents":[{"role":"user","parts":[]}],"generationConfig":{"responseModalities":["TEXT","IMAGE"]}}`)
+	out, _ = sjson.SetBytes(out, "contents.0.parts.-1", map[string]any{"text": req.Prompt})
+	for _, image := range req.Images {
+		out, _ = sjson.SetBytes(out, "contents.0.parts.-1", image.part())
+	}
+	if req.Mask != nil {
+		out, _ = sjson.SetBytes(out, "contents.0.parts.-1", map[string]any{"text": "The next image is a mask: only change the areas where it is transparent."})
+		out, _ = sjson.SetBytes(out, "contents.0.parts.-1", req.Mask.part())
+	}
+	aspectRatio, err := aspectRatioForSize(req.Size)
+	if err != nil {
+		return nil, err
+	}
+	if aspectRatio != "" {
+		out, _ = sjson.SetBytes(out, "generationConfig.imageConfig.aspectRatio", aspectRatio)
+	}
+	return out, nil
+}
+
+// aspectRatioForSize maps an OpenAI size such as "1536x1024" to the closest Gemini aspect ratio.
+func aspectRatioForSize(size string) (string, error) {
+	size = strings.ToLower(strings.TrimSpace(size))
+	if size == "" || size == "auto" {
+		return "", nil
+	}
+	w, h, ok := parseRatio(size, "x")
+	if !ok {
+		return "", fmt.Errorf("unsupported size %q", size)
+	}
+	target := math.Log(w / h)
+	best, bestDiff := "", math.Inf(1)
+	for _, ratio := range geminiAspectRatios {
+		a, b, _ := parseRatio(ratio, ":")
+		if diff := math.Abs(math.Log(a/b) - target); diff < bestDiff {
+			best, bestDiff = ratio, diff
+		}
+	}
+	return best, nil
+}
+
+func parseRatio(value, sep string) (float64, float64, bool) {
+	left, right, found := strings.Cut(value, sep)
+	if !found {
+		return 0, 0, false
+	}
+	a, errA := strconv.ParseFloat(left, 64)
+	b, errB := strconv.ParseFloat(right, 64)
+	if errA != nil || errB != nil || a <= 0 || b <= 0 {
+		return 0, 0, false
+	}
+	return a, b, true
+}
+
+// geminiImagesFromResponse extracts the generated images and any accompanying text.
+func geminiImagesFromResponse(resp []byte) ([]inlineImage, string) {
+	var images []inlineImage
+	var text []string
+	for _, part := range gjson.GetBytes(resp, "candidates.0.content.parts").Array() {
+		inline := part.Get("inlineData")
+		if !inline.Exists() {
+			inline = part.Get("inline_data")
+		}
+		if data := inline.Get("data").String(); data != "" {
+			mimeType := inline.Get("mimeType").String()
+			if mimeType == "" {
+				mimeType = inline.Get("mime_type").String()
+			}
+			if mimeType == "" {
+				mimeType = "image/png"
+			}
+			images = append(images, inlineImage{MimeType: mimeType, Data: data})
+			continue
+		}
+		if t := strings.TrimSpace(part.Get("text").String()); t != "" && !part.Get("thought").Bool() {
+			text = append(text, t)
+		}
+	}
+	return images, strings.Join(text, "\n")
+}
+
+func parseMultipartImageEdit(c *gin.Context) (imageRequest, error) {
+	if err := c.Request.ParseMultipartForm(maxImageUploadBytes); err != nil {
+		return imageRequest{}, err
+	}
+	form := c.Request.MultipartForm
+	n, _ := strconv.Atoi(c.PostForm("n"))
+	req := imageRequest{
+		Model:          c.PostForm("model"),
+		Prompt:         c.PostForm("prompt"),
+		N:              n,
+		Size:           c.PostForm("size"),
+		ResponseFormat: c.PostForm("response_format"),
+	}
+	for _, field := range []string{"image", "image[]"} {
+		for _, header := range form.File[field] {
+			image, err := readImageFile(header)
+			if err != nil {
+				return imageRequest{}, err
+			}
+			req.Images = append(req.Images, image)
+		}
+	}
+	if headers := form.File["mask"]; len(headers) > 0 {
+		mask, err := readImageFile(headers[0])
+		if err != nil {
+			return imageRequest{}, err
+		}
+		req.Mask = &mask
+	}
+	return req, nil
+}
+
+func readImageFile(header *multipart.FileHeader) (inlineImage, error) {
+	file, err := header.Open()
+	if err != nil {
+		return inlineImage{}, err
+	}
+	defer func() { _ = file.Close() }()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return inlineImage{}, err
+	}
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+	return inlineImage{MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(data)}, nil
+}
+
+func parseJSONImageEdit(c *gin.Context) (imageRequest, error) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		return imageRequest{}, err
+	}
+	root := gjson.ParseBytes(rawJSON)
+	req := imageRequest{
+		Model:          root.Get("model").String(),
+		Prompt:         root.Get("prompt").String(),
+		N:              int(root.Get("n").Int()),
+		Size:           root.Get("size").String(),
+		ResponseFormat: root.Get("response_format").String(),
+	}
+	var sources []gjson.Result
+	for _, field := range []string{"image", "images"} {
+		if value := root.Get(field); value.IsArray() {
+			sources = append(sources, value.Array()...)
+		} else if value.Exists() {
+			sources = append(sources, value)
+		}
+	}
+	for _, source := range sources {
+		image, errParse := parseDataURL(source)
+		if errParse != nil {
+			return imageRequest{}, errParse
+		}
+		req.Images = append(req.Images, image)
+	}
+	if mask := root.Get("mask"); mask.Exists() {
+		image, errParse := parseDataURL(mask)
+		if errParse != nil {
+			return imageRequest{}, errParse
+		}
+		req.Mask = &image
+	}
+	return req, nil
+}
+
+// parseDataURL reads an image given as a data URL string or as an object with an image_url.
+func parseDataURL(value gjson.Result) (inlineImage, error) {
+	url := value.String()
+	if value.IsObject() {
+		url = value.Get("image_url").String()
+	}
+	header, data, found := strings.Cut(url, ",")
+	if !found || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return inlineImage{}, fmt.Errorf("images must be base64 data URLs")
+	}
+	return inlineImage{MimeType: strings.TrimSuffix(strings.TrimPrefix(header, "data:"), ";base64"), Data: data}, nil
+}
+
+func writeImageError(c *gin.Context, status int, message string) {
+	errType := "invalid_request_error"
+	switch {
+	case status == http.StatusNotFound:
+		errType = "not_found_error"
+	case status >= http.StatusInternalServerError:
+		errType = "server_error"
+	}
+	c.JSON(status, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: message,
+			Type:    errType,
+		},
+	})
+}
+
+// imagePath returns the path serving the stored image id.
+func imagePath(id string) string {
+	return "/v1/images/files/" + id
+}
+
+// imageBaseURL returns the scheme and host image URLs start with: the configured public URL,
+// or else the ones the request reached the proxy with. It returns "" when neither is known.
+func imageBaseURL(c *gin.Context, publicURL string) string {
+	if base := strings.TrimRight(strings.TrimSpace(publicURL), "/"); base != "" {
+		return base
+	}
+	host := c.Request.Host
+	if host == "" {
+		return ""
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + host
+}
+
+// generatedImages keeps the images returned as URLs until they expire.
+var generatedImages = &imageStore{items: make(map[string]storedImage), now: time.Now}
+
+type storedImage struct {
+	mimeType string
+	data     []byte
+	expires  time.Time
+}
+
+type imageStore struct {
+	mu    sync.Mutex
+	items map[string]storedImage
+	// order lists the ids oldest first, which is also the order they expire in.
+	order   []string
+	bytes   int
+	now     func() time.Time
+	janitor sync.Once
+}
+
+// put stores an image and returns its id, dropping the oldest images beyond the store limits.
+func (s *imageStore) put(mimeType string, data []byte) string {
+	s.janitor.Do(func() { go s.sweep() })
+	id := uuid.NewString()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.evictLocked(now)
+	for len(s.order) > 0 && (len(s.order) >= maxStoredImages || s.bytes+len(data) > maxStoredImageBytes) {
+		s.removeOldestLocked()
+	}
+	s.items[id] = storedImage{mimeType: mimeType, data: data, expires: now.Add(imageURLTTL)}
+	s.order = append(s.order, id)
+	s.bytes += len(data)
+	return id
+}
+
+func (s *imageStore) get(id string) (storedImage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok || s.now().After(item.expires) {
+		return storedImage{}, false
+	}
+	return item, true
+}
+
+// sweep drops expired images periodically, so they do not outlive their URLs when no new
+// image is stored.
+func (s *imageStore) sweep() {
+	ticker := time.NewTicker(imageEvictInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.mu.Lock()
+		s.evictLocked(s.now())
+		s.mu.Unlock()
+	}
+}
+
+func (s *imageStore) evictLocked(now time.Time) {
+	for len(s.order) > 0 && now.After(s.items[s.order[0]].expires) {
+		s.removeOldestLocked()
+	}
+}
+
+func (s *imageStore) removeOldestLocked() {
+	id := s.order[0]
+	s.order = s.order[1:]
+	s.bytes -= len(s.items[id].data)
+	delete(s.items, id)
+}
@@ -0,0 +1,309 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+const testImageModel = "test-image-model"
+
+var testImageBytes = []byte("\x89PNG\r\n\x1a\nimage")
+
+// imageExecutor answers every request with one generated image and records the payloads.
+type imageExecutor struct {
+	mu       sync.Mutex
+	payloads [][]byte
+}
+
+func (e *imageExecutor) Identifier() string { return "gemini" }
+
+func (e *imageExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.payloads = append(e.payloads, bytes.Clone(req.Payload))
+	e.mu.Unlock()
+	data := base64.StdEncoding.EncodeToString(testImageBytes)
+	return coreexecutor.Response{Payload: []byte(`{"candidates":[{"content":{"parts":[{"text":"a cat"},{"inlineData":{"mimeType":"image/png","data":"` + data + `"}}]}}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":5,"totalTokenCount":8}}`)}, nil
+}
+
+func (e *imageExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *imageExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *imageExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *imageExecutor) requests() [][]byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.payloads
+}
+
+// newImageTestRouter serves the image endpoints, attaching policy to every request.
+func newImageTestRouter(t *testing.T, executor *imageExecutor, policy sdkaccess.Policy) *gin.Engine {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: "image-test", Provider: "gemini", Status: coreauth.StatusActive}); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient("image-test", "gemini", []*registry.ModelInfo{{ID: testImageModel}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("image-test") })
+
+	gin.SetMode(gin.TestMode)
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("accessMetadata", policy.Metadata()) })
+	router.POST("/v1/images/generations", h.ImageGenerations)
+	router.POST("/v1/images/edits", h.ImageEdits)
+	router.GET("/v1/images/files/:id", h.ImageFile)
+	return router
+}
+
+func serveImages(router *gin.Engine, method, path, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestImageGenerations_ReturnsImagesAndUsage(t *testing.T) {
+	executor := &imageExecutor{}
+	router := newImageTestRouter(t, executor, sdkaccess.Policy{})
+
+	rec := serveImages(router, http.MethodPost, "/v1/images/generations", "application/json", []byte(`{"model":"`+testImageModel+`","prompt":"a cat","n":2,"size":"1536x1024"}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.Bytes()
+	if n := gjson.GetBytes(body, "data.#").Int(); n != 2 {
+		t.Fatalf("expected 2 images, got %d: %s", n, body)
+	}
+	if got := gjson.GetBytes(body, "data.0.b64_json").String(); got != base64.StdEncoding.EncodeToString(testImageBytes) {
+		t.Fatalf("unexpected image data %q", got)
+	}
+	if gjson.GetBytes(body, "data.0.revised_prompt").String() != "a cat" || gjson.GetBytes(body, "usage.total_tokens").Int() != 16 {
+		t.Fatalf("unexpected response %s", body)
+	}
+	payloads := executor.requests()
+	if len(payloads) != 2 {
+		t.Fatalf("expected one upstream request per image, got %d", len(payloads))
+	}
+	if got := gjson.GetBytes(payloads[0], "generationConfig.imageConfig.aspectRatio").String(); got != "3:2" {
+		t.Fatalf("expected a 3:2 aspect ratio, got %q", got)
+	}
+
+	rec = serveImages(router, http.MethodPost, "/v1/images/generations", "application/json", []byte(`{"model":"`+testImageModel+`","prompt":"a cat","size":"wide"}`))
+	if rec.Code != http.StatusBadRequest || gjson.Get(rec.Body.String(), "error.type").String() != "invalid_request_error" {
+		t.Fatalf("expected an invalid size to be refused, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestImageGenerations_URLsExpire(t *testing.T) {
+	router := newImageTestRouter(t, &imageExecutor{}, sdkaccess.Policy{})
+
+	rec := serveImages(router, http.MethodPost, "/v1/images/generations", "application/json", []byte(`{"model":"`+testImageModel+`","prompt":"a cat","response_format":"url"}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	url, found := strings.CutPrefix(gjson.Get(rec.Body.String(), "data.0.url").String(), "http://example.com")
+	if !found || !strings.HasPrefix(url, "/v1/images/files/") {
+		t.Fatalf("expected an absolute image URL on the request host, got %s", rec.Body.String())
+	}
+	rec = serveImages(router, http.MethodGet, url, "", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" || !bytes.Equal(rec.Body.Bytes(), testImageBytes) {
+		t.Fatalf("unexpected image response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	generatedImages.mu.Lock()
+	generatedImages.now = func() time.Time { return time.Now().Add(imageURLTTL + time.Minute) }
+	generatedImages.mu.Unlock()
+	defer func() {
+		generatedImages.mu.Lock()
+		generatedImages.now = time.Now
+		generatedImages.mu.Unlock()
+	}()
+	rec = serveImages(router, http.MethodGet, url, "", nil)
+	if rec.Code != http.StatusNotFound || gjson.Get(rec.Body.String(), "error.type").String() != "not_found_error" {
+		t.Fatalf("expected an expired image to be gone, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestImageGenerations_URLFallsBackToInlineData(t *testing.T) {
+	router := newImageTestRouter(t, &imageExecutor{}, sdkaccess.Policy{})
+	req := httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(`{"model":"`+testImageModel+`","prompt":"a cat","response_format":"url"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Host = ""
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || gjson.Get(rec.Body.String(), "data.0.url").Exists() || gjson.Get(rec.Body.String(), "data.0.b64_json").String() == "" {
+		t.Fatalf("expected the image inline without a host, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestImageBaseURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/generations", nil)
+	c.Request.Header.Set("X-Forwarded-Proto", "https")
+	if got := imageBaseURL(c, ""); got != "https://example.com" {
+		t.Fatalf("expected the forwarded scheme and request host, got %q", got)
+	}
+	if got := imageBaseURL(c, " https://proxy.example.org/ "); got != "https://proxy.example.org" {
+		t.Fatalf("expected the configured public URL, got %q", got)
+	}
+}
+
+func TestImageEdits_RefusesLargeBodies(t *testing.T) {
+	router := newImageTestRouter(t, &imageExecutor{}, sdkaccess.Policy{})
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	_ = form.WriteField("prompt", "add a hat")
+	part, _ := form.CreateFormFile("image", "image.png")
+	_, _ = part.Write(make([]byte, maxImageUploadBytes+1))
+	_ = form.Close()
+
+	rec := serveImages(router, http.MethodPost, "/v1/images/edits", form.FormDataContentType(), body.Bytes())
+	if rec.Code != http.StatusRequestEntityTooLarge || gjson.Get(rec.Body.String(), "error.type").String() != "invalid_request_error" {
+		t.Fatalf("expected an oversized upload to be refused, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestImageEdits_Multipart(t *testing.T) {
+	executor := &imageExecutor{}
+	router := newImageTestRouter(t, executor, sdkaccess.Policy{})
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	_ = form.WriteField("model", testImageModel)
+	_ = form.WriteField("prompt", "add a hat")
+	_ = form.WriteField("size", "1024x1536")
+	for _, field := range []string{"image[]", "mask"} {
+		part, err := form.CreateFormFile(field, field+".png")
+		if err != nil {
+			t.Fatalf("CreateFormFile: %v", err)
+		}
+		_, _ = part.Write(testImageBytes)
+	}
+	_ = form.Close()
+
+	rec := serveImages(router, http.MethodPost, "/v1/images/edits", form.FormDataContentType(), body.Bytes())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	payload := executor.requests()[0]
+	parts := gjson.GetBytes(payload, "contents.0.parts").Array()
+	if len(parts) != 4 || parts[0].Get("text").String() != "add a hat" || parts[2].Get("text").String() == "" {
+		t.Fatalf("expected the prompt, the image, the mask instruction and the mask, got %s", payload)
+	}
+	if parts[1].Get("inlineData.mimeType").String() != "image/png" || parts[3].Get("inlineData.data").String() != base64.StdEncoding.EncodeToString(testImageBytes) {
+		t.Fatalf("unexpected image parts %s", payload)
+	}
+	if got := gjson.GetBytes(payload, "generationConfig.imageConfig.aspectRatio").String(); got != "2:3" {
+		t.Fatalf("expected a 2:3 aspect ratio, got %q", got)
+	}
+}
+
+func TestImageEdits_JSON(t *testing.T) {
+	executor := &imageExecutor{}
+	router := newImageTestRouter(t, executor, sdkaccess.Policy{})
+	dataURL := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(testImageBytes)
+
+	rec := serveImages(router, http.MethodPost, "/v1/images/edits", "application/json", []byte(`{"model":"`+testImageModel+`","prompt":"merge","images":[{"image_url":"`+dataURL+`"},"`+dataURL+`"]}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	parts := gjson.GetBytes(executor.requests()[0], "contents.0.parts").Array()
+	if len(parts) != 3 || parts[1].Get("inlineData.mimeType").String() != "image/jpeg" || parts[2].Get("inlineData.mimeType").String() != "image/jpeg" {
+		t.Fatalf("expected the prompt and two images, got %v", parts)
+	}
+
+	rec = serveImages(router, http.MethodPost, "/v1/images/edits", "application/json", []byte(`{"model":"`+testImageModel+`","prompt":"merge","image":"https://example.com/a.png"}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected a remote image URL to be refused, got %d", rec.Code)
+	}
+	rec = serveImages(router, http.MethodPost, "/v1/images/edits", "application/json", []byte(`{"model":"`+testImageModel+`","prompt":"merge"}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected an edit without images to be refused, got %d", rec.Code)
+	}
+}
+
+func TestImageGenerations_PolicyRejectionUsesOpenAIErrors(t *testing.T) {
+	executor := &imageExecutor{}
+	router := newImageTestRouter(t, executor, sdkaccess.Policy{Models: []string{"other-*"}})
+
+	rec := serveImages(router, http.MethodPost, "/v1/images/generations", "application/json", []byte(`{"model":"`+testImageModel+`","prompt":"a cat"}`))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if gjson.Get(body, "error.type").String() != "permission_error" || gjson.Get(body, "error.code").String() != "model_not_allowed" || gjson.Get(body, "error.status").Exists() {
+		t.Fatalf("expected an OpenAI permission error, got %s", body)
+	}
+	if len(executor.requests()) != 0 {
+		t.Fatalf("denied request reached the executor")
+	}
+}
+
+func TestAspectRatioForSize(t *testing.T) {
+	tests := map[string]string{
+		"":          "",
+		"auto":      "",
+		"1024x1024": "1:1",
+		"1536x1024": "3:2",
+		"1024x1536": "2:3",
+		"1792x1024": "16:9",
+		"1024X1792": "9:16",
+		"2560x1080": "21:9",
+	}
+	for size, want := range tests {
+		got, err := aspectRatioForSize(size)
+		if err != nil || got != want {
+			t.Errorf("aspectRatioForSize(%q) = %q, %v; want %q", size, got, err, want)
+		}
+	}
+	for _, size := range []string{"large", "0x100", "1024x"} {
+		if _, err := aspectRatioForSize(size); err == nil {
+			t.Errorf("aspectRatioForSize(%q) accepted an invalid size", size)
+		}
+	}
+}
+
+func TestImageStore_EvictsOldestBeyondLimit(t *testing.T) {
+	store := &imageStore{items: make(map[string]storedImage), now: time.Now}
+	first := store.put("image/png", []byte("a"))
+	for i := 0; i < maxStoredImages; i++ {
+		store.put("image/png", []byte("b"))
+	}
+	if _, ok := store.get(first); ok {
+		t.Fatalf("expected the oldest image to be evicted")
+	}
+	if len(store.items) != maxStoredImages || len(store.order) != maxStoredImages || store.bytes != maxStoredImages {
+		t.Fatalf("unexpected store size: %d items, %d ordered, %d bytes", len(store.items), len(store.order), store.bytes)
+	}
+}
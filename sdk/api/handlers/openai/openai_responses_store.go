@@ -0,0 +1,224 @@
+package openai
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultInputItemsLimit = 20
+	maxInputItemsLimit     = 100
+)
+
+// GetResponse handles GET /v1/responses/{id} and returns a stored response.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIResponsesAPIHandler) GetResponse(c *gin.Context) {
+	rec, ok := h.lookupResponse(c)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "application/json", rec.Response)
+}
+
+// DeleteResponse handles DELETE /v1/responses/{id}.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIResponsesAPIHandler) DeleteResponse(c *gin.Context) {
+	id := c.Param("id")
+	if err := responsestore.Default().Delete(id, responseOwner(c)); err != nil {
+		writeResponseNotFound(c, id)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "response.deleted", "deleted": true})
+}
+
+// ResponseInputItems handles GET /v1/responses/{id}/input_items and lists the input items
+// sent with a stored response. It supports the order, limit and after query parameters.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIResponsesAPIHandler) ResponseInputItems(c *gin.Context) {
+	rec, ok := h.lookupResponse(c)
+	if !ok {
+		return
+	}
+	limit := defaultInputItemsLimit
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > maxInputItemsLimit {
+			writeResponsesError(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxInputItemsLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	items := gjson.ParseBytes(rec.Input).Array()
+	raw := make([]string, len(items))
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.Get("id").String()
+		raw[i] = item.Raw
+		if ids[i] == "" {
+			ids[i] = fmt.Sprintf("item_%s_%d", strings.TrimPrefix(rec.ID, "resp_"), i)
+			raw[i], _ = sjson.Set(raw[i], "id", ids[i])
+		}
+	}
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+		if c.DefaultQuery("order", "desc") != "asc" {
+			order[i] = len(items) - 1 - i
+		}
+	}
+	if after := c.Query("after"); after != "" {
+		for pos, idx := range order {
+			if ids[idx] == after {
+				order = order[pos+1:]
+				break
+			}
+		}
+	}
+	hasMore := len(order) > limit
+	if hasMore {
+		order = order[:limit]
+	}
+
+	var out bytes.Buffer
+	out.WriteString(`{"object":"list","data":[`)
+	for pos, idx := range order {
+		if pos > 0 {
+			out.WriteByte(',')
+		}
+		out.WriteString(raw[idx])
+	}
+	out.WriteString(`]}`)
+	body := out.Bytes()
+	if len(order) > 0 {
+		body, _ = sjson.SetBytes(body, "first_id", ids[order[0]])
+		body, _ = sjson.SetBytes(body, "last_id", ids[order[len(order)-1]])
+	}
+	body, _ = sjson.SetBytes(body, "has_more", hasMore)
+	c.Data(http.StatusOK, "application/json", body)
+}
+
+func (h *OpenAIResponsesAPIHandler) lookupResponse(c *gin.Context) (*responsestore.Record, bool) {
+	id := c.Param("id")
+	rec, err := responsestore.Default().Get(id, responseOwner(c))
+	if err != nil {
+		writeResponseNotFound(c, id)
+		return nil, false
+	}
+	return rec, true
+}
+
+// expandPreviousResponse replaces the input of a request continuing a stored response with the
+// full conversation, so backends without server-side state receive the history.
+func (h *OpenAIResponsesAPIHandler) expandPreviousResponse(c *gin.Context, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	previousID := gjson.GetBytes(rawJSON, "previous_response_id").String()
+	store := responsestore.Default()
+	if previousID == "" || !store.Enabled() {
+		return rawJSON, nil
+	}
+	history, err := store.History(previousID, responseOwner(c))
+	if err != nil {
+		if errors.Is(err, responsestore.ErrNotFound) {
+			return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("Previous response with id '%s' not found.", previousID)}
+		}
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+	}
+	var input bytes.Buffer
+	input.WriteByte('[')
+	for i, item := range history {
+		if i > 0 {
+			input.WriteByte(',')
+		}
+		input.Write(item)
+	}
+	for _, item := range gjson.ParseBytes(responsestore.NormalizeInput(gjson.GetBytes(rawJSON, "input"))).Array() {
+		if input.Len() > 1 {
+			input.WriteByte(',')
+		}
+		input.WriteString(item.Raw)
+	}
+	input.WriteByte(']')
+	expanded, errSet := sjson.SetRawBytes(bytes.Clone(rawJSON), "input", input.Bytes())
+	if errSet != nil {
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: errSet}
+	}
+	return expanded, nil
+}
+
+// storeResponse records a completed response unless the request opted out with "store": false.
+func (h *OpenAIResponsesAPIHandler) storeResponse(c *gin.Context, rawJSON, response []byte) {
+	store := responsestore.Default()
+	if !store.Enabled() || gjson.GetBytes(rawJSON, "store").Type == gjson.False {
+		return
+	}
+	id := gjson.GetBytes(response, "id").String()
+	if id == "" {
+		return
+	}
+	err := store.Put(responsestore.Record{
+		ID:                 id,
+		PreviousResponseID: gjson.GetBytes(rawJSON, "previous_response_id").String(),
+		Owner:              responseOwner(c),
+		Input:              responsestore.NormalizeInput(gjson.GetBytes(rawJSON, "input")),
+		Response:           bytes.Clone(response),
+	})
+	if err != nil {
+		log.Warnf("failed to store response %s: %v", id, err)
+	}
+}
+
+// completedResponseFromChunk returns the response object of a response.completed stream event.
+func completedResponseFromChunk(chunk []byte) []byte {
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		data, found := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if !found {
+			continue
+		}
+		event := gjson.ParseBytes(bytes.TrimSpace(data))
+		if event.Get("type").String() == "response.completed" {
+			return []byte(event.Get("response").Raw)
+		}
+	}
+	return nil
+}
+
+// responseOwner identifies the client key that may access stored responses.
+func responseOwner(c *gin.Context) string {
+	if key, exists := c.Get("apiKey"); exists {
+		if s, ok := key.(string); ok {
+			return responsestore.OwnerOf(s)
+		}
+	}
+	return ""
+}
+
+func writeResponseNotFound(c *gin.Context, id string) {
+	writeResponsesError(c, http.StatusNotFound, fmt.Sprintf("Response with id '%s' not found.", id))
+}
+
+func writeResponsesError(c *gin.Context, status int, message string) {
+	c.JSON(status, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: message,
+			Type:    "invalid_request_error",
+		},
+	})
+}
@@ -0,0 +1,150 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+const testResponsesModel = "test-responses-model"
+
+// responsesExecutor answers with a fixed response and records the payloads it receives.
+type responsesExecutor struct {
+	mu       sync.Mutex
+	payloads [][]byte
+}
+
+func (e *responsesExecutor) Identifier() string { return "codex" }
+
+func (e *responsesExecutor) record(payload []byte) {
+	e.mu.Lock()
+	e.payloads = append(e.payloads, bytes.Clone(payload))
+	e.mu.Unlock()
+}
+
+func (e *responsesExecutor) last() []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.payloads[len(e.payloads)-1]
+}
+
+func (e *responsesExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.record(req.Payload)
+	return coreexecutor.Response{Payload: []byte(`{"id":"resp_store_test_2","object":"response","output":[{"id":"msg_2","type":"message","role":"assistant","content":[{"type":"output_text","text":"second"}]}]}`)}, nil
+}
+
+func (e *responsesExecutor) ExecuteStream(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.record(req.Payload)
+	out := make(chan coreexecutor.StreamChunk, 2)
+	out <- coreexecutor.StreamChunk{Payload: []byte(`event: response.created` + "\n" + `data: {"type":"response.created","response":{"id":"resp_store_test_1","output":[]}}`)}
+	out <- coreexecutor.StreamChunk{Payload: []byte(`event: response.completed` + "\n" + `data: {"type":"response.completed","response":{"id":"resp_store_test_1","object":"response","output":[{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"output_text","text":"first"}]}]}}`)}
+	close(out)
+	return out, nil
+}
+
+func (e *responsesExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *responsesExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+// newResponsesTestRouter serves the Responses API, authenticating every request with the
+// client key in the X-Test-Key header.
+func newResponsesTestRouter(t *testing.T, executor *responsesExecutor) *gin.Engine {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: "responses-test", Provider: "codex", Status: coreauth.StatusActive}); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient("responses-test", "codex", []*registry.ModelInfo{{ID: testResponsesModel}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("responses-test") })
+
+	gin.SetMode(gin.TestMode)
+	h := NewOpenAIResponsesAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("apiKey", c.GetHeader("X-Test-Key")) })
+	router.POST("/v1/responses", h.Responses)
+	router.GET("/v1/responses/:id", h.GetResponse)
+	return router
+}
+
+func serveResponses(router *gin.Engine, method, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-Key", key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestResponses_StoresStreamAndExpandsPreviousResponse(t *testing.T) {
+	executor := &responsesExecutor{}
+	router := newResponsesTestRouter(t, executor)
+	t.Cleanup(func() {
+		for _, id := range []string{"resp_store_test_1", "resp_store_test_2"} {
+			_ = responsestore.Default().Delete(id, responsestore.OwnerOf("key-a"))
+		}
+	})
+
+	rec := serveResponses(router, http.MethodPost, "/v1/responses", "key-a", `{"model":"`+testResponsesModel+`","stream":true,"input":"hello"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "response.completed") {
+		t.Fatalf("unexpected stream response %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = serveResponses(router, http.MethodGet, "/v1/responses/resp_store_test_1", "key-a", "")
+	if rec.Code != http.StatusOK || gjson.Get(rec.Body.String(), "output.0.content.0.text").String() != "first" {
+		t.Fatalf("expected the completed stream to be stored, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = serveResponses(router, http.MethodGet, "/v1/responses/resp_store_test_1", "key-b", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected another client key to be refused, got %d", rec.Code)
+	}
+
+	rec = serveResponses(router, http.MethodPost, "/v1/responses", "key-a", `{"model":"`+testResponsesModel+`","previous_response_id":"resp_store_test_1","input":"again"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	input := gjson.GetBytes(executor.last(), "input").Array()
+	var texts []string
+	for _, item := range input {
+		texts = append(texts, item.Get("role").String()+":"+item.Get("content.0.text").String())
+	}
+	if got := strings.Join(texts, ","); got != "user:hello,assistant:first,user:again" {
+		t.Fatalf("expected the history to be expanded, got %s", got)
+	}
+	if _, err := responsestore.Default().Get("resp_store_test_2", responsestore.OwnerOf("key-a")); err != nil {
+		t.Fatalf("expected the non-streaming response to be stored: %v", err)
+	}
+
+	rec = serveResponses(router, http.MethodPost, "/v1/responses", "key-b", `{"model":"`+testResponsesModel+`","previous_response_id":"resp_store_test_1","input":"again"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(gjson.Get(rec.Body.String(), "error.message").String(), "resp_store_test_1") {
+		t.Fatalf("expected a foreign previous response to be refused, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestResponses_StoreFalseSkipsStreamedResponse(t *testing.T) {
+	router := newResponsesTestRouter(t, &responsesExecutor{})
+	t.Cleanup(func() { _ = responsestore.Default().Delete("resp_store_test_1", responsestore.OwnerOf("key-c")) })
+
+	rec := serveResponses(router, http.MethodPost, "/v1/responses", "key-c", `{"model":"`+testResponsesModel+`","stream":true,"store":false,"input":"hello"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected stream response %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := responsestore.Default().Get("resp_store_test_1", responsestore.OwnerOf("key-c")); err == nil {
+		t.Fatalf("expected a response with store false not to be kept")
+	}
+}
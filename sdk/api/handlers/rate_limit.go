@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtualkeys"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+)
+
+// admitClientRequest applies the budgets and the rate limit of the caller's client key. The
+// returned release function must be called once the request or stream has finished; until
+// then the request counts towards InteractiveInFlight.
+func (h *BaseAPIHandler) admitClientRequest(ctx context.Context, handlerType string, rawJSON []byte) (func(), *interfaces.ErrorMessage) {
+	key := clientKeyFromContext(ctx)
+	if key == "" || h.Cfg == nil {
+		return trackInteractive(ctx), nil
+	}
+	handlerType = errorFormat(ctx, handlerType)
+	if errMsg := h.checkClientBudget(ctx, handlerType, key); errMsg != nil {
+		return nil, errMsg
+	}
+	release, rejection := ratelimit.Default().Acquire(key, logging.GetRequestID(ctx), virtualkeys.Default().RateLimitFor(h.Cfg, key), ratelimit.EstimateInputTokens(rawJSON))
+	if rejection != nil {
+		return nil, rateLimitedError(handlerType, rejection)
+	}
+	done := trackInteractive(ctx)
+	return func() {
+		release()
+		done()
+	}, nil
+}
+
+// rateLimitedError builds a 429 carrying Retry-After in the error format of the client API.
+func rateLimitedError(handlerType string, rejection *ratelimit.Rejection) *interfaces.ErrorMessage {
+	msg := clientFormatError(handlerType, http.StatusTooManyRequests, rejection.Error(), "rate_limit_error", "rate_limit_exceeded", "RESOURCE_EXHAUSTED")
+	msg.Addon = http.Header{"Retry-After": []string{strconv.Itoa(rejection.RetryAfterSeconds())}}
+	return msg
+}
+
+func clientKeyFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return ""
+	}
+	key, _ := ginCtx.Get("apiKey")
+	value, _ := key.(string)
+	return value
+}
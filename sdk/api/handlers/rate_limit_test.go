@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestExecuteWithAuthManager_RateLimitedPerClientKey(t *testing.T) {
+	executor := &recordingAuthExecutor{}
+	handler := newAccessPolicyTestHandler(t, executor)
+	handler.Cfg = &sdkconfig.SDKConfig{
+		APIKeyEntries: []sdkconfig.ClientAPIKey{{APIKey: "rate-limit-test-key", RateLimit: &sdkconfig.ClientRateLimit{RequestsPerMinute: 1}}},
+	}
+
+	gin.SetMode(gin.TestMode)
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/policy-model:generateContent", nil)
+	ginCtx.Set("apiKey", "rate-limit-test-key")
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+
+	if _, errMsg := handler.ExecuteWithAuthManager(ctx, "gemini", "policy-model", []byte(`{}`), ""); errMsg != nil {
+		t.Fatalf("first request rejected: %+v", errMsg)
+	}
+	_, errMsg := handler.ExecuteWithAuthManager(ctx, "gemini", "policy-model", []byte(`{}`), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %+v", errMsg)
+	}
+	if errMsg.Addon.Get("Retry-After") == "" {
+		t.Fatalf("expected a Retry-After header, got %v", errMsg.Addon)
+	}
+	body := BuildErrorResponseBody(errMsg.StatusCode, errMsg.Error.Error())
+	if gjson.GetBytes(body, "error.status").String() != "RESOURCE_EXHAUSTED" {
+		t.Fatalf("expected a Gemini rate limit error, got %s", body)
+	}
+	if len(executor.authIDs) != 1 {
+		t.Fatalf("rejected request reached the executor")
+	}
+}
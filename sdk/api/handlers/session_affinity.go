@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// sessionIDHeader lets clients name their conversation explicitly for sticky routing.
+const sessionIDHeader = "X-Session-Id"
+
+var (
+	sessionSystemPaths  = []string{"system", "systemInstruction", "system_instruction", "instructions"}
+	sessionMessagePaths = []string{"messages", "contents", "input"}
+)
+
+// sessionAffinityKey derives a stable conversation identifier for sticky credential routing.
+// It prefers the X-Session-Id header, then the Claude metadata.user_id field, and finally a
+// hash of the system prompt plus the first conversational message, which stays constant
+// across the turns of an agent session.
+func sessionAffinityKey(ctx context.Context, rawJSON []byte) string {
+	if ctx != nil {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+			if key := strings.TrimSpace(ginCtx.GetHeader(sessionIDHeader)); key != "" {
+				return key
+			}
+		}
+	}
+	if len(rawJSON) == 0 || !gjson.ValidBytes(rawJSON) {
+		return ""
+	}
+	root := gjson.ParseBytes(rawJSON)
+	if userID := strings.TrimSpace(root.Get("metadata.user_id").String()); userID != "" {
+		return userID
+	}
+
+	system := ""
+	for _, path := range sessionSystemPaths {
+		if value := root.Get(path); value.Exists() {
+			system = value.Raw
+			break
+		}
+	}
+	first := ""
+	for _, path := range sessionMessagePaths {
+		value := root.Get(path)
+		if !value.Exists() {
+			continue
+		}
+		if value.Type == gjson.String {
+			first = value.Raw
+			break
+		}
+		value.ForEach(func(_, message gjson.Result) bool {
+			switch message.Get("role").String() {
+			case "system", "developer":
+				if system == "" {
+					system = message.Raw
+				}
+				return true
+			}
+			first = message.Raw
+			return false
+		})
+		break
+	}
+	if system == "" && first == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(system + "\x00" + first))
+	return "prompt:" + hex.EncodeToString(sum[:16])
+}
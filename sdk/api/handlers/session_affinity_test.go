@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessionAffinityKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	ginCtx.Request.Header.Set("X-Session-Id", "abc-123")
+	withHeader := context.WithValue(context.Background(), "gin", ginCtx)
+
+	if got := sessionAffinityKey(withHeader, []byte(`{"metadata":{"user_id":"user_1"}}`)); got != "abc-123" {
+		t.Fatalf("header key = %q, want %q", got, "abc-123")
+	}
+	if got := sessionAffinityKey(context.Background(), []byte(`{"metadata":{"user_id":"user_1"}}`)); got != "user_1" {
+		t.Fatalf("user_id key = %q, want %q", got, "user_1")
+	}
+
+	turn1 := sessionAffinityKey(context.Background(), []byte(`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`))
+	turn2 := sessionAffinityKey(context.Background(), []byte(`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"more"}]}`))
+	other := sessionAffinityKey(context.Background(), []byte(`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"bye"}]}`))
+	if turn1 == "" || turn1 != turn2 {
+		t.Fatalf("prompt hash not stable across turns: %q vs %q", turn1, turn2)
+	}
+	if other == turn1 {
+		t.Fatalf("different conversations share key %q", other)
+	}
+
+	if got := sessionAffinityKey(context.Background(), []byte(`{"model":"x"}`)); got != "" {
+		t.Fatalf("key without conversation = %q, want empty", got)
+	}
+}
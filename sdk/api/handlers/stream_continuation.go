@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// StreamingContinuationRetries returns how many times a stream that failed after bytes were sent
+// may be resumed on another credential. Zero (the default) disables mid-stream failover.
+func StreamingContinuationRetries(cfg *config.SDKConfig) int {
+	if cfg == nil || cfg.Streaming.ContinuationRetries == nil || *cfg.Streaming.ContinuationRetries < 0 {
+		return 0
+	}
+	return *cfg.Streaming.ContinuationRetries
+}
+
+// streamContinuation tracks the assistant output already delivered to the client so a stream
+// that dies mid-way can be re-issued with that output as a prefill, and rewrites the resumed
+// stream so it splices into the client's existing response. Only text is carried over: once
+// a tool call has been emitted the stream cannot be resumed safely.
+type streamContinuation struct {
+	format string
+	// gemini-cli wraps every response in a "response" envelope.
+	responsePrefix string
+
+	text        strings.Builder
+	toolCall    bool
+	finished    bool
+	unsupported bool
+	resumed     bool
+
+	// OpenAI chat identity of the original completion, reused for resumed chunks.
+	chunkID string
+	created int64
+
+	// Claude content block bookkeeping.
+	nextBlock   int
+	openBlocks  map[int]struct{}
+	blockOffset int
+	closeBlocks []int
+}
+
+// newStreamContinuation returns a tracker for handlerType, or nil when the client format
+// cannot be resumed (OpenAI Responses, non-SSE Gemini output).
+func newStreamContinuation(handlerType, alt string) *streamContinuation {
+	switch handlerType {
+	case constant.OpenAI, constant.Claude:
+		return &streamContinuation{format: handlerType}
+	case constant.Gemini:
+		if alt != "" {
+			return nil
+		}
+		return &streamContinuation{format: handlerType}
+	case constant.GeminiCLI:
+		if alt != "" {
+			return nil
+		}
+		return &streamContinuation{format: handlerType, responsePrefix: "response."}
+	default:
+		return nil
+	}
+}
+
+// resumable reports whether the delivered output can be continued on a new stream.
+func (s *streamContinuation) resumable() bool {
+	return s != nil && !s.toolCall && !s.finished && !s.unsupported
+}
+
+// observe records the assistant state carried by a chunk that was sent to the client.
+func (s *streamContinuation) observe(chunk []byte) {
+	if s == nil {
+		return
+	}
+	switch s.format {
+	case constant.OpenAI:
+		s.observeOpenAI(chunk)
+	case constant.Claude:
+		forEachClaudeEvent(chunk, s.observeClaude)
+	default:
+		s.observeGemini(chunk)
+	}
+}
+
+// resume builds the continuation request: the original payload with the delivered text appended
+// as a trailing assistant turn, routed away from every credential that already failed.
+func (s *streamContinuation) resume(req coreexecutor.Request, opts coreexecutor.Options, failedAuths []string) (coreexecutor.Request, coreexecutor.Options, bool) {
+	payload, ok := s.prefill(req.Payload)
+	if !ok {
+		return req, opts, false
+	}
+	req.Payload = payload
+	opts.OriginalRequest = cloneBytes(payload)
+	opts.Metadata = mergeMetadata(opts.Metadata, map[string]any{
+		coreauth.ExcludedAuthsMetadataKey: append([]string(nil), failedAuths...),
+	})
+	s.resumed = true
+	if s.format == constant.Claude {
+		s.blockOffset = s.nextBlock
+		s.closeBlocks = s.closeBlocks[:0]
+		for index := range s.openBlocks {
+			s.closeBlocks = append(s.closeBlocks, index)
+		}
+		sort.Ints(s.closeBlocks)
+	}
+	return req, opts, true
+}
+
+// rewrite adapts a chunk of a resumed stream so the client sees one continuous response.
+// Chunks of the original stream are returned unchanged.
+func (s *streamContinuation) rewrite(chunk []byte) []byte {
+	if s == nil || !s.resumed {
+		return chunk
+	}
+	switch s.format {
+	case constant.OpenAI:
+		return s.rewriteOpenAI(chunk)
+	case constant.Claude:
+		return s.rewriteClaude(chunk)
+	default:
+		return chunk
+	}
+}
+
+// prefill appends the delivered text to the conversation in the client's request format.
+// Trailing whitespace is dropped because several upstreams reject assistant prefills ending in it.
+func (s *streamContinuation) prefill(payload []byte) ([]byte, bool) {
+	if !gjson.ValidBytes(payload) {
+		return nil, false
+	}
+	text := strings.TrimRight(s.text.String(), " \t\r\n")
+	if text == "" {
+		return cloneBytes(payload), true
+	}
+	var (
+		listPath      string
+		assistantRole string
+		message       string
+	)
+	switch s.format {
+	case constant.OpenAI, constant.Claude:
+		listPath, assistantRole = "messages", "assistant"
+		message, _ = sjson.Set(`{"role":"assistant"}`, "content", text)
+	case constant.Gemini:
+		listPath, assistantRole = "contents", "model"
+		message, _ = sjson.Set(`{"role":"model","parts":[]}`, "parts.-1.text", text)
+	case constant.GeminiCLI:
+		listPath, assistantRole = "request.contents", "model"
+		message, _ = sjson.Set(`{"role":"model","parts":[]}`, "parts.-1.text", text)
+	default:
+		return nil, false
+	}
+	messages := gjson.GetBytes(payload, listPath)
+	if !messages.IsArray() {
+		return nil, false
+	}
+	// A request that already ends with an assistant prefill is extended instead of followed by a
+	// second assistant turn.
+	if items := messages.Array(); len(items) > 0 && items[len(items)-1].Get("role").String() == assistantRole {
+		lastPath := listPath + "." + strconv.Itoa(len(items)-1)
+		last := items[len(items)-1]
+		var out []byte
+		var err error
+		switch {
+		case s.format == constant.Gemini || s.format == constant.GeminiCLI:
+			out, err = sjson.SetBytes(payload, lastPath+".parts.-1", map[string]any{"text": text})
+		case last.Get("content").Type == gjson.String:
+			out, err = sjson.SetBytes(payload, lastPath+".content", last.Get("content").String()+text)
+		default:
+			out, err = sjson.SetBytes(payload, lastPath+".content.-1", map[string]any{"type": "text", "text": text})
+		}
+		if err != nil {
+			return nil, false
+		}
+		return out, true
+	}
+	out, err := sjson.SetRawBytes(payload, listPath+".-1", []byte(message))
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func (s *streamContinuation) observeOpenAI(chunk []byte) {
+	chunk = trimSSEData(chunk)
+	if !gjson.ValidBytes(chunk) {
+		return
+	}
+	root := gjson.ParseBytes(chunk)
+	if s.chunkID == "" {
+		s.chunkID = root.Get("id").String()
+		s.created = root.Get("created").Int()
+	}
+	root.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+		if choice.Get("index").Int() != 0 {
+			s.unsupported = true
+		}
+		delta := choice.Get("delta")
+		if content := delta.Get("content"); content.Type == gjson.String {
+			s.text.WriteString(content.String())
+		}
+		if calls := delta.Get("tool_calls"); calls.IsArray() && len(calls.Array()) > 0 {
+			s.toolCall = true
+		}
+		if delta.Get("function_call").Exists() {
+			s.toolCall = true
+		}
+		if reason := choice.Get("finish_reason"); reason.Type == gjson.String && reason.String() != "" {
+			s.finished = true
+		}
+		return true
+	})
+}
+
+func (s *streamContinuation) rewriteOpenAI(chunk []byte) []byte {
+	data := trimSSEData(chunk)
+	if !gjson.ValidBytes(data) {
+		return chunk
+	}
+	out := cloneBytes(data)
+	if s.chunkID != "" && gjson.GetBytes(out, "id").Exists() {
+		out, _ = sjson.SetBytes(out, "id", s.chunkID)
+	}
+	if s.created != 0 && gjson.GetBytes(out, "created").Exists() {
+		out, _ = sjson.SetBytes(out, "created", s.created)
+	}
+	if gjson.GetBytes(out, "choices.0.delta.role").Exists() {
+		out, _ = sjson.DeleteBytes(out, "choices.0.delta.role")
+	}
+	return out
+}
+
+func (s *streamContinuation) observeClaude(eventType string, data gjson.Result) {
+	switch eventType {
+	case "content_block_start":
+		index := int(data.Get("index").Int())
+		if index+1 > s.nextBlock {
+			s.nextBlock = index + 1
+		}
+		if s.openBlocks == nil {
+			s.openBlocks = make(map[int]struct{})
+		}
+		s.openBlocks[index] = struct{}{}
+		switch data.Get("content_block.type").String() {
+		case "tool_use", "server_tool_use":
+			s.toolCall = true
+		}
+	case "content_block_delta":
+		switch data.Get("delta.type").String() {
+		case "text_delta":
+			s.text.WriteString(data.Get("delta.text").String())
+		case "input_json_delta":
+			s.toolCall = true
+		}
+	case "content_block_stop":
+		delete(s.openBlocks, int(data.Get("index").Int()))
+	case "message_delta":
+		if data.Get("delta.stop_reason").String() != "" {
+			s.finished = true
+		}
+	case "message_stop":
+		s.finished = true
+	}
+}
+
+// rewriteClaude drops the resumed stream's message_start, closes blocks left open by the failed
+// stream and shifts content block indices past the ones the client already received.
+func (s *streamContinuation) rewriteClaude(chunk []byte) []byte {
+	var out bytes.Buffer
+	for _, index := range s.closeBlocks {
+		stop, _ := sjson.Set(`{"type":"content_block_stop"}`, "index", index)
+		writeClaudeEvent(&out, "content_block_stop", stop)
+	}
+	s.closeBlocks = nil
+	forEachClaudeEvent(chunk, func(eventType string, data gjson.Result) {
+		raw := data.Raw
+		switch eventType {
+		case "message_start":
+			return
+		case "content_block_start", "content_block_delta", "content_block_stop":
+			raw, _ = sjson.Set(raw, "index", data.Get("index").Int()+int64(s.blockOffset))
+		}
+		writeClaudeEvent(&out, eventType, raw)
+	})
+	return out.Bytes()
+}
+
+func (s *streamContinuation) observeGemini(chunk []byte) {
+	chunk = trimSSEData(chunk)
+	if !gjson.ValidBytes(chunk) {
+		return
+	}
+	candidates := gjson.GetBytes(chunk, s.responsePrefix+"candidates")
+	if len(candidates.Array()) > 1 {
+		s.unsupported = true
+	}
+	candidate := candidates.Get("0")
+	candidate.Get("content.parts").ForEach(func(_, part gjson.Result) bool {
+		if part.Get("functionCall").Exists() {
+			s.toolCall = true
+		}
+		if text := part.Get("text"); text.Exists() && !part.Get("thought").Bool() {
+			s.text.WriteString(text.String())
+		}
+		return true
+	})
+	if candidate.Get("finishReason").String() != "" {
+		s.finished = true
+	}
+}
+
+// forEachClaudeEvent invokes fn for every JSON data line in a Claude SSE chunk. Chunks carry
+// either whole events or single SSE lines, so the event type is read from the payload.
+func forEachClaudeEvent(chunk []byte, fn func(eventType string, data gjson.Result)) {
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		payload := bytes.TrimSpace(line[len("data:"):])
+		if !gjson.ValidBytes(payload) {
+			continue
+		}
+		data := gjson.ParseBytes(payload)
+		fn(data.Get("type").String(), data)
+	}
+}
+
+func writeClaudeEvent(out *bytes.Buffer, eventType, data string) {
+	out.WriteString("event: ")
+	out.WriteString(eventType)
+	out.WriteString("\ndata: ")
+	out.WriteString(data)
+	out.WriteString("\n\n")
+}
+
+func trimSSEData(chunk []byte) []byte {
+	chunk = bytes.TrimSpace(chunk)
+	if bytes.HasPrefix(chunk, []byte("data:")) {
+		chunk = bytes.TrimSpace(chunk[len("data:"):])
+	}
+	return chunk
+}
@@ -14,6 +14,9 @@ import (
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
+// runtimeStateFileName holds auth runtime state inside the auth directory.
+const runtimeStateFileName = ".runtime-state"
+
 // FileTokenStore persists token records and auth metadata using the filesystem as backing storage.
 type FileTokenStore struct {
 	mu      sync.Mutex
@@ -151,6 +154,91 @@ func (s *FileTokenStore) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// LoadStates reads the persisted auth runtime state (cooldowns and model states).
+func (s *FileTokenStore) LoadStates(ctx context.Context) (map[string]*cliproxyauth.RuntimeState, error) {
+	path, err := s.runtimeStatePath()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return readRuntimeStateFile(path)
+}
+
+// SaveState persists the runtime state for an auth next to the auth files; a nil state removes it.
+// The state file does not use the .json suffix so it is never mistaken for a credential.
+func (s *FileTokenStore) SaveState(ctx context.Context, id string, state *cliproxyauth.RuntimeState) error {
+	path, err := s.runtimeStatePath()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states, err := readRuntimeStateFile(path)
+	if err != nil {
+		states = make(map[string]*cliproxyauth.RuntimeState)
+	}
+	if state == nil {
+		if _, ok := states[id]; !ok {
+			return nil
+		}
+		delete(states, id)
+	} else {
+		states[id] = state
+	}
+	return writeRuntimeStateFile(path, states)
+}
+
+func (s *FileTokenStore) runtimeStatePath() (string, error) {
+	dir := s.baseDirSnapshot()
+	if dir == "" {
+		return "", fmt.Errorf("auth filestore: directory not configured")
+	}
+	return filepath.Join(dir, runtimeStateFileName), nil
+}
+
+func readRuntimeStateFile(path string) (map[string]*cliproxyauth.RuntimeState, error) {
+	states := make(map[string]*cliproxyauth.RuntimeState)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return states, nil
+		}
+		return nil, fmt.Errorf("auth filestore: read runtime state failed: %w", err)
+	}
+	if len(data) == 0 {
+		return states, nil
+	}
+	if err = json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("auth filestore: unmarshal runtime state failed: %w", err)
+	}
+	return states, nil
+}
+
+func writeRuntimeStateFile(path string, states map[string]*cliproxyauth.RuntimeState) error {
+	if len(states) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("auth filestore: remove runtime state failed: %w", err)
+		}
+		return nil
+	}
+	raw, err := json.Marshal(states)
+	if err != nil {
+		return fmt.Errorf("auth filestore: marshal runtime state failed: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("auth filestore: create dir failed: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("auth filestore: write temp failed: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("auth filestore: rename failed: %w", err)
+	}
+	return nil
+}
+
 func (s *FileTokenStore) resolveDeletePath(id string) (string, error) {
 	if strings.ContainsRune(id, os.PathSeparator) || filepath.IsAbs(id) {
 		return id, nil
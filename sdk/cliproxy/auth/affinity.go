@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionAffinityMetadataKey carries the conversation identifier used for sticky credential routing.
+const SessionAffinityMetadataKey = "session_affinity_key"
+
+// DefaultSessionAffinityTTL is used when session affinity is enabled without an explicit TTL.
+const DefaultSessionAffinityTTL = time.Hour
+
+// Session affinity outcomes reported alongside usage records.
+const (
+	// SessionAffinityHit means the conversation was routed to its previously bound credential.
+	SessionAffinityHit = "hit"
+	// SessionAffinityMiss means the conversation had no usable binding and was (re)bound.
+	SessionAffinityMiss = "miss"
+)
+
+// sessionAffinitySweepInterval bounds how often expired bindings are purged.
+const sessionAffinitySweepInterval = time.Minute
+
+type sessionAffinityContextKey struct{}
+
+// SessionAffinityFromContext returns the affinity outcome recorded for the current execution,
+// or an empty string when the request carried no session key or affinity is disabled.
+func SessionAffinityFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	outcome, _ := ctx.Value(sessionAffinityContextKey{}).(string)
+	return outcome
+}
+
+func withSessionAffinity(ctx context.Context, outcome string) context.Context {
+	if outcome == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionAffinityContextKey{}, outcome)
+}
+
+type affinityBinding struct {
+	authID  string
+	expires time.Time
+}
+
+// sessionAffinity binds conversations to credentials so upstream prompt caches stay warm.
+type sessionAffinity struct {
+	mu        sync.Mutex
+	enabled   bool
+	ttl       time.Duration
+	bindings  map[string]affinityBinding
+	lastSweep time.Time
+}
+
+func newSessionAffinity() *sessionAffinity {
+	return &sessionAffinity{ttl: DefaultSessionAffinityTTL, bindings: make(map[string]affinityBinding)}
+}
+
+// SetSessionAffinity enables or disables sticky conversation routing. Bindings expire after
+// ttl without use; ttl <= 0 selects DefaultSessionAffinityTTL. Disabling drops all bindings.
+func (m *Manager) SetSessionAffinity(enabled bool, ttl time.Duration) {
+	if m == nil || m.affinity == nil {
+		return
+	}
+	if ttl <= 0 {
+		ttl = DefaultSessionAffinityTTL
+	}
+	a := m.affinity
+	a.mu.Lock()
+	a.enabled = enabled
+	a.ttl = ttl
+	if !enabled {
+		a.bindings = make(map[string]affinityBinding)
+	}
+	a.mu.Unlock()
+}
+
+// bindingKey scopes the session to a provider and model; an empty result disables affinity.
+func (a *sessionAffinity) bindingKey(metadata map[string]any, provider, model string) string {
+	if a == nil || len(metadata) == 0 {
+		return ""
+	}
+	session, _ := metadata[SessionAffinityMetadataKey].(string)
+	session = strings.TrimSpace(session)
+	if session == "" {
+		return ""
+	}
+	a.mu.Lock()
+	enabled := a.enabled
+	a.mu.Unlock()
+	if !enabled {
+		return ""
+	}
+	return provider + "|" + model + "|" + session
+}
+
+// lookup returns the bound auth when it is still among the candidates and not blocked for the model.
+func (a *sessionAffinity) lookup(key, model string, candidates []*Auth, now time.Time) *Auth {
+	if key == "" {
+		return nil
+	}
+	a.mu.Lock()
+	binding, ok := a.bindings[key]
+	a.mu.Unlock()
+	if !ok || now.After(binding.expires) {
+		return nil
+	}
+	for _, candidate := range candidates {
+		if candidate == nil || candidate.ID != binding.authID {
+			continue
+		}
+		if blocked, _, _ := isAuthBlockedForModel(candidate, model, now); blocked {
+			return nil
+		}
+		return candidate
+	}
+	return nil
+}
+
+// bind records (or refreshes) the credential serving the conversation.
+func (a *sessionAffinity) bind(key, authID string, now time.Time) {
+	if key == "" || authID == "" {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.bindings[key] = affinityBinding{authID: authID, expires: now.Add(a.ttl)}
+	if now.Sub(a.lastSweep) < sessionAffinitySweepInterval {
+		return
+	}
+	a.lastSweep = now
+	for k, binding := range a.bindings {
+		if now.After(binding.expires) {
+			delete(a.bindings, k)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type affinityTestExecutor struct {
+	authIDs  []string
+	outcomes []string
+}
+
+func (e *affinityTestExecutor) Identifier() string { return "affinity-test" }
+
+func (e *affinityTestExecutor) Execute(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.authIDs = append(e.authIDs, auth.ID)
+	e.outcomes = append(e.outcomes, SessionAffinityFromContext(ctx))
+	return cliproxyexecutor.Response{Payload: []byte("ok")}, nil
+}
+
+func (e *affinityTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, &Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *affinityTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	return auth, nil
+}
+
+func (e *affinityTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, &Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func newAffinityTestManager(t *testing.T) (*Manager, *affinityTestExecutor) {
+	t.Helper()
+	executor := &affinityTestExecutor{}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	for _, id := range []string{"affinity-a", "affinity-b", "affinity-c"} {
+		if _, err := manager.Register(context.Background(), &Auth{ID: id, Provider: executor.Identifier(), Status: StatusActive}); err != nil {
+			t.Fatalf("Register(%s): %v", id, err)
+		}
+		registry.GetGlobalRegistry().RegisterClient(id, executor.Identifier(), []*registry.ModelInfo{{ID: "affinity-model"}})
+		authID := id
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(authID) })
+	}
+	manager.SetSessionAffinity(true, time.Minute)
+	return manager, executor
+}
+
+func affinityOptions(session string) cliproxyexecutor.Options {
+	return cliproxyexecutor.Options{Metadata: map[string]any{SessionAffinityMetadataKey: session}}
+}
+
+func TestManagerSessionAffinity_PinsConversation(t *testing.T) {
+	manager, executor := newAffinityTestManager(t)
+	req := cliproxyexecutor.Request{Model: "affinity-model"}
+
+	for i := 0; i < 4; i++ {
+		if _, err := manager.Execute(context.Background(), []string{"affinity-test"}, req, affinityOptions("session-1")); err != nil {
+			t.Fatalf("Execute() #%d error = %v", i, err)
+		}
+	}
+	for i, id := range executor.authIDs {
+		if id != executor.authIDs[0] {
+			t.Fatalf("request %d routed to %s, want %s", i, id, executor.authIDs[0])
+		}
+	}
+	want := []string{SessionAffinityMiss, SessionAffinityHit, SessionAffinityHit, SessionAffinityHit}
+	for i, outcome := range executor.outcomes {
+		if outcome != want[i] {
+			t.Fatalf("outcome[%d] = %q, want %q", i, outcome, want[i])
+		}
+	}
+
+	if _, err := manager.Execute(context.Background(), []string{"affinity-test"}, req, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("Execute() without session error = %v", err)
+	}
+	if got := executor.outcomes[len(executor.outcomes)-1]; got != "" {
+		t.Fatalf("outcome without session = %q, want empty", got)
+	}
+}
+
+func TestManagerSessionAffinity_RebindsWhenBlocked(t *testing.T) {
+	manager, executor := newAffinityTestManager(t)
+	req := cliproxyexecutor.Request{Model: "affinity-model"}
+
+	if _, err := manager.Execute(context.Background(), []string{"affinity-test"}, req, affinityOptions("session-2")); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	bound := executor.authIDs[0]
+
+	manager.mu.Lock()
+	manager.auths[bound].ModelStates = map[string]*ModelState{
+		"affinity-model": {Status: StatusError, Unavailable: true, NextRetryAfter: time.Now().Add(time.Hour)},
+	}
+	manager.mu.Unlock()
+
+	for i := 0; i < 2; i++ {
+		if _, err := manager.Execute(context.Background(), []string{"affinity-test"}, req, affinityOptions("session-2")); err != nil {
+			t.Fatalf("Execute() #%d error = %v", i, err)
+		}
+	}
+	if executor.authIDs[1] == bound || executor.authIDs[2] != executor.authIDs[1] {
+		t.Fatalf("unexpected routing after block: %v", executor.authIDs)
+	}
+	if executor.outcomes[1] != SessionAffinityMiss || executor.outcomes[2] != SessionAffinityHit {
+		t.Fatalf("unexpected outcomes after block: %v", executor.outcomes)
+	}
+}
+
+func TestManagerSessionAffinity_Disabled(t *testing.T) {
+	manager, executor := newAffinityTestManager(t)
+	manager.SetSessionAffinity(false, 0)
+	req := cliproxyexecutor.Request{Model: "affinity-model"}
+
+	for i := 0; i < 3; i++ {
+		if _, err := manager.Execute(context.Background(), []string{"affinity-test"}, req, affinityOptions("session-3")); err != nil {
+			t.Fatalf("Execute() #%d error = %v", i, err)
+		}
+	}
+	if executor.authIDs[0] == executor.authIDs[1] {
+		t.Fatalf("expected round-robin routing when affinity is disabled, got %v", executor.authIDs)
+	}
+	for i, outcome := range executor.outcomes {
+		if outcome != "" {
+			t.Fatalf("outcome[%d] = %q, want empty", i, outcome)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitMinRequests      = 10
+	defaultCircuitWindow           = time.Minute
+	defaultCircuitOpenDuration     = 30 * time.Second
+)
+
+// CircuitBreakerConfig controls the per auth/model circuit breaker applied to transient
+// upstream failures (network errors, timeouts and 5xx responses).
+type CircuitBreakerConfig struct {
+	// FailureThreshold opens the circuit after this many consecutive failures. Zero uses the default.
+	FailureThreshold int
+	// ErrorRateThreshold opens the circuit when the failure ratio within Window reaches this value
+	// (0-1). Zero disables the error-rate trigger.
+	ErrorRateThreshold float64
+	// MinRequests is the minimum number of requests in Window before the error rate is evaluated.
+	MinRequests int
+	// Window is the error-rate observation window.
+	Window time.Duration
+	// OpenDuration is how long the circuit stays open before a single half-open probe is allowed.
+	OpenDuration time.Duration
+}
+
+func (c CircuitBreakerConfig) normalized() CircuitBreakerConfig {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = defaultCircuitFailureThreshold
+	}
+	if c.ErrorRateThreshold < 0 {
+		c.ErrorRateThreshold = 0
+	}
+	if c.ErrorRateThreshold > 1 {
+		c.ErrorRateThreshold = 1
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = defaultCircuitMinRequests
+	}
+	if c.Window <= 0 {
+		c.Window = defaultCircuitWindow
+	}
+	if c.OpenDuration <= 0 {
+		c.OpenDuration = defaultCircuitOpenDuration
+	}
+	return c
+}
+
+// SetCircuitBreaker enables the circuit breaker with the given settings; nil disables it.
+// While disabled, transient failures fall back to the fixed short cooldown.
+func (m *Manager) SetCircuitBreaker(cfg *CircuitBreakerConfig) {
+	if m == nil {
+		return
+	}
+	var normalized *CircuitBreakerConfig
+	if cfg != nil {
+		value := cfg.normalized()
+		normalized = &value
+	}
+	m.mu.Lock()
+	m.circuit = normalized
+	m.mu.Unlock()
+}
+
+// ResetCircuitBreaker force-closes the circuit for the auth and model and clears the
+// resulting cooldown. It reports whether a matching model state was found.
+func (m *Manager) ResetCircuitBreaker(ctx context.Context, authID, model string) bool {
+	if m == nil {
+		return false
+	}
+	model = strings.TrimSpace(model)
+	m.mu.Lock()
+	auth, ok := m.auths[authID]
+	if !ok || auth == nil || model == "" {
+		m.mu.Unlock()
+		return false
+	}
+	state, ok := auth.ModelStates[model]
+	if !ok || state == nil {
+		m.mu.Unlock()
+		return false
+	}
+	now := time.Now()
+	if state.Circuit.State == CircuitOpen || state.Circuit.State == CircuitHalfOpen {
+		resetModelState(state, now)
+	}
+	state.Circuit = CircuitState{}
+	updateAggregatedAvailability(auth, now)
+	_ = m.persist(ctx, auth)
+	saveState := m.prepareStateSave(ctx, auth)
+	m.mu.Unlock()
+	if saveState != nil {
+		saveState()
+	}
+	return true
+}
+
+// isCircuitFailure reports whether a failed result counts towards the circuit breaker.
+// Only transient upstream failures count; client, auth and quota errors have dedicated handling.
+func isCircuitFailure(ctx context.Context, statusCode int) bool {
+	if ctx != nil && ctx.Err() != nil {
+		return false
+	}
+	return statusCode == 0 || statusCode == http.StatusRequestTimeout || statusCode >= http.StatusInternalServerError
+}
+
+func (c *CircuitState) rollWindow(window time.Duration, now time.Time) {
+	if c.WindowStart.IsZero() || now.Sub(c.WindowStart) >= window {
+		c.WindowStart = now
+		c.WindowRequests = 0
+		c.WindowFailures = 0
+	}
+}
+
+// recordCircuitSuccess closes the circuit and counts the request in the error-rate window.
+func recordCircuitSuccess(state *ModelState, cfg *CircuitBreakerConfig, now time.Time) {
+	if state == nil {
+		return
+	}
+	if cfg == nil {
+		state.Circuit = CircuitState{}
+		return
+	}
+	circuit := &state.Circuit
+	circuit.rollWindow(cfg.Window, now)
+	circuit.WindowRequests++
+	circuit.State = CircuitClosed
+	circuit.ConsecutiveFailures = 0
+	circuit.ProbeStartedAt = time.Time{}
+}
+
+// recordCircuitFailure counts a transient failure and reports whether the circuit is now open.
+func recordCircuitFailure(state *ModelState, cfg *CircuitBreakerConfig, now time.Time) bool {
+	if state == nil || cfg == nil {
+		return false
+	}
+	circuit := &state.Circuit
+	circuit.rollWindow(cfg.Window, now)
+	circuit.WindowRequests++
+	circuit.WindowFailures++
+	circuit.ConsecutiveFailures++
+
+	trip := circuit.State == CircuitHalfOpen || circuit.State == CircuitOpen
+	if circuit.ConsecutiveFailures >= cfg.FailureThreshold {
+		trip = true
+	}
+	if cfg.ErrorRateThreshold > 0 && circuit.WindowRequests >= cfg.MinRequests {
+		if float64(circuit.WindowFailures)/float64(circuit.WindowRequests) >= cfg.ErrorRateThreshold {
+			trip = true
+		}
+	}
+	if !trip {
+		if circuit.State == "" {
+			circuit.State = CircuitClosed
+		}
+		return false
+	}
+	circuit.State = CircuitOpen
+	circuit.OpenedAt = now
+	circuit.ProbeStartedAt = time.Time{}
+	return true
+}
+
+// claimCircuitProbe reports whether the auth may serve the model right now. When the circuit
+// is open and its delay has elapsed, the caller becomes the single half-open probe and the
+// model is blocked for everyone else until the probe resolves or OpenDuration passes again.
+// Callers must hold m.mu.
+func (m *Manager) claimCircuitProbe(authID, model string, now time.Time) bool {
+	if m.circuit == nil || model == "" {
+		return true
+	}
+	auth, ok := m.auths[authID]
+	if !ok || auth == nil {
+		return true
+	}
+	state, ok := auth.ModelStates[model]
+	if !ok || state == nil {
+		return true
+	}
+	switch state.Circuit.State {
+	case CircuitOpen, CircuitHalfOpen:
+	default:
+		return true
+	}
+	if state.NextRetryAfter.After(now) {
+		return false
+	}
+	state.Circuit.State = CircuitHalfOpen
+	state.Circuit.ProbeStartedAt = now
+	state.Unavailable = true
+	state.NextRetryAfter = now.Add(m.circuit.OpenDuration)
+	state.UpdatedAt = now
+	return true
+}
+
+// circuitAwaitingProbe reports whether the auth's circuit for model is open or half-open,
+// meaning a selection must go through claimCircuitProbe.
+func circuitAwaitingProbe(auth *Auth, model string) bool {
+	if auth == nil || model == "" {
+		return false
+	}
+	state, ok := auth.ModelStates[model]
+	if !ok || state == nil {
+		return false
+	}
+	return state.Circuit.State == CircuitOpen || state.Circuit.State == CircuitHalfOpen
+}
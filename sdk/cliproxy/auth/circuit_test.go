@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type circuitTestExecutor struct {
+	status int
+	calls  int
+}
+
+func (e *circuitTestExecutor) Identifier() string { return "circuit-test" }
+
+func (e *circuitTestExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.calls++
+	if e.status != 0 {
+		return cliproxyexecutor.Response{}, &Error{Code: "upstream", Message: "upstream failure", HTTPStatus: e.status}
+	}
+	return cliproxyexecutor.Response{Payload: []byte("ok")}, nil
+}
+
+func (e *circuitTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, &Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *circuitTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *circuitTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, &Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func circuitStateOf(t *testing.T, manager *Manager, authID, model string) ModelState {
+	t.Helper()
+	auth, ok := manager.GetByID(authID)
+	if !ok || auth.ModelStates[model] == nil {
+		t.Fatalf("missing model state for %s/%s", authID, model)
+	}
+	return *auth.ModelStates[model]
+}
+
+func TestManagerCircuitBreaker_OpenHalfOpenClose(t *testing.T) {
+	executor := &circuitTestExecutor{status: http.StatusBadGateway}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	manager.SetCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: 50 * time.Millisecond})
+	if _, err := manager.Register(context.Background(), &Auth{ID: "circuit-auth", Provider: executor.Identifier(), Status: StatusActive}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient("circuit-auth", executor.Identifier(), []*registry.ModelInfo{{ID: "circuit-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("circuit-auth") })
+
+	req := cliproxyexecutor.Request{Model: "circuit-model"}
+	providers := []string{executor.Identifier()}
+
+	// The first failure stays below the threshold, so the model remains selectable.
+	_, _ = manager.Execute(context.Background(), providers, req, cliproxyexecutor.Options{})
+	state := circuitStateOf(t, manager, "circuit-auth", "circuit-model")
+	if state.Circuit.State != CircuitClosed || state.Unavailable {
+		t.Fatalf("after first failure: circuit=%q unavailable=%v", state.Circuit.State, state.Unavailable)
+	}
+
+	_, _ = manager.Execute(context.Background(), providers, req, cliproxyexecutor.Options{})
+	state = circuitStateOf(t, manager, "circuit-auth", "circuit-model")
+	if state.Circuit.State != CircuitOpen {
+		t.Fatalf("after threshold: circuit=%q, want %q", state.Circuit.State, CircuitOpen)
+	}
+
+	// While open, no request reaches the executor.
+	if _, err := manager.Execute(context.Background(), providers, req, cliproxyexecutor.Options{}); err == nil {
+		t.Fatalf("expected error while circuit is open")
+	}
+	if executor.calls != 2 {
+		t.Fatalf("executor calls while open = %d, want 2", executor.calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	// Exactly one probe is admitted once the open delay elapses.
+	if _, _, _, err := manager.pickNext(context.Background(), executor.Identifier(), "circuit-model", cliproxyexecutor.Options{}, map[string]struct{}{}); err != nil {
+		t.Fatalf("probe pick error = %v", err)
+	}
+	if state = circuitStateOf(t, manager, "circuit-auth", "circuit-model"); state.Circuit.State != CircuitHalfOpen {
+		t.Fatalf("after probe pick: circuit=%q, want %q", state.Circuit.State, CircuitHalfOpen)
+	}
+	if _, _, _, err := manager.pickNext(context.Background(), executor.Identifier(), "circuit-model", cliproxyexecutor.Options{}, map[string]struct{}{}); err == nil {
+		t.Fatalf("expected second pick to be rejected while probe is in flight")
+	}
+
+	// A successful probe closes the circuit.
+	executor.status = 0
+	manager.MarkResult(context.Background(), Result{AuthID: "circuit-auth", Provider: executor.Identifier(), Model: "circuit-model", Success: true})
+	state = circuitStateOf(t, manager, "circuit-auth", "circuit-model")
+	if state.Circuit.State != CircuitClosed || state.Unavailable || state.Circuit.ConsecutiveFailures != 0 {
+		t.Fatalf("after probe success: circuit=%q unavailable=%v failures=%d", state.Circuit.State, state.Unavailable, state.Circuit.ConsecutiveFailures)
+	}
+	if _, err := manager.Execute(context.Background(), providers, req, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("Execute after close error = %v", err)
+	}
+}
+
+func TestRecordCircuitFailure_ErrorRate(t *testing.T) {
+	cfg := CircuitBreakerConfig{FailureThreshold: 100, ErrorRateThreshold: 0.5, MinRequests: 4}.normalized()
+	state := &ModelState{}
+	now := time.Now()
+	recordCircuitSuccess(state, &cfg, now)
+	recordCircuitSuccess(state, &cfg, now)
+	if recordCircuitFailure(state, &cfg, now) {
+		t.Fatalf("tripped before reaching min requests")
+	}
+	if !recordCircuitFailure(state, &cfg, now) {
+		t.Fatalf("expected trip at 50%% error rate over 4 requests")
+	}
+	if state.Circuit.State != CircuitOpen {
+		t.Fatalf("circuit=%q, want %q", state.Circuit.State, CircuitOpen)
+	}
+}
+
+func TestIsCircuitFailure(t *testing.T) {
+	if !isCircuitFailure(context.Background(), http.StatusServiceUnavailable) {
+		t.Fatalf("5xx must count towards the circuit")
+	}
+	if isCircuitFailure(context.Background(), http.StatusBadRequest) || isCircuitFailure(context.Background(), http.StatusTooManyRequests) {
+		t.Fatalf("client and quota errors must not count towards the circuit")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if isCircuitFailure(ctx, 0) {
+		t.Fatalf("cancelled requests must not count towards the circuit")
+	}
+}
+
+func TestCircuitState_OmitsZeroTimes(t *testing.T) {
+	data, err := json.Marshal(ModelState{Status: StatusActive})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(data), "circuit") {
+		t.Fatalf("expected a closed circuit to be omitted, got %s", data)
+	}
+	data, err = json.Marshal(CircuitState{ConsecutiveFailures: 1})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(data) != `{"consecutive_failures":1}` {
+		t.Fatalf("expected zero timestamps to be omitted, got %s", data)
+	}
+}
@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrencyQueueTimeout bounds how long a request waits for a free credential slot.
+const DefaultConcurrencyQueueTimeout = 30 * time.Second
+
+// ConcurrencyStats reports the in-flight requests of a concurrency-limited credential.
+type ConcurrencyStats struct {
+	// Key identifies the slot pool; virtual Gemini project auths share their parent's pool.
+	Key string `json:"key"`
+	// InFlight is the number of requests currently holding a slot.
+	InFlight int `json:"in_flight"`
+	// Waiting is the number of queued requests that could be served by this pool.
+	Waiting int `json:"waiting"`
+}
+
+// ConcurrencySnapshot summarises slot usage across all credentials.
+type ConcurrencySnapshot struct {
+	// QueueDepth is the number of requests waiting for any slot.
+	QueueDepth int `json:"queue_depth"`
+	// Pools lists per-credential usage ordered by key.
+	Pools []ConcurrencyStats `json:"pools"`
+}
+
+// concurrencyLimiter enforces per-credential max-concurrency. When every eligible credential is
+// saturated, requests wait in a single FIFO queue and freed slots are handed to the oldest waiter
+// that can use them, so newcomers cannot overtake queued requests.
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+	queue    []*concurrencyWaiter
+	timeout  time.Duration
+}
+
+type concurrencyWaiter struct {
+	keys  map[string]struct{}
+	ready chan string
+}
+
+func newConcurrencyLimiter() *concurrencyLimiter {
+	return &concurrencyLimiter{inFlight: make(map[string]int), timeout: DefaultConcurrencyQueueTimeout}
+}
+
+// SetConcurrencyQueueTimeout sets how long requests wait for a credential slot once every
+// eligible credential is at max-concurrency. Values <= 0 use DefaultConcurrencyQueueTimeout.
+func (m *Manager) SetConcurrencyQueueTimeout(timeout time.Duration) {
+	if m == nil || m.concurrency == nil {
+		return
+	}
+	if timeout <= 0 {
+		timeout = DefaultConcurrencyQueueTimeout
+	}
+	m.concurrency.mu.Lock()
+	m.concurrency.timeout = timeout
+	m.concurrency.mu.Unlock()
+}
+
+// ConcurrencyStats returns current slot usage and queue depth.
+func (m *Manager) ConcurrencyStats() ConcurrencySnapshot {
+	if m == nil || m.concurrency == nil {
+		return ConcurrencySnapshot{}
+	}
+	return m.concurrency.snapshot()
+}
+
+// authMaxConcurrency reads the "max_concurrency" attribute; zero means unlimited.
+func authMaxConcurrency(auth *Auth) int {
+	if auth == nil || auth.Attributes == nil {
+		return 0
+	}
+	raw := strings.TrimSpace(auth.Attributes["max_concurrency"])
+	if raw == "" {
+		return 0
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// concurrencyKey returns the slot pool for the auth. Virtual Gemini project auths share the
+// pool of the account they were derived from.
+func concurrencyKey(auth *Auth) string {
+	if auth == nil {
+		return ""
+	}
+	if parent := strings.TrimSpace(auth.Attributes["gemini_virtual_parent"]); parent != "" {
+		return parent
+	}
+	return auth.ID
+}
+
+// saturated reports whether the auth has no free slot.
+func (l *concurrencyLimiter) saturated(auth *Auth) bool {
+	limit := authMaxConcurrency(auth)
+	if l == nil || limit == 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight[concurrencyKey(auth)] >= limit
+}
+
+// tryAcquire takes a slot for the auth and reports whether one was free.
+func (l *concurrencyLimiter) tryAcquire(auth *Auth) bool {
+	limit := authMaxConcurrency(auth)
+	if l == nil || limit == 0 {
+		return true
+	}
+	key := concurrencyKey(auth)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[key] >= limit {
+		return false
+	}
+	l.inFlight[key]++
+	return true
+}
+
+// release frees the slot held for the auth.
+func (l *concurrencyLimiter) release(auth *Auth) {
+	if l == nil || authMaxConcurrency(auth) == 0 {
+		return
+	}
+	l.releaseKey(concurrencyKey(auth))
+}
+
+// releaseKey frees a slot of the pool, handing it directly to the oldest waiter that wants it.
+func (l *concurrencyLimiter) releaseKey(key string) {
+	if l == nil || key == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, waiter := range l.queue {
+		if _, ok := waiter.keys[key]; !ok {
+			continue
+		}
+		l.queue = append(l.queue[:i], l.queue[i+1:]...)
+		waiter.ready <- key
+		return
+	}
+	if l.inFlight[key] <= 1 {
+		delete(l.inFlight, key)
+		return
+	}
+	l.inFlight[key]--
+}
+
+// wait queues the caller until a slot of one of keys is handed over, returning the reserved key.
+func (l *concurrencyLimiter) wait(ctx context.Context, keys map[string]struct{}, deadline time.Time) (string, error) {
+	waiter := &concurrencyWaiter{keys: keys, ready: make(chan string, 1)}
+	l.mu.Lock()
+	l.queue = append(l.queue, waiter)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	var errWait error
+	select {
+	case key := <-waiter.ready:
+		return key, nil
+	case <-timer.C:
+		errWait = &Error{Code: "concurrency_limit", Message: "all credentials are at max concurrency", Retryable: true, HTTPStatus: http.StatusTooManyRequests}
+	case <-ctx.Done():
+		errWait = ctx.Err()
+	}
+
+	l.mu.Lock()
+	for i, queued := range l.queue {
+		if queued == waiter {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			break
+		}
+	}
+	l.mu.Unlock()
+	// A slot may have been handed over while giving up; pass it on.
+	select {
+	case key := <-waiter.ready:
+		l.releaseKey(key)
+	default:
+	}
+	return "", errWait
+}
+
+func (l *concurrencyLimiter) queueTimeout() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.timeout
+}
+
+func (l *concurrencyLimiter) snapshot() ConcurrencySnapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := ConcurrencySnapshot{QueueDepth: len(l.queue)}
+	waiting := make(map[string]int)
+	for _, waiter := range l.queue {
+		for key := range waiter.keys {
+			waiting[key]++
+		}
+	}
+	keys := make(map[string]struct{}, len(l.inFlight)+len(waiting))
+	for key := range l.inFlight {
+		keys[key] = struct{}{}
+	}
+	for key := range waiting {
+		keys[key] = struct{}{}
+	}
+	for key := range keys {
+		out.Pools = append(out.Pools, ConcurrencyStats{Key: key, InFlight: l.inFlight[key], Waiting: waiting[key]})
+	}
+	sort.Slice(out.Pools, func(i, j int) bool { return out.Pools[i].Key < out.Pools[j].Key })
+	return out
+}
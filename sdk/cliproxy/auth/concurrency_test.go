@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type blockingTestExecutor struct {
+	provider string
+	started  chan string
+	release  chan struct{}
+}
+
+func (e *blockingTestExecutor) Identifier() string { return e.provider }
+
+func (e *blockingTestExecutor) Execute(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.started <- auth.ID
+	select {
+	case <-e.release:
+	case <-ctx.Done():
+		return cliproxyexecutor.Response{}, ctx.Err()
+	}
+	return cliproxyexecutor.Response{Payload: []byte(auth.ID)}, nil
+}
+
+func (e *blockingTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, &Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *blockingTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	return auth, nil
+}
+
+func (e *blockingTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, &Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func registerLimitedTestAuth(t *testing.T, manager *Manager, id, provider, model string, limit string) {
+	t.Helper()
+	auth := &Auth{ID: id, Provider: provider, Status: StatusActive, Attributes: map[string]string{"max_concurrency": limit}}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register(%s): %v", id, err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(id, provider, []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(id) })
+}
+
+func TestManagerConcurrency_SkipsSaturatedAuth(t *testing.T) {
+	executor := &blockingTestExecutor{provider: "concurrency-skip", started: make(chan string, 2), release: make(chan struct{})}
+	manager := NewManager(nil, &FillFirstSelector{}, nil)
+	manager.RegisterExecutor(executor)
+	registerLimitedTestAuth(t, manager, "concurrency-skip-a", executor.provider, "concurrency-model", "1")
+	registerLimitedTestAuth(t, manager, "concurrency-skip-b", executor.provider, "concurrency-model", "1")
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := manager.Execute(context.Background(), []string{executor.provider}, cliproxyexecutor.Request{Model: "concurrency-model"}, cliproxyexecutor.Options{})
+			errs <- err
+		}()
+	}
+	seen := map[string]bool{<-executor.started: true, <-executor.started: true}
+	if !seen["concurrency-skip-a"] || !seen["concurrency-skip-b"] {
+		t.Fatalf("saturated auth was not skipped: %v", seen)
+	}
+	close(executor.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	}
+	if stats := manager.ConcurrencyStats(); stats.QueueDepth != 0 || len(stats.Pools) != 0 {
+		t.Fatalf("slots leaked: %+v", stats)
+	}
+}
+
+func TestManagerConcurrency_QueuesUntilSlotFrees(t *testing.T) {
+	executor := &blockingTestExecutor{provider: "concurrency-queue", started: make(chan string, 2), release: make(chan struct{}, 2)}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	registerLimitedTestAuth(t, manager, "concurrency-queue-auth", executor.provider, "concurrency-model", "1")
+
+	errs := make(chan error, 2)
+	run := func() {
+		_, err := manager.Execute(context.Background(), []string{executor.provider}, cliproxyexecutor.Request{Model: "concurrency-model"}, cliproxyexecutor.Options{})
+		errs <- err
+	}
+	go run()
+	<-executor.started
+	go run()
+
+	deadline := time.Now().Add(time.Second)
+	for manager.ConcurrencyStats().QueueDepth != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("second request did not queue: %+v", manager.ConcurrencyStats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	stats := manager.ConcurrencyStats()
+	if len(stats.Pools) != 1 || stats.Pools[0].InFlight != 1 || stats.Pools[0].Waiting != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	executor.release <- struct{}{}
+	<-executor.started
+	executor.release <- struct{}{}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	}
+}
+
+func TestManagerConcurrency_QueueTimeout(t *testing.T) {
+	executor := &blockingTestExecutor{provider: "concurrency-timeout", started: make(chan string, 1), release: make(chan struct{})}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	manager.SetConcurrencyQueueTimeout(30 * time.Millisecond)
+	registerLimitedTestAuth(t, manager, "concurrency-timeout-auth", executor.provider, "concurrency-model", "1")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := manager.Execute(context.Background(), []string{executor.provider}, cliproxyexecutor.Request{Model: "concurrency-model"}, cliproxyexecutor.Options{})
+		done <- err
+	}()
+	<-executor.started
+
+	_, err := manager.Execute(context.Background(), []string{executor.provider}, cliproxyexecutor.Request{Model: "concurrency-model"}, cliproxyexecutor.Options{})
+	var authErr *Error
+	if !errors.As(err, &authErr) || authErr.HTTPStatus != http.StatusTooManyRequests {
+		t.Fatalf("Execute() error = %v, want concurrency limit 429", err)
+	}
+	close(executor.release)
+	if err := <-done; err != nil {
+		t.Fatalf("first Execute() error = %v", err)
+	}
+}
+
+type streamingTestExecutor struct {
+	blockingTestExecutor
+}
+
+// ExecuteStream produces chunks until the stream is exhausted, without watching the context.
+func (e *streamingTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	chunks := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(chunks)
+		for i := 0; i < 8; i++ {
+			chunks <- cliproxyexecutor.StreamChunk{Payload: []byte("chunk")}
+		}
+	}()
+	return chunks, nil
+}
+
+// resultHook forwards every execution result to a channel.
+type resultHook struct {
+	NoopHook
+	results chan Result
+}
+
+func (h *resultHook) OnResult(_ context.Context, result Result) { h.results <- result }
+
+func TestManagerConcurrency_StreamCancelReleasesSlot(t *testing.T) {
+	executor := &streamingTestExecutor{blockingTestExecutor{provider: "concurrency-stream"}}
+	hook := &resultHook{results: make(chan Result, 4)}
+	manager := NewManager(nil, &FillFirstSelector{}, hook)
+	manager.RegisterExecutor(executor)
+	registerLimitedTestAuth(t, manager, "concurrency-stream-a", executor.provider, "concurrency-model", "1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks, err := manager.ExecuteStream(ctx, []string{executor.provider}, cliproxyexecutor.Request{Model: "concurrency-model"}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("ExecuteStream() error = %v", err)
+	}
+	<-chunks
+	// The client disconnects and stops reading mid-stream.
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, inFlight := manager.latency.load("concurrency-stream-a", "concurrency-model", time.Now())
+		if stats := manager.ConcurrencyStats(); len(stats.Pools) == 0 && inFlight == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("slot not released after the stream was cancelled: %+v", manager.ConcurrencyStats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case result := <-hook.results:
+		if result.Success || result.Error == nil || result.Error.Message != context.Canceled.Error() {
+			t.Fatalf("expected a canceled result, got %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no result recorded for the cancelled stream")
+	}
+	auth, _ := manager.GetByID("concurrency-stream-a")
+	if blocked, _, _ := isAuthBlockedForModel(auth, "concurrency-model", time.Now()); blocked {
+		t.Fatalf("expected the cancelled stream not to block the credential")
+	}
+}
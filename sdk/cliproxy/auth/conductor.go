@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -33,6 +34,12 @@ type ProviderExecutor interface {
 	CountTokens(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
 }
 
+// EmbeddingExecutor is implemented by provider executors able to compute embeddings.
+type EmbeddingExecutor interface {
+	// Embed returns the embeddings of the request inputs in the source format of the request.
+	Embed(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+}
+
 // RefreshEvaluator allows runtime state to override refresh decisions.
 type RefreshEvaluator interface {
 	ShouldRefresh(now time.Time, auth *Auth) bool
@@ -106,6 +113,28 @@ type Manager struct {
 	auths     map[string]*Auth
 	// providerOffsets tracks per-model provider rotation state for multi-provider routing.
 	providerOffsets map[string]int
+	// latency tracks per auth/model in-flight counts and latency averages.
+	latency *latencyTracker
+	// modelFallbacks maps lower-cased models to ordered fallback models.
+	modelFallbacks map[string][]string
+	// affinity binds conversations to credentials for prompt-cache reuse.
+	affinity *sessionAffinity
+	// circuit holds circuit breaker settings; nil disables the breaker.
+	circuit *CircuitBreakerConfig
+	// probes holds background health probe settings and history.
+	probes *healthProber
+	// concurrency enforces per-credential max-concurrency and queues saturated requests.
+	concurrency *concurrencyLimiter
+	// hedging holds hedged request settings; nil disables hedging.
+	hedging *HedgingConfig
+	// savedStates fingerprints the runtime state last written to a StateStore, keyed by auth ID.
+	savedStates map[string]string
+	// pendingStates holds restored runtime state for auths that are registered after Load.
+	pendingStates map[string]*RuntimeState
+	// stateWriter persists runtime state changes in the background.
+	stateWriter *stateWriter
+	// stateSeq orders queued runtime state changes.
+	stateSeq uint64
 
 	// Retry controls request retry behavior.
 	requestRetry     atomic.Int32
@@ -126,6 +155,10 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 	if hook == nil {
 		hook = NoopHook{}
 	}
+	tracker := newLatencyTracker()
+	if aware, ok := selector.(latencyAwareSelector); ok {
+		aware.bindLatencyTracker(tracker)
+	}
 	return &Manager{
 		store:           store,
 		executors:       make(map[string]ProviderExecutor),
@@ -133,6 +166,11 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 		hook:            hook,
 		auths:           make(map[string]*Auth),
 		providerOffsets: make(map[string]int),
+		latency:         tracker,
+		affinity:        newSessionAffinity(),
+		concurrency:     newConcurrencyLimiter(),
+		probes:          newHealthProber(),
+		stateWriter:     newStateWriter(),
 	}
 }
 
@@ -143,11 +181,22 @@ func (m *Manager) SetSelector(selector Selector) {
 	if selector == nil {
 		selector = &RoundRobinSelector{}
 	}
+	if aware, ok := selector.(latencyAwareSelector); ok {
+		aware.bindLatencyTracker(m.latency)
+	}
 	m.mu.Lock()
 	m.selector = selector
 	m.mu.Unlock()
 }
 
+// ExecutionStats returns the latency and in-flight statistics tracked for the auth, one entry per model.
+func (m *Manager) ExecutionStats(authID string) []ExecutionStats {
+	if m == nil {
+		return nil
+	}
+	return m.latency.snapshot(authID)
+}
+
 // SetStore swaps the underlying persistence store.
 func (m *Manager) SetStore(store Store) {
 	m.mu.Lock()
@@ -208,8 +257,15 @@ func (m *Manager) Register(ctx context.Context, auth *Auth) (*Auth, error) {
 	}
 	auth.EnsureIndex()
 	m.mu.Lock()
+	if pending, ok := m.pendingStates[auth.ID]; ok {
+		delete(m.pendingStates, auth.ID)
+		if len(auth.ModelStates) == 0 && !auth.Unavailable {
+			applyRuntimeState(auth, pending, time.Now())
+		}
+	}
 	m.auths[auth.ID] = auth.Clone()
 	m.mu.Unlock()
+	m.forgetAuthStats(auth.ID)
 	_ = m.persist(ctx, auth)
 	m.hook.OnAuthRegistered(ctx, auth.Clone())
 	return auth.Clone(), nil
@@ -221,18 +277,35 @@ func (m *Manager) Update(ctx context.Context, auth *Auth) (*Auth, error) {
 		return nil, nil
 	}
 	m.mu.Lock()
-	if existing, ok := m.auths[auth.ID]; ok && existing != nil && !auth.indexAssigned && auth.Index == "" {
-		auth.Index = existing.Index
-		auth.indexAssigned = existing.indexAssigned
+	removed := auth.Disabled
+	if existing, ok := m.auths[auth.ID]; ok && existing != nil {
+		removed = removed && !existing.Disabled
+		if !auth.indexAssigned && auth.Index == "" {
+			auth.Index = existing.Index
+			auth.indexAssigned = existing.indexAssigned
+		}
+		// Replacements synthesized from config or auth files carry no runtime state;
+		// keep existing cooldowns so a reload does not hammer rate-limited accounts.
+		inheritRuntimeState(auth, existing, time.Now())
 	}
 	auth.EnsureIndex()
 	m.auths[auth.ID] = auth.Clone()
 	m.mu.Unlock()
+	if removed {
+		m.forgetAuthStats(auth.ID)
+	}
 	_ = m.persist(ctx, auth)
 	m.hook.OnAuthUpdated(ctx, auth.Clone())
 	return auth.Clone(), nil
 }
 
+// forgetAuthStats drops the execution statistics and probe history of an auth that was disabled
+// or registered anew, so a replacement credential does not inherit those of the previous one.
+func (m *Manager) forgetAuthStats(id string) {
+	m.latency.remove(id)
+	m.probes.forget(id)
+}
+
 // Load resets manager state from the backing store.
 func (m *Manager) Load(ctx context.Context) error {
 	m.mu.Lock()
@@ -252,12 +325,80 @@ func (m *Manager) Load(ctx context.Context) error {
 		auth.EnsureIndex()
 		m.auths[auth.ID] = auth.Clone()
 	}
+	m.restoreRuntimeStates(ctx)
 	return nil
 }
 
+// restoreRuntimeStates applies persisted runtime state to loaded auths. States for auths that
+// are not in the store (for example config API keys) are kept until those auths register.
+// Callers must hold m.mu.
+func (m *Manager) restoreRuntimeStates(ctx context.Context) {
+	m.savedStates = make(map[string]string)
+	m.pendingStates = make(map[string]*RuntimeState)
+	stateStore, ok := m.store.(StateStore)
+	if !ok {
+		return
+	}
+	states, err := stateStore.LoadStates(ctx)
+	if err != nil {
+		log.Warnf("failed to load auth runtime state: %v", err)
+		return
+	}
+	now := time.Now()
+	for id, state := range states {
+		if state == nil {
+			continue
+		}
+		auth, exists := m.auths[id]
+		if !exists {
+			m.pendingStates[id] = state
+			m.savedStates[id] = stateFingerprint(stripStateTimestamp(state))
+			continue
+		}
+		applyRuntimeState(auth, state, now)
+		if current := snapshotRuntimeState(auth); current == nil {
+			// Every cooldown expired while the proxy was down.
+			if errSave := stateStore.SaveState(ctx, id, nil); errSave != nil {
+				log.Warnf("failed to prune runtime state for auth %s: %v", id, errSave)
+			}
+			continue
+		}
+		m.savedStates[id] = stateFingerprint(stripStateTimestamp(state))
+	}
+}
+
 // Execute performs a non-streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// When every attempt fails, the configured model fallback chain is tried in order.
 func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	ctx = withAttemptTrace(ctx)
+	resp, err := m.executeModel(ctx, providers, req, opts)
+	if err == nil {
+		recordServedModel(ctx, "")
+		return resp, err
+	}
+	if !fallbackEligible(ctx, err) {
+		return resp, err
+	}
+	for _, fallback := range m.fallbackChain(req.Model) {
+		fbProviders, fbReq, fbOpts, ok := prepareFallback(fallback, req, opts)
+		if !ok {
+			continue
+		}
+		logEntryWithRequestID(ctx).Debugf("model %s unavailable, falling back to %s", req.Model, fbReq.Model)
+		fbResp, errFallback := m.executeModel(ctx, fbProviders, fbReq, fbOpts)
+		if errFallback == nil {
+			recordServedModel(ctx, fbReq.Model)
+			return fbResp, nil
+		}
+		if !fallbackEligible(ctx, errFallback) {
+			break
+		}
+	}
+	return resp, err
+}
+
+func (m *Manager) executeModel(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -274,7 +415,7 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 	var lastErr error
 	for attempt := 0; attempt < attempts; attempt++ {
 		resp, errExec := m.executeProvidersOnce(ctx, rotated, func(execCtx context.Context, provider string) (cliproxyexecutor.Response, error) {
-			return m.executeWithProvider(execCtx, provider, req, opts)
+			return m.executeWithProvider(execCtx, provider, rotated, req, opts)
 		})
 		if errExec == nil {
 			return resp, nil
@@ -297,6 +438,28 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 // ExecuteCount performs a non-streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
 func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return m.executeUnary(ctx, providers, req, opts, func(ctx context.Context, executor ProviderExecutor, auth *Auth, req cliproxyexecutor.Request) (cliproxyexecutor.Response, error) {
+		return executor.CountTokens(ctx, auth, req, opts)
+	})
+}
+
+// ExecuteEmbed computes embeddings with the executors implementing EmbeddingExecutor, using the
+// same selection and retry rules as ExecuteCount.
+func (m *Manager) ExecuteEmbed(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return m.executeUnary(ctx, providers, req, opts, func(ctx context.Context, executor ProviderExecutor, auth *Auth, req cliproxyexecutor.Request) (cliproxyexecutor.Response, error) {
+		embedder, ok := executor.(EmbeddingExecutor)
+		if !ok {
+			return cliproxyexecutor.Response{}, &Error{Code: "not_supported", Message: fmt.Sprintf("provider %s does not support embeddings", executor.Identifier()), HTTPStatus: http.StatusBadRequest}
+		}
+		return embedder.Embed(ctx, auth, req, opts)
+	})
+}
+
+// unaryCall runs one non-streaming, non-generating call on the executor selected for an attempt.
+type unaryCall func(ctx context.Context, executor ProviderExecutor, auth *Auth, req cliproxyexecutor.Request) (cliproxyexecutor.Response, error)
+
+func (m *Manager) executeUnary(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, call unaryCall) (cliproxyexecutor.Response, error) {
+	ctx = withAttemptTrace(ctx)
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -313,7 +476,7 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 	var lastErr error
 	for attempt := 0; attempt < attempts; attempt++ {
 		resp, errExec := m.executeProvidersOnce(ctx, rotated, func(execCtx context.Context, provider string) (cliproxyexecutor.Response, error) {
-			return m.executeCountWithProvider(execCtx, provider, req, opts)
+			return m.executeUnaryWithProvider(execCtx, provider, req, opts, call)
 		})
 		if errExec == nil {
 			return resp, nil
@@ -335,7 +498,36 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 
 // ExecuteStream performs a streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// When the stream cannot be established, the configured model fallback chain is tried in order.
 func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	ctx = withAttemptTrace(ctx)
+	chunks, err := m.executeStreamModel(ctx, providers, req, opts)
+	if err == nil {
+		recordServedModel(ctx, "")
+		return chunks, err
+	}
+	if !fallbackEligible(ctx, err) {
+		return chunks, err
+	}
+	for _, fallback := range m.fallbackChain(req.Model) {
+		fbProviders, fbReq, fbOpts, ok := prepareFallback(fallback, req, opts)
+		if !ok {
+			continue
+		}
+		logEntryWithRequestID(ctx).Debugf("model %s unavailable, falling back to %s", req.Model, fbReq.Model)
+		fbChunks, errFallback := m.executeStreamModel(ctx, fbProviders, fbReq, fbOpts)
+		if errFallback == nil {
+			recordServedModel(ctx, fbReq.Model)
+			return fbChunks, nil
+		}
+		if !fallbackEligible(ctx, errFallback) {
+			break
+		}
+	}
+	return chunks, err
+}
+
+func (m *Manager) executeStreamModel(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return nil, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -372,7 +564,10 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
 
-func (m *Manager) executeWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+// executeWithProvider executes the request on credentials of provider until one succeeds.
+// For models that opt into hedging, a slow attempt is raced against another credential drawn
+// from provider first and then from the remaining providers.
+func (m *Manager) executeWithProvider(ctx context.Context, provider string, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	if provider == "" {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
@@ -380,7 +575,7 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 	tried := make(map[string]struct{})
 	var lastErr error
 	for {
-		auth, executor, errPick := m.pickNext(ctx, provider, routeModel, opts, tried)
+		auth, executor, affinity, errPick := m.pickNext(ctx, provider, routeModel, opts, tried)
 		if errPick != nil {
 			if lastErr != nil {
 				return cliproxyexecutor.Response{}, lastErr
@@ -406,34 +601,26 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 		}
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
-		if rt := m.roundTripperFor(auth); rt != nil {
-			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
-			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+		candidate := attemptCandidate{provider: provider, auth: auth, executor: executor, affinity: affinity}
+		if delay, ok := m.hedgeDelay(routeModel); ok {
+			resp, errExec := m.executeHedged(ctx, candidate, providers, routeModel, req, opts, tried, delay)
+			if errExec != nil {
+				lastErr = errExec
+				continue
+			}
+			return resp, nil
 		}
-		execReq := req
-		execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
-		resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
-		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
+		resp, result, execCtx, errExec := m.executeAttempt(ctx, candidate, routeModel, req, opts)
+		m.MarkResult(execCtx, result)
 		if errExec != nil {
-			result.Error = &Error{Message: errExec.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errExec, &se) && se != nil {
-				result.Error.HTTPStatus = se.StatusCode()
-			}
-			if ra := retryAfterFromError(errExec); ra != nil {
-				result.RetryAfter = ra
-			}
-			m.MarkResult(execCtx, result)
 			lastErr = errExec
 			continue
 		}
-		m.MarkResult(execCtx, result)
 		return resp, nil
 	}
 }
 
-func (m *Manager) executeCountWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+func (m *Manager) executeUnaryWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, call unaryCall) (cliproxyexecutor.Response, error) {
 	if provider == "" {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
@@ -441,7 +628,7 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 	tried := make(map[string]struct{})
 	var lastErr error
 	for {
-		auth, executor, errPick := m.pickNext(ctx, provider, routeModel, opts, tried)
+		auth, executor, affinity, errPick := m.pickNext(ctx, provider, routeModel, opts, tried)
 		if errPick != nil {
 			if lastErr != nil {
 				return cliproxyexecutor.Response{}, lastErr
@@ -467,14 +654,15 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 		}
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx, span := startAttemptSpan(withSessionAffinity(ctx, affinity), provider, auth, routeModel)
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
 		execReq := req
 		execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
-		resp, errExec := executor.CountTokens(execCtx, auth, execReq, opts)
+		resp, errExec := call(execCtx, executor, auth, execReq)
+		m.concurrency.release(auth)
 		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 		if errExec != nil {
 			result.Error = &Error{Message: errExec.Error()}
@@ -485,10 +673,12 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 			if ra := retryAfterFromError(errExec); ra != nil {
 				result.RetryAfter = ra
 			}
+			endAttemptSpan(execCtx, span, result)
 			m.MarkResult(execCtx, result)
 			lastErr = errExec
 			continue
 		}
+		endAttemptSpan(execCtx, span, result)
 		m.MarkResult(execCtx, result)
 		return resp, nil
 	}
@@ -502,7 +692,7 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 	tried := make(map[string]struct{})
 	var lastErr error
 	for {
-		auth, executor, errPick := m.pickNext(ctx, provider, routeModel, opts, tried)
+		auth, executor, affinity, errPick := m.pickNext(ctx, provider, routeModel, opts, tried)
 		if errPick != nil {
 			if lastErr != nil {
 				return nil, lastErr
@@ -528,15 +718,22 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 		}
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx, span := startAttemptSpan(withSessionAffinity(ctx, affinity), provider, auth, routeModel)
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
 		execReq := req
 		execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
+		m.latency.acquire(auth.ID, routeModel)
+		started := time.Now()
 		chunks, errStream := executor.ExecuteStream(execCtx, auth, execReq, opts)
 		if errStream != nil {
+			if execCtx.Err() == nil {
+				m.latency.observeFailure(auth.ID, routeModel, time.Since(started))
+			}
+			m.latency.release(auth.ID, routeModel)
+			m.concurrency.release(auth)
 			rerr := &Error{Message: errStream.Error()}
 			var se cliproxyexecutor.StatusError
 			if errors.As(errStream, &se) && se != nil {
@@ -544,6 +741,7 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 			}
 			result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: false, Error: rerr}
 			result.RetryAfter = retryAfterFromError(errStream)
+			endAttemptSpan(execCtx, span, result)
 			m.MarkResult(execCtx, result)
 			lastErr = errStream
 			continue
@@ -551,23 +749,54 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 		out := make(chan cliproxyexecutor.StreamChunk)
 		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
 			defer close(out)
-			var failed bool
+			defer m.latency.release(streamAuth.ID, routeModel)
+			defer m.concurrency.release(streamAuth)
+			var failed, firstByte bool
 			for chunk := range streamChunks {
+				if !firstByte && chunk.Err == nil && len(chunk.Payload) > 0 {
+					firstByte = true
+					m.latency.observe(streamAuth.ID, routeModel, time.Since(started))
+				}
 				if chunk.Err != nil && !failed {
 					failed = true
+					if streamCtx.Err() == nil {
+						m.latency.observeFailure(streamAuth.ID, routeModel, time.Since(started))
+					}
 					rerr := &Error{Message: chunk.Err.Error()}
 					var se cliproxyexecutor.StatusError
 					if errors.As(chunk.Err, &se) && se != nil {
 						rerr.HTTPStatus = se.StatusCode()
 					}
-					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: false, Error: rerr})
+					result := Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: false, Error: rerr}
+					endAttemptSpan(streamCtx, span, result)
+					m.MarkResult(streamCtx, result)
+				}
+				select {
+				case out <- chunk:
+				case <-streamCtx.Done():
+					// The client went away; drain the upstream so the executor can exit and
+					// release the slot held by this stream.
+					go func() {
+						for range streamChunks {
+						}
+					}()
+					if !failed {
+						// Recorded like a canceled unary attempt: the canceled context keeps it
+						// out of the circuit breaker and the cooldowns.
+						result := Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: false, Error: &Error{Message: streamCtx.Err().Error()}}
+						endAttemptSpan(streamCtx, span, result)
+						m.MarkResult(streamCtx, result)
+					}
+					return
 				}
-				out <- chunk
 			}
 			if !failed {
-				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: true})
+				result := Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: true}
+				endAttemptSpan(streamCtx, span, result)
+				m.MarkResult(streamCtx, result)
 			}
 		}(execCtx, auth.Clone(), provider, chunks)
+		recordStreamAuth(ctx, auth.ID)
 		return out, nil
 	}
 }
@@ -803,6 +1032,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	suspendReason := ""
 	clearModelQuota := false
 	setModelQuota := false
+	var saveState func()
 
 	m.mu.Lock()
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
@@ -812,6 +1042,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 			if result.Model != "" {
 				state := ensureModelState(auth, result.Model)
 				resetModelState(state, now)
+				recordCircuitSuccess(state, m.circuit, now)
 				updateAggregatedAvailability(auth, now)
 				if !hasModelError(auth, now) {
 					auth.LastError = nil
@@ -882,6 +1113,17 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 				default:
 					state.NextRetryAfter = time.Time{}
 				}
+				if m.circuit != nil && isCircuitFailure(ctx, statusCode) {
+					// The breaker replaces the fixed transient cooldown: the model stays available
+					// until the breaker trips, then is blocked until the half-open probe.
+					if recordCircuitFailure(state, m.circuit, now) {
+						state.NextRetryAfter = now.Add(m.circuit.OpenDuration)
+						state.StatusMessage = "circuit open: " + state.StatusMessage
+					} else {
+						state.Unavailable = false
+						state.NextRetryAfter = time.Time{}
+					}
+				}
 
 				auth.Status = StatusError
 				auth.UpdatedAt = now
@@ -892,8 +1134,12 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 		}
 
 		_ = m.persist(ctx, auth)
+		saveState = m.prepareStateSave(ctx, auth)
 	}
 	m.mu.Unlock()
+	if saveState != nil {
+		saveState()
+	}
 
 	if clearModelQuota && result.Model != "" {
 		registry.GetGlobalRegistry().ClearModelQuotaExceeded(result.AuthID, result.Model)
@@ -1181,16 +1427,54 @@ func (m *Manager) GetByID(id string) (*Auth, bool) {
 	return auth.Clone(), true
 }
 
-func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (*Auth, ProviderExecutor, error) {
+// pickNext selects an auth and claims one of its concurrency slots. The caller must release the
+// slot with m.concurrency.release once the request finishes. When every eligible auth is at its
+// max-concurrency, the call waits in the FIFO queue until a slot frees up or the queue timeout passes.
+// The returned affinity outcome is empty unless the request carries a session key and session
+// affinity is enabled.
+func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (*Auth, ProviderExecutor, string, error) {
+	var deadline time.Time
+	reserved := ""
+	for {
+		auth, executor, affinity, saturated, errPick := m.pickCandidate(ctx, provider, model, opts, tried, reserved)
+		if errPick == nil {
+			return auth, executor, affinity, nil
+		}
+		if reserved != "" {
+			m.concurrency.releaseKey(reserved)
+			reserved = ""
+		}
+		if len(saturated) == 0 {
+			return nil, nil, "", errPick
+		}
+		if deadline.IsZero() {
+			deadline = time.Now().Add(m.concurrency.queueTimeout())
+		}
+		key, errWait := m.concurrency.wait(ctx, saturated, deadline)
+		if errWait != nil {
+			return nil, nil, "", errWait
+		}
+		reserved = key
+	}
+}
+
+// pickCandidate performs a single selection without queueing. reserved names a slot pool whose
+// slot the caller already holds; it is consumed when the selected auth belongs to it and released
+// otherwise. On failure it returns the pools of auths that were skipped only for being saturated.
+func (m *Manager) pickCandidate(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, tried map[string]struct{}, reserved string) (*Auth, ProviderExecutor, string, map[string]struct{}, error) {
 	m.mu.RLock()
 	executor, okExecutor := m.executors[provider]
 	if !okExecutor {
 		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "executor_not_found", Message: "executor not registered"}
+		return nil, nil, "", nil, &Error{Code: "executor_not_found", Message: "executor not registered"}
 	}
 	candidates := make([]*Auth, 0, len(m.auths))
+	var saturated map[string]struct{}
 	modelKey := strings.TrimSpace(model)
 	registryRef := registry.GetGlobalRegistry()
+	excluded := excludedAuths(opts.Metadata)
+	policy := accessPolicy(opts.Metadata)
+	denied := false
 	for _, candidate := range m.auths {
 		if candidate.Provider != provider || candidate.Disabled {
 			continue
@@ -1198,35 +1482,83 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		if _, used := tried[candidate.ID]; used {
 			continue
 		}
+		if _, skip := excluded[candidate.ID]; skip {
+			continue
+		}
 		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
 			continue
 		}
+		if !policy.AllowsPrefix(candidate.Prefix) {
+			denied = true
+			continue
+		}
+		if key := concurrencyKey(candidate); key != reserved && m.concurrency.saturated(candidate) {
+			if saturated == nil {
+				saturated = make(map[string]struct{})
+			}
+			saturated[key] = struct{}{}
+			continue
+		}
 		candidates = append(candidates, candidate)
 	}
 	if len(candidates) == 0 {
 		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
-	}
-	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
-	if errPick != nil {
-		m.mu.RUnlock()
-		return nil, nil, errPick
+		if denied && len(saturated) == 0 {
+			return nil, nil, "", nil, &Error{Code: "auth_not_allowed", Message: "no credential available to this client key", HTTPStatus: http.StatusForbidden}
+		}
+		return nil, nil, "", saturated, &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
-	if selected == nil {
-		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "auth_not_found", Message: "selector returned no auth"}
+	now := time.Now()
+	affinityKey := m.affinity.bindingKey(opts.Metadata, provider, modelKey)
+	affinity := ""
+	selected := m.affinity.lookup(affinityKey, modelKey, candidates, now)
+	if selected != nil {
+		affinity = SessionAffinityHit
+	} else {
+		var errPick error
+		selected, errPick = m.selector.Pick(ctx, provider, model, opts, candidates)
+		if errPick != nil {
+			m.mu.RUnlock()
+			return nil, nil, "", saturated, errPick
+		}
+		if selected == nil {
+			m.mu.RUnlock()
+			return nil, nil, "", saturated, &Error{Code: "auth_not_found", Message: "selector returned no auth"}
+		}
+		if affinityKey != "" {
+			affinity = SessionAffinityMiss
+		}
 	}
+	probe := m.circuit != nil && circuitAwaitingProbe(selected, modelKey)
 	authCopy := selected.Clone()
 	m.mu.RUnlock()
-	if !selected.indexAssigned {
+	if probe || !selected.indexAssigned {
 		m.mu.Lock()
-		if current := m.auths[authCopy.ID]; current != nil && !current.indexAssigned {
-			current.EnsureIndex()
+		if probe && !m.claimCircuitProbe(authCopy.ID, modelKey, time.Now()) {
+			// Another request claimed the half-open probe first; pick a different auth.
+			m.mu.Unlock()
+			tried[authCopy.ID] = struct{}{}
+			return m.pickCandidate(ctx, provider, model, opts, tried, reserved)
+		}
+		if current := m.auths[authCopy.ID]; current != nil {
+			if !current.indexAssigned {
+				current.EnsureIndex()
+			}
 			authCopy = current.Clone()
 		}
 		m.mu.Unlock()
 	}
-	return authCopy, executor, nil
+	if key := concurrencyKey(authCopy); key != reserved || authMaxConcurrency(authCopy) == 0 {
+		if !m.concurrency.tryAcquire(authCopy) {
+			// The last slot was taken since the candidate scan; select again.
+			return m.pickCandidate(ctx, provider, model, opts, tried, reserved)
+		}
+		if reserved != "" {
+			m.concurrency.releaseKey(reserved)
+		}
+	}
+	m.affinity.bind(affinityKey, authCopy.ID, now)
+	return authCopy, executor, affinity, nil, nil
 }
 
 func (m *Manager) persist(ctx context.Context, auth *Auth) error {
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type embedTestExecutor struct {
+	fallbackTestExecutor
+}
+
+func (e *embedTestExecutor) Embed(_ context.Context, auth *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.models = append(e.models, req.Model)
+	return cliproxyexecutor.Response{Payload: []byte(auth.ID)}, nil
+}
+
+func TestManagerExecuteEmbed(t *testing.T) {
+	embedder := &embedTestExecutor{fallbackTestExecutor{provider: "embed-test"}}
+	chat := &fallbackTestExecutor{provider: "embed-test-chat"}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(embedder)
+	manager.RegisterExecutor(chat)
+	registerFallbackTestAuth(t, manager, "embed-auth", embedder.provider, "embed-model")
+	registerFallbackTestAuth(t, manager, "embed-chat-auth", chat.provider, "chat-model")
+
+	resp, err := manager.ExecuteEmbed(context.Background(), []string{embedder.provider}, cliproxyexecutor.Request{Model: "embed-model"}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("ExecuteEmbed() error = %v", err)
+	}
+	if string(resp.Payload) != "embed-auth" || len(embedder.models) != 1 {
+		t.Fatalf("unexpected embed result: payload=%q calls=%v", resp.Payload, embedder.models)
+	}
+
+	_, err = manager.ExecuteEmbed(context.Background(), []string{chat.provider}, cliproxyexecutor.Request{Model: "chat-model"}, cliproxyexecutor.Options{})
+	var se cliproxyexecutor.StatusError
+	if err == nil || !errors.As(err, &se) || se.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("expected a bad request for a provider without embeddings, got %v", err)
+	}
+	if len(chat.models) != 0 {
+		t.Fatalf("expected no generation call, got %v", chat.models)
+	}
+}
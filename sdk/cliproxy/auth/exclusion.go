@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+)
+
+// ExcludedAuthsMetadataKey lists auth IDs ([]string) that must not serve the request, for
+// example when a stream that failed mid-way on one of them is resumed elsewhere.
+const ExcludedAuthsMetadataKey = "excluded_auth_ids"
+
+// AccessPolicyMetadataKey carries the access policy (sdkaccess.Policy) of the calling client
+// key. Selection skips credentials whose prefix it denies, and fallback models are checked
+// against its allowed models and providers.
+const AccessPolicyMetadataKey = "access_policy"
+
+type streamAuthTraceKey struct{}
+
+// StreamAuthTrace records which auth served the most recent stream started with its context.
+type StreamAuthTrace struct {
+	mu     sync.Mutex
+	authID string
+}
+
+// WithStreamAuthTrace attaches a stream auth trace to ctx. Callers read the serving auth from
+// the returned trace after ExecuteStream returns.
+func WithStreamAuthTrace(ctx context.Context) (context.Context, *StreamAuthTrace) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	trace := &StreamAuthTrace{}
+	return context.WithValue(ctx, streamAuthTraceKey{}, trace), trace
+}
+
+// AuthID returns the ID of the auth serving the latest stream, or an empty string.
+func (t *StreamAuthTrace) AuthID() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.authID
+}
+
+func recordStreamAuth(ctx context.Context, authID string) {
+	if ctx == nil {
+		return
+	}
+	trace, ok := ctx.Value(streamAuthTraceKey{}).(*StreamAuthTrace)
+	if !ok || trace == nil {
+		return
+	}
+	trace.mu.Lock()
+	trace.authID = authID
+	trace.mu.Unlock()
+}
+
+// excludedAuths returns the auth IDs listed under ExcludedAuthsMetadataKey.
+func excludedAuths(metadata map[string]any) map[string]struct{} {
+	return metadataStringSet(metadata, ExcludedAuthsMetadataKey)
+}
+
+// accessPolicy returns the policy stored under AccessPolicyMetadataKey; the zero policy
+// leaves selection unrestricted.
+func accessPolicy(metadata map[string]any) sdkaccess.Policy {
+	if len(metadata) == 0 {
+		return sdkaccess.Policy{}
+	}
+	policy, _ := metadata[AccessPolicyMetadataKey].(sdkaccess.Policy)
+	return policy
+}
+
+func metadataStringSet(metadata map[string]any, key string) map[string]struct{} {
+	if len(metadata) == 0 {
+		return nil
+	}
+	var ids []string
+	switch raw := metadata[key].(type) {
+	case []string:
+		ids = raw
+	case []any:
+		for _, item := range raw {
+			if id, ok := item.(string); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	out := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" {
+			out[id] = struct{}{}
+		}
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// thinkingMetadataKeys lists metadata entries derived from the requested model name.
+// They are replaced when a request is re-routed to a fallback model.
+var thinkingMetadataKeys = []string{
+	util.ThinkingBudgetMetadataKey,
+	util.ThinkingIncludeThoughtsMetadataKey,
+	util.ReasoningEffortMetadataKey,
+	util.ThinkingOriginalModelMetadataKey,
+	util.GeminiIncludeThoughtsMetadataKey,
+	util.GeminiOriginalModelMetadataKey,
+}
+
+type modelFallbackTraceKey struct{}
+
+// ModelFallbackTrace records which model actually served a request when the manager
+// falls back to an alternative model.
+type ModelFallbackTrace struct {
+	mu     sync.Mutex
+	served string
+}
+
+// WithModelFallbackTrace attaches a fallback trace to ctx. Callers read the served model
+// from the returned trace after Execute or ExecuteStream returns.
+func WithModelFallbackTrace(ctx context.Context) (context.Context, *ModelFallbackTrace) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	trace := &ModelFallbackTrace{}
+	return context.WithValue(ctx, modelFallbackTraceKey{}, trace), trace
+}
+
+// ServedModel returns the fallback model that served the request, or an empty string
+// when the originally requested model was used.
+func (t *ModelFallbackTrace) ServedModel() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.served
+}
+
+func recordServedModel(ctx context.Context, model string) {
+	if ctx == nil {
+		return
+	}
+	trace, ok := ctx.Value(modelFallbackTraceKey{}).(*ModelFallbackTrace)
+	if !ok || trace == nil {
+		return
+	}
+	trace.mu.Lock()
+	trace.served = model
+	trace.mu.Unlock()
+}
+
+// SetModelFallbacks replaces the fallback chains consulted once every credential for a model failed.
+// Keys are matched case-insensitively against the requested model.
+func (m *Manager) SetModelFallbacks(fallbacks map[string][]string) {
+	if m == nil {
+		return
+	}
+	normalized := make(map[string][]string, len(fallbacks))
+	for model, chain := range fallbacks {
+		key := strings.ToLower(strings.TrimSpace(model))
+		if key == "" || len(chain) == 0 {
+			continue
+		}
+		normalized[key] = append([]string(nil), chain...)
+	}
+	m.mu.Lock()
+	m.modelFallbacks = normalized
+	m.mu.Unlock()
+}
+
+func (m *Manager) fallbackChain(model string) []string {
+	key := strings.ToLower(strings.TrimSpace(model))
+	if key == "" {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.modelFallbacks[key]
+}
+
+// fallbackEligible reports whether err justifies trying another model. Client errors that
+// would fail identically on any model (malformed or oversized requests) are not retried.
+func fallbackEligible(ctx context.Context, err error) bool {
+	if err == nil || (ctx != nil && ctx.Err() != nil) {
+		return false
+	}
+	switch statusCodeFromError(err) {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return false
+	}
+	return true
+}
+
+// prepareFallback rewrites the request for the fallback model. Executors translate the
+// original client payload using req.Model, so only routing data has to change here. Models
+// and providers the caller's access policy denies are skipped.
+func prepareFallback(model string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) ([]string, cliproxyexecutor.Request, cliproxyexecutor.Options, bool) {
+	model = strings.TrimSpace(model)
+	normalized, metadata := util.NormalizeThinkingModel(model)
+	if normalized == "" {
+		return nil, req, opts, false
+	}
+	policy := accessPolicy(opts.Metadata)
+	if !policy.AllowsModel(normalized) && !policy.AllowsModel(model) {
+		return nil, req, opts, false
+	}
+	var providers []string
+	for _, provider := range util.GetProviderName(normalized) {
+		if policy.AllowsProvider(provider) {
+			providers = append(providers, provider)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, req, opts, false
+	}
+	fbReq := req
+	fbReq.Model = normalized
+	fbReq.Metadata = metadata
+	fbOpts := opts
+	fbOpts.Metadata = replaceThinkingMetadata(opts.Metadata, metadata)
+	return providers, fbReq, fbOpts, true
+}
+
+func replaceThinkingMetadata(base, overlay map[string]any) map[string]any {
+	out := make(map[string]any, len(base)+len(overlay))
+	for k, v := range base {
+		out[k] = v
+	}
+	for _, key := range thinkingMetadataKeys {
+		delete(out, key)
+	}
+	for k, v := range overlay {
+		out[k] = v
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type fallbackTestExecutor struct {
+	provider string
+	status   int
+	models   []string
+}
+
+func (e *fallbackTestExecutor) Identifier() string { return e.provider }
+
+func (e *fallbackTestExecutor) Execute(_ context.Context, _ *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.models = append(e.models, req.Model)
+	if e.status != 0 {
+		return cliproxyexecutor.Response{}, &Error{Code: "upstream", Message: "upstream failure", HTTPStatus: e.status}
+	}
+	return cliproxyexecutor.Response{Payload: []byte(req.Model)}, nil
+}
+
+func (e *fallbackTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, &Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *fallbackTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	return auth, nil
+}
+
+func (e *fallbackTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, &Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func registerFallbackTestAuth(t *testing.T, manager *Manager, id, provider, model string) {
+	t.Helper()
+	auth := &Auth{ID: id, Provider: provider, Status: StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register(%s): %v", id, err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(id, provider, []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(id) })
+}
+
+func TestManagerExecute_FallsBackToConfiguredModel(t *testing.T) {
+	primary := &fallbackTestExecutor{provider: "fallback-test-primary", status: http.StatusTooManyRequests}
+	secondary := &fallbackTestExecutor{provider: "fallback-test-secondary"}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(primary)
+	manager.RegisterExecutor(secondary)
+	registerFallbackTestAuth(t, manager, "fallback-primary-auth", primary.provider, "fallback-primary-model")
+	registerFallbackTestAuth(t, manager, "fallback-secondary-auth", secondary.provider, "fallback-secondary-model")
+	manager.SetModelFallbacks(map[string][]string{
+		"Fallback-Primary-Model": {"unknown-fallback-model", "fallback-secondary-model"},
+	})
+
+	ctx, trace := WithModelFallbackTrace(context.Background())
+	resp, err := manager.Execute(ctx, []string{primary.provider}, cliproxyexecutor.Request{Model: "fallback-primary-model"}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if string(resp.Payload) != "fallback-secondary-model" {
+		t.Fatalf("Execute() payload = %q, want %q", resp.Payload, "fallback-secondary-model")
+	}
+	if got := trace.ServedModel(); got != "fallback-secondary-model" {
+		t.Fatalf("ServedModel() = %q, want %q", got, "fallback-secondary-model")
+	}
+	if len(primary.models) != 1 || len(secondary.models) != 1 {
+		t.Fatalf("unexpected attempts: primary=%v secondary=%v", primary.models, secondary.models)
+	}
+}
+
+func TestManagerExecute_NoFallbackOnBadRequest(t *testing.T) {
+	primary := &fallbackTestExecutor{provider: "fallback-test-badreq", status: http.StatusBadRequest}
+	secondary := &fallbackTestExecutor{provider: "fallback-test-unused"}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(primary)
+	manager.RegisterExecutor(secondary)
+	registerFallbackTestAuth(t, manager, "fallback-badreq-auth", primary.provider, "fallback-badreq-model")
+	registerFallbackTestAuth(t, manager, "fallback-unused-auth", secondary.provider, "fallback-unused-model")
+	manager.SetModelFallbacks(map[string][]string{"fallback-badreq-model": {"fallback-unused-model"}})
+
+	ctx, trace := WithModelFallbackTrace(context.Background())
+	if _, err := manager.Execute(ctx, []string{primary.provider}, cliproxyexecutor.Request{Model: "fallback-badreq-model"}, cliproxyexecutor.Options{}); err == nil {
+		t.Fatalf("Execute() error = nil, want bad request error")
+	}
+	if len(secondary.models) != 0 {
+		t.Fatalf("fallback executor called %d times, want 0", len(secondary.models))
+	}
+	if got := trace.ServedModel(); got != "" {
+		t.Fatalf("ServedModel() = %q, want empty", got)
+	}
+}
+
+func TestManagerExecute_FallbackHonorsAccessPolicy(t *testing.T) {
+	primary := &fallbackTestExecutor{provider: "fallback-test-policy", status: http.StatusServiceUnavailable}
+	secondary := &fallbackTestExecutor{provider: "fallback-test-forbidden"}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(primary)
+	manager.RegisterExecutor(secondary)
+	registerFallbackTestAuth(t, manager, "fallback-policy-auth", primary.provider, "fallback-policy-model")
+	registerFallbackTestAuth(t, manager, "fallback-forbidden-auth", secondary.provider, "fallback-forbidden-model")
+	manager.SetModelFallbacks(map[string][]string{"fallback-policy-model": {"fallback-forbidden-model"}})
+
+	for name, policy := range map[string]sdkaccess.Policy{
+		"model":    {Models: []string{"fallback-policy-*"}},
+		"provider": {Providers: []string{primary.provider}},
+	} {
+		opts := cliproxyexecutor.Options{Metadata: map[string]any{AccessPolicyMetadataKey: policy}}
+		if _, err := manager.Execute(context.Background(), []string{primary.provider}, cliproxyexecutor.Request{Model: "fallback-policy-model"}, opts); err == nil {
+			t.Fatalf("%s policy: Execute() succeeded through a denied fallback", name)
+		}
+	}
+	if len(secondary.models) != 0 {
+		t.Fatalf("denied fallback was attempted: %v", secondary.models)
+	}
+}
+
+func TestManagerExecute_PrefixPolicyIgnoresCase(t *testing.T) {
+	executor := &fallbackTestExecutor{provider: "fallback-test-prefix"}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &Auth{ID: "fallback-prefix-auth", Provider: executor.provider, Prefix: "TeamA", Status: StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, executor.provider, []*registry.ModelInfo{{ID: "fallback-prefix-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{AccessPolicyMetadataKey: sdkaccess.Policy{Prefixes: []string{"teama"}}}}
+	if _, err := manager.Execute(context.Background(), []string{executor.provider}, cliproxyexecutor.Request{Model: "fallback-prefix-model"}, opts); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const (
+	defaultHedgePercentile = 0.95
+	defaultHedgeMinSamples = 20
+)
+
+// HedgingConfig controls hedged non-streaming requests. When the first attempt has not answered
+// within the observed latency percentile, a second attempt is fired on another credential and the
+// first response wins.
+type HedgingConfig struct {
+	// Models lists model names or '*' wildcard patterns that opt into hedging.
+	Models []string
+	// Percentile (0-1) of recent completion times used as the hedge delay. Zero uses 0.95.
+	Percentile float64
+	// MinSamples is the number of recent completions required before the percentile is trusted.
+	MinSamples int
+	// MinDelay is the lower bound for the hedge delay.
+	MinDelay time.Duration
+	// DefaultDelay is used until enough samples exist; zero disables hedging until then.
+	DefaultDelay time.Duration
+}
+
+func (c HedgingConfig) normalized() HedgingConfig {
+	if c.Percentile <= 0 || c.Percentile > 1 {
+		c.Percentile = defaultHedgePercentile
+	}
+	if c.MinSamples <= 0 {
+		c.MinSamples = defaultHedgeMinSamples
+	}
+	if c.MinDelay < 0 {
+		c.MinDelay = 0
+	}
+	if c.DefaultDelay < 0 {
+		c.DefaultDelay = 0
+	}
+	models := make([]string, 0, len(c.Models))
+	for _, pattern := range c.Models {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			models = append(models, pattern)
+		}
+	}
+	c.Models = models
+	return c
+}
+
+// SetHedging enables hedged requests with the given settings; nil or an empty model list disables it.
+func (m *Manager) SetHedging(cfg *HedgingConfig) {
+	if m == nil {
+		return
+	}
+	var normalized *HedgingConfig
+	if cfg != nil {
+		value := cfg.normalized()
+		if len(value.Models) > 0 {
+			normalized = &value
+		}
+	}
+	m.mu.Lock()
+	m.hedging = normalized
+	m.mu.Unlock()
+}
+
+// hedgeDelay returns how long to wait for the first attempt before hedging a request for model.
+func (m *Manager) hedgeDelay(model string) (time.Duration, bool) {
+	m.mu.RLock()
+	cfg := m.hedging
+	m.mu.RUnlock()
+	if cfg == nil || model == "" {
+		return 0, false
+	}
+	lower := strings.ToLower(model)
+	matched := false
+	for _, pattern := range cfg.Models {
+		if matchModelPattern(pattern, lower) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return 0, false
+	}
+	delay, ok := m.latency.percentile(model, cfg.Percentile, cfg.MinSamples)
+	if !ok {
+		delay = cfg.DefaultDelay
+	}
+	if delay <= 0 {
+		return 0, false
+	}
+	if delay < cfg.MinDelay {
+		delay = cfg.MinDelay
+	}
+	return delay, true
+}
+
+// matchModelPattern reports whether model matches pattern, where '*' matches any substring.
+func matchModelPattern(pattern, model string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == model
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(model, parts[0]) {
+		return false
+	}
+	model = model[len(parts[0]):]
+	last := parts[len(parts)-1]
+	if !strings.HasSuffix(model, last) {
+		return false
+	}
+	model = model[:len(model)-len(last)]
+	for _, segment := range parts[1 : len(parts)-1] {
+		idx := strings.Index(model, segment)
+		if idx < 0 {
+			return false
+		}
+		model = model[idx+len(segment):]
+	}
+	return true
+}
+
+// attemptCandidate is a selected credential ready to serve an attempt.
+type attemptCandidate struct {
+	provider string
+	auth     *Auth
+	executor ProviderExecutor
+	affinity string
+}
+
+// hedgeAttempt is the outcome of one leg of a hedged request.
+type hedgeAttempt struct {
+	candidate attemptCandidate
+	ctx       context.Context
+	resp      cliproxyexecutor.Response
+	result    Result
+	err       error
+	cancel    context.CancelFunc
+	usage     *coreusage.Deferred
+}
+
+// executeAttempt runs a single non-streaming request on the candidate and returns the result to
+// record along with the execution context it ran under. The candidate's concurrency slot is released.
+func (m *Manager) executeAttempt(ctx context.Context, candidate attemptCandidate, routeModel string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, Result, context.Context, error) {
+	auth := candidate.auth
+	execCtx, span := startAttemptSpan(withSessionAffinity(ctx, candidate.affinity), candidate.provider, auth, routeModel)
+	if rt := m.roundTripperFor(auth); rt != nil {
+		execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+		execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+	}
+	execReq := req
+	execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
+	m.latency.acquire(auth.ID, routeModel)
+	started := time.Now()
+	resp, errExec := candidate.executor.Execute(execCtx, auth, execReq, opts)
+	elapsed := time.Since(started)
+	switch {
+	case errExec == nil:
+		m.latency.observe(auth.ID, routeModel, elapsed)
+		m.latency.observeCompletion(routeModel, elapsed)
+	case execCtx.Err() == nil:
+		// Attempts abandoned by the client or a winning hedge are not the credential's fault.
+		m.latency.observeFailure(auth.ID, routeModel, elapsed)
+	}
+	m.latency.release(auth.ID, routeModel)
+	m.concurrency.release(auth)
+	result := Result{AuthID: auth.ID, Provider: candidate.provider, Model: routeModel, Success: errExec == nil}
+	if errExec != nil {
+		result.Error = &Error{Message: errExec.Error()}
+		var se cliproxyexecutor.StatusError
+		if errors.As(errExec, &se) && se != nil {
+			result.Error.HTTPStatus = se.StatusCode()
+		}
+		if ra := retryAfterFromError(errExec); ra != nil {
+			result.RetryAfter = ra
+		}
+	}
+	endAttemptSpan(execCtx, span, result)
+	return resp, result, execCtx, errExec
+}
+
+// pickHedge selects a credential for the hedged attempt, preferring another credential of the
+// same provider and then the remaining providers serving the model.
+func (m *Manager) pickHedge(ctx context.Context, provider string, providers []string, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (attemptCandidate, bool) {
+	candidates := make([]string, 0, len(providers)+1)
+	candidates = append(candidates, provider)
+	for _, p := range providers {
+		if p != provider {
+			candidates = append(candidates, p)
+		}
+	}
+	for _, p := range candidates {
+		// Hedges never queue for a concurrency slot: a saturated pool means there is no spare capacity.
+		auth, executor, affinity, _, err := m.pickCandidate(ctx, p, model, opts, tried, "")
+		if err != nil || auth == nil {
+			continue
+		}
+		return attemptCandidate{provider: p, auth: auth, executor: executor, affinity: affinity}, true
+	}
+	return attemptCandidate{}, false
+}
+
+// executeHedged runs primary and, if it has not finished after delay, a second attempt on another
+// credential. The first success wins: the other attempt is cancelled, and neither its result nor its
+// usage is recorded. Failed attempts are recorded as usual. Both selected credentials are added to tried.
+func (m *Manager) executeHedged(ctx context.Context, primary attemptCandidate, providers []string, routeModel string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, tried map[string]struct{}, delay time.Duration) (cliproxyexecutor.Response, error) {
+	done := make(chan *hedgeAttempt, 2)
+	launch := func(candidate attemptCandidate) *hedgeAttempt {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		attemptCtx, deferred := coreusage.WithDeferred(attemptCtx)
+		attempt := &hedgeAttempt{candidate: candidate, cancel: cancel, usage: deferred}
+		go func() {
+			attempt.resp, attempt.result, attempt.ctx, attempt.err = m.executeAttempt(attemptCtx, candidate, routeModel, req, opts)
+			done <- attempt
+		}()
+		return attempt
+	}
+
+	running := []*hedgeAttempt{launch(primary)}
+	hedged := false
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for len(running) > 0 {
+		select {
+		case <-timer.C:
+			secondary, ok := m.pickHedge(ctx, primary.provider, providers, routeModel, opts, tried)
+			if !ok {
+				continue
+			}
+			tried[secondary.auth.ID] = struct{}{}
+			hedged = true
+			logEntryWithRequestID(ctx).Debugf("hedging model %s after %s with %s auth %s", routeModel, delay, secondary.provider, secondary.auth.ID)
+			running = append(running, launch(secondary))
+		case attempt := <-done:
+			running = removeHedgeAttempt(running, attempt)
+			attempt.usage.Commit(hedgeUsageMutator(hedged))
+			m.MarkResult(attempt.ctx, attempt.result)
+			attempt.cancel()
+			if attempt.err != nil {
+				lastErr = attempt.err
+				continue
+			}
+			for _, loser := range running {
+				loser.usage.Discard()
+				loser.cancel()
+			}
+			return attempt.resp, nil
+		}
+	}
+	return cliproxyexecutor.Response{}, lastErr
+}
+
+func hedgeUsageMutator(hedged bool) func(*coreusage.Record) {
+	if !hedged {
+		return nil
+	}
+	return func(record *coreusage.Record) { record.Hedged = true }
+}
+
+func removeHedgeAttempt(running []*hedgeAttempt, attempt *hedgeAttempt) []*hedgeAttempt {
+	out := running[:0]
+	for _, item := range running {
+		if item != attempt {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+type hedgeTestExecutor struct {
+	provider string
+	delay    time.Duration
+}
+
+func (e *hedgeTestExecutor) Identifier() string { return e.provider }
+
+func (e *hedgeTestExecutor) Execute(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	select {
+	case <-time.After(e.delay):
+	case <-ctx.Done():
+		coreusage.PublishRecord(ctx, coreusage.Record{Provider: e.provider, Model: req.Model, AuthID: auth.ID, Failed: true})
+		return cliproxyexecutor.Response{}, ctx.Err()
+	}
+	coreusage.PublishRecord(ctx, coreusage.Record{Provider: e.provider, Model: req.Model, AuthID: auth.ID})
+	return cliproxyexecutor.Response{Payload: []byte(auth.ID)}, nil
+}
+
+func (e *hedgeTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, &Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *hedgeTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	return auth, nil
+}
+
+func (e *hedgeTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, &Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+type hedgeUsageRecorder struct {
+	model   string
+	mu      sync.Mutex
+	records []coreusage.Record
+}
+
+func (r *hedgeUsageRecorder) HandleUsage(_ context.Context, record coreusage.Record) {
+	if record.Model != r.model {
+		return
+	}
+	r.mu.Lock()
+	r.records = append(r.records, record)
+	r.mu.Unlock()
+}
+
+func (r *hedgeUsageRecorder) snapshot() []coreusage.Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]coreusage.Record(nil), r.records...)
+}
+
+func TestManagerExecute_HedgedRequestWinnerOnly(t *testing.T) {
+	const model = "hedge-test-model"
+	slow := &hedgeTestExecutor{provider: "hedge-test-slow", delay: 2 * time.Second}
+	fast := &hedgeTestExecutor{provider: "hedge-test-fast", delay: 0}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(slow)
+	manager.RegisterExecutor(fast)
+	registerFallbackTestAuth(t, manager, "hedge-slow-auth", slow.provider, model)
+	registerFallbackTestAuth(t, manager, "hedge-fast-auth", fast.provider, model)
+	manager.SetHedging(&HedgingConfig{Models: []string{"hedge-test-*"}, DefaultDelay: 20 * time.Millisecond})
+
+	recorder := &hedgeUsageRecorder{model: model}
+	coreusage.RegisterPlugin(recorder)
+
+	started := time.Now()
+	resp, err := manager.Execute(context.Background(), []string{slow.provider, fast.provider}, cliproxyexecutor.Request{Model: model}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if string(resp.Payload) != "hedge-fast-auth" {
+		t.Fatalf("Execute() payload = %q, want hedge-fast-auth", resp.Payload)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("hedged request took %s", elapsed)
+	}
+
+	slowAuth, _ := manager.GetByID("hedge-slow-auth")
+	if _, ok := slowAuth.ModelStates[model]; ok {
+		t.Fatalf("cancelled hedge loser recorded a result: %+v", slowAuth.ModelStates[model])
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.snapshot()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	records := recorder.snapshot()
+	if len(records) != 1 {
+		t.Fatalf("usage records = %+v, want only the winner", records)
+	}
+	if records[0].AuthID != "hedge-fast-auth" || !records[0].Hedged || records[0].Failed {
+		t.Fatalf("winner record = %+v", records[0])
+	}
+}
+
+func TestManagerHedgeDelay(t *testing.T) {
+	manager := NewManager(nil, nil, nil)
+	if _, ok := manager.hedgeDelay("gemini-2.5-flash"); ok {
+		t.Fatalf("hedging enabled without configuration")
+	}
+	manager.SetHedging(&HedgingConfig{Models: []string{"Gemini-*-Flash"}, MinSamples: 10, MinDelay: 50 * time.Millisecond})
+	if _, ok := manager.hedgeDelay("gemini-2.5-flash"); ok {
+		t.Fatalf("hedging enabled before enough samples without a default delay")
+	}
+	for i := 1; i <= 100; i++ {
+		manager.latency.observeCompletion("gemini-2.5-flash", time.Duration(i)*10*time.Millisecond)
+	}
+	delay, ok := manager.hedgeDelay("gemini-2.5-flash")
+	if !ok || delay != 950*time.Millisecond {
+		t.Fatalf("hedgeDelay() = %s, %v; want 950ms, true", delay, ok)
+	}
+	if _, ok := manager.hedgeDelay("gemini-2.5-pro"); ok {
+		t.Fatalf("hedging applied to a model outside the configured patterns")
+	}
+	for i := 0; i < latencyWindowSize; i++ {
+		manager.latency.observeCompletion("gemini-2.5-flash", time.Millisecond)
+	}
+	if delay, _ = manager.hedgeDelay("gemini-2.5-flash"); delay != 50*time.Millisecond {
+		t.Fatalf("hedgeDelay() = %s, want the 50ms floor", delay)
+	}
+}
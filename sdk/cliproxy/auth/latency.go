@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const (
+	// latencyEWMAAlpha weights the most recent sample when updating the moving average.
+	latencyEWMAAlpha = 0.3
+	// latencyStaleAfter discards averages that have not been refreshed recently so that
+	// previously slow credentials are re-evaluated instead of being starved forever.
+	latencyStaleAfter = 5 * time.Minute
+	// latencyWindowSize bounds the per-model completion samples kept for percentile estimates.
+	latencyWindowSize = 128
+	// latencyFailureFactor scales the penalty sample recorded for a failed request so that a
+	// credential failing fast does not look faster than the ones that succeed.
+	latencyFailureFactor = 2
+	// latencyFailureMin is the smallest penalty sample recorded for a failed request.
+	latencyFailureMin = time.Second
+)
+
+// ExecutionStats summarises recent request timings for a single auth and model pair.
+type ExecutionStats struct {
+	// Model is the route model the statistics were collected for.
+	Model string `json:"model"`
+	// LatencyEWMA is the exponentially weighted moving average of request latency.
+	// For streaming requests the time-to-first-byte is used; failed requests add a penalty sample.
+	LatencyEWMA time.Duration `json:"latency_ewma"`
+	// LastLatency is the most recent latency sample.
+	LastLatency time.Duration `json:"last_latency"`
+	// InFlight is the number of requests currently executing.
+	InFlight int `json:"in_flight"`
+	// Samples counts how many latency samples contributed to the average.
+	Samples int64 `json:"samples"`
+	// UpdatedAt records when the latency average last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// latencyTracker records per auth/model in-flight counts and latency averages.
+type latencyTracker struct {
+	mu    sync.Mutex
+	stats map[string]map[string]*ExecutionStats
+	// completions keeps recent non-streaming completion times per route model across auths.
+	completions map[string]*latencyWindow
+}
+
+// latencyWindow is a fixed-size ring of recent latency samples.
+type latencyWindow struct {
+	samples   [latencyWindowSize]time.Duration
+	next      int
+	count     int
+	updatedAt time.Time
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		stats:       make(map[string]map[string]*ExecutionStats),
+		completions: make(map[string]*latencyWindow),
+	}
+}
+
+func (t *latencyTracker) entry(authID, model string) *ExecutionStats {
+	byModel := t.stats[authID]
+	if byModel == nil {
+		byModel = make(map[string]*ExecutionStats)
+		t.stats[authID] = byModel
+	}
+	stat := byModel[model]
+	if stat == nil {
+		stat = &ExecutionStats{Model: model}
+		byModel[model] = stat
+	}
+	return stat
+}
+
+// acquire marks the start of a request for the auth and model.
+func (t *latencyTracker) acquire(authID, model string) {
+	if t == nil || authID == "" {
+		return
+	}
+	t.mu.Lock()
+	t.entry(authID, model).InFlight++
+	t.mu.Unlock()
+}
+
+// release marks the end of a request previously registered with acquire.
+func (t *latencyTracker) release(authID, model string) {
+	if t == nil || authID == "" {
+		return
+	}
+	t.mu.Lock()
+	stat := t.entry(authID, model)
+	if stat.InFlight > 0 {
+		stat.InFlight--
+	}
+	t.mu.Unlock()
+}
+
+// observe folds a latency sample into the moving average.
+func (t *latencyTracker) observe(authID, model string, latency time.Duration) {
+	if t == nil || authID == "" || latency < 0 {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	observeLocked(t.entry(authID, model), latency, now)
+	t.mu.Unlock()
+}
+
+// observeFailure folds a penalty sample for a failed request into the moving average: the
+// larger of the elapsed time and the current average, scaled by latencyFailureFactor.
+func (t *latencyTracker) observeFailure(authID, model string, elapsed time.Duration) {
+	if t == nil || authID == "" || elapsed < 0 {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	stat := t.entry(authID, model)
+	penalty := elapsed
+	if stat.Samples > 0 && now.Sub(stat.UpdatedAt) <= latencyStaleAfter && stat.LatencyEWMA > penalty {
+		penalty = stat.LatencyEWMA
+	}
+	observeLocked(stat, max(penalty*latencyFailureFactor, latencyFailureMin), now)
+	t.mu.Unlock()
+}
+
+func observeLocked(stat *ExecutionStats, latency time.Duration, now time.Time) {
+	if stat.Samples == 0 || now.Sub(stat.UpdatedAt) > latencyStaleAfter {
+		stat.LatencyEWMA = latency
+	} else {
+		stat.LatencyEWMA = time.Duration(latencyEWMAAlpha*float64(latency) + (1-latencyEWMAAlpha)*float64(stat.LatencyEWMA))
+	}
+	stat.LastLatency = latency
+	stat.Samples++
+	stat.UpdatedAt = now
+}
+
+// remove discards the statistics of the auth.
+func (t *latencyTracker) remove(authID string) {
+	if t == nil || authID == "" {
+		return
+	}
+	t.mu.Lock()
+	delete(t.stats, authID)
+	t.mu.Unlock()
+}
+
+// observeCompletion records the full duration of a successful non-streaming request for model.
+func (t *latencyTracker) observeCompletion(model string, latency time.Duration) {
+	if t == nil || model == "" || latency < 0 {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	window := t.completions[model]
+	if window == nil || now.Sub(window.updatedAt) > latencyStaleAfter {
+		window = &latencyWindow{}
+		t.completions[model] = window
+	}
+	window.samples[window.next] = latency
+	window.next = (window.next + 1) % latencyWindowSize
+	if window.count < latencyWindowSize {
+		window.count++
+	}
+	window.updatedAt = now
+	t.mu.Unlock()
+}
+
+// percentile returns the q-th percentile (0-1) of recent completion times for model. It
+// reports false until at least minSamples fresh samples exist.
+func (t *latencyTracker) percentile(model string, q float64, minSamples int) (time.Duration, bool) {
+	if t == nil || model == "" {
+		return 0, false
+	}
+	t.mu.Lock()
+	window := t.completions[model]
+	if window == nil || window.count == 0 || window.count < minSamples || time.Since(window.updatedAt) > latencyStaleAfter {
+		t.mu.Unlock()
+		return 0, false
+	}
+	samples := make([]time.Duration, window.count)
+	copy(samples, window.samples[:window.count])
+	t.mu.Unlock()
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	idx := int(math.Ceil(q*float64(len(samples)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(samples) {
+		idx = len(samples) - 1
+	}
+	return samples[idx], true
+}
+
+// load returns the effective latency average and in-flight count used for selection.
+// Stale averages are reported as zero so the credential gets probed again.
+func (t *latencyTracker) load(authID, model string, now time.Time) (time.Duration, int) {
+	if t == nil {
+		return 0, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stat := t.stats[authID][model]
+	if stat == nil {
+		return 0, 0
+	}
+	latency := stat.LatencyEWMA
+	if stat.Samples == 0 || now.Sub(stat.UpdatedAt) > latencyStaleAfter {
+		latency = 0
+	}
+	return latency, stat.InFlight
+}
+
+// snapshot returns a copy of all statistics tracked for the auth, ordered by model.
+func (t *latencyTracker) snapshot(authID string) []ExecutionStats {
+	if t == nil || authID == "" {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	byModel := t.stats[authID]
+	if len(byModel) == 0 {
+		return nil
+	}
+	out := make([]ExecutionStats, 0, len(byModel))
+	for _, stat := range byModel {
+		if stat == nil {
+			continue
+		}
+		out = append(out, *stat)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Model < out[j].Model })
+	return out
+}
+
+// latencyAwareSelector is implemented by selectors that consume manager execution statistics.
+type latencyAwareSelector interface {
+	bindLatencyTracker(tracker *latencyTracker)
+}
+
+// LatencySelector prefers the credential with the lowest expected cost, computed as the
+// EWMA latency multiplied by the number of in-flight requests plus one. Credentials without
+// recent samples are assumed to match the fastest known one; ties rotate round-robin.
+type LatencySelector struct {
+	mu      sync.Mutex
+	tracker *latencyTracker
+	cursors map[string]int
+}
+
+func (s *LatencySelector) bindLatencyTracker(tracker *latencyTracker) {
+	s.mu.Lock()
+	s.tracker = tracker
+	s.mu.Unlock()
+}
+
+// Pick selects the available auth with the lowest latency-weighted load for the model.
+func (s *LatencySelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	now := time.Now()
+	available, err := getAvailableAuths(auths, provider, model, now)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	type load struct {
+		latency  time.Duration
+		inFlight int
+	}
+	loads := make([]load, len(available))
+	var baseline time.Duration
+	for i, candidate := range available {
+		latency, inFlight := s.tracker.load(candidate.ID, model, now)
+		loads[i] = load{latency: latency, inFlight: inFlight}
+		if latency > 0 && (baseline == 0 || latency < baseline) {
+			baseline = latency
+		}
+	}
+	if baseline == 0 {
+		baseline = 1
+	}
+	best := make([]*Auth, 0, len(available))
+	var bestScore float64
+	for i, candidate := range available {
+		latency := loads[i].latency
+		if latency == 0 {
+			// Unsampled credentials are assumed to be as fast as the best known one.
+			latency = baseline
+		}
+		score := float64(latency) * float64(loads[i].inFlight+1)
+		switch {
+		case len(best) == 0 || score < bestScore:
+			best = append(best[:0], candidate)
+			bestScore = score
+		case score == bestScore:
+			best = append(best, candidate)
+		}
+	}
+	if len(best) == 1 {
+		return best[0], nil
+	}
+	if s.cursors == nil {
+		s.cursors = make(map[string]int)
+	}
+	key := provider + ":" + model
+	index := s.cursors[key]
+	if index >= 2_147_483_640 {
+		index = 0
+	}
+	s.cursors[key] = index + 1
+	return best[index%len(best)], nil
+}
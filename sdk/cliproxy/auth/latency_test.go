@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestLatencySelectorPick_PrefersLowestLatency(t *testing.T) {
+	t.Parallel()
+
+	tracker := newLatencyTracker()
+	selector := &LatencySelector{}
+	selector.bindLatencyTracker(tracker)
+	tracker.observe("slow", "gpt-5", 3*time.Second)
+	tracker.observe("fast", "gpt-5", 200*time.Millisecond)
+
+	auths := []*Auth{{ID: "slow"}, {ID: "fast"}}
+	for i := 0; i < 3; i++ {
+		got, err := selector.Pick(context.Background(), "codex", "gpt-5", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		if got.ID != "fast" {
+			t.Fatalf("Pick() #%d auth.ID = %q, want %q", i, got.ID, "fast")
+		}
+	}
+}
+
+func TestLatencySelectorPick_AccountsForInFlight(t *testing.T) {
+	t.Parallel()
+
+	tracker := newLatencyTracker()
+	selector := &LatencySelector{}
+	selector.bindLatencyTracker(tracker)
+	tracker.observe("a", "gpt-5", time.Second)
+	tracker.observe("b", "gpt-5", 1500*time.Millisecond)
+	tracker.acquire("a", "gpt-5")
+	tracker.acquire("a", "gpt-5")
+
+	got, err := selector.Pick(context.Background(), "codex", "gpt-5", cliproxyexecutor.Options{}, []*Auth{{ID: "a"}, {ID: "b"}})
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "b" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "b")
+	}
+}
+
+func TestLatencySelectorPick_RotatesWithoutSamples(t *testing.T) {
+	t.Parallel()
+
+	selector := &LatencySelector{}
+	auths := []*Auth{{ID: "b"}, {ID: "a"}}
+	want := []string{"a", "b", "a"}
+	for i, id := range want {
+		got, err := selector.Pick(context.Background(), "codex", "gpt-5", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		if got.ID != id {
+			t.Fatalf("Pick() #%d auth.ID = %q, want %q", i, got.ID, id)
+		}
+	}
+}
+
+func TestLatencyTracker_SnapshotAndRelease(t *testing.T) {
+	t.Parallel()
+
+	tracker := newLatencyTracker()
+	tracker.acquire("auth", "m")
+	tracker.observe("auth", "m", 100*time.Millisecond)
+	tracker.observe("auth", "m", 200*time.Millisecond)
+
+	stats := tracker.snapshot("auth")
+	if len(stats) != 1 {
+		t.Fatalf("snapshot() len = %d, want 1", len(stats))
+	}
+	if stats[0].InFlight != 1 || stats[0].Samples != 2 {
+		t.Fatalf("snapshot() = %+v, want in_flight 1 and samples 2", stats[0])
+	}
+	if want := 130 * time.Millisecond; stats[0].LatencyEWMA != want {
+		t.Fatalf("snapshot() latency = %s, want %s", stats[0].LatencyEWMA, want)
+	}
+
+	tracker.release("auth", "m")
+	tracker.release("auth", "m")
+	if stats = tracker.snapshot("auth"); stats[0].InFlight != 0 {
+		t.Fatalf("snapshot() in_flight = %d after release, want 0", stats[0].InFlight)
+	}
+}
+
+func TestLatencyTracker_FailurePenalty(t *testing.T) {
+	t.Parallel()
+
+	tracker := newLatencyTracker()
+	tracker.observe("auth", "m", 2*time.Second)
+	tracker.observeFailure("auth", "m", 10*time.Millisecond)
+
+	stats := tracker.snapshot("auth")
+	if want := 4 * time.Second; stats[0].LastLatency != want {
+		t.Fatalf("penalty sample = %s, want %s", stats[0].LastLatency, want)
+	}
+
+	tracker.observeFailure("fresh", "m", 10*time.Millisecond)
+	if got := tracker.snapshot("fresh")[0].LastLatency; got != latencyFailureMin {
+		t.Fatalf("penalty sample without history = %s, want %s", got, latencyFailureMin)
+	}
+}
+
+func TestManagerUpdate_DisablingDropsLatencyStats(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	manager := NewManager(nil, nil, nil)
+	auth, _ := manager.Register(ctx, &Auth{ID: "auth", Provider: "codex"})
+	manager.latency.observe("auth", "gpt-5", time.Second)
+
+	if _, err := manager.Update(ctx, auth); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if len(manager.ExecutionStats("auth")) != 1 {
+		t.Fatal("stats dropped by a plain update")
+	}
+
+	auth.Disabled = true
+	if _, err := manager.Update(ctx, auth); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if stats := manager.ExecutionStats("auth"); len(stats) != 0 {
+		t.Fatalf("ExecutionStats() = %+v after disabling, want none", stats)
+	}
+}
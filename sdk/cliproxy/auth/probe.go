@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// ProbeMethodGenerate probes with a one-token, non-streaming generation.
+	ProbeMethodGenerate = "generate"
+	// ProbeMethodCountTokens probes with a token counting request.
+	ProbeMethodCountTokens = "count-tokens"
+
+	defaultProbeInterval    = 30 * time.Minute
+	defaultProbeTimeout     = 30 * time.Second
+	defaultProbeHistorySize = 20
+	probeParallelism        = 4
+)
+
+// HealthProbeConfig controls the background credential health probes.
+type HealthProbeConfig struct {
+	// Interval between probe rounds. Zero uses 30 minutes.
+	Interval time.Duration
+	// Timeout bounds a single probe. Zero uses 30 seconds.
+	Timeout time.Duration
+	// Method is ProbeMethodGenerate (default) or ProbeMethodCountTokens.
+	Method string
+	// Providers restricts probing to these providers; empty probes every provider.
+	Providers []string
+	// Models maps a provider to the model used for its probes. Providers without an entry use
+	// the first model registered for the credential.
+	Models map[string]string
+	// HistorySize is the number of probe results kept per credential. Zero uses 20.
+	HistorySize int
+}
+
+func (c HealthProbeConfig) normalized() HealthProbeConfig {
+	if c.Interval <= 0 {
+		c.Interval = defaultProbeInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultProbeTimeout
+	}
+	if c.Method != ProbeMethodCountTokens {
+		c.Method = ProbeMethodGenerate
+	}
+	if c.HistorySize <= 0 {
+		c.HistorySize = defaultProbeHistorySize
+	}
+	providers := make([]string, 0, len(c.Providers))
+	for _, provider := range c.Providers {
+		if provider = strings.ToLower(strings.TrimSpace(provider)); provider != "" {
+			providers = append(providers, provider)
+		}
+	}
+	c.Providers = providers
+	models := make(map[string]string, len(c.Models))
+	for provider, model := range c.Models {
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		if model = strings.TrimSpace(model); provider != "" && model != "" {
+			models[provider] = model
+		}
+	}
+	c.Models = models
+	return c
+}
+
+func (c HealthProbeConfig) probes(provider string) bool {
+	if len(c.Providers) == 0 {
+		return true
+	}
+	provider = strings.ToLower(provider)
+	for _, p := range c.Providers {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// ProbeResult records the outcome of a single health probe.
+type ProbeResult struct {
+	// At is when the probe started.
+	At time.Time `json:"at"`
+	// Model is the model the probe targeted.
+	Model string `json:"model"`
+	// Method is the probe method used.
+	Method string `json:"method"`
+	// OK reports whether the upstream accepted the probe.
+	OK bool `json:"ok"`
+	// Latency is the probe round-trip time.
+	Latency time.Duration `json:"latency"`
+	// StatusCode is the upstream HTTP status for failed probes, when known.
+	StatusCode int `json:"status_code,omitempty"`
+	// Error holds the failure message.
+	Error string `json:"error,omitempty"`
+}
+
+// ProbeHistory summarises recent health probes for a credential.
+type ProbeHistory struct {
+	// AuthID identifies the probed credential.
+	AuthID string `json:"auth_id"`
+	// LastProbeAt is when the credential was last probed.
+	LastProbeAt time.Time `json:"last_probe_at"`
+	// LastVerifiedAt is when a probe last succeeded.
+	LastVerifiedAt time.Time `json:"last_verified_at,omitempty"`
+	// ConsecutiveFailures counts failed probes since the last success.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// Results holds the most recent probes, oldest first.
+	Results []ProbeResult `json:"results"`
+}
+
+// healthProber keeps probe settings, history and the background loop state.
+type healthProber struct {
+	mu      sync.Mutex
+	cfg     *HealthProbeConfig
+	history map[string]*ProbeHistory
+	cancel  context.CancelFunc
+}
+
+func newHealthProber() *healthProber {
+	return &healthProber{history: make(map[string]*ProbeHistory)}
+}
+
+// StartHealthProbes launches a background loop that probes every credential through its executor
+// right away and then once per interval, recording the outcome like a regular request. A nil config
+// stops the loop. Calling it again replaces the running loop with the new settings.
+func (m *Manager) StartHealthProbes(parent context.Context, cfg *HealthProbeConfig) {
+	if m == nil {
+		return
+	}
+	m.StopHealthProbes()
+	if cfg == nil {
+		return
+	}
+	normalized := cfg.normalized()
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	m.probes.mu.Lock()
+	m.probes.cfg = &normalized
+	m.probes.cancel = cancel
+	m.probes.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(normalized.Interval)
+		defer ticker.Stop()
+		m.probeAll(ctx, normalized)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.probeAll(ctx, normalized)
+			}
+		}
+	}()
+}
+
+// StopHealthProbes cancels the background probe loop, if running.
+func (m *Manager) StopHealthProbes() {
+	if m == nil {
+		return
+	}
+	m.probes.mu.Lock()
+	cancel := m.probes.cancel
+	m.probes.cancel = nil
+	m.probes.cfg = nil
+	m.probes.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// ProbeHistory returns the recorded probe history for the credential.
+func (m *Manager) ProbeHistory(authID string) (ProbeHistory, bool) {
+	if m == nil {
+		return ProbeHistory{}, false
+	}
+	m.probes.mu.Lock()
+	defer m.probes.mu.Unlock()
+	history, ok := m.probes.history[authID]
+	if !ok || history == nil {
+		return ProbeHistory{}, false
+	}
+	out := *history
+	out.Results = append([]ProbeResult(nil), history.Results...)
+	return out, true
+}
+
+// ProbeAuth probes a single credential immediately using the active probe settings, or the
+// defaults when background probing is disabled. Unlike background probes, it also probes
+// credentials that are cooling down, but not ones whose open circuit breaker is not yet due
+// for its half-open probe.
+func (m *Manager) ProbeAuth(ctx context.Context, authID string) (ProbeResult, error) {
+	if m == nil {
+		return ProbeResult{}, &Error{Code: "auth_not_found", Message: "auth manager unavailable"}
+	}
+	m.probes.mu.Lock()
+	var cfg HealthProbeConfig
+	if m.probes.cfg != nil {
+		cfg = *m.probes.cfg
+	} else {
+		cfg = HealthProbeConfig{}.normalized()
+	}
+	m.probes.mu.Unlock()
+	auth, ok := m.GetByID(authID)
+	if !ok {
+		return ProbeResult{}, &Error{Code: "auth_not_found", Message: "auth not found"}
+	}
+	result, ok := m.probeAuth(ctx, auth, cfg, true)
+	if !ok {
+		return ProbeResult{}, &Error{Code: "probe_unavailable", Message: "no executor or model available to probe this auth"}
+	}
+	return result, nil
+}
+
+func (m *Manager) probeAll(ctx context.Context, cfg HealthProbeConfig) {
+	sem := make(chan struct{}, probeParallelism)
+	var wg sync.WaitGroup
+	for _, auth := range m.snapshotAuths() {
+		if auth == nil || auth.Disabled || auth.Status == StatusDisabled || !cfg.probes(auth.Provider) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(a *Auth) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			m.probeAuth(ctx, a, cfg, false)
+		}(auth)
+	}
+	wg.Wait()
+}
+
+// probeModel picks the model to probe the auth with.
+func probeModel(auth *Auth, cfg HealthProbeConfig) string {
+	if model := cfg.Models[strings.ToLower(auth.Provider)]; model != "" {
+		return model
+	}
+	for _, info := range registry.GetGlobalRegistry().GetModelsForClient(auth.ID) {
+		if info != nil && strings.TrimSpace(info.ID) != "" {
+			return info.ID
+		}
+	}
+	return ""
+}
+
+// probeAuth sends one probe request for the auth and records it. It reports false when the auth
+// cannot be probed right now: no executor or model, at max-concurrency, another request holding
+// the half-open claim of its circuit breaker, or, unless manual is set, cooling down for the
+// probe model. A probe of an open circuit takes the half-open claim like a regular request.
+func (m *Manager) probeAuth(ctx context.Context, auth *Auth, cfg HealthProbeConfig, manual bool) (ProbeResult, bool) {
+	executor := m.executorFor(auth.Provider)
+	model := probeModel(auth, cfg)
+	if executor == nil || model == "" {
+		return ProbeResult{}, false
+	}
+	now := time.Now()
+	if blocked, _, _ := isAuthBlockedForModel(auth, model, now); blocked && !manual {
+		return ProbeResult{}, false
+	}
+	if !m.concurrency.tryAcquire(auth) {
+		return ProbeResult{}, false
+	}
+	defer m.concurrency.release(auth)
+	m.mu.Lock()
+	claimed := m.claimCircuitProbe(auth.ID, model, now)
+	m.mu.Unlock()
+	if !claimed {
+		return ProbeResult{}, false
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	// Probes must not count towards usage statistics.
+	probeCtx, deferred := coreusage.WithDeferred(probeCtx)
+	defer deferred.Discard()
+	if rt := m.roundTripperFor(auth); rt != nil {
+		probeCtx = context.WithValue(probeCtx, roundTripperContextKey{}, rt)
+		probeCtx = context.WithValue(probeCtx, "cliproxy.roundtripper", rt)
+	}
+
+	req := cliproxyexecutor.Request{Model: model, Format: sdktranslator.FormatOpenAI}
+	req.Model, req.Metadata = rewriteModelForAuth(model, nil, auth)
+	req.Payload, _ = sjson.SetBytes([]byte(`{"messages":[{"role":"user","content":"ping"}],"max_tokens":1,"stream":false}`), "model", req.Model)
+	opts := cliproxyexecutor.Options{SourceFormat: sdktranslator.FormatOpenAI, OriginalRequest: req.Payload}
+
+	var err error
+	if cfg.Method == ProbeMethodCountTokens {
+		_, err = executor.CountTokens(probeCtx, auth, req, opts)
+	} else {
+		_, err = executor.Execute(probeCtx, auth, req, opts)
+	}
+	result := ProbeResult{At: now, Model: model, Method: cfg.Method, OK: err == nil, Latency: time.Since(now)}
+	if err != nil {
+		result.Error = err.Error()
+		result.StatusCode = statusCodeFromError(err)
+	}
+	if ctx.Err() != nil {
+		// Shutting down; the failure says nothing about the credential.
+		return result, true
+	}
+	m.recordProbe(auth.ID, result, cfg.HistorySize)
+
+	markResult := Result{AuthID: auth.ID, Provider: auth.Provider, Model: model, Success: err == nil}
+	if err != nil {
+		markResult.Error = &Error{Message: result.Error, HTTPStatus: result.StatusCode}
+		markResult.RetryAfter = retryAfterFromError(err)
+		log.Debugf("health probe failed for %s auth %s (model %s): %v", auth.Provider, auth.ID, model, err)
+	}
+	m.MarkResult(ctx, markResult)
+	return result, true
+}
+
+// forget drops the probe history of the auth.
+func (p *healthProber) forget(authID string) {
+	p.mu.Lock()
+	delete(p.history, authID)
+	p.mu.Unlock()
+}
+
+func (m *Manager) recordProbe(authID string, result ProbeResult, historySize int) {
+	m.probes.mu.Lock()
+	defer m.probes.mu.Unlock()
+	history := m.probes.history[authID]
+	if history == nil {
+		history = &ProbeHistory{AuthID: authID}
+		m.probes.history[authID] = history
+	}
+	history.LastProbeAt = result.At
+	if result.OK {
+		history.LastVerifiedAt = result.At
+		history.ConsecutiveFailures = 0
+	} else {
+		history.ConsecutiveFailures++
+	}
+	history.Results = append(history.Results, result)
+	if historySize > 0 && len(history.Results) > historySize {
+		history.Results = append([]ProbeResult(nil), history.Results[len(history.Results)-historySize:]...)
+	}
+}
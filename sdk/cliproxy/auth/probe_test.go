@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type probeTestExecutor struct {
+	provider string
+	failing  map[string]int
+	requests []cliproxyexecutor.Request
+}
+
+func (e *probeTestExecutor) Identifier() string { return e.provider }
+
+func (e *probeTestExecutor) Execute(_ context.Context, auth *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.requests = append(e.requests, req)
+	if status := e.failing[auth.ID]; status != 0 {
+		return cliproxyexecutor.Response{}, &Error{Message: "token revoked", HTTPStatus: status}
+	}
+	return cliproxyexecutor.Response{Payload: []byte(`{}`)}, nil
+}
+
+func (e *probeTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, &Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *probeTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	return auth, nil
+}
+
+func (e *probeTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, &Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func TestManagerHealthProbes_RecordAndMarkStatus(t *testing.T) {
+	executor := &probeTestExecutor{provider: "probe-test", failing: map[string]int{"probe-revoked": http.StatusUnauthorized}}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	registerFallbackTestAuth(t, manager, "probe-healthy", executor.provider, "probe-registered-model")
+	registerFallbackTestAuth(t, manager, "probe-revoked", executor.provider, "probe-registered-model")
+	if _, err := manager.Register(context.Background(), &Auth{ID: "probe-other-provider", Provider: "probe-skipped", Status: StatusActive}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	manager.probeAll(context.Background(), HealthProbeConfig{Providers: []string{"Probe-Test"}}.normalized())
+
+	if len(executor.requests) != 2 {
+		t.Fatalf("probe requests = %d, want 2", len(executor.requests))
+	}
+	if model := executor.requests[0].Model; model != "probe-registered-model" {
+		t.Fatalf("probe model = %q, want the credential's first model", model)
+	}
+
+	healthy, ok := manager.ProbeHistory("probe-healthy")
+	if !ok || healthy.LastVerifiedAt.IsZero() || healthy.ConsecutiveFailures != 0 || len(healthy.Results) != 1 || !healthy.Results[0].OK {
+		t.Fatalf("unexpected healthy history: %+v", healthy)
+	}
+	revoked, ok := manager.ProbeHistory("probe-revoked")
+	if !ok || !revoked.LastVerifiedAt.IsZero() || revoked.ConsecutiveFailures != 1 || revoked.Results[0].StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unexpected revoked history: %+v", revoked)
+	}
+	if auth, _ := manager.GetByID("probe-revoked"); auth.Status != StatusError || auth.StatusMessage != "token revoked" {
+		t.Fatalf("revoked auth status = %q (%q), want error", auth.Status, auth.StatusMessage)
+	}
+	if _, ok := manager.ProbeHistory("probe-other-provider"); ok {
+		t.Fatalf("provider outside the probe list was probed")
+	}
+
+	// The revoked credential is now cooling down: background rounds skip it, manual probes do not.
+	manager.probeAll(context.Background(), HealthProbeConfig{Models: map[string]string{"probe-test": "probe-configured-model"}}.normalized())
+	revoked, _ = manager.ProbeHistory("probe-revoked")
+	if len(revoked.Results) != 2 || revoked.Results[1].Model != "probe-configured-model" {
+		t.Fatalf("configured probe model not used: %+v", revoked.Results)
+	}
+	before := len(executor.requests)
+	manager.probeAll(context.Background(), HealthProbeConfig{}.normalized())
+	if got := len(executor.requests) - before; got != 1 {
+		t.Fatalf("background round sent %d probes, want only the healthy credential", got)
+	}
+	if _, err := manager.ProbeAuth(context.Background(), "probe-revoked"); err != nil {
+		t.Fatalf("ProbeAuth() error = %v", err)
+	}
+	if revoked, _ = manager.ProbeHistory("probe-revoked"); revoked.ConsecutiveFailures != 3 {
+		t.Fatalf("consecutive failures = %d, want 3", revoked.ConsecutiveFailures)
+	}
+}
+
+func TestManagerHealthProbes_CircuitClaimAndRemoval(t *testing.T) {
+	executor := &probeTestExecutor{provider: "probe-circuit-test"}
+	manager := NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	manager.SetCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: time.Minute})
+	registerFallbackTestAuth(t, manager, "probe-circuit", executor.provider, "probe-circuit-model")
+	openCircuit := func(retryAfter time.Time) {
+		manager.mu.Lock()
+		manager.auths["probe-circuit"].ModelStates = map[string]*ModelState{"probe-circuit-model": {
+			Status:         StatusError,
+			Unavailable:    true,
+			NextRetryAfter: retryAfter,
+			Circuit:        CircuitState{State: CircuitOpen},
+		}}
+		manager.mu.Unlock()
+	}
+
+	// The open circuit is not due for its half-open probe: even a manual probe must wait.
+	openCircuit(time.Now().Add(time.Hour))
+	if _, err := manager.ProbeAuth(context.Background(), "probe-circuit"); err == nil {
+		t.Fatal("ProbeAuth() succeeded before the circuit was due for its half-open probe")
+	}
+	if len(executor.requests) != 0 {
+		t.Fatalf("probe requests = %d, want 0", len(executor.requests))
+	}
+
+	openCircuit(time.Now().Add(-time.Second))
+	if _, err := manager.ProbeAuth(context.Background(), "probe-circuit"); err != nil {
+		t.Fatalf("ProbeAuth() error = %v", err)
+	}
+	if state := circuitStateOf(t, manager, "probe-circuit", "probe-circuit-model"); state.Circuit.State != CircuitClosed {
+		t.Fatalf("circuit = %q after a successful probe, want %q", state.Circuit.State, CircuitClosed)
+	}
+
+	auth, _ := manager.GetByID("probe-circuit")
+	auth.Disabled = true
+	if _, err := manager.Update(context.Background(), auth); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, ok := manager.ProbeHistory("probe-circuit"); ok {
+		t.Fatal("probe history kept after the auth was disabled")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,6 +26,14 @@ type RoundRobinSelector struct {
 // rolling-window subscription caps (e.g. chat message limits).
 type FillFirstSelector struct{}
 
+// WeightedSelector honours per-credential priority tiers and weights.
+// Only the highest priority tier that still has available credentials is considered;
+// within that tier credentials are chosen with smooth weighted round-robin.
+type WeightedSelector struct {
+	mu      sync.Mutex
+	current map[string]map[string]int
+}
+
 type blockReason int
 
 const (
@@ -183,6 +192,94 @@ func (s *FillFirstSelector) Pick(ctx context.Context, provider, model string, op
 	return available[0], nil
 }
 
+// Pick selects the next available auth from the highest priority tier using smooth weighted round-robin.
+func (s *WeightedSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	now := time.Now()
+	available, err := getAvailableAuths(auths, provider, model, now)
+	if err != nil {
+		return nil, err
+	}
+	tier := highestPriorityTier(available)
+	if len(tier) == 1 {
+		return tier[0], nil
+	}
+	key := provider + ":" + model
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		s.current = make(map[string]map[string]int)
+	}
+	prev := s.current[key]
+	next := make(map[string]int, len(tier))
+	total := 0
+	var selected *Auth
+	for _, candidate := range tier {
+		weight := authWeight(candidate)
+		total += weight
+		next[candidate.ID] = prev[candidate.ID] + weight
+		if selected == nil || next[candidate.ID] > next[selected.ID] {
+			selected = candidate
+		}
+	}
+	next[selected.ID] -= total
+	s.current[key] = next
+	return selected, nil
+}
+
+// highestPriorityTier returns the subset of auths sharing the highest priority value.
+func highestPriorityTier(auths []*Auth) []*Auth {
+	if len(auths) <= 1 {
+		return auths
+	}
+	best := authPriority(auths[0])
+	for _, candidate := range auths[1:] {
+		if p := authPriority(candidate); p > best {
+			best = p
+		}
+	}
+	tier := make([]*Auth, 0, len(auths))
+	for _, candidate := range auths {
+		if authPriority(candidate) == best {
+			tier = append(tier, candidate)
+		}
+	}
+	return tier
+}
+
+// authPriority reads the "priority" attribute; missing or invalid values default to 0.
+func authPriority(auth *Auth) int {
+	if auth == nil || auth.Attributes == nil {
+		return 0
+	}
+	raw := strings.TrimSpace(auth.Attributes["priority"])
+	if raw == "" {
+		return 0
+	}
+	priority, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0
+	}
+	return priority
+}
+
+// authWeight reads the "weight" attribute; missing or non-positive values default to 1.
+func authWeight(auth *Auth) int {
+	if auth == nil || auth.Attributes == nil {
+		return 1
+	}
+	raw := strings.TrimSpace(auth.Attributes["weight"])
+	if raw == "" {
+		return 1
+	}
+	weight, err := strconv.Atoi(raw)
+	if err != nil || weight <= 0 {
+		return 1
+	}
+	return weight
+}
+
 func isAuthBlockedForModel(auth *Auth, model string, now time.Time) (bool, blockReason, time.Time) {
 	if auth == nil {
 		return true, blockReasonOther, time.Time{}
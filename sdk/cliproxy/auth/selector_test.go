@@ -5,6 +5,7 @@ import (
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 )
@@ -111,3 +112,70 @@ func TestRoundRobinSelectorPick_Concurrent(t *testing.T) {
 	default:
 	}
 }
+
+func TestWeightedSelectorPick_PrefersHighestPriorityTier(t *testing.T) {
+	t.Parallel()
+
+	selector := &WeightedSelector{}
+	auths := []*Auth{
+		{ID: "emergency", Attributes: map[string]string{"priority": "-1"}},
+		{ID: "paid", Attributes: map[string]string{"priority": "10"}},
+		{ID: "cheap", Attributes: map[string]string{"priority": "10"}},
+	}
+
+	for i := 0; i < 4; i++ {
+		got, err := selector.Pick(context.Background(), "claude", "claude-sonnet-4", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		if got.ID == "emergency" {
+			t.Fatalf("Pick() #%d selected lower tier while higher tier available", i)
+		}
+	}
+}
+
+func TestWeightedSelectorPick_FallsBackWhenTierCoolingDown(t *testing.T) {
+	t.Parallel()
+
+	model := "claude-sonnet-4"
+	selector := &WeightedSelector{}
+	cooling := &ModelState{
+		Unavailable:    true,
+		NextRetryAfter: time.Now().Add(time.Minute),
+		Quota:          QuotaState{Exceeded: true},
+	}
+	auths := []*Auth{
+		{ID: "paid", Attributes: map[string]string{"priority": "10"}, ModelStates: map[string]*ModelState{model: cooling}},
+		{ID: "emergency", Attributes: map[string]string{"priority": "-1"}},
+	}
+
+	got, err := selector.Pick(context.Background(), "claude", model, cliproxyexecutor.Options{}, auths)
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "emergency" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "emergency")
+	}
+}
+
+func TestWeightedSelectorPick_SmoothWeightedRoundRobin(t *testing.T) {
+	t.Parallel()
+
+	selector := &WeightedSelector{}
+	auths := []*Auth{
+		{ID: "a", Attributes: map[string]string{"weight": "5"}},
+		{ID: "b", Attributes: map[string]string{"weight": "1"}},
+		{ID: "c", Attributes: map[string]string{"weight": "1"}},
+	}
+
+	want := []string{"a", "a", "b", "a", "c", "a", "a"}
+	for i, id := range want {
+		got, err := selector.Pick(context.Background(), "gemini", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		if got.ID != id {
+			t.Fatalf("Pick() #%d auth.ID = %q, want %q", i, got.ID, id)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RuntimeState captures the availability state of an auth (cooldowns, quota backoff and
+// per-model status). It is persisted separately from the credential payload so auth files
+// are not rewritten on every request.
+type RuntimeState struct {
+	// Status is the aggregated auth status.
+	Status Status `json:"status,omitempty"`
+	// StatusMessage mirrors Auth.StatusMessage.
+	StatusMessage string `json:"status_message,omitempty"`
+	// Unavailable mirrors Auth.Unavailable.
+	Unavailable bool `json:"unavailable,omitempty"`
+	// NextRetryAfter mirrors Auth.NextRetryAfter.
+	NextRetryAfter time.Time `json:"next_retry_after"`
+	// Quota mirrors Auth.Quota.
+	Quota QuotaState `json:"quota"`
+	// LastError mirrors Auth.LastError.
+	LastError *Error `json:"last_error,omitempty"`
+	// ModelStates holds the per-model states that still block or throttle the auth.
+	ModelStates map[string]*ModelState `json:"model_states,omitempty"`
+	// UpdatedAt records when the state was captured.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StateStore is implemented by stores that can persist auth runtime state. Stores that do not
+// implement it simply lose cooldowns across restarts.
+type StateStore interface {
+	// LoadStates returns every persisted runtime state keyed by auth ID.
+	LoadStates(ctx context.Context) (map[string]*RuntimeState, error)
+	// SaveState persists the runtime state for the auth; a nil state removes it.
+	SaveState(ctx context.Context, id string, state *RuntimeState) error
+}
+
+// StateFlusher is implemented by state stores that defer part of their writes, such as pushes
+// to a remote. FlushStates completes the deferred writes.
+type StateFlusher interface {
+	FlushStates(ctx context.Context) error
+}
+
+// snapshotRuntimeState extracts the state worth persisting. Healthy models are omitted, and
+// nil is returned when nothing would need restoring.
+func snapshotRuntimeState(auth *Auth) *RuntimeState {
+	if auth == nil {
+		return nil
+	}
+	state := &RuntimeState{}
+	for model, ms := range auth.ModelStates {
+		if ms == nil {
+			continue
+		}
+		circuitTripped := ms.Circuit.State == CircuitOpen || ms.Circuit.State == CircuitHalfOpen
+		if !ms.Unavailable && ms.Status != StatusDisabled && !ms.Quota.Exceeded && !circuitTripped {
+			continue
+		}
+		if state.ModelStates == nil {
+			state.ModelStates = make(map[string]*ModelState)
+		}
+		state.ModelStates[model] = ms.Clone()
+	}
+	if auth.Unavailable || auth.Quota.Exceeded || len(state.ModelStates) > 0 {
+		state.Status = auth.Status
+		state.StatusMessage = auth.StatusMessage
+		state.Unavailable = auth.Unavailable
+		state.NextRetryAfter = auth.NextRetryAfter
+		state.Quota = auth.Quota
+		state.LastError = cloneError(auth.LastError)
+	}
+	if len(state.ModelStates) == 0 && !state.Unavailable && !state.Quota.Exceeded {
+		return nil
+	}
+	return state
+}
+
+// applyRuntimeState restores persisted state onto an auth. Cooldowns that already expired are
+// cleared by the aggregation pass; disabled auths keep their own status.
+func applyRuntimeState(auth *Auth, state *RuntimeState, now time.Time) {
+	if auth == nil || state == nil {
+		return
+	}
+	if len(state.ModelStates) > 0 {
+		if auth.ModelStates == nil {
+			auth.ModelStates = make(map[string]*ModelState, len(state.ModelStates))
+		}
+		for model, ms := range state.ModelStates {
+			if ms == nil {
+				continue
+			}
+			auth.ModelStates[model] = ms.Clone()
+		}
+	}
+	if !auth.Disabled && auth.Status != StatusDisabled {
+		if state.Unavailable && state.NextRetryAfter.After(now) {
+			auth.Unavailable = true
+			auth.NextRetryAfter = state.NextRetryAfter
+			auth.Quota = state.Quota
+		}
+		if state.Status != "" && state.Status != StatusDisabled {
+			auth.Status = state.Status
+			auth.StatusMessage = state.StatusMessage
+			auth.LastError = cloneError(state.LastError)
+		}
+	}
+	updateAggregatedAvailability(auth, now)
+	if auth.Status == StatusError && !auth.Unavailable && !hasModelError(auth, now) {
+		auth.Status = StatusActive
+		auth.StatusMessage = ""
+		auth.LastError = nil
+	}
+}
+
+// inheritRuntimeState carries runtime state from the current in-memory auth onto a freshly
+// synthesized replacement (for example after a config reload) that has none of its own.
+func inheritRuntimeState(next, current *Auth, now time.Time) {
+	if next == nil || current == nil || len(next.ModelStates) > 0 || next.Unavailable {
+		return
+	}
+	applyRuntimeState(next, snapshotRuntimeState(current), now)
+}
+
+// stateFingerprint returns a comparable encoding of the state; nil yields an empty string.
+func stateFingerprint(state *RuntimeState) string {
+	if state == nil {
+		return ""
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+// prepareStateSave decides whether the auth's runtime state changed since it was last saved.
+// Callers must hold m.mu and invoke the returned function after releasing it. The function
+// only queues the snapshot; the background state writer persists it.
+func (m *Manager) prepareStateSave(ctx context.Context, auth *Auth) func() {
+	stateStore, ok := m.store.(StateStore)
+	if !ok || auth == nil || auth.ID == "" {
+		return nil
+	}
+	state := snapshotRuntimeState(auth)
+	if state != nil {
+		state.UpdatedAt = time.Now()
+	}
+	fingerprint := stateFingerprint(stripStateTimestamp(state))
+	if m.savedStates == nil {
+		m.savedStates = make(map[string]string)
+	}
+	previous, known := m.savedStates[auth.ID]
+	if known && previous == fingerprint {
+		return nil
+	}
+	if !known && fingerprint == "" {
+		return nil
+	}
+	if fingerprint == "" {
+		delete(m.savedStates, auth.ID)
+	} else {
+		m.savedStates[auth.ID] = fingerprint
+	}
+	if m.stateWriter == nil {
+		m.stateWriter = newStateWriter()
+	}
+	// The sequence is taken under m.mu so the writer can drop updates that are queued late.
+	m.stateSeq++
+	queued := queuedState{seq: m.stateSeq, state: state, ctx: context.Background()}
+	if ctx != nil {
+		// The request context may be cancelled as soon as the response is written.
+		queued.ctx = context.WithoutCancel(ctx)
+	}
+	writer, id := m.stateWriter, auth.ID
+	return func() {
+		writer.enqueue(stateStore, id, queued)
+	}
+}
+
+// FlushRuntimeStates waits until queued runtime state changes reach the store.
+func (m *Manager) FlushRuntimeStates(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	writer := m.stateWriter
+	m.mu.RUnlock()
+	if writer == nil {
+		return nil
+	}
+	return writer.flush(ctx)
+}
+
+// FlushStateStore completes the writes the store deferred. Call it after FlushRuntimeStates so
+// the queued changes are included.
+func (m *Manager) FlushStateStore(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	flusher, ok := m.store.(StateFlusher)
+	m.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return flusher.FlushStates(ctx)
+}
+
+// stateWriter persists runtime state in the background. Changes queued while a write is in
+// flight coalesce, so a burst of transitions results in one write per auth.
+type stateWriter struct {
+	mu      sync.Mutex
+	pending map[string]queuedState
+	// latest records the newest sequence queued per auth.
+	latest map[string]uint64
+	// done is closed when the running write loop exits; nil while idle.
+	done chan struct{}
+}
+
+type queuedState struct {
+	seq   uint64
+	ctx   context.Context
+	state *RuntimeState
+}
+
+func newStateWriter() *stateWriter {
+	return &stateWriter{
+		pending: make(map[string]queuedState),
+		latest:  make(map[string]uint64),
+	}
+}
+
+func (w *stateWriter) enqueue(store StateStore, id string, queued queuedState) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if queued.seq <= w.latest[id] {
+		return
+	}
+	w.latest[id] = queued.seq
+	w.pending[id] = queued
+	if w.done == nil {
+		w.done = make(chan struct{})
+		go w.run(store, w.done)
+	}
+}
+
+func (w *stateWriter) run(store StateStore, done chan struct{}) {
+	defer close(done)
+	w.mu.Lock()
+	for len(w.pending) > 0 {
+		batch := w.pending
+		w.pending = make(map[string]queuedState)
+		w.mu.Unlock()
+		for id, queued := range batch {
+			if err := store.SaveState(queued.ctx, id, queued.state); err != nil {
+				log.Warnf("failed to persist runtime state for auth %s: %v", id, err)
+			}
+		}
+		w.mu.Lock()
+	}
+	w.done = nil
+	w.mu.Unlock()
+}
+
+func (w *stateWriter) flush(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for {
+		w.mu.Lock()
+		done := w.done
+		w.mu.Unlock()
+		if done == nil {
+			return nil
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func stripStateTimestamp(state *RuntimeState) *RuntimeState {
+	if state == nil {
+		return nil
+	}
+	copyState := *state
+	copyState.UpdatedAt = time.Time{}
+	return &copyState
+}
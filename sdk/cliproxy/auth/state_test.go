@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryStateStore struct {
+	mu     sync.Mutex
+	auths  []*Auth
+	states map[string]*RuntimeState
+	saves  int
+	// pushed counts the saves completed by FlushStates.
+	pushed int
+	// block, when set, holds every SaveState until it is closed.
+	block chan struct{}
+}
+
+func (s *memoryStateStore) List(context.Context) ([]*Auth, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*Auth, 0, len(s.auths))
+	for _, auth := range s.auths {
+		out = append(out, auth.Clone())
+	}
+	return out, nil
+}
+
+func (s *memoryStateStore) Save(context.Context, *Auth) (string, error) { return "", nil }
+
+func (s *memoryStateStore) Delete(context.Context, string) error { return nil }
+
+func (s *memoryStateStore) LoadStates(context.Context) (map[string]*RuntimeState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]*RuntimeState, len(s.states))
+	for id, state := range s.states {
+		out[id] = state
+	}
+	return out, nil
+}
+
+func (s *memoryStateStore) SaveState(_ context.Context, id string, state *RuntimeState) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saves++
+	if state == nil {
+		delete(s.states, id)
+		return nil
+	}
+	s.states[id] = state
+	return nil
+}
+
+func (s *memoryStateStore) FlushStates(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pushed = s.saves
+	return nil
+}
+
+func flushStates(t *testing.T, m *Manager) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.FlushRuntimeStates(ctx); err != nil {
+		t.Fatalf("FlushRuntimeStates() error = %v", err)
+	}
+}
+
+func TestManagerRuntimeState_SurvivesRestart(t *testing.T) {
+	store := &memoryStateStore{
+		auths: []*Auth{
+			{ID: "state-file-auth", Provider: "claude", Status: StatusActive, Metadata: map[string]any{"type": "claude"}},
+		},
+		states: make(map[string]*RuntimeState),
+	}
+	first := NewManager(store, nil, nil)
+	if err := first.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if _, err := first.Register(context.Background(), &Auth{ID: "state-api-key", Provider: "claude", Status: StatusActive}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	first.MarkResult(context.Background(), Result{AuthID: "state-file-auth", Provider: "claude", Model: "state-model", Success: true})
+	flushStates(t, first)
+	if store.saves != 0 {
+		t.Fatalf("healthy result persisted state (%d saves)", store.saves)
+	}
+	for _, id := range []string{"state-file-auth", "state-api-key"} {
+		first.MarkResult(context.Background(), Result{
+			AuthID:   id,
+			Provider: "claude",
+			Model:    "state-model",
+			Error:    &Error{Message: "quota", HTTPStatus: http.StatusTooManyRequests},
+		})
+	}
+	flushStates(t, first)
+	if len(store.states) != 2 {
+		t.Fatalf("persisted states = %d, want 2", len(store.states))
+	}
+
+	second := NewManager(store, nil, nil)
+	if err := second.Load(context.Background()); err != nil {
+		t.Fatalf("Load() after restart error = %v", err)
+	}
+	if _, err := second.Register(context.Background(), &Auth{ID: "state-api-key", Provider: "claude", Status: StatusActive}); err != nil {
+		t.Fatalf("Register() after restart error = %v", err)
+	}
+	now := time.Now()
+	for _, id := range []string{"state-file-auth", "state-api-key"} {
+		auth, ok := second.GetByID(id)
+		if !ok {
+			t.Fatalf("auth %s missing after restart", id)
+		}
+		if blocked, reason, _ := isAuthBlockedForModel(auth, "state-model", now); !blocked || reason != blockReasonCooldown {
+			t.Fatalf("auth %s blocked=%v reason=%v after restart, want cooldown", id, blocked, reason)
+		}
+	}
+
+	// A reload replaces the auth with a fresh copy; the cooldown must carry over.
+	if _, err := second.Update(context.Background(), &Auth{ID: "state-api-key", Provider: "claude", Status: StatusActive}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	auth, _ := second.GetByID("state-api-key")
+	if blocked, _, _ := isAuthBlockedForModel(auth, "state-model", now); !blocked {
+		t.Fatalf("cooldown lost after Update")
+	}
+
+	// Recovery clears the persisted state.
+	second.MarkResult(context.Background(), Result{AuthID: "state-api-key", Provider: "claude", Model: "state-model", Success: true})
+	flushStates(t, second)
+	if _, ok := store.states["state-api-key"]; ok {
+		t.Fatalf("runtime state not removed after recovery")
+	}
+}
+
+func TestManagerRuntimeState_PrunesExpired(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	store := &memoryStateStore{
+		auths: []*Auth{{ID: "expired-auth", Provider: "claude", Status: StatusActive}},
+		states: map[string]*RuntimeState{
+			"expired-auth": {
+				Status:      StatusError,
+				ModelStates: map[string]*ModelState{"m": {Status: StatusError, Unavailable: true, NextRetryAfter: past}},
+			},
+		},
+	}
+	manager := NewManager(store, nil, nil)
+	if err := manager.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	auth, _ := manager.GetByID("expired-auth")
+	if auth.Status != StatusActive {
+		t.Fatalf("status = %q, want %q", auth.Status, StatusActive)
+	}
+	if len(store.states) != 0 {
+		t.Fatalf("expired state not pruned: %v", store.states)
+	}
+}
+
+func TestManagerRuntimeState_SavesInBackground(t *testing.T) {
+	store := &memoryStateStore{states: make(map[string]*RuntimeState), block: make(chan struct{})}
+	manager := NewManager(store, nil, nil)
+	if _, err := manager.Register(context.Background(), &Auth{ID: "state-slow", Provider: "claude", Status: StatusActive}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	marked := make(chan struct{})
+	go func() {
+		defer close(marked)
+		quota := &Error{Message: "quota", HTTPStatus: http.StatusTooManyRequests}
+		for _, model := range []string{"state-a", "state-b", "state-c"} {
+			manager.MarkResult(context.Background(), Result{AuthID: "state-slow", Provider: "claude", Model: model, Error: quota})
+		}
+	}()
+	select {
+	case <-marked:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("MarkResult blocked on a slow state store")
+	}
+	close(store.block)
+	flushStates(t, manager)
+	if err := manager.FlushStateStore(context.Background()); err != nil {
+		t.Fatalf("FlushStateStore() error = %v", err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.saves > 2 {
+		t.Fatalf("saves = %d, want queued changes to coalesce", store.saves)
+	}
+	if state := store.states["state-slow"]; state == nil || len(state.ModelStates) != 3 {
+		t.Fatalf("persisted state = %+v, want the latest snapshot", state)
+	}
+	if store.pushed != store.saves {
+		t.Fatalf("pushed %d of %d saves, want the store flushed after the queue", store.pushed, store.saves)
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// attemptTrace numbers the credential attempts of one request and remembers why the previous
+// attempt failed, so every attempt span carries its attempt number and retry reason.
+type attemptTrace struct {
+	mu     sync.Mutex
+	count  int
+	reason string
+}
+
+type attemptTraceKey struct{}
+
+type attemptNumberKey struct{}
+
+// AttemptFromContext returns the 1-based number of the credential attempt executing with ctx,
+// or 0 outside an attempt.
+func AttemptFromContext(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	number, _ := ctx.Value(attemptNumberKey{}).(int)
+	return number
+}
+
+// withAttemptTrace attaches attempt numbering to ctx unless it is already present, so retries
+// issued by the handlers continue the numbering of the same request.
+func withAttemptTrace(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Value(attemptTraceKey{}).(*attemptTrace); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, attemptTraceKey{}, &attemptTrace{})
+}
+
+// startAttemptSpan starts the span of one credential attempt.
+func startAttemptSpan(ctx context.Context, provider string, auth *Auth, model string) (context.Context, trace.Span) {
+	number, reason := 1, ""
+	if at, ok := ctx.Value(attemptTraceKey{}).(*attemptTrace); ok {
+		at.mu.Lock()
+		at.count++
+		number, reason = at.count, at.reason
+		at.mu.Unlock()
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("cliproxy.provider", provider),
+		attribute.String("cliproxy.model", model),
+		attribute.Int("cliproxy.attempt", number),
+	}
+	if auth != nil {
+		attrs = append(attrs, attribute.String("cliproxy.auth_index", auth.EnsureIndex()))
+	}
+	if reason != "" {
+		attrs = append(attrs, attribute.String("cliproxy.retry_reason", reason))
+	}
+	ctx = context.WithValue(ctx, attemptNumberKey{}, number)
+	return tracing.Tracer().Start(ctx, "attempt "+provider, trace.WithAttributes(attrs...))
+}
+
+// endAttemptSpan finishes an attempt span. A failure becomes the retry reason reported by the
+// next attempt of the request.
+func endAttemptSpan(ctx context.Context, span trace.Span, result Result) {
+	if !result.Success {
+		reason := "error"
+		if result.Error != nil && result.Error.HTTPStatus > 0 {
+			reason = "http_" + strconv.Itoa(result.Error.HTTPStatus)
+		}
+		message := reason
+		if result.Error != nil && result.Error.Message != "" {
+			message = result.Error.Message
+		}
+		span.SetStatus(codes.Error, message)
+		if at, ok := ctx.Value(attemptTraceKey{}).(*attemptTrace); ok {
+			at.mu.Lock()
+			at.reason = reason
+			at.mu.Unlock()
+		}
+	}
+	span.End()
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAttemptSpans_NumberAttemptsAndReportRetryReason(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	ctx := withAttemptTrace(context.Background())
+	ctx = withAttemptTrace(ctx)
+	auth := &Auth{ID: "a", Provider: "gemini"}
+
+	firstCtx, first := startAttemptSpan(ctx, "gemini", auth, "gemini-2.5-pro")
+	endAttemptSpan(firstCtx, first, Result{Error: &Error{HTTPStatus: 429, Message: "quota"}})
+	secondCtx, second := startAttemptSpan(ctx, "gemini", auth, "gemini-2.5-pro")
+	endAttemptSpan(secondCtx, second, Result{Success: true})
+	if got := AttemptFromContext(secondCtx); got != 2 {
+		t.Fatalf("AttemptFromContext = %d, want 2", got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected two spans, got %d", len(spans))
+	}
+	attrs := func(i int) map[attribute.Key]attribute.Value {
+		out := make(map[attribute.Key]attribute.Value)
+		for _, kv := range spans[i].Attributes() {
+			out[kv.Key] = kv.Value
+		}
+		return out
+	}
+	if got := attrs(0)["cliproxy.attempt"].AsInt64(); got != 1 {
+		t.Fatalf("first attempt number = %d", got)
+	}
+	if _, ok := attrs(0)["cliproxy.retry_reason"]; ok {
+		t.Fatalf("first attempt must not carry a retry reason")
+	}
+	retried := attrs(1)
+	if retried["cliproxy.attempt"].AsInt64() != 2 || retried["cliproxy.retry_reason"].AsString() != "http_429" {
+		t.Fatalf("unexpected second attempt attributes %v", retried)
+	}
+}
@@ -77,6 +77,36 @@ type QuotaState struct {
 	BackoffLevel int `json:"backoff_level,omitempty"`
 }
 
+// CircuitStatus enumerates circuit breaker positions.
+type CircuitStatus string
+
+const (
+	// CircuitClosed lets requests through normally.
+	CircuitClosed CircuitStatus = "closed"
+	// CircuitOpen rejects requests until the open delay elapses.
+	CircuitOpen CircuitStatus = "open"
+	// CircuitHalfOpen lets exactly one probe request through.
+	CircuitHalfOpen CircuitStatus = "half_open"
+)
+
+// CircuitState contains circuit breaker bookkeeping for an auth and model pair.
+type CircuitState struct {
+	// State is the current breaker position; empty means closed.
+	State CircuitStatus `json:"state,omitempty"`
+	// ConsecutiveFailures counts transient failures since the last success.
+	ConsecutiveFailures int `json:"consecutive_failures,omitempty"`
+	// WindowStart marks the beginning of the current error-rate window.
+	WindowStart time.Time `json:"window_start,omitzero"`
+	// WindowRequests counts requests observed in the current window.
+	WindowRequests int `json:"window_requests,omitempty"`
+	// WindowFailures counts transient failures observed in the current window.
+	WindowFailures int `json:"window_failures,omitempty"`
+	// OpenedAt records when the breaker last tripped.
+	OpenedAt time.Time `json:"opened_at,omitzero"`
+	// ProbeStartedAt records when the current half-open probe was dispatched.
+	ProbeStartedAt time.Time `json:"probe_started_at,omitzero"`
+}
+
 // ModelState captures the execution state for a specific model under an auth entry.
 type ModelState struct {
 	// Status reflects the lifecycle status for this model.
@@ -91,6 +121,8 @@ type ModelState struct {
 	LastError *Error `json:"last_error,omitempty"`
 	// Quota retains quota information if this model hit rate limits.
 	Quota QuotaState `json:"quota"`
+	// Circuit tracks circuit breaker state for transient upstream failures.
+	Circuit CircuitState `json:"circuit,omitzero"`
 	// UpdatedAt tracks the last update timestamp for this model state.
 	UpdatedAt time.Time `json:"updated_at"`
 }
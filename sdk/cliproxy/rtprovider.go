@@ -8,6 +8,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/proxy"
@@ -24,8 +25,18 @@ func newDefaultRoundTripperProvider() *defaultRoundTripperProvider {
 	return &defaultRoundTripperProvider{cache: make(map[string]http.RoundTripper)}
 }
 
-// RoundTripperFor implements coreauth.RoundTripperProvider.
+// RoundTripperFor implements coreauth.RoundTripperProvider. When tracing is enabled the
+// returned transport records upstream calls as client spans.
 func (p *defaultRoundTripperProvider) RoundTripperFor(auth *coreauth.Auth) http.RoundTripper {
+	rt := p.proxyRoundTripper(auth)
+	if auth != nil && tracing.Enabled() {
+		return tracing.WrapTransport(rt)
+	}
+	return rt
+}
+
+// proxyRoundTripper returns the cached transport for the auth's proxy URL, if any.
+func (p *defaultRoundTripperProvider) proxyRoundTripper(auth *coreauth.Auth) http.RoundTripper {
 	if auth == nil {
 		return nil
 	}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -317,6 +318,83 @@ func (s *Service) applyRetryConfig(cfg *config.Config) {
 	s.coreManager.SetRetryConfig(cfg.RequestRetry, maxInterval)
 }
 
+func (s *Service) applyModelFallbacks(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	s.coreManager.SetModelFallbacks(cfg.ModelFallbacks)
+}
+
+func (s *Service) applySessionAffinity(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	affinity := cfg.Routing.SessionAffinity
+	s.coreManager.SetSessionAffinity(affinity.Enabled, time.Duration(affinity.TTLSeconds)*time.Second)
+}
+
+func (s *Service) applyConcurrencyQueue(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	s.coreManager.SetConcurrencyQueueTimeout(time.Duration(cfg.Routing.QueueTimeoutSeconds) * time.Second)
+}
+
+func (s *Service) applyCircuitBreaker(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	breaker := cfg.CircuitBreaker
+	if !breaker.Enabled {
+		s.coreManager.SetCircuitBreaker(nil)
+		return
+	}
+	s.coreManager.SetCircuitBreaker(&coreauth.CircuitBreakerConfig{
+		FailureThreshold:   breaker.FailureThreshold,
+		ErrorRateThreshold: breaker.ErrorRateThreshold,
+		MinRequests:        breaker.MinRequests,
+		Window:             time.Duration(breaker.WindowSeconds) * time.Second,
+		OpenDuration:       time.Duration(breaker.OpenSeconds) * time.Second,
+	})
+}
+
+func (s *Service) applyHedging(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	hedging := cfg.Hedging
+	if !hedging.Enabled {
+		s.coreManager.SetHedging(nil)
+		return
+	}
+	s.coreManager.SetHedging(&coreauth.HedgingConfig{
+		Models:       hedging.Models,
+		Percentile:   hedging.Percentile,
+		MinSamples:   hedging.MinSamples,
+		MinDelay:     time.Duration(hedging.MinDelayMS) * time.Millisecond,
+		DefaultDelay: time.Duration(hedging.DefaultDelayMS) * time.Millisecond,
+	})
+}
+
+func (s *Service) applyHealthProbes(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	probe := cfg.HealthProbe
+	if !probe.Enabled {
+		s.coreManager.StopHealthProbes()
+		return
+	}
+	s.coreManager.StartHealthProbes(context.Background(), &coreauth.HealthProbeConfig{
+		Interval:    time.Duration(probe.IntervalSeconds) * time.Second,
+		Timeout:     time.Duration(probe.TimeoutSeconds) * time.Second,
+		Method:      strings.ToLower(strings.TrimSpace(probe.Method)),
+		Providers:   probe.Providers,
+		Models:      probe.Models,
+		HistorySize: probe.HistorySize,
+	})
+}
+
 func openAICompatInfoFromAuth(a *coreauth.Auth) (providerKey string, compatName string, ok bool) {
 	if a == nil {
 		return "", "", false
@@ -431,6 +509,11 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 
 	s.applyRetryConfig(s.cfg)
+	s.applyModelFallbacks(s.cfg)
+	s.applySessionAffinity(s.cfg)
+	s.applyConcurrencyQueue(s.cfg)
+	s.applyCircuitBreaker(s.cfg)
+	s.applyHedging(s.cfg)
 
 	if s.coreManager != nil {
 		if errLoad := s.coreManager.Load(ctx); errLoad != nil {
@@ -507,9 +590,11 @@ func (s *Service) Run(ctx context.Context) error {
 	var watcherWrapper *WatcherWrapper
 	reloadCallback := func(newCfg *config.Config) {
 		previousStrategy := ""
+		var previousProbe config.HealthProbeConfig
 		s.cfgMu.RLock()
 		if s.cfg != nil {
 			previousStrategy = strings.ToLower(strings.TrimSpace(s.cfg.Routing.Strategy))
+			previousProbe = s.cfg.HealthProbe
 		}
 		s.cfgMu.RUnlock()
 
@@ -527,6 +612,10 @@ func (s *Service) Run(ctx context.Context) error {
 			switch strategy {
 			case "fill-first", "fillfirst", "ff":
 				return "fill-first"
+			case "weighted", "weight", "priority":
+				return "weighted"
+			case "latency", "least-latency", "least-in-flight", "least-inflight":
+				return "latency"
 			default:
 				return "round-robin"
 			}
@@ -538,6 +627,10 @@ func (s *Service) Run(ctx context.Context) error {
 			switch nextStrategy {
 			case "fill-first":
 				selector = &coreauth.FillFirstSelector{}
+			case "weighted":
+				selector = &coreauth.WeightedSelector{}
+			case "latency":
+				selector = &coreauth.LatencySelector{}
 			default:
 				selector = &coreauth.RoundRobinSelector{}
 			}
@@ -546,6 +639,14 @@ func (s *Service) Run(ctx context.Context) error {
 		}
 
 		s.applyRetryConfig(newCfg)
+		s.applyModelFallbacks(newCfg)
+		s.applySessionAffinity(newCfg)
+		s.applyConcurrencyQueue(newCfg)
+		s.applyCircuitBreaker(newCfg)
+		s.applyHedging(newCfg)
+		if !reflect.DeepEqual(previousProbe, newCfg.HealthProbe) {
+			s.applyHealthProbes(newCfg)
+		}
 		if s.server != nil {
 			s.server.UpdateClients(newCfg)
 		}
@@ -578,6 +679,7 @@ func (s *Service) Run(ctx context.Context) error {
 		interval := 15 * time.Minute
 		s.coreManager.StartAutoRefresh(context.Background(), interval)
 		log.Infof("core auth auto-refresh started (interval=%s)", interval)
+		s.applyHealthProbes(s.cfg)
 	}
 
 	select {
@@ -615,6 +717,13 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 		if s.coreManager != nil {
 			s.coreManager.StopAutoRefresh()
+			s.coreManager.StopHealthProbes()
+			if err := s.coreManager.FlushRuntimeStates(ctx); err != nil {
+				log.Warnf("failed to flush auth runtime state: %v", err)
+			}
+			if err := s.coreManager.FlushStateStore(ctx); err != nil {
+				log.Warnf("failed to push auth runtime state: %v", err)
+			}
 		}
 		if s.watcher != nil {
 			if err := s.watcher.Stop(); err != nil {
@@ -811,6 +920,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 							OwnedBy:     compat.Name,
 							Type:        "openai-compatibility",
 							DisplayName: modelID,
+							Embedding:   m.Embedding,
 						})
 					}
 					// Register and return
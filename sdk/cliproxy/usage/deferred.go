@@ -0,0 +1,95 @@
+package usage
+
+import (
+	"context"
+	"sync"
+)
+
+type deferredContextKey struct{}
+
+type deferredState int
+
+const (
+	deferredPending deferredState = iota
+	deferredCommitted
+	deferredDiscarded
+)
+
+type deferredItem struct {
+	manager *Manager
+	ctx     context.Context
+	record  Record
+}
+
+// Deferred holds the records published under a context until the caller decides whether they
+// count. The auth manager uses it to publish usage only for the winning attempt of a hedged request.
+type Deferred struct {
+	mu     sync.Mutex
+	state  deferredState
+	items  []deferredItem
+	mutate func(*Record)
+}
+
+// WithDeferred returns a context whose published records are held by the returned Deferred.
+func WithDeferred(ctx context.Context) (context.Context, *Deferred) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	d := &Deferred{}
+	return context.WithValue(ctx, deferredContextKey{}, d), d
+}
+
+// Commit publishes the held records, applying mutate to each one first. Records published
+// after Commit are delivered immediately and are mutated the same way.
+func (d *Deferred) Commit(mutate func(*Record)) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	if d.state != deferredPending {
+		d.mu.Unlock()
+		return
+	}
+	d.state = deferredCommitted
+	d.mutate = mutate
+	items := d.items
+	d.items = nil
+	d.mu.Unlock()
+	for _, item := range items {
+		if mutate != nil {
+			mutate(&item.record)
+		}
+		item.manager.enqueue(item.ctx, item.record)
+	}
+}
+
+// Discard drops the held records and every record published afterwards.
+func (d *Deferred) Discard() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	if d.state == deferredPending {
+		d.state = deferredDiscarded
+		d.items = nil
+	}
+	d.mu.Unlock()
+}
+
+// admit reports whether the record should be delivered now. Pending records are held.
+func (d *Deferred) admit(m *Manager, ctx context.Context, record *Record) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch d.state {
+	case deferredCommitted:
+		if d.mutate != nil {
+			d.mutate(record)
+		}
+		return true
+	case deferredDiscarded:
+		return false
+	default:
+		d.items = append(d.items, deferredItem{manager: m, ctx: ctx, record: *record})
+		return false
+	}
+}
@@ -10,15 +10,39 @@ import (
 
 // Record contains the usage statistics captured for a single provider request.
 type Record struct {
-	Provider    string
-	Model       string
-	APIKey      string
-	AuthID      string
-	AuthIndex   string
-	Source      string
-	RequestedAt time.Time
-	Failed      bool
-	Detail      Detail
+	Provider  string
+	Model     string
+	APIKey    string
+	AuthID    string
+	AuthIndex string
+	// AuthType is the kind of credential that served the request: "oauth" or "api_key".
+	AuthType        string
+	Source          string
+	RequestedAt     time.Time
+	Failed          bool
+	SessionAffinity string
+	Hedged          bool
+	Detail          Detail
+
+	// RequestID is the proxy request ID shared with the request logs.
+	RequestID string
+	// Latency is the time from sending the upstream request until the record was published,
+	// which for streams is normally the final usage event.
+	Latency time.Duration
+	// TimeToFirstToken is the time until the first upstream stream event; zero when not streaming.
+	TimeToFirstToken time.Duration
+	// StatusCode is the upstream HTTP status, when known.
+	StatusCode int
+	// ErrorClass classifies failures: rate_limit, auth, client, upstream, timeout, canceled,
+	// stream (a read error after the stream started) or network.
+	ErrorClass string
+	// Stream reports whether the request was streamed.
+	Stream bool
+	// Attempt is the 1-based credential attempt that produced the record.
+	Attempt int
+	// SourceFormat and TargetFormat are the client and upstream request schemas.
+	SourceFormat string
+	TargetFormat string
 }
 
 // Detail holds the token usage breakdown.
@@ -27,7 +51,9 @@ type Detail struct {
 	OutputTokens    int64
 	ReasoningTokens int64
 	CachedTokens    int64
-	TotalTokens     int64
+	// CacheWriteTokens counts input tokens written to the prompt cache, where reported.
+	CacheWriteTokens int64
+	TotalTokens      int64
 }
 
 // Plugin consumes usage records emitted by the proxy runtime.
@@ -109,6 +135,15 @@ func (m *Manager) Publish(ctx context.Context, record Record) {
 	if m == nil {
 		return
 	}
+	if ctx != nil {
+		if d, ok := ctx.Value(deferredContextKey{}).(*Deferred); ok && d != nil && !d.admit(m, ctx, &record) {
+			return
+		}
+	}
+	m.enqueue(ctx, record)
+}
+
+func (m *Manager) enqueue(ctx context.Context, record Record) {
 	// ensure worker is running even if Start was not called explicitly
 	m.Start(context.Background())
 	m.mu.Lock()
@@ -19,6 +19,12 @@ type AmpCode = internalconfig.AmpCode
 type PayloadConfig = internalconfig.PayloadConfig
 type PayloadRule = internalconfig.PayloadRule
 type PayloadModelRule = internalconfig.PayloadModelRule
+type HealthProbeConfig = internalconfig.HealthProbeConfig
+type ClientAPIKey = internalconfig.ClientAPIKey
+type ClientRateLimit = internalconfig.ClientRateLimit
+type ClientBudget = internalconfig.ClientBudget
+type JWTAuthConfig = internalconfig.JWTAuthConfig
+type JWTClaimPolicy = internalconfig.JWTClaimPolicy
 
 type GeminiKey = internalconfig.GeminiKey
 type CodexKey = internalconfig.CodexKey
@@ -33,6 +39,7 @@ type TLS = internalconfig.TLSConfig
 
 const (
 	AccessProviderTypeConfigAPIKey = internalconfig.AccessProviderTypeConfigAPIKey
+	AccessProviderTypeJWT          = internalconfig.AccessProviderTypeJWT
 	DefaultAccessProviderName      = internalconfig.DefaultAccessProviderName
 	DefaultPanelGitHubRepository   = internalconfig.DefaultPanelGitHubRepository
 )
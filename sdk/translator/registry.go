@@ -3,8 +3,62 @@ package translator
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+
+	"github.com/tidwall/gjson"
 )
 
+// ErrorObserver is notified when a registered transform fails: it panics, or a request,
+// non-stream or token-count transform turns a payload into malformed JSON. Stage is "request",
+// "stream", "non-stream" or "token-count". A panic is re-raised once the observer returns.
+type ErrorObserver func(from, to Format, stage string)
+
+var errorObserver atomic.Pointer[ErrorObserver]
+
+// SetErrorObserver installs the observer notified about failing transforms; nil removes it.
+func SetErrorObserver(fn ErrorObserver) {
+	if fn == nil {
+		errorObserver.Store(nil)
+		return
+	}
+	errorObserver.Store(&fn)
+}
+
+// observeFailure reports a panicking transform to the error observer and re-raises the panic.
+// It must be deferred directly by the translating method.
+func observeFailure(from, to Format, stage string) {
+	rec := recover()
+	if rec == nil {
+		return
+	}
+	if fn := errorObserver.Load(); fn != nil {
+		(*fn)(from, to, stage)
+	}
+	panic(rec)
+}
+
+// observeOutput reports a transform that produced malformed JSON from a non-empty payload.
+func observeOutput[T []byte | string](from, to Format, stage string, in []byte, out T) T {
+	if len(in) == 0 {
+		return out
+	}
+	fn := errorObserver.Load()
+	if fn == nil {
+		return out
+	}
+	var valid bool
+	switch v := any(out).(type) {
+	case []byte:
+		valid = gjson.ValidBytes(v)
+	case string:
+		valid = gjson.Valid(v)
+	}
+	if !valid {
+		(*fn)(from, to, stage)
+	}
+	return out
+}
+
 // Registry manages translation functions across schemas.
 type Registry struct {
 	mu        sync.RWMutex
@@ -46,12 +100,20 @@ func (r *Registry) TranslateRequest(from, to Format, model string, rawJSON []byt
 
 	if byTarget, ok := r.requests[from]; ok {
 		if fn, isOk := byTarget[to]; isOk && fn != nil {
-			return fn(model, rawJSON, stream)
+			defer observeFailure(from, to, "request")
+			return observeOutput(from, to, "request", rawJSON, fn(model, rawJSON, stream))
 		}
 	}
 	return rawJSON
 }
 
+// TranslateRequestContext is TranslateRequest recorded as a span of the trace in ctx.
+func (r *Registry) TranslateRequestContext(ctx context.Context, from, to Format, model string, rawJSON []byte, stream bool) []byte {
+	_, span := startSpan(ctx, "translate request", from, to)
+	defer span.End()
+	return r.TranslateRequest(from, to, model, rawJSON, stream)
+}
+
 // HasResponseTransformer indicates whether a response translator exists.
 func (r *Registry) HasResponseTransformer(from, to Format) bool {
 	r.mu.RLock()
@@ -72,6 +134,7 @@ func (r *Registry) TranslateStream(ctx context.Context, from, to Format, model s
 
 	if byTarget, ok := r.responses[to]; ok {
 		if fn, isOk := byTarget[from]; isOk && fn.Stream != nil {
+			defer observeFailure(from, to, "stream")
 			return fn.Stream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
 		}
 	}
@@ -80,12 +143,15 @@ func (r *Registry) TranslateStream(ctx context.Context, from, to Format, model s
 
 // TranslateNonStream applies the registered non-stream response translator.
 func (r *Registry) TranslateNonStream(ctx context.Context, from, to Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
+	_, span := startSpan(ctx, "translate response", from, to)
+	defer span.End()
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	if byTarget, ok := r.responses[to]; ok {
 		if fn, isOk := byTarget[from]; isOk && fn.NonStream != nil {
-			return fn.NonStream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+			defer observeFailure(from, to, "non-stream")
+			return observeOutput(from, to, "non-stream", rawJSON, fn.NonStream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param))
 		}
 	}
 	return string(rawJSON)
@@ -98,7 +164,8 @@ func (r *Registry) TranslateTokenCount(ctx context.Context, from, to Format, cou
 
 	if byTarget, ok := r.responses[to]; ok {
 		if fn, isOk := byTarget[from]; isOk && fn.TokenCount != nil {
-			return fn.TokenCount(ctx, count)
+			defer observeFailure(from, to, "token-count")
+			return observeOutput(from, to, "token-count", rawJSON, fn.TokenCount(ctx, count))
 		}
 	}
 	return string(rawJSON)
@@ -121,6 +188,11 @@ func TranslateRequest(from, to Format, model string, rawJSON []byte, stream bool
 	return defaultRegistry.TranslateRequest(from, to, model, rawJSON, stream)
 }
 
+// TranslateRequestContext is a helper on the default registry.
+func TranslateRequestContext(ctx context.Context, from, to Format, model string, rawJSON []byte, stream bool) []byte {
+	return defaultRegistry.TranslateRequestContext(ctx, from, to, model, rawJSON, stream)
+}
+
 // HasResponseTransformer inspects the default registry.
 func HasResponseTransformer(from, to Format) bool {
 	return defaultRegistry.HasResponseTransformer(from, to)
@@ -0,0 +1,24 @@
+package translator
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName matches the instrumentation name used by the rest of the proxy.
+const tracerName = "github.com/router-for-me/CLIProxyAPI/v6"
+
+// startSpan starts a translation span. Streamed chunks are translated too often to be traced
+// individually, so only whole requests and non-stream responses get spans.
+func startSpan(ctx context.Context, name string, from, to Format) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(
+		attribute.String("cliproxy.translate.from", from.String()),
+		attribute.String("cliproxy.translate.to", to.String()),
+	))
+}